@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBotName names the default bot in the chat discovery webhook
+const defaultBotName = "default"
+
+// chatDiscovery is the response of the chat discovery webhook
+type chatDiscovery struct {
+	Bot   string          `json:"bot"`
+	Chats []telegram.Chat `json:"chats"`
+}
+
+// handleDiscoverChats lists the chats the bot received messages from in the last 24 hours with their IDs.
+// The bot query parameter selects a configured bot instead of the default bot
+func (p *Plugin) handleDiscoverChats(c *gin.Context) {
+	if p.config == nil || p.tgclient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "plugin is not configured"})
+		return
+	}
+
+	name := c.DefaultQuery("bot", defaultBotName)
+	token := p.config.Settings.Telegram.DefaultBotToken
+	if name != defaultBotName {
+		bot, ok := p.config.Settings.Telegram.Bots[name]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "bot " + name + " is not configured"})
+			return
+		}
+		token = bot.Token
+	}
+
+	chats, err := p.tgclient.DiscoverChats(token)
+	if err != nil {
+		message := err.Error()
+		if token != "" {
+			// network errors contain the endpoint including the token
+			message = strings.ReplaceAll(message, token, utils.MaskToken(token))
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": message})
+		return
+	}
+
+	c.JSON(http.StatusOK, chatDiscovery{Bot: name, Chats: chats})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlugin_handleDiscoverChats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger}
+
+	router := gin.New()
+	p.RegisterWebhook("/plugin/1/custom/token/", router.Group("/plugin/1/custom/token/"))
+	request := func(target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+		return res
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, request("/plugin/1/custom/token/chats").Code)
+
+	p.config = &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+		DefaultBotToken: "123456789:secret-default-token",
+		Bots:            map[string]config.TelegramBot{"alerts": {Token: "987654321:secret-alerts-token"}},
+	}}}
+	// requests fail at the unreachable proxy
+	p.tgclient = telegram.NewClient(telegram.Config{
+		ErrChan:        make(chan error, 1),
+		Logger:         &logger,
+		RequestTimeout: time.Second,
+		Proxy:          &url.URL{Scheme: "http", Host: "127.0.0.1:1"},
+	})
+
+	res := request("/plugin/1/custom/token/chats?bot=unknown")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.JSONEq(t, `{"error":"bot unknown is not configured"}`, res.Body.String())
+
+	res = request("/plugin/1/custom/token/chats")
+	assert.Equal(t, http.StatusBadGateway, res.Code)
+	assert.NotContains(t, res.Body.String(), "secret-default-token")
+
+	res = request("/plugin/1/custom/token/chats?bot=alerts")
+	assert.Equal(t, http.StatusBadGateway, res.Code)
+	assert.NotContains(t, res.Body.String(), "secret-alerts-token")
+	assert.Contains(t, res.Body.String(), "getUpdates")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+)
+
+// correlationsBucket is the storage bucket mapping correlation keys to the Telegram messages last sent for them
+const correlationsBucket = "correlations"
+
+// correlationRecord is the Telegram message last sent to a chat for a correlation key
+type correlationRecord struct {
+	SentAt    time.Time           `json:"sent_at"`
+	Token     string              `json:"token"`
+	ParseMode string              `json:"parse_mode,omitempty"`
+	Parts     []telegram.SentPart `json:"parts"`
+}
+
+// correlationStorageKey identifies the correlation key in a chat
+func correlationStorageKey(chatID, key string) string {
+	return chatID + ":" + key
+}
+
+// correlatedMessage returns the Telegram message last sent to the chat with the correlation key of the message.
+// It is nil without a correlation key or if no message was sent with the key in the last 24 hours
+func (p *Plugin) correlatedMessage(msg api.Message, chatID string, formatOpts config.MessageFormatOptions) *telegram.SentMessage {
+	if p.store == nil {
+		return nil
+	}
+
+	key := telegram.CorrelationKey(formatOpts.CorrelationKey, msg)
+	if key == "" {
+		return nil
+	}
+
+	var record correlationRecord
+	err := storage.GetJSON(p.store, correlationsBucket, correlationStorageKey(chatID, key), &record)
+	if err != nil || time.Since(record.SentAt) >= deliveryKeyTTL {
+		return nil
+	}
+
+	return &telegram.SentMessage{
+		ChatID:         chatID,
+		Token:          record.Token,
+		ParseMode:      record.ParseMode,
+		Parts:          record.Parts,
+		CorrelationKey: key,
+	}
+}
+
+// recordCorrelation remembers the Telegram messages a gotify message with a correlation key was sent as so
+// that the next message with the key edits them
+func (p *Plugin) recordCorrelation(sent telegram.SentMessage) {
+	if p.store == nil || sent.CorrelationKey == "" {
+		return
+	}
+
+	record := correlationRecord{
+		SentAt:    time.Now(),
+		Token:     sent.Token,
+		ParseMode: sent.ParseMode,
+		Parts:     append([]telegram.SentPart(nil), sent.Parts...),
+	}
+	for i := range record.Parts {
+		record.Parts[i].Text = ""
+	}
+
+	if err := storage.PutJSON(p.store, correlationsBucket, correlationStorageKey(sent.ChatID, sent.CorrelationKey), record); err != nil {
+		p.logger.Error().
+			Err(err).
+			Str("chat_id", sent.ChatID).
+			Msg("failed to save correlated message")
+	}
+}
+
+// pruneCorrelations removes the correlation keys that are older than the TTL from the plugin storage
+func (p *Plugin) pruneCorrelations(now time.Time) error {
+	keys, err := p.store.Keys(correlationsBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		var record correlationRecord
+		if err := storage.GetJSON(p.store, correlationsBucket, key, &record); err == nil && now.Sub(record.SentAt) < deliveryKeyTTL {
+			continue
+		}
+		if err := p.store.Delete(correlationsBucket, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_correlatedMessage(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger, store: storage.NewMemory()}
+	formatOpts := config.MessageFormatOptions{CorrelationKey: "{{ .AppID }}-{{ .Extras.service }}"}
+	msg := api.Message{AppID: 3, Extras: map[string]interface{}{"service": "nginx"}}
+
+	assert.Nil(t, p.correlatedMessage(msg, "123", formatOpts))
+
+	p.recordCorrelation(telegram.SentMessage{
+		ChatID:         "123",
+		Token:          "token",
+		ParseMode:      "HTML",
+		Parts:          []telegram.SentPart{{MessageID: 42, Text: "<b>nginx</b> down"}},
+		CorrelationKey: "3-nginx",
+	})
+
+	assert.Equal(t, &telegram.SentMessage{
+		ChatID:         "123",
+		Token:          "token",
+		ParseMode:      "HTML",
+		Parts:          []telegram.SentPart{{MessageID: 42}},
+		CorrelationKey: "3-nginx",
+	}, p.correlatedMessage(msg, "123", formatOpts))
+
+	assert.Nil(t, p.correlatedMessage(msg, "456", formatOpts), "other chat")
+	assert.Nil(t, p.correlatedMessage(msg, "123", config.MessageFormatOptions{}), "no correlation key")
+	assert.Nil(t, p.correlatedMessage(api.Message{AppID: 3}, "123", formatOpts), "missing extras")
+}
+
+func TestPlugin_pruneCorrelations(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger, store: storage.NewMemory()}
+
+	require.NoError(t, storage.PutJSON(p.store, correlationsBucket, "123:fresh", correlationRecord{SentAt: now.Add(-time.Hour)}))
+	require.NoError(t, storage.PutJSON(p.store, correlationsBucket, "123:expired", correlationRecord{SentAt: now.Add(-deliveryKeyTTL)}))
+	require.NoError(t, p.store.Put(correlationsBucket, "123:broken", []byte("{")))
+
+	require.NoError(t, p.pruneCorrelations(now))
+
+	keys, err := p.store.Keys(correlationsBucket)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"123:fresh"}, keys)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// deadLettersBucket is the storage bucket of the messages that failed to send
+	deadLettersBucket = "dead_letters"
+	// maxDeadLetters is the number of dead letters kept. The oldest are dropped above it
+	maxDeadLetters = 500
+	// maxListedDeadLetters is the number of most recent dead letters listed on the status page
+	maxListedDeadLetters = 20
+)
+
+// deadLetter is a message that failed to send to a chat
+type deadLetter struct {
+	Key      string      `json:"key,omitempty"`
+	Message  api.Message `json:"message"`
+	ChatID   string      `json:"chat_id"`
+	Error    string      `json:"error"`
+	Attempts int         `json:"attempts"`
+	FailedAt time.Time   `json:"failed_at"`
+}
+
+// deadLettersEnabled returns whether messages that failed to send are kept as dead letters
+func (p *Plugin) deadLettersEnabled() bool {
+	return p.deadLetters != nil && p.config != nil && p.config.Settings.Telegram.DeadLetter.Enabled
+}
+
+// addDeadLetter keeps the message of the job that failed to send and drops the oldest dead letters above the limit
+func (p *Plugin) addDeadLetter(job sendJob, sendErr error) {
+	if !p.deadLettersEnabled() {
+		return
+	}
+
+	data, err := json.Marshal(deadLetter{
+		Message:  job.msg,
+		ChatID:   job.chatID,
+		Error:    errorText(sendErr, job.token),
+		Attempts: job.attempts + 1,
+		FailedAt: time.Now(),
+	})
+	if err == nil {
+		_, err = p.deadLetters.Add(data)
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to save dead letter")
+		return
+	}
+
+	length, err := p.deadLetters.Len()
+	for ; err == nil && length > maxDeadLetters; length-- {
+		_, err = p.deadLetters.Pop()
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to trim dead letters")
+	}
+}
+
+// errorText returns the text of a send error with the bot token masked, so that tokens are never persisted with
+// dead letters or shown on the status page
+func errorText(err error, token string) string {
+	if token == "" {
+		return err.Error()
+	}
+	return strings.ReplaceAll(err.Error(), token, "***")
+}
+
+// listDeadLetters returns the dead letters from the oldest to the newest
+func (p *Plugin) listDeadLetters() ([]deadLetter, error) {
+	if p.deadLetters == nil {
+		return nil, nil
+	}
+
+	entries, err := p.deadLetters.Entries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+
+	letters := make([]deadLetter, 0, len(entries))
+	for _, entry := range entries {
+		var letter deadLetter
+		if err := json.Unmarshal(entry.Value, &letter); err != nil {
+			return nil, fmt.Errorf("failed to decode dead letter: %w", err)
+		}
+		letter.Key = entry.Key
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// retryDeadLetters queues the dead letters to be sent again with the current route of their app and returns
+// the number of queued messages. Unless all are retried, dead letters of unhealthy chats and dead letters that
+// reached the maximum number of attempts are kept
+func (p *Plugin) retryDeadLetters(all bool) (int, error) {
+	if p.config == nil {
+		return 0, nil
+	}
+
+	letters, err := p.listDeadLetters()
+	if err != nil {
+		return 0, err
+	}
+
+	maxAttempts := p.config.Settings.Telegram.DeadLetter.MaxAttempts
+	retried := 0
+	for _, letter := range letters {
+		if !all && (p.chatHealth.isUnhealthy(letter.ChatID) || (maxAttempts > 0 && letter.Attempts >= maxAttempts)) {
+			continue
+		}
+		if err := p.deadLetters.Remove(letter.Key); err != nil {
+			return retried, fmt.Errorf("failed to remove dead letter: %w", err)
+		}
+
+		job := p.routeJob(letter.Message, letter.ChatID)
+		job.attempts = letter.Attempts
+		p.enqueue(job)
+		retried++
+	}
+
+	return retried, nil
+}
+
+// flushDeadLetters removes all dead letters and returns the number of removed dead letters
+func (p *Plugin) flushDeadLetters() (int, error) {
+	if p.deadLetters == nil {
+		return 0, nil
+	}
+
+	entries, err := p.deadLetters.Entries()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+
+	for i, entry := range entries {
+		if err := p.deadLetters.Remove(entry.Key); err != nil {
+			return i, fmt.Errorf("failed to remove dead letter: %w", err)
+		}
+	}
+
+	return len(entries), nil
+}
+
+// runDeadLetterRetry periodically retries the dead letters until the context is done
+func (p *Plugin) runDeadLetterRetry(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(p.config.Settings.Telegram.DeadLetter.RetryIntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			retried, err := p.retryDeadLetters(false)
+			if err != nil {
+				p.logger.Error().Err(err).Msg("failed to retry dead letters")
+			} else if retried > 0 {
+				p.logger.Info().Int("count", retried).Msg("retrying dead letters")
+			}
+		}
+	}
+}
+
+// handleGetDeadLetters lists the dead letters from the oldest to the newest
+func (p *Plugin) handleGetDeadLetters(c *gin.Context) {
+	letters, err := p.listDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dead_letters": letters})
+}
+
+// handleRetryDeadLetters sends all dead letters again right away
+func (p *Plugin) handleRetryDeadLetters(c *gin.Context) {
+	if p.config == nil || p.tgclient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "plugin is not configured"})
+		return
+	}
+
+	retried, err := p.retryDeadLetters(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.logger.Info().Int("count", retried).Msg("retrying dead letters")
+	c.JSON(http.StatusAccepted, gin.H{"retried": retried})
+}
+
+// handleFlushDeadLetters removes all dead letters
+func (p *Plugin) handleFlushDeadLetters(c *gin.Context) {
+	flushed, err := p.flushDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.logger.Info().Int("count", flushed).Msg("flushed dead letters")
+	c.JSON(http.StatusOK, gin.H{"flushed": flushed})
+}
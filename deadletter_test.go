@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_deliver_DeadLetter(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	errChan := make(chan error, 10)
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{},
+		// sends fail right away without a bot token
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: errChan, Logger: &logger}),
+	}
+	p.setStore(storage.NewMemory())
+
+	p.deliver(sendJob{msg: api.Message{Id: 1}, chatID: "123"})
+	letters, err := p.listDeadLetters()
+	require.NoError(t, err)
+	assert.Empty(t, letters, "dead letters are disabled by default")
+
+	p.config.Settings.Telegram.DeadLetter.Enabled = true
+	p.deliver(sendJob{msg: api.Message{Id: 2, Title: "disk full"}, chatID: "123", attempts: 2})
+	letters, err = p.listDeadLetters()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "disk full", letters[0].Message.Title)
+	assert.Equal(t, "123", letters[0].ChatID)
+	assert.Equal(t, "telegram bot token is empty", letters[0].Error)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.NotEmpty(t, letters[0].Key)
+	assert.Len(t, errChan, 2, "errors are still reported")
+}
+
+func TestPlugin_deliver_DeadLetterWithoutToken(t *testing.T) {
+	// nothing listens on the proxy, so sends fail with a connection error carrying the request URL
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	proxy, err := url.Parse("http://" + listener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			DeadLetter: config.DeadLetter{Enabled: true},
+		}}},
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: make(chan error, 1), Logger: &logger, Proxy: proxy}),
+	}
+	p.setStore(storage.NewMemory())
+
+	job := sendJob{
+		msg:        api.Message{Id: 1, Title: "disk full"},
+		token:      "123456:secret-token",
+		chatID:     "123",
+		formatOpts: config.MessageFormatOptions{ParseMode: telegram.ParseModeHTML},
+	}
+	require.Error(t, p.deliver(job))
+	letters, err := p.listDeadLetters()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.NotEmpty(t, letters[0].Error)
+	assert.NotContains(t, letters[0].Error, "secret-token")
+}
+
+func TestErrorText(t *testing.T) {
+	err := errors.New("token 123456:secret-token was rejected")
+	assert.Equal(t, "token *** was rejected", errorText(err, "123456:secret-token"))
+	assert.Equal(t, "token 123456:secret-token was rejected", errorText(err, ""))
+}
+
+func TestPlugin_retryDeadLetters(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			DefaultBotToken: "token",
+			DeadLetter:      config.DeadLetter{Enabled: true, RetryIntervalMinutes: 15, MaxAttempts: 3},
+		}}},
+	}
+	p.setStore(storage.NewMemory())
+
+	p.addDeadLetter(sendJob{msg: api.Message{Id: 1}, chatID: "123", attempts: 2}, errors.New("gave up"))
+	p.addDeadLetter(sendJob{msg: api.Message{Id: 2}, chatID: "123"}, errors.New("timeout"))
+	p.addDeadLetter(sendJob{msg: api.Message{Id: 3}, chatID: "456"}, errors.New("forbidden"))
+	p.chatHealth.recordForbidden("456", "blocked", time.Now(), 1)
+
+	retried, err := p.retryDeadLetters(false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, retried, "exhausted dead letters and dead letters of unhealthy chats are kept")
+	assert.Equal(t, 1, p.backlog())
+
+	job, ok := p.sendQueue.pop(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, uint32(2), job.msg.Id)
+	assert.Equal(t, "token", job.token)
+	assert.Equal(t, 1, job.attempts)
+
+	letters, err := p.listDeadLetters()
+	require.NoError(t, err)
+	assert.Len(t, letters, 2)
+
+	retried, err = p.retryDeadLetters(true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, retried)
+	letters, err = p.listDeadLetters()
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestPlugin_handleDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			DefaultBotToken: "token",
+			DeadLetter:      config.DeadLetter{Enabled: true},
+		}}},
+	}
+	p.setStore(storage.NewMemory())
+	for i := 1; i <= 3; i++ {
+		p.addDeadLetter(sendJob{msg: api.Message{Id: uint32(i)}, chatID: "123"}, errors.New("timeout"))
+	}
+
+	router := gin.New()
+	p.RegisterWebhook("/plugin/1/custom/token/", router.Group("/plugin/1/custom/token/"))
+	request := func(method, target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(method, target, nil))
+		return res
+	}
+
+	res := request(http.MethodGet, "/plugin/1/custom/token/dead-letters")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"chat_id":"123","error":"timeout","attempts":1`)
+
+	res = request(http.MethodPost, "/plugin/1/custom/token/dead-letters/retry")
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code, "nothing is sent without a Telegram client")
+
+	p.tgclient = telegram.NewClient(telegram.Config{ErrChan: make(chan error, 1), Logger: &logger})
+	res = request(http.MethodPost, "/plugin/1/custom/token/dead-letters/retry")
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.JSONEq(t, `{"retried":3}`, res.Body.String())
+	assert.Equal(t, 3, p.backlog())
+
+	p.addDeadLetter(sendJob{msg: api.Message{Id: 4}, chatID: "123"}, errors.New("timeout"))
+	res = request(http.MethodDelete, "/plugin/1/custom/token/dead-letters")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"flushed":1}`, res.Body.String())
+
+	res = request(http.MethodGet, "/plugin/1/custom/token/dead-letters")
+	assert.JSONEq(t, `{"dead_letters":[]}`, res.Body.String())
+}
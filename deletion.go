@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+)
+
+// sentMessagesBucket is the storage bucket mapping gotify messages to the Telegram messages they were sent as
+const sentMessagesBucket = "sent_messages"
+
+// sentRecord is a gotify message that was sent to a chat
+type sentRecord struct {
+	SentAt    time.Time           `json:"sent_at"`
+	ChatID    string              `json:"chat_id"`
+	Token     string              `json:"token"`
+	ParseMode string              `json:"parse_mode,omitempty"`
+	Parts     []telegram.SentPart `json:"parts"`
+}
+
+// recordSent remembers the Telegram messages a gotify message was sent as so that they can be
+// deleted or struck through when the gotify message is deleted. Texts are only kept for strikethroughs
+func (p *Plugin) recordSent(msg api.Message, sent telegram.SentMessage) {
+	if p.store == nil || p.config == nil || msg.Id == 0 {
+		return
+	}
+
+	mode := p.config.Settings.Telegram.DeletionSync.Mode
+	if mode == "" {
+		return
+	}
+
+	record := sentRecord{
+		SentAt:    time.Now(),
+		ChatID:    sent.ChatID,
+		Token:     sent.Token,
+		ParseMode: sent.ParseMode,
+		Parts:     append([]telegram.SentPart(nil), sent.Parts...),
+	}
+	if mode != config.DeletionSyncStrikethrough {
+		for i := range record.Parts {
+			record.Parts[i].Text = ""
+		}
+	}
+
+	if err := storage.PutJSON(p.store, sentMessagesBucket, deliveryKey(msg.Id, sent.ChatID), record); err != nil {
+		p.logger.Error().
+			Err(err).
+			Uint32("message_id", msg.Id).
+			Str("chat_id", sent.ChatID).
+			Msg("failed to save sent message")
+	}
+}
+
+// deletedMessages returns the records of the gotify messages that are no longer on the gotify server
+// by their storage key. Expired and unreadable records are deleted
+func (p *Plugin) deletedMessages(existing func(oldest uint32) (map[uint32]bool, error), now time.Time) (map[string]sentRecord, error) {
+	keys, err := p.store.Keys(sentMessagesBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]sentRecord, len(keys))
+	ids := make(map[string]uint32, len(keys))
+	var oldest uint32
+	for _, key := range keys {
+		var record sentRecord
+		id, parseErr := strconv.ParseUint(strings.SplitN(key, ":", 2)[0], 10, 32)
+		if err := storage.GetJSON(p.store, sentMessagesBucket, key, &record); err != nil || parseErr != nil ||
+			now.Sub(record.SentAt) >= deliveryKeyTTL {
+			if err := p.store.Delete(sentMessagesBucket, key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		records[key] = record
+		ids[key] = uint32(id)
+		if oldest == 0 || uint32(id) < oldest {
+			oldest = uint32(id)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	found, err := existing(oldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gotify messages: %w", err)
+	}
+
+	for key := range records {
+		if found[ids[key]] {
+			delete(records, key)
+		}
+	}
+
+	return records, nil
+}
+
+// syncDeletions deletes or strikes through the Telegram messages of gotify messages that were deleted
+func (p *Plugin) syncDeletions() {
+	if p.store == nil || p.config == nil || p.apiclient == nil || p.tgclient == nil {
+		return
+	}
+
+	deleted, err := p.deletedMessages(p.apiclient.MessageIDs, time.Now())
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to check for deleted messages")
+		return
+	}
+
+	mode := p.config.Settings.Telegram.DeletionSync.Mode
+	for key, record := range deleted {
+		sent := telegram.SentMessage{
+			ChatID:    record.ChatID,
+			Token:     record.Token,
+			ParseMode: record.ParseMode,
+			Parts:     record.Parts,
+		}
+
+		if mode == config.DeletionSyncStrikethrough {
+			err = p.tgclient.StrikeThroughMessage(sent)
+		} else {
+			err = p.tgclient.DeleteMessage(sent)
+		}
+		if err != nil {
+			p.logger.Warn().
+				Err(err).
+				Str("chat_id", record.ChatID).
+				Str("mode", mode).
+				Msg("failed to sync deleted gotify message to Telegram")
+		}
+
+		// failed syncs are not retried as they mostly fail for messages that were already deleted in the chat
+		if err := p.store.Delete(sentMessagesBucket, key); err != nil {
+			p.logger.Error().Err(err).Msg("failed to delete sent message")
+		}
+	}
+
+	if len(deleted) > 0 {
+		p.logger.Info().
+			Int("count", len(deleted)).
+			Str("mode", mode).
+			Msg("synced deleted gotify messages to Telegram")
+	}
+}
+
+// runDeletionSync periodically syncs deleted gotify messages to Telegram until the context is done
+func (p *Plugin) runDeletionSync(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(p.config.Settings.Telegram.DeletionSync.IntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.syncDeletions()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_recordSent(t *testing.T) {
+	sent := telegram.SentMessage{
+		ChatID:    "123",
+		Token:     "token",
+		ParseMode: "MarkdownV2",
+		Parts:     []telegram.SentPart{{MessageID: 42, Text: "*Title*"}},
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		expected []telegram.SentPart
+	}{
+		{name: "disabled", mode: ""},
+		{name: "delete", mode: config.DeletionSyncDelete, expected: []telegram.SentPart{{MessageID: 42}}},
+		{name: "strikethrough", mode: config.DeletionSyncStrikethrough, expected: []telegram.SentPart{{MessageID: 42, Text: "*Title*"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zerolog.New(zerolog.NewTestWriter(t))
+			cfg := config.DefaultConfig()
+			cfg.Settings.Telegram.DeletionSync.Mode = tt.mode
+			p := &Plugin{logger: &logger, config: cfg, store: storage.NewMemory()}
+
+			p.recordSent(api.Message{Id: 7}, sent)
+
+			var record sentRecord
+			err := storage.GetJSON(p.store, sentMessagesBucket, "7:123", &record)
+			if tt.expected == nil {
+				assert.ErrorIs(t, err, storage.ErrNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "token", record.Token)
+			assert.Equal(t, tt.expected, record.Parts)
+		})
+	}
+}
+
+func TestPlugin_deletedMessages(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger, store: storage.NewMemory()}
+
+	put := func(key string, sentAt time.Time) {
+		require.NoError(t, storage.PutJSON(p.store, sentMessagesBucket, key, sentRecord{SentAt: sentAt, ChatID: "123"}))
+	}
+	put("5:123", now.Add(-time.Hour))
+	put("6:123", now.Add(-time.Hour))
+	put("7:123", now.Add(-time.Minute))
+	put("1:123", now.Add(-deliveryKeyTTL))
+	require.NoError(t, p.store.Put(sentMessagesBucket, "8:123", []byte("invalid")))
+
+	var oldest uint32
+	deleted, err := p.deletedMessages(func(id uint32) (map[uint32]bool, error) {
+		oldest = id
+		return map[uint32]bool{6: true, 7: true}, nil
+	}, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint32(5), oldest)
+	assert.Equal(t, map[string]sentRecord{"5:123": {SentAt: now.Add(-time.Hour), ChatID: "123"}}, deleted)
+
+	// expired and invalid records are removed
+	keys, err := p.store.Keys(sentMessagesBucket)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"5:123", "6:123", "7:123"}, keys)
+
+	_, err = p.deletedMessages(func(uint32) (map[uint32]bool, error) {
+		return nil, errors.New("connection refused")
+	}, now)
+	assert.EqualError(t, err, "failed to list gotify messages: connection refused")
+}
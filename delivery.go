@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+)
+
+const (
+	// deliveriesBucket is the storage bucket of the delivery keys
+	deliveriesBucket = "deliveries"
+	// deliveryKeyTTL is how long a delivered message is remembered
+	deliveryKeyTTL = 24 * time.Hour
+	// deliveryPruneInterval is how often expired delivery keys are removed
+	deliveryPruneInterval = time.Hour
+)
+
+// deliveryKey identifies the delivery of a gotify message to a chat
+func deliveryKey(messageID uint32, chatID string) string {
+	return fmt.Sprintf("%d:%s", messageID, chatID)
+}
+
+// deliveryLog remembers which gotify messages were delivered to which chats so
+// that messages replayed after a restart or reconnect are not posted twice
+type deliveryLog struct {
+	mu sync.Mutex
+	// pending holds the keys of deliveries that are in flight
+	pending map[string]bool
+}
+
+// claim reserves the delivery of the message to the chat. Returns false if the
+// message was already delivered to the chat or a delivery is in flight
+func (d *deliveryLog) claim(store storage.Store, key string, now time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pending[key] {
+		return false, nil
+	}
+
+	var delivered time.Time
+	err := storage.GetJSON(store, deliveriesBucket, key, &delivered)
+	switch {
+	case err == nil && now.Sub(delivered) < deliveryKeyTTL:
+		return false, nil
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		return true, fmt.Errorf("failed to read delivery key: %w", err)
+	}
+
+	if d.pending == nil {
+		d.pending = make(map[string]bool)
+	}
+	d.pending[key] = true
+	return true, nil
+}
+
+// complete persists the delivery key after the message was delivered
+func (d *deliveryLog) complete(store storage.Store, key string, now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.pending, key)
+	return storage.PutJSON(store, deliveriesBucket, key, now)
+}
+
+// release gives up the claim of a delivery that failed so that it can be retried
+func (d *deliveryLog) release(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.pending, key)
+}
+
+// prune removes the delivery keys that are older than the TTL
+func (d *deliveryLog) prune(store storage.Store, now time.Time) error {
+	keys, err := store.Keys(deliveriesBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		var delivered time.Time
+		if err := storage.GetJSON(store, deliveriesBucket, key, &delivered); err == nil && now.Sub(delivered) < deliveryKeyTTL {
+			continue
+		}
+		if err := store.Delete(deliveriesBucket, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// claimDelivery returns false if the message must not be sent to the chat because it
+// was already delivered. Messages without a gotify message id are always sent
+func (p *Plugin) claimDelivery(msg api.Message, chatID string) bool {
+	if p.store == nil || msg.Id == 0 {
+		return true
+	}
+
+	ok, err := p.deliveries.claim(p.store, deliveryKey(msg.Id, chatID), time.Now())
+	if err != nil {
+		p.logger.Error().
+			Err(err).
+			Uint32("message_id", msg.Id).
+			Str("chat_id", chatID).
+			Msg("failed to check delivery key. Sending message anyway")
+	}
+
+	return ok
+}
+
+// completeDelivery records that the message was delivered to the chat
+func (p *Plugin) completeDelivery(msg api.Message, chatID string) {
+	if p.store == nil || msg.Id == 0 {
+		return
+	}
+
+	if err := p.deliveries.complete(p.store, deliveryKey(msg.Id, chatID), time.Now()); err != nil {
+		p.logger.Error().
+			Err(err).
+			Uint32("message_id", msg.Id).
+			Str("chat_id", chatID).
+			Msg("failed to save delivery key")
+	}
+}
+
+// pruneDeliveries removes expired delivery and correlation keys from the plugin storage
+func (p *Plugin) pruneDeliveries() {
+	if p.store == nil {
+		return
+	}
+
+	if err := p.deliveries.prune(p.store, time.Now()); err != nil {
+		p.logger.Error().Err(err).Msg("failed to prune delivery keys")
+	}
+	if err := p.pruneCorrelations(time.Now()); err != nil {
+		p.logger.Error().Err(err).Msg("failed to prune correlation keys")
+	}
+}
+
+// runDeliveryPruner periodically removes expired delivery keys until the context is done
+func (p *Plugin) runDeliveryPruner(ctx context.Context) {
+	p.pruneDeliveries()
+
+	ticker := time.NewTicker(deliveryPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.pruneDeliveries()
+		}
+	}
+}
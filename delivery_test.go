@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryLog(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	store := storage.NewMemory()
+	var d deliveryLog
+
+	ok, err := d.claim(store, "1:123", now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// a delivery in flight can not be claimed again
+	ok, err = d.claim(store, "1:123", now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// a failed delivery can be retried
+	d.release("1:123")
+	ok, err = d.claim(store, "1:123", now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, d.complete(store, "1:123", now))
+	ok, err = d.claim(store, "1:123", now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// the key expires after the TTL
+	ok, err = d.claim(store, "1:123", now.Add(deliveryKeyTTL))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestDeliveryLog_prune(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	store := storage.NewMemory()
+	var d deliveryLog
+
+	require.NoError(t, d.complete(store, "1:123", now.Add(-deliveryKeyTTL)))
+	require.NoError(t, d.complete(store, "2:123", now.Add(-time.Minute)))
+	require.NoError(t, store.Put(deliveriesBucket, "3:123", []byte("invalid")))
+
+	require.NoError(t, d.prune(store, now))
+
+	keys, err := store.Keys(deliveriesBucket)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2:123"}, keys)
+}
+
+func TestPlugin_claimDelivery(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	handler := &MockStorageHandler{}
+
+	p := &Plugin{logger: &logger}
+	p.SetStorageHandler(handler)
+
+	msg := api.Message{Id: 42, AppID: 1, Message: "backup failed"}
+	require.True(t, p.claimDelivery(msg, "123"))
+	require.True(t, p.claimDelivery(msg, "456"))
+	p.completeDelivery(msg, "123")
+
+	// the failed delivery to 456 is released by the error handler
+	p.handleError(&telegram.SendError{MessageID: 42, ChatID: "456", Err: errors.New("timeout")})
+	p.flushStore()
+
+	restarted := &Plugin{logger: &logger}
+	restarted.SetStorageHandler(handler)
+	assert.False(t, restarted.claimDelivery(msg, "123"))
+	assert.True(t, restarted.claimDelivery(msg, "456"))
+
+	// messages without an id are always sent
+	assert.True(t, restarted.claimDelivery(api.Message{Message: "test"}, "123"))
+	assert.True(t, restarted.claimDelivery(api.Message{Message: "test"}, "123"))
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/utils"
+)
+
+// readmeURL is linked from the status page for the full documentation
+const readmeURL = "https://github.com/0xPeterSatoshi/gotify-to-telegram#readme"
+
+// statusTemplate renders the plugin status page shown in the Gotify UI
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"cell": escapeTableCell,
+	"join": strings.Join,
+}).Parse(`# gotify-to-telegram {{ .Version }}
+
+Forwards Gotify messages to Telegram bots based on configurable routing rules. See the
+[documentation]({{ .ReadmeURL }}) for all configuration options.
+
+## Status
+
+| | |
+| --- | --- |
+| Plugin | {{ if .Enabled }}enabled{{ else }}disabled{{ end }} |
+| Gotify connection | {{ if .Connected }}connected{{ else }}disconnected{{ end }} |
+{{- if .TelegramAPI }}
+| Telegram API | {{ .TelegramAPI }} |
+{{- end }}
+| Uptime | {{ .Uptime }} |
+| Messages forwarded | {{ .Forwarded }} |
+| Messages shed | {{ .Shed }} |
+{{- if .SchemaURL }}
+| Config JSON schema | [{{ .SchemaURL }}]({{ .SchemaURL }}) |
+{{- end }}
+{{- if .MetricsURL }}
+| Prometheus metrics | [{{ .MetricsURL }}]({{ .MetricsURL }}) |
+{{- end }}
+
+## Config summary
+
+| Setting | Value |
+| --- | --- |
+| Gotify server | {{ cell .GotifyURL }} |
+| Gotify client token | {{ cell .ClientToken }} |
+| Default bot token | {{ cell .DefaultBotToken }} |
+| Default chat IDs | {{ cell (join .DefaultChatIDs ", ") }} |
+| Log level | {{ cell .LogLevel }} |
+| Env vars | {{ if .IgnoreEnvVars }}ignored{{ else }}applied{{ end }} |
+
+## Routes
+
+{{ if .RoutesOverridden -}}
+The routes below were set through the routing webhook and override the routes of the plugin config.
+
+{{ end -}}
+{{ if .Routes -}}
+| Bot | Token | Chat IDs | Gotify apps |
+| --- | --- | --- | --- |
+{{ range .Routes -}}
+| {{ cell .Name }} | {{ cell .Token }} | {{ cell (join .ChatIDs ", ") }} | {{ cell (join .Apps ", ") }} |
+{{ end -}}
+{{ else -}}
+No bots configured. All messages are sent to the default chats.
+{{ end }}
+{{- if .ConfigWarnings }}
+## Config warnings
+
+{{ range .ConfigWarnings -}}
+- {{ . }}
+{{ end }}
+{{ end -}}
+{{- if .UnhealthyChats }}
+## Unhealthy chats
+
+Messages are no longer sent to these chats. Send a POST request to the release URL or save the plugin config to
+retry them.
+
+| Chat | Since | Reason | Release URL |
+| --- | --- | --- | --- |
+{{ range .UnhealthyChats -}}
+| {{ cell .ChatID }} | {{ .Since }} | {{ cell .Reason }} | {{ if .ReleaseURL }}` + "`{{ .ReleaseURL }}`" + `{{ end }} |
+{{ end }}
+{{ end -}}
+{{- if .DeadLetters }}
+## Dead letters
+
+Messages that failed to send are kept until they are sent or removed ({{ .DeadLetterCount }} in total). The most
+recent are listed below.
+{{- if .DeadLetterURL }} Send a POST request to ` + "`{{ .DeadLetterURL }}/retry`" + ` to send all of them again or
+a DELETE request to ` + "`{{ .DeadLetterURL }}`" + ` to remove them.
+{{- end }}
+
+| Failed | Chat | App | Attempts | Error |
+| --- | --- | --- | --- | --- |
+{{ range .DeadLetters -}}
+| {{ .Time }} | {{ cell .ChatID }} | {{ cell .App }} | {{ .Attempts }} | {{ cell .Error }} |
+{{ end }}
+{{ end -}}
+{{ .AppStats }}
+## Delivery latency
+
+{{ if .Latency -}}
+| Measured from | p50 | p95 | Messages |
+| --- | --- | --- | --- |
+{{ range .Latency -}}
+| {{ .Name }} | {{ .P50 }} | {{ .P95 }} | {{ .Count }} |
+{{ end -}}
+{{ else -}}
+No messages forwarded yet.
+{{ end }}
+## Recent errors
+
+{{ if .RecentErrors -}}
+| Time | Component | Chat | App | Error |
+| --- | --- | --- | --- | --- |
+{{ range .RecentErrors -}}
+| {{ .Time }} | {{ .Component }} | {{ cell .ChatID }} | {{ cell .App }} | {{ cell .Message }} |
+{{ end -}}
+{{ else -}}
+No errors.
+{{ end }}`))
+
+// routeStatus describes a configured bot on the status page
+type routeStatus struct {
+	Name    string
+	Token   string
+	ChatIDs []string
+	Apps    []string
+}
+
+// statusData is rendered by the status template
+type statusData struct {
+	Version          string
+	ReadmeURL        string
+	Enabled          bool
+	Connected        bool
+	TelegramAPI      string
+	Uptime           time.Duration
+	Forwarded        uint64
+	Shed             uint64
+	SchemaURL        string
+	MetricsURL       string
+	GotifyURL        string
+	ClientToken      string
+	DefaultBotToken  string
+	DefaultChatIDs   []string
+	LogLevel         string
+	IgnoreEnvVars    bool
+	Routes           []routeStatus
+	RoutesOverridden bool
+	AppStats         string
+	RecentErrors     []errorStatus
+	UnhealthyChats   []chatStatus
+	DeadLetters      []deadLetterStatus
+	DeadLetterCount  int
+	DeadLetterURL    string
+	Latency          []latencyStatus
+	ConfigWarnings   []string
+}
+
+// latencyStatus describes a delivery latency histogram on the status page
+type latencyStatus struct {
+	Name  string
+	P50   time.Duration
+	P95   time.Duration
+	Count uint64
+}
+
+// chatStatus describes an unhealthy chat on the status page
+type chatStatus struct {
+	ChatID     string
+	Since      string
+	Reason     string
+	ReleaseURL string
+}
+
+// deadLetterStatus describes a dead letter on the status page
+type deadLetterStatus struct {
+	Time     string
+	ChatID   string
+	App      string
+	Attempts int
+	Error    string
+}
+
+// errorStatus describes a recent error on the status page
+type errorStatus struct {
+	Time      string
+	Component string
+	ChatID    string
+	App       string
+	Message   string
+}
+
+// formatLastSeen formats a last seen timestamp relative to now
+func formatLastSeen(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), now.Sub(t).Truncate(time.Second))
+}
+
+// errorStatuses converts the recorded errors for the status page
+func errorStatuses(entries []stats.ErrorEntry, now time.Time) []errorStatus {
+	errors := make([]errorStatus, 0, len(entries))
+	for _, entry := range entries {
+		var app string
+		switch {
+		case entry.AppName != "":
+			app = fmt.Sprintf("%s (%d)", entry.AppName, entry.AppID)
+		case entry.AppID != 0:
+			app = fmt.Sprintf("%d", entry.AppID)
+		}
+
+		errors = append(errors, errorStatus{
+			Time:      formatLastSeen(entry.Time, now),
+			Component: entry.Component,
+			ChatID:    entry.ChatID,
+			App:       app,
+			Message:   entry.Message,
+		})
+	}
+
+	return errors
+}
+
+// deadLetterStatuses converts the most recent dead letters for the status page, the newest first
+func deadLetterStatuses(letters []deadLetter, now time.Time) []deadLetterStatus {
+	statuses := make([]deadLetterStatus, 0, min(len(letters), maxListedDeadLetters))
+	for i := len(letters) - 1; i >= 0 && len(statuses) < maxListedDeadLetters; i-- {
+		letter := letters[i]
+		app := letter.Message.AppName
+		if app == "" {
+			app = fmt.Sprintf("%d", letter.Message.AppID)
+		} else {
+			app = fmt.Sprintf("%s (%d)", app, letter.Message.AppID)
+		}
+
+		statuses = append(statuses, deadLetterStatus{
+			Time:     formatLastSeen(letter.FailedAt, now),
+			ChatID:   letter.ChatID,
+			App:      app,
+			Attempts: letter.Attempts,
+			Error:    letter.Error,
+		})
+	}
+
+	return statuses
+}
+
+// escapeTableCell escapes characters that would break a markdown table cell
+func escapeTableCell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// renderAppStats renders the per-application statistics as a markdown table
+func renderAppStats(apps []stats.AppStats, now time.Time) string {
+	var builder strings.Builder
+
+	builder.WriteString("## Application statistics\n\n")
+	if len(apps) == 0 {
+		builder.WriteString("No messages received yet.\n")
+		return builder.String()
+	}
+
+	builder.WriteString("| App | App ID | Received | Forwarded | Last forwarded |\n")
+	builder.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, app := range apps {
+		builder.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %s |\n",
+			escapeTableCell(app.AppName), app.AppID, app.Received, app.Forwarded, formatLastSeen(app.LastForwarded, now)))
+	}
+
+	return builder.String()
+}
+
+// routeStatuses returns the configured bots sorted by name with masked tokens
+func (p *Plugin) routeStatuses(bots map[string]config.TelegramBot) []routeStatus {
+	names := make([]string, 0, len(bots))
+	for name := range bots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	routes := make([]routeStatus, 0, len(bots))
+	for _, name := range names {
+		bot := bots[name]
+
+		apps := make([]string, 0, len(bot.AppIDs)+len(bot.AppNames))
+		for _, appID := range bot.AppIDs {
+			apps = append(apps, fmt.Sprintf("%d", appID))
+		}
+		for _, appName := range bot.AppNames {
+			if appID, ok := p.appIDsByName[strings.ToLower(appName)]; ok {
+				apps = append(apps, fmt.Sprintf("%s (%d)", appName, appID))
+			} else {
+				apps = append(apps, fmt.Sprintf("%s (unresolved)", appName))
+			}
+		}
+
+		tokens := bot.GetTokens()
+		for i, token := range tokens {
+			tokens[i] = utils.MaskToken(token)
+		}
+
+		routes = append(routes, routeStatus{
+			Name:    name,
+			Token:   strings.Join(tokens, ", "),
+			ChatIDs: bot.ChatIDs,
+			Apps:    apps,
+		})
+	}
+
+	return routes
+}
+
+// latencyStatuses returns the estimated p50 and p95 of the delivery latency histograms with observations
+func latencyStatuses(latency, age stats.HistogramSnapshot) []latencyStatus {
+	var statuses []latencyStatus
+	for _, h := range []struct {
+		name      string
+		histogram stats.HistogramSnapshot
+	}{
+		{"Receipt from websocket", latency},
+		{"Creation in Gotify", age},
+	} {
+		if h.histogram.Count == 0 {
+			continue
+		}
+		statuses = append(statuses, latencyStatus{
+			Name:  h.name,
+			P50:   h.histogram.Quantile(0.5).Round(time.Millisecond),
+			P95:   h.histogram.Quantile(0.95).Round(time.Millisecond),
+			Count: h.histogram.Count,
+		})
+	}
+
+	return statuses
+}
+
+// statusData collects the data rendered on the status page
+func (p *Plugin) statusData(location *url.URL, now time.Time) statusData {
+	data := statusData{
+		Version:   Version,
+		ReadmeURL: readmeURL,
+		Enabled:   p.enabled,
+	}
+
+	if p.webhookBasePath != "" {
+		data.SchemaURL = p.webhookURL(location, "config/schema.json")
+		data.MetricsURL = p.webhookURL(location, "metrics")
+	}
+
+	if p.apiclient != nil {
+		data.Connected = p.apiclient.IsConnected()
+	}
+
+	if p.tgclient != nil {
+		switch state := p.tgclient.CircuitState(); state {
+		case "closed":
+			data.TelegramAPI = "available"
+		case "open":
+			data.TelegramAPI = "unavailable (circuit breaker open, messages are queued)"
+		default:
+			data.TelegramAPI = "probing (circuit breaker " + state + ")"
+		}
+	}
+
+	if p.stats != nil {
+		data.Uptime = now.Sub(p.stats.StartedAt()).Truncate(time.Second)
+		data.Forwarded = p.stats.Forwarded()
+		data.Shed = p.stats.Shed()
+		data.AppStats = renderAppStats(p.stats.Apps(), now)
+		data.RecentErrors = errorStatuses(p.stats.RecentErrors(), now)
+		data.Latency = latencyStatuses(p.stats.Latency(), p.stats.Age())
+	}
+
+	for _, chat := range p.chatHealth.unhealthy() {
+		status := chatStatus{
+			ChatID: chat.ChatID,
+			Since:  formatLastSeen(chat.Since, now),
+			Reason: chat.Reason,
+		}
+		if p.webhookBasePath != "" {
+			status.ReleaseURL = p.webhookURL(location, "chats/"+url.PathEscape(chat.ChatID)+"/release")
+		}
+		data.UnhealthyChats = append(data.UnhealthyChats, status)
+	}
+
+	if letters, err := p.listDeadLetters(); err != nil {
+		p.logger.Error().Err(err).Msg("failed to list dead letters")
+	} else if len(letters) > 0 {
+		data.DeadLetterCount = len(letters)
+		data.DeadLetters = deadLetterStatuses(letters, now)
+		if p.webhookBasePath != "" {
+			data.DeadLetterURL = p.webhookURL(location, "dead-letters")
+		}
+	}
+
+	if p.config != nil {
+		settings := p.config.Settings
+		data.GotifyURL = settings.GotifyServer.RawUrl
+		data.ClientToken = utils.MaskToken(settings.GotifyServer.ClientToken)
+		data.DefaultBotToken = utils.MaskToken(settings.Telegram.DefaultBotToken)
+		data.DefaultChatIDs = settings.Telegram.DefaultChatIDs
+		data.LogLevel = settings.LogOptions.LogLevel
+		data.IgnoreEnvVars = settings.IgnoreEnvVars
+		data.Routes = p.routeStatuses(settings.Telegram.Bots)
+		data.RoutesOverridden = p.routesOverridden
+		data.ConfigWarnings = p.configWarnings()
+	}
+
+	return data
+}
+
+// renderStatus renders the status page for the location the user is accessing the gotify API from
+func (p *Plugin) renderStatus(location *url.URL, now time.Time) (string, error) {
+	var builder strings.Builder
+	if err := statusTemplate.Execute(&builder, p.statusData(location, now)); err != nil {
+		return "", err
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderAppStats(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "## Application statistics\n\nNo messages received yet.\n", renderAppStats(nil, now))
+
+	apps := []stats.AppStats{
+		{
+			AppID:         1,
+			AppName:       "Backups | nightly",
+			Received:      3,
+			Forwarded:     2,
+			LastForwarded: now.Add(-90 * time.Minute),
+		},
+		{
+			AppID:    2,
+			AppName:  "Sonarr",
+			Received: 1,
+		},
+	}
+
+	expected := "## Application statistics\n\n" +
+		"| App | App ID | Received | Forwarded | Last forwarded |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| Backups \\| nightly | 1 | 3 | 2 | 2024-01-02T10:30:00Z (1h30m0s ago) |\n" +
+		"| Sonarr | 2 | 1 | 0 | never |\n"
+	assert.Equal(t, expected, renderAppStats(apps, now))
+}
+
+func TestErrorStatuses(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	entries := []stats.ErrorEntry{
+		{
+			Component: "telegram",
+			Time:      now.Add(-time.Minute),
+			Message:   "telegram API error (status 403): Forbidden",
+			ChatID:    "123",
+			AppID:     3,
+			AppName:   "backups",
+		},
+		{
+			Component: "gotify",
+			Time:      now.Add(-time.Hour),
+			Message:   "api client is not initialized",
+		},
+	}
+
+	expected := []errorStatus{
+		{
+			Time:      "2024-01-02T11:59:00Z (1m0s ago)",
+			Component: "telegram",
+			ChatID:    "123",
+			App:       "backups (3)",
+			Message:   "telegram API error (status 403): Forbidden",
+		},
+		{
+			Time:      "2024-01-02T11:00:00Z (1h0m0s ago)",
+			Component: "gotify",
+			Message:   "api client is not initialized",
+		},
+	}
+	assert.Equal(t, expected, errorStatuses(entries, now))
+}
+
+func TestPlugin_renderStatus_RecentErrors(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		stats:  stats.NewTracker(),
+	}
+
+	p.handleError(&telegram.SendError{
+		ChatID:  "123",
+		AppID:   3,
+		AppName: "backups",
+		Err:     errors.New("failed to make request: bad\nrequest | retry"),
+	})
+
+	status, err := p.renderStatus(nil, time.Now())
+	require.NoError(t, err)
+
+	assert.Contains(t, status, "| Time | Component | Chat | App | Error |")
+	assert.Contains(t, status, "| telegram | 123 | backups (3) | failed to make request: bad request \\| retry |")
+	assert.NotContains(t, status, "No errors.")
+}
+
+func TestPlugin_renderStatus_DeadLetters(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			DeadLetter: config.DeadLetter{Enabled: true},
+		}}},
+	}
+	p.setStore(storage.NewMemory())
+
+	status, err := p.renderStatus(nil, time.Now())
+	require.NoError(t, err)
+	assert.NotContains(t, status, "## Dead letters")
+
+	for i := 1; i <= maxListedDeadLetters+1; i++ {
+		p.addDeadLetter(sendJob{msg: api.Message{Id: uint32(i), AppID: 3, AppName: "backups"}, chatID: "123"},
+			fmt.Errorf("failed to make request: attempt | %d", i))
+	}
+
+	p.webhookBasePath = "/plugin/1/custom/token/"
+	status, err = p.renderStatus(&url.URL{Scheme: "https", Host: "gotify.example.com"}, time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, status, "(21 in total)")
+	assert.Contains(t, status, "`https://gotify.example.com/plugin/1/custom/token/dead-letters/retry`")
+	assert.Contains(t, status, "| 123 | backups (3) | 1 | failed to make request: attempt \\| 21 |")
+	assert.NotContains(t, status, "attempt \\| 1 |", "only the most recent dead letters are listed")
+}
+
+func TestPlugin_renderStatus(t *testing.T) {
+	now := time.Now()
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger:  &logger,
+		enabled: true,
+		stats:   stats.NewTracker(),
+		config: &config.Plugin{
+			Settings: config.Settings{
+				LogOptions: config.LogOptions{LogLevel: "info"},
+				GotifyServer: config.GotifyServer{
+					RawUrl:      "http://gotify.example.com",
+					ClientToken: "client-token-secret",
+				},
+				Telegram: config.Telegram{
+					DefaultBotToken: "123456:default-bot-token",
+					DefaultChatIDs:  []string{"111", "222"},
+					Bots: map[string]config.TelegramBot{
+						"backups": {
+							Token:    "987654:backups-bot-token",
+							ChatIDs:  []string{"333"},
+							AppIDs:   []uint32{5},
+							AppNames: []string{"Restic", "Borg"},
+						},
+					},
+				},
+			},
+		},
+	}
+	p.resolveAppNames([]api.Application{{ID: 7, Name: "restic"}})
+
+	p.webhookBasePath = "/plugin/1/custom/token/"
+	location := &url.URL{Scheme: "https", Host: "gotify.example.com"}
+	status, err := p.renderStatus(location, now)
+	require.NoError(t, err)
+
+	assert.Contains(t, status, "| Plugin | enabled |")
+	assert.Contains(t, status, "| Gotify connection | disconnected |")
+	assert.NotContains(t, status, "| Telegram API |")
+	assert.Contains(t, status, "| Gotify client token | clie...cret |")
+	assert.Contains(t, status, "| Default bot token | 1234...oken |")
+	assert.Contains(t, status, "| Default chat IDs | 111, 222 |")
+	assert.Contains(t, status, "| backups | 9876...oken | 333 | 5, Restic (7), Borg (unresolved) |")
+	assert.Contains(t, status, "## Config warnings\n\n"+
+		"- bot \"backups\" routes gotify app id 5 which does not exist on the gotify server\n"+
+		"- bot \"backups\" routes gotify app name \"borg\" which does not exist on the gotify server\n\n")
+	assert.Contains(t, status, "[https://gotify.example.com/plugin/1/custom/token/config/schema.json]")
+	assert.Contains(t, status, "No messages received yet.")
+	assert.Contains(t, status, "## Delivery latency\n\nNo messages forwarded yet.")
+	assert.Contains(t, status, "## Recent errors\n\nNo errors.")
+	assert.Contains(t, status, "[https://gotify.example.com/plugin/1/custom/token/metrics]")
+	assert.Contains(t, status, readmeURL)
+	assert.NotContains(t, status, "client-token-secret")
+	assert.NotContains(t, status, "backups-bot-token")
+}
+
+func TestLatencyStatuses(t *testing.T) {
+	latency := stats.NewHistogram(stats.LatencyBuckets)
+	age := stats.NewHistogram(stats.LatencyBuckets)
+
+	assert.Empty(t, latencyStatuses(latency.Snapshot(), age.Snapshot()))
+
+	for i := 0; i < 20; i++ {
+		latency.Observe(200 * time.Millisecond)
+	}
+
+	expected := []latencyStatus{
+		{
+			Name:  "Receipt from websocket",
+			P50:   175 * time.Millisecond,
+			P95:   243 * time.Millisecond,
+			Count: 20,
+		},
+	}
+	assert.Equal(t, expected, latencyStatuses(latency.Snapshot(), age.Snapshot()))
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/utils"
+)
+
+// maxFailoverHops bounds the chain of replaced bot tokens followed for a chat
+const maxFailoverHops = 16
+
+// tokenFailover switches chats to a backup bot token after the bot token used for them
+// failed repeatedly, e.g. because it was revoked or the bot was banned from the chat
+type tokenFailover struct {
+	mu sync.Mutex
+	// failures holds the consecutive failures keyed by bot token and chat. Send errors are reported both to
+	// the send queue and the error channel, they count once
+	failures map[string]map[*telegram.SendError]struct{}
+	// replaced holds the backup token used instead of a bot token keyed by bot token and chat
+	replaced map[string]string
+}
+
+func failoverKey(token, chatID string) string {
+	return token + "|" + chatID
+}
+
+// resolve returns the token to send to the chat with instead of the given token
+func (f *tokenFailover) resolve(token, chatID string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := 0; i < maxFailoverHops; i++ {
+		next, ok := f.replaced[failoverKey(token, chatID)]
+		if !ok {
+			break
+		}
+		token = next
+	}
+
+	return token
+}
+
+// recordFailure counts a failure of the token in the chat. Returns true once the threshold is reached
+func (f *tokenFailover) recordFailure(sendErr *telegram.SendError, threshold int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failures == nil {
+		f.failures = make(map[string]map[*telegram.SendError]struct{})
+	}
+	key := failoverKey(sendErr.Token, sendErr.ChatID)
+	if f.failures[key] == nil {
+		f.failures[key] = make(map[*telegram.SendError]struct{})
+	}
+	f.failures[key][sendErr] = struct{}{}
+
+	return len(f.failures[key]) >= threshold
+}
+
+// recordSuccess resets the failures of the token in the chat
+func (f *tokenFailover) recordSuccess(token, chatID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.failures, failoverKey(token, chatID))
+}
+
+// replace sends the messages of the chat with the backup token instead of the token
+func (f *tokenFailover) replace(token, chatID, backup string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.replaced == nil {
+		f.replaced = make(map[string]string)
+	}
+	f.replaced[failoverKey(token, chatID)] = backup
+	delete(f.failures, failoverKey(token, chatID))
+}
+
+// isReplaced returns true if the token failed over to a backup token in the chat
+func (f *tokenFailover) isReplaced(token, chatID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.replaced[failoverKey(token, chatID)]
+	return ok
+}
+
+// reset uses the configured tokens again
+func (f *tokenFailover) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = nil
+	f.replaced = nil
+}
+
+// nextBackupToken returns the first backup token of the route that didn't fail in the chat yet
+func (p *Plugin) nextBackupToken(backups []string, token, chatID string) (string, bool) {
+	for _, backup := range backups {
+		if backup != "" && backup != token && !p.failover.isReplaced(backup, chatID) {
+			return backup, true
+		}
+	}
+	return "", false
+}
+
+// handleFailover counts auth errors and 403 responses of the bot token in the chat and fails the chat over
+// to the next backup token of its route once the failover threshold is reached. Returns true while a backup
+// token is left, so that the chat isn't marked unhealthy because of a failing bot token
+func (p *Plugin) handleFailover(sendErr *telegram.SendError) bool {
+	if p.config == nil || sendErr.ChatID == "" || sendErr.Token == "" {
+		return false
+	}
+	threshold := p.config.Settings.Telegram.FailoverThreshold
+	if threshold <= 0 {
+		return false
+	}
+
+	var apiErr *telegram.APIError
+	if !errors.As(sendErr, &apiErr) || !(apiErr.IsAuthError() || apiErr.StatusCode == http.StatusForbidden) {
+		return false
+	}
+
+	// failures of sends in flight when the chat failed over to a backup token don't count
+	if p.failover.isReplaced(sendErr.Token, sendErr.ChatID) {
+		return true
+	}
+
+	backups := p.getTelegramBotConfigForAppID(sendErr.AppID).BackupTokens
+	backup, ok := p.nextBackupToken(backups, sendErr.Token, sendErr.ChatID)
+	if !ok {
+		return false
+	}
+	if !p.failover.recordFailure(sendErr, threshold) {
+		return true
+	}
+
+	p.failover.replace(sendErr.Token, sendErr.ChatID, backup)
+	p.logger.Warn().
+		Str("from_bot_token", utils.MaskToken(sendErr.Token)).
+		Str("to_bot_token", utils.MaskToken(backup)).
+		Str("chat_id", sendErr.ChatID).
+		Uint32("app_id", sendErr.AppID).
+		Str("reason", apiErr.Description).
+		Msg("bot token failed repeatedly. Failed over to a backup bot token")
+
+	return true
+}
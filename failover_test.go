@@ -0,0 +1,205 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// botAPIFunc answers the requests to the Telegram Bot API
+type botAPIFunc func(req *http.Request) (*http.Response, error)
+
+func (f botAPIFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTokenFailover_resolve(t *testing.T) {
+	var f tokenFailover
+	assert.Equal(t, "token-a", f.resolve("token-a", "123"))
+
+	failure := func() *telegram.SendError {
+		return &telegram.SendError{Token: "token-a", ChatID: "123"}
+	}
+	assert.False(t, f.recordFailure(failure(), 2))
+	f.recordSuccess("token-a", "123")
+	sendErr := failure()
+	assert.False(t, f.recordFailure(sendErr, 2), "successful sends reset the failures")
+	assert.False(t, f.recordFailure(sendErr, 2), "a send error counts once")
+	assert.True(t, f.recordFailure(failure(), 2))
+
+	f.replace("token-a", "123", "token-b")
+	f.replace("token-b", "123", "token-c")
+	assert.Equal(t, "token-c", f.resolve("token-a", "123"))
+	assert.Equal(t, "token-a", f.resolve("token-a", "456"), "other chats keep the token")
+
+	// a cycle of replaced tokens doesn't block
+	f.replace("token-c", "123", "token-a")
+	assert.NotEmpty(t, f.resolve("token-a", "123"))
+
+	f.reset()
+	assert.Equal(t, "token-a", f.resolve("token-a", "123"))
+}
+
+func TestPlugin_handleError_Failover(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{
+					DefaultBotToken:      "default-token",
+					BlockedChatThreshold: 2,
+					FailoverThreshold:    2,
+					Bots: map[string]config.TelegramBot{
+						"alerts": {
+							Token:        "token-a",
+							BackupTokens: []string{"token-b", "token-c"},
+							ChatIDs:      []string{"123"},
+							AppIDs:       []uint32{1},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	kicked := &telegram.APIError{StatusCode: http.StatusForbidden, Description: "Forbidden: bot was kicked from the group chat"}
+	fail := func(token string) {
+		p.handleError(&telegram.SendError{Token: token, ChatID: "123", AppID: 1, Err: kicked})
+	}
+
+	fail("token-a")
+	assert.Equal(t, "token-a", p.failover.resolve("token-a", "123"))
+	fail("token-a")
+	assert.Equal(t, "token-b", p.failover.resolve("token-a", "123"))
+	assert.False(t, p.chatHealth.isUnhealthy("123"), "the chat isn't blocked while a backup token is left")
+
+	fail("token-b")
+	fail("token-b")
+	assert.Equal(t, "token-c", p.failover.resolve("token-a", "123"))
+
+	// the chat is blocked once all backup tokens failed
+	fail("token-c")
+	fail("token-c")
+	assert.Equal(t, "token-c", p.failover.resolve("token-a", "123"))
+	assert.True(t, p.chatHealth.isUnhealthy("123"))
+
+	// other errors and disabled failovers don't switch tokens
+	p.failover.reset()
+	p.handleError(&telegram.SendError{
+		Token: "token-a", ChatID: "123", AppID: 1,
+		Err: &telegram.APIError{StatusCode: http.StatusBadRequest, Description: "Bad Request"},
+	})
+	p.handleError(&telegram.SendError{
+		Token: "token-a", ChatID: "123", AppID: 1,
+		Err: &telegram.APIError{StatusCode: http.StatusBadRequest, Description: "Bad Request"},
+	})
+	assert.Equal(t, "token-a", p.failover.resolve("token-a", "123"))
+
+	p.config.Settings.Telegram.FailoverThreshold = 0
+	fail("token-a")
+	fail("token-a")
+	assert.Equal(t, "token-a", p.failover.resolve("token-a", "123"))
+}
+
+func TestPlugin_handleError_FailoverDefaultBot(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{
+					DefaultBotToken:     "default-token",
+					DefaultBackupTokens: []string{"backup-token"},
+					FailoverThreshold:   1,
+				},
+			},
+		},
+	}
+
+	unauthorized := &telegram.APIError{StatusCode: http.StatusUnauthorized, Description: "Unauthorized"}
+	p.handleError(&telegram.SendError{Token: "default-token", ChatID: "123", AppID: 7, Err: unauthorized})
+	assert.Equal(t, "backup-token", p.failover.resolve("default-token", "123"))
+}
+
+func TestPlugin_deliver_Failover(t *testing.T) {
+	var tokens []string
+	botAPI := botAPIFunc(func(req *http.Request) (*http.Response, error) {
+		token := strings.TrimPrefix(strings.Split(req.URL.Path, "/")[1], "bot")
+		tokens = append(tokens, token)
+		if token == "token-a" {
+			return &http.Response{
+				StatusCode: http.StatusForbidden,
+				Body:       io.NopCloser(strings.NewReader(`{"ok":false,"error_code":403,"description":"Forbidden: bot was kicked"}`)),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"ok":true,"result":{"message_id":1,"chat":{"id":123}}}`)),
+		}, nil
+	})
+
+	errChan := make(chan error, 10)
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{
+					FailoverThreshold: 2,
+					DeadLetter:        config.DeadLetter{Enabled: true},
+					Bots: map[string]config.TelegramBot{
+						"alerts": {
+							Token:        "token-a",
+							BackupTokens: []string{"token-b"},
+							ChatIDs:      []string{"123"},
+							AppIDs:       []uint32{1},
+						},
+					},
+				},
+			},
+		},
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: errChan, Logger: &logger, HTTPClient: botAPI}),
+	}
+	p.setStore(storage.NewMemory())
+
+	job := func(id uint32) sendJob {
+		return sendJob{
+			msg:        api.Message{Id: id, AppID: 1, Message: "disk full"},
+			token:      "token-a",
+			chatID:     "123",
+			formatOpts: config.MessageFormatOptions{ParseMode: telegram.ParseModeHTML},
+		}
+	}
+
+	require.Error(t, p.deliver(job(1)))
+	assert.Equal(t, []string{"token-a"}, tokens)
+
+	// the error reaching the threshold fails the chat over and its message is sent with the backup token
+	require.NoError(t, p.deliver(job(2)))
+	assert.Equal(t, []string{"token-a", "token-a", "token-b"}, tokens)
+	assert.Equal(t, "token-b", p.failover.resolve("token-a", "123"))
+
+	letters, err := p.listDeadLetters()
+	require.NoError(t, err)
+	require.Len(t, letters, 1, "only the message sent before the failover is a dead letter")
+	assert.Equal(t, uint32(1), letters[0].Message.Id)
+
+	// the errors handled by the send queue don't count twice when they reach the error channel
+	require.Len(t, errChan, 2)
+	for len(errChan) > 0 {
+		p.handleError(<-errChan)
+		assert.Empty(t, p.failover.failures)
+	}
+	assert.Equal(t, "token-b", p.failover.resolve("token-a", "123"))
+	assert.False(t, p.chatHealth.isUnhealthy("123"))
+}
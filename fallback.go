@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+)
+
+// sendToFallback forwards a message that can't be delivered to the chat to the fallback chat of its route, so
+// that it isn't lost silently. Messages are forwarded to the fallback chat once, and not if the fallback chat
+// itself fails or is unhealthy
+func (p *Plugin) sendToFallback(msg api.Message, chatID, reason string) {
+	if p.config == nil {
+		return
+	}
+
+	fallback := p.getTelegramBotConfigForAppID(msg.AppID).FallbackChatID
+	if fallback == "" || fallback == chatID || p.chatHealth.isUnhealthy(fallback) {
+		return
+	}
+	if !p.claimDelivery(msg, fallback) {
+		return
+	}
+
+	p.logger.Warn().
+		Uint32("message_id", msg.Id).
+		Str("chat_id", chatID).
+		Str("fallback_chat_id", fallback).
+		Str("reason", reason).
+		Msg("message can't be delivered to chat. Forwarding it to the fallback chat")
+	p.enqueue(p.routeJob(msg, fallback))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_deliver_Fallback(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			Bots: map[string]config.TelegramBot{
+				"alerts": {ChatIDs: []string{"123", "456"}, FallbackChatID: "789", AppIDs: []uint32{1}},
+			},
+		}}},
+		// sends fail right away without a bot token
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: make(chan error, 10), Logger: &logger}),
+	}
+	p.setStore(storage.NewMemory())
+
+	msg := api.Message{Id: 1, AppID: 1, Title: "disk full"}
+	assert.Error(t, p.deliver(sendJob{msg: msg, chatID: "123"}))
+	assert.Error(t, p.deliver(sendJob{msg: msg, chatID: "456"}))
+	assert.Equal(t, 1, p.backlog(), "the message is forwarded to the fallback chat once")
+
+	job, ok := p.sendQueue.pop(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, "789", job.chatID)
+	assert.Equal(t, "disk full", job.msg.Title)
+
+	// messages that fail in the fallback chat aren't forwarded again
+	assert.Error(t, p.deliver(job))
+	assert.Equal(t, 1, p.backlog())
+}
+
+func TestPlugin_handleMessage_FallbackForUnhealthyChat(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			DefaultBotToken:       "token",
+			DefaultChatIDs:        []string{"123"},
+			DefaultFallbackChatID: "789",
+		}}},
+	}
+	p.chatHealth.recordForbidden("123", "blocked", time.Now(), 1)
+
+	p.handleMessage(api.Message{Id: 1, AppID: 7})
+	job, ok := p.sendQueue.pop(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, "789", job.chatID)
+	assert.Equal(t, "token", job.token)
+
+	// nothing is forwarded to an unhealthy fallback chat
+	p.chatHealth.recordForbidden("789", "blocked", time.Now(), 1)
+	p.handleMessage(api.Message{Id: 2, AppID: 7})
+	assert.Equal(t, 1, p.backlog())
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+)
+
+const (
+	// eventChatBlocked is emitted when a chat is marked unhealthy after repeated 403 responses
+	eventChatBlocked metaEvent = "chat_blocked"
+	// eventChatQuarantined is emitted when a chat is quarantined after failing persistently
+	eventChatQuarantined metaEvent = "chat_quarantined"
+)
+
+// chatState is the delivery health of a single chat
+type chatState struct {
+	// consecutive 403 responses
+	forbidden int
+	// times of consecutive failures within the quarantine window
+	failures  []time.Time
+	unhealthy bool
+	reason    string
+	since     time.Time
+}
+
+// unhealthyChat describes a chat that messages are no longer sent to
+type unhealthyChat struct {
+	ChatID string
+	Reason string
+	Since  time.Time
+}
+
+// chatHealth tracks chats that can no longer receive messages, e.g. because
+// the bot was blocked by the user or kicked from the group
+type chatHealth struct {
+	mu    sync.Mutex
+	chats map[string]*chatState
+}
+
+// state returns the state of the chat. Callers must hold the lock
+func (h *chatHealth) state(chatID string) *chatState {
+	if h.chats == nil {
+		h.chats = make(map[string]*chatState)
+	}
+
+	state, ok := h.chats[chatID]
+	if !ok {
+		state = &chatState{}
+		h.chats[chatID] = state
+	}
+
+	return state
+}
+
+// recordForbidden records a 403 response for the chat. Returns true if the
+// chat became unhealthy because the threshold of consecutive 403 responses was reached
+func (h *chatHealth) recordForbidden(chatID string, reason string, now time.Time, threshold int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := h.state(chatID)
+	state.forbidden++
+	if threshold <= 0 || state.unhealthy || state.forbidden < threshold {
+		return false
+	}
+
+	state.unhealthy = true
+	state.reason = reason
+	state.since = now
+	return true
+}
+
+// recordFailure records a failed send to the chat. Returns true if the chat was quarantined
+// because it failed more than threshold times in a row within the window
+func (h *chatHealth) recordFailure(chatID string, reason string, now time.Time, threshold int, window time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := h.state(chatID)
+	state.failures = append(state.failures, now)
+
+	// Drop failures that fell out of the window
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(state.failures) && state.failures[i].Before(cutoff) {
+		i++
+	}
+	state.failures = state.failures[i:]
+
+	if threshold <= 0 || state.unhealthy || len(state.failures) <= threshold {
+		return false
+	}
+
+	state.unhealthy = true
+	state.reason = fmt.Sprintf("quarantined after %d failures: %s", len(state.failures), reason)
+	state.since = now
+	return true
+}
+
+// release marks the chat as healthy again. Returns false if the chat was not unhealthy
+func (h *chatHealth) release(chatID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.chats[chatID]
+	if !ok || !state.unhealthy {
+		return false
+	}
+
+	delete(h.chats, chatID)
+	return true
+}
+
+// recordSuccess resets the failure counters of the chat
+func (h *chatHealth) recordSuccess(chatID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if state, ok := h.chats[chatID]; ok && !state.unhealthy {
+		delete(h.chats, chatID)
+	}
+}
+
+// isUnhealthy returns true if messages should no longer be sent to the chat
+func (h *chatHealth) isUnhealthy(chatID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.chats[chatID]
+	return ok && state.unhealthy
+}
+
+// unhealthy returns the unhealthy chats sorted by chat ID
+func (h *chatHealth) unhealthy() []unhealthyChat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var chats []unhealthyChat
+	for chatID, state := range h.chats {
+		if state.unhealthy {
+			chats = append(chats, unhealthyChat{ChatID: chatID, Reason: state.reason, Since: state.since})
+		}
+	}
+
+	sort.Slice(chats, func(i, j int) bool {
+		return chats[i].ChatID < chats[j].ChatID
+	})
+
+	return chats
+}
+
+// reset marks all chats as healthy again
+func (h *chatHealth) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.chats = nil
+}
+
+// handleSendError updates the health of the chat a message could not be sent to
+func (p *Plugin) handleSendError(sendErr *telegram.SendError) {
+	if p.config == nil || sendErr.ChatID == "" {
+		return
+	}
+
+	var apiErr *telegram.APIError
+	if errors.As(sendErr, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+		p.handleForbidden(sendErr.ChatID, apiErr)
+	}
+
+	quarantine := p.config.Settings.Telegram.Quarantine
+	window := time.Duration(quarantine.WindowMinutes) * time.Minute
+	if !p.chatHealth.recordFailure(sendErr.ChatID, sendErr.Error(), time.Now(), quarantine.FailureThreshold, window) {
+		return
+	}
+
+	p.logger.Warn().
+		Str("chat_id", sendErr.ChatID).
+		Err(sendErr).
+		Msg("chat quarantined. Messages are no longer sent to it")
+
+	text := fmt.Sprintf(
+		"⚠️ gotify-to-telegram quarantined chat %s after more than %d failures within %d minutes. Last error: %s. "+
+			"Release it from the status page of the plugin once the problem is fixed.",
+		sendErr.ChatID, quarantine.FailureThreshold, quarantine.WindowMinutes, sendErr.Error(),
+	)
+	p.notifyAdmin(text)
+	p.notifyGotify(eventChatQuarantined, "Telegram chat quarantined", text)
+}
+
+// handleForbidden marks the chat unhealthy after repeated 403 responses
+func (p *Plugin) handleForbidden(chatID string, apiErr *telegram.APIError) {
+	threshold := p.config.Settings.Telegram.BlockedChatThreshold
+	if !p.chatHealth.recordForbidden(chatID, apiErr.Description, time.Now(), threshold) {
+		return
+	}
+
+	p.logger.Warn().
+		Str("chat_id", chatID).
+		Str("reason", apiErr.Description).
+		Msg("chat marked unhealthy. Messages are no longer sent to it")
+
+	text := fmt.Sprintf(
+		"⚠️ gotify-to-telegram stopped sending messages to chat %s after %d consecutive 403 responses: %s. "+
+			"Re-add the bot to the chat and release it from the status page of the plugin to resume.",
+		chatID, threshold, apiErr.Description,
+	)
+	p.notifyAdmin(text)
+	p.notifyGotify(eventChatBlocked, "Telegram chat blocked the bot", text)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/gin-gonic/gin"
+	"github.com/gotify/plugin-api"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatHealth_recordForbidden(t *testing.T) {
+	var h chatHealth
+	now := time.Now()
+
+	assert.False(t, h.recordForbidden("123", "Forbidden: bot was blocked by the user", now, 3))
+	assert.False(t, h.recordForbidden("123", "Forbidden: bot was blocked by the user", now, 3))
+	assert.False(t, h.isUnhealthy("123"))
+
+	// a successful send resets the consecutive 403 responses
+	h.recordSuccess("123")
+	assert.False(t, h.recordForbidden("123", "Forbidden: bot was blocked by the user", now, 3))
+	assert.False(t, h.recordForbidden("123", "Forbidden: bot was blocked by the user", now, 3))
+	assert.True(t, h.recordForbidden("123", "Forbidden: bot was blocked by the user", now, 3))
+	assert.True(t, h.isUnhealthy("123"))
+
+	// only the first 403 above the threshold marks the chat unhealthy
+	assert.False(t, h.recordForbidden("123", "Forbidden: bot was blocked by the user", now, 3))
+	h.recordSuccess("123")
+	assert.True(t, h.isUnhealthy("123"))
+
+	assert.Equal(t, []unhealthyChat{
+		{ChatID: "123", Reason: "Forbidden: bot was blocked by the user", Since: now},
+	}, h.unhealthy())
+
+	h.reset()
+	assert.False(t, h.isUnhealthy("123"))
+	assert.Empty(t, h.unhealthy())
+
+	// a threshold of 0 disables it
+	for i := 0; i < 10; i++ {
+		assert.False(t, h.recordForbidden("456", "Forbidden: bot was kicked from the group chat", now, 0))
+	}
+	assert.False(t, h.isUnhealthy("456"))
+}
+
+func TestPlugin_handleError_BlockedChat(t *testing.T) {
+	handler := new(MockMessageHandler)
+	handler.On("SendMessage", mock.MatchedBy(func(msg plugin.Message) bool {
+		return msg.Title == "Telegram chat blocked the bot"
+	})).Return(nil).Once()
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger:     &logger,
+		msgHandler: handler,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				MetaNotifications: config.MetaNotifications{Enabled: true},
+				Telegram:          config.Telegram{BlockedChatThreshold: 2},
+			},
+		},
+	}
+
+	sendErr := &telegram.SendError{
+		ChatID: "-100123",
+		Err: &telegram.APIError{
+			StatusCode:  http.StatusForbidden,
+			Description: "Forbidden: bot was kicked from the group chat",
+		},
+	}
+	for i := 0; i < 3; i++ {
+		p.handleError(sendErr)
+	}
+
+	assert.True(t, p.chatHealth.isUnhealthy("-100123"))
+	handler.AssertExpectations(t)
+
+	status, err := p.renderStatus(nil, time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, status, "## Unhealthy chats")
+	assert.Contains(t, status, "Forbidden: bot was kicked from the group chat |")
+}
+
+func TestChatHealth_recordFailure(t *testing.T) {
+	var h chatHealth
+	start := time.Now()
+	window := time.Hour
+
+	for i := 0; i < 3; i++ {
+		assert.False(t, h.recordFailure("123", "timeout", start.Add(time.Duration(i)*time.Minute), 3, window))
+	}
+
+	// failures outside the window are not counted
+	assert.False(t, h.recordFailure("123", "timeout", start.Add(2*time.Hour), 3, window))
+	assert.False(t, h.isUnhealthy("123"))
+
+	for i := 1; i <= 3; i++ {
+		quarantined := h.recordFailure("123", "timeout", start.Add(2*time.Hour+time.Duration(i)*time.Minute), 3, window)
+		assert.Equal(t, i == 3, quarantined)
+	}
+	assert.True(t, h.isUnhealthy("123"))
+	assert.Equal(t, "quarantined after 4 failures: timeout", h.unhealthy()[0].Reason)
+
+	assert.True(t, h.release("123"))
+	assert.False(t, h.isUnhealthy("123"))
+	assert.False(t, h.release("123"))
+
+	// a threshold of 0 disables the quarantine
+	for i := 0; i < 10; i++ {
+		assert.False(t, h.recordFailure("456", "timeout", start, 0, window))
+	}
+}
+
+func TestPlugin_handleReleaseChat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger}
+	p.chatHealth.recordFailure("-100123", "timeout", time.Now(), 0, time.Hour)
+	p.chatHealth.recordForbidden("-100123", "Forbidden: bot was blocked by the user", time.Now(), 1)
+
+	router := gin.New()
+	p.RegisterWebhook("/plugin/1/custom/token/", router.Group("/plugin/1/custom/token/"))
+
+	status, err := p.renderStatus(&url.URL{Scheme: "https", Host: "gotify.example.com"}, time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, status, "`https://gotify.example.com/plugin/1/custom/token/chats/-100123/release`")
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/plugin/1/custom/token/chats/-100123/release", nil))
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.False(t, p.chatHealth.isUnhealthy("-100123"))
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/plugin/1/custom/token/chats/-100123/release", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
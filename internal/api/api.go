@@ -9,9 +9,12 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/chaos"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/logger"
 	"github.com/gorilla/websocket"
@@ -29,6 +32,10 @@ type Message struct {
 	Priority       uint32
 	Extras         map[string]interface{}
 	Date           time.Time
+	// ReceivedAt is the time the message was received from the websocket
+	ReceivedAt time.Time `json:"-"`
+	// AppImage is the path of the image of the app relative to the gotify server URL
+	AppImage string
 }
 
 type Application struct {
@@ -55,6 +62,10 @@ type Client struct {
 	mu               sync.Mutex
 	isConnected      bool
 	handshakeTimeout int
+	onAppsRefresh    func([]Application)
+	onReconnect      func()
+	connections      int
+	chaos            *chaos.Injector
 }
 
 type Config struct {
@@ -63,6 +74,15 @@ type Config struct {
 	HandshakeTimeout int
 	Messages         chan<- Message
 	ErrChan          chan<- error
+	// Logger is the parent logger of the client. Defaults to the package logger
+	Logger *zerolog.Logger
+	// OnApplicationsRefresh is called with the full list of applications
+	// every time they are fetched from the gotify server
+	OnApplicationsRefresh func([]Application)
+	// OnReconnect is called every time the websocket connection is re-established
+	OnReconnect func()
+	// Chaos injects websocket drops and slow reads for testing. Defaults to no faults
+	Chaos *chaos.Injector
 }
 
 // NewClient creates a new gotify API client
@@ -76,13 +96,16 @@ func NewClient(ctx context.Context, c Config) *Client {
 	}
 
 	return &Client{
-		serverURL:   c.Url,
-		clientToken: c.ClientToken,
-		logger:      logger.WithComponent("api"),
-		messages:    c.Messages,
-		errChan:     c.ErrChan,
-		cache:       cache,
-		ctx:         ctx,
+		serverURL:     c.Url,
+		clientToken:   c.ClientToken,
+		logger:        logger.WithComponent(c.Logger, "api"),
+		messages:      c.Messages,
+		errChan:       c.ErrChan,
+		cache:         cache,
+		ctx:           ctx,
+		onAppsRefresh: c.OnApplicationsRefresh,
+		onReconnect:   c.OnReconnect,
+		chaos:         c.Chaos,
 	}
 }
 
@@ -104,11 +127,12 @@ func (c *Client) connect() error {
 		return errors.New("gotify client token is not set")
 	}
 
-	protocol := "ws://"
+	streamURL := c.endpointURL("stream", url.Values{"token": {c.clientToken}})
+	streamURL.Scheme = "ws"
 	if c.serverURL.Scheme == "https" {
-		protocol = "wss://"
+		streamURL.Scheme = "wss"
 	}
-	endpoint := protocol + c.serverURL.Host + "/stream?token=" + c.clientToken
+	endpoint := streamURL.String()
 
 	dialer := websocket.Dialer{
 		HandshakeTimeout: time.Duration(c.handshakeTimeout) * time.Second,
@@ -123,8 +147,9 @@ func (c *Client) connect() error {
 	c.isConnected = true
 
 	c.logger.Info().
-		Str("protocol", protocol).
+		Str("protocol", streamURL.Scheme).
 		Str("host", c.serverURL.Host).
+		Str("path", c.serverURL.Path).
 		Msg("connected to gotify server")
 
 	return nil
@@ -160,6 +185,11 @@ func (c *Client) Start() {
 				}
 			}
 
+			c.connections++
+			if c.connections > 1 && c.onReconnect != nil {
+				c.onReconnect()
+			}
+
 			// Start message reading
 			if err := c.readMessages(); err != nil {
 				if !errors.Is(err, context.Canceled) {
@@ -175,6 +205,14 @@ func (c *Client) Start() {
 	}
 }
 
+// IsConnected returns true if the websocket connection to the gotify server is established
+func (c *Client) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.isConnected
+}
+
 // Close closes the gotify websocket connection
 func (c *Client) Close() error {
 	c.mu.Lock()
@@ -222,6 +260,18 @@ func (c *Client) readMessages() error {
 				errChan <- err
 				return
 			}
+			msg.ReceivedAt = time.Now()
+
+			if delay := c.chaos.SlowRead(); delay > 0 {
+				c.logger.Warn().Dur("delay", delay).Msg("chaos testing: slowing down websocket read")
+				time.Sleep(delay)
+			}
+			if c.chaos.Drop() {
+				// closing the connection makes the next read fail like a dropped connection
+				c.logger.Warn().Msg("chaos testing: dropping websocket connection")
+				c.conn.Close()
+			}
+
 			msgChan <- msg
 		}
 	}()
@@ -258,6 +308,7 @@ func (c *Client) processMessage(msg Message) error {
 		app := appItem.(Application)
 		msg.AppName = app.Name
 		msg.AppDescription = app.Description
+		msg.AppImage = app.Image
 	} else {
 		app, err := c.getApplicationByID(msg.AppID)
 		if err != nil {
@@ -266,6 +317,7 @@ func (c *Client) processMessage(msg Message) error {
 		c.cache.SetDefault(fmt.Sprintf("%d", msg.AppID), *app)
 		msg.AppName = app.Name
 		msg.AppDescription = app.Description
+		msg.AppImage = app.Image
 	}
 
 	select {
@@ -281,6 +333,17 @@ func (c *Client) processMessage(msg Message) error {
 	return nil
 }
 
+// endpointURL returns the URL of an endpoint of the gotify server. The path of the server URL is
+// kept so that gotify servers behind a reverse proxy on a sub-path such as /gotify work
+func (c *Client) endpointURL(endpoint string, query url.Values) *url.URL {
+	u := *c.serverURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + endpoint
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	return &u
+}
+
 // makeRequest makes a request to the gotify API and returns the raw response
 func (c *Client) makeRequest(method string, endpoint string, body *bytes.Buffer) (*http.Response, error) {
 	// Create request body if provided
@@ -306,15 +369,87 @@ func (c *Client) makeRequest(method string, endpoint string, body *bytes.Buffer)
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, err
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected status code %d from %s", res.StatusCode, req.URL.Path)
 	}
 
 	return res, nil
 }
 
+// messagesPageSize is the number of messages fetched per request. It is the maximum allowed by gotify
+const messagesPageSize = 200
+
+// pagedMessages is a page of the messages of the gotify server, ordered from the newest to the oldest
+type pagedMessages struct {
+	Messages []Message `json:"messages"`
+	Paging   struct {
+		// Since is the id to request the next page with. 0 if this is the last page
+		Since uint32 `json:"since"`
+	} `json:"paging"`
+}
+
+// MessageIDs returns the ids of the messages on the gotify server that are not older than the oldest id
+func (c *Client) MessageIDs(oldest uint32) (map[uint32]bool, error) {
+	ids := make(map[uint32]bool)
+	var since uint32
+	for {
+		query := url.Values{"limit": {strconv.Itoa(messagesPageSize)}, "token": {c.clientToken}}
+		if since > 0 {
+			query.Set("since", strconv.FormatUint(uint64(since), 10))
+		}
+		endpoint := c.endpointURL("message", query).String()
+
+		res, err := c.makeRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page pagedMessages
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode messages: %w", err)
+		}
+
+		for _, msg := range page.Messages {
+			ids[msg.Id] = true
+		}
+
+		if page.Paging.Since == 0 || len(page.Messages) == 0 || page.Messages[len(page.Messages)-1].Id <= oldest {
+			return ids, nil
+		}
+		since = page.Paging.Since
+	}
+}
+
+// AppImage downloads the image of an application from the gotify server. The path is the image path of the
+// application relative to the server URL. Images larger than the maximum size are rejected
+func (c *Client) AppImage(path string, maxSize int64) ([]byte, error) {
+	endpoint := c.endpointURL(strings.TrimLeft(path, "/"), url.Values{"token": {c.clientToken}}).String()
+
+	res, err := c.makeRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.ContentLength > maxSize {
+		return nil, fmt.Errorf("app image of %d bytes exceeds the maximum size of %d bytes", res.ContentLength, maxSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app image: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("app image exceeds the maximum size of %d bytes", maxSize)
+	}
+
+	return data, nil
+}
+
 // getApplications returns a list of applications
 func (c *Client) getApplications() ([]Application, error) {
-	endpoint := c.serverURL.String() + "/application?token=" + c.clientToken
+	endpoint := c.endpointURL("application", url.Values{"token": {c.clientToken}}).String()
 
 	res, err := c.makeRequest("GET", endpoint, nil)
 	if err != nil {
@@ -331,9 +466,27 @@ func (c *Client) getApplications() ([]Application, error) {
 	return applications, nil
 }
 
+// RefreshApplications fetches all applications from the gotify server and updates the application cache
+func (c *Client) RefreshApplications() ([]Application, error) {
+	applications, err := c.getApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, application := range applications {
+		c.cache.SetDefault(fmt.Sprintf("%d", application.ID), application)
+	}
+
+	if c.onAppsRefresh != nil {
+		c.onAppsRefresh(applications)
+	}
+
+	return applications, nil
+}
+
 // getApplicationByID returns an application by id
 func (c *Client) getApplicationByID(id uint32) (*Application, error) {
-	applications, err := c.getApplications()
+	applications, err := c.RefreshApplications()
 	if err != nil {
 		return nil, err
 	}
@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/chaos"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,6 +23,7 @@ var mockApps = []Application{
 		Token:       "test-token",
 		Name:        "Test App",
 		Description: "Test Description",
+		Image:       "image/test-app.png",
 	},
 	{
 		ID:          2,
@@ -29,6 +33,9 @@ var mockApps = []Application{
 	},
 }
 
+// mockMessageIDs are the ids of the messages on the test server from the newest to the oldest
+var mockMessageIDs = []uint32{9, 7, 5, 3, 1}
+
 func setupTestServer(t *testing.T) (*httptest.Server, *websocket.Upgrader) {
 	upgrader := &websocket.Upgrader{
 		ReadBufferSize:  1024,
@@ -60,6 +67,25 @@ func setupTestServer(t *testing.T) (*httptest.Server, *websocket.Upgrader) {
 			// Return mock applications
 			json.NewEncoder(w).Encode(mockApps)
 
+		case "/message":
+			// Return the mock messages in pages of two
+			since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 32)
+			var page []Message
+			for _, id := range mockMessageIDs {
+				if since == 0 || uint64(id) < since {
+					page = append(page, Message{Id: id})
+				}
+			}
+			next := uint32(0)
+			if len(page) > 2 {
+				page = page[:2]
+				next = page[1].Id
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"messages": page,
+				"paging":   map[string]interface{}{"since": next},
+			})
+
 		default:
 			http.NotFound(w, r)
 		}
@@ -204,6 +230,7 @@ func TestClientStruct_processMessage(t *testing.T) {
 		assert.Equal(t, msg.Id, receivedMsg.Id)
 		assert.Equal(t, "Test App", receivedMsg.AppName)
 		assert.Equal(t, "Test Description", receivedMsg.AppDescription)
+		assert.Equal(t, "image/test-app.png", receivedMsg.AppImage)
 	case <-time.After(time.Second):
 		t.Fatal("Timeout waiting for message")
 	}
@@ -233,6 +260,37 @@ func TestClientStruct_getApplications(t *testing.T) {
 	assert.Equal(t, mockApps, apps)
 }
 
+func TestClientStruct_MessageIDs(t *testing.T) {
+	server, _ := setupTestServer(t)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	client := NewClient(context.Background(), Config{
+		Url:         serverURL,
+		ClientToken: "test-token",
+	})
+
+	tests := []struct {
+		name     string
+		oldest   uint32
+		expected map[uint32]bool
+	}{
+		{name: "first page only", oldest: 8, expected: map[uint32]bool{9: true, 7: true}},
+		{name: "pages down to the oldest id", oldest: 4, expected: map[uint32]bool{9: true, 7: true, 5: true, 3: true}},
+		{name: "all pages", oldest: 0, expected: map[uint32]bool{9: true, 7: true, 5: true, 3: true, 1: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := client.MessageIDs(tt.oldest)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
 func TestClientStruct_getApplicationByID(t *testing.T) {
 	server, _ := setupTestServer(t)
 	defer server.Close()
@@ -283,3 +341,166 @@ func TestClientStruct_getApplicationByID(t *testing.T) {
 		})
 	}
 }
+
+func TestClientStruct_RefreshApplications(t *testing.T) {
+	server, _ := setupTestServer(t)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	var refreshed []Application
+	client := NewClient(context.Background(), Config{
+		Url:         serverURL,
+		ClientToken: "test-token",
+		Messages:    make(chan Message, 1),
+		ErrChan:     make(chan error, 1),
+		OnApplicationsRefresh: func(apps []Application) {
+			refreshed = apps
+		},
+	})
+
+	apps, err := client.RefreshApplications()
+	require.NoError(t, err)
+	assert.Equal(t, mockApps, apps)
+	assert.Equal(t, mockApps, refreshed)
+
+	cached, found := client.cache.Get("2")
+	assert.True(t, found)
+	assert.Equal(t, mockApps[1], cached)
+}
+
+func TestClientStruct_readMessages_ChaosDrop(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteJSON(Message{Id: 1, AppID: 1, Message: "test"})
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	messages := make(chan Message, 1)
+	client := NewClient(context.Background(), Config{
+		Url:         serverURL,
+		ClientToken: "valid-token",
+		Messages:    messages,
+		ErrChan:     make(chan error, 1),
+		Chaos:       chaos.New(config.Chaos{Enabled: true, WebsocketDropPercent: 100}),
+	})
+	client.cache.SetDefault("1", mockApps[0])
+	require.NoError(t, client.connect())
+
+	done := make(chan error, 1)
+	go func() { done <- client.readMessages() }()
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "test", msg.Message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+		assert.False(t, client.IsConnected())
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection was not dropped")
+	}
+}
+
+func TestClientStruct_AppImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "valid-token", r.URL.Query().Get("token"))
+		switch r.URL.Path {
+		case "/gotify/image/backup.png":
+			_, _ = w.Write([]byte("png"))
+		case "/gotify/image/large.png":
+			_, _ = w.Write([]byte("large png"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL + "/gotify/")
+	require.NoError(t, err)
+	client := NewClient(context.Background(), Config{Url: serverURL, ClientToken: "valid-token"})
+
+	data, err := client.AppImage("/image/backup.png", 4)
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(data))
+
+	_, err = client.AppImage("image/large.png", 4)
+	assert.ErrorContains(t, err, "exceeds the maximum size")
+
+	_, err = client.AppImage("image/missing.png", 4)
+	assert.Error(t, err)
+}
+
+func TestClientStruct_endpointURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverURL string
+		expected  string
+	}{
+		{name: "root", serverURL: "https://gotify.example.com", expected: "https://gotify.example.com/application?token=abc"},
+		{name: "trailing slash", serverURL: "https://gotify.example.com/", expected: "https://gotify.example.com/application?token=abc"},
+		{name: "sub-path", serverURL: "https://example.com/gotify", expected: "https://example.com/gotify/application?token=abc"},
+		{name: "sub-path with trailing slash", serverURL: "http://example.com:8080/apps/gotify/", expected: "http://example.com:8080/apps/gotify/application?token=abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverURL, err := url.Parse(tt.serverURL)
+			require.NoError(t, err)
+
+			client := NewClient(context.Background(), Config{Url: serverURL})
+			assert.Equal(t, tt.expected, client.endpointURL("application", url.Values{"token": {"abc"}}).String())
+			assert.Equal(t, tt.serverURL, client.serverURL.String())
+		})
+	}
+}
+
+func TestClientStruct_SubPath(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gotify/stream", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "valid-token", r.URL.Query().Get("token"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	})
+	mux.HandleFunc("/gotify/application", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(mockApps)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL + "/gotify/")
+	require.NoError(t, err)
+
+	client := NewClient(context.Background(), Config{
+		Url:         serverURL,
+		ClientToken: "valid-token",
+		Messages:    make(chan Message, 1),
+		ErrChan:     make(chan error, 1),
+	})
+
+	require.NoError(t, client.connect())
+	client.Close()
+
+	applications, err := client.getApplications()
+	require.NoError(t, err)
+	assert.Equal(t, mockApps, applications)
+}
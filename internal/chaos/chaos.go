@@ -0,0 +1,65 @@
+// Package chaos injects faults into the Telegram and gotify clients to exercise the retries,
+// reconnects and queues of the plugin in staging. It must never be enabled in production.
+package chaos
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// Injector randomly decides whether to inject a fault. A nil injector never injects faults
+type Injector struct {
+	mu     sync.Mutex
+	rand   *rand.Rand
+	config config.Chaos
+}
+
+// New returns an injector for the settings or nil if chaos testing is disabled
+func New(cfg config.Chaos) *Injector {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	return newInjector(cfg, time.Now().UnixNano())
+}
+
+func newInjector(cfg config.Chaos, seed int64) *Injector {
+	return &Injector{rand: rand.New(rand.NewSource(seed)), config: cfg}
+}
+
+// roll returns true with the given probability in percent
+func (i *Injector) roll(percent int) bool {
+	if i == nil || percent <= 0 {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Intn(100) < percent
+}
+
+// RateLimit returns true if a Telegram request should fail with a 429 response
+func (i *Injector) RateLimit() bool {
+	return i != nil && i.roll(i.config.TelegramRateLimitPercent)
+}
+
+// ServerError returns true if a Telegram request should fail with a 500 response
+func (i *Injector) ServerError() bool {
+	return i != nil && i.roll(i.config.TelegramServerErrorPercent)
+}
+
+// Drop returns true if the websocket connection should be dropped after reading a message
+func (i *Injector) Drop() bool {
+	return i != nil && i.roll(i.config.WebsocketDropPercent)
+}
+
+// SlowRead returns how long reading a websocket message should be delayed. 0 means no delay
+func (i *Injector) SlowRead() time.Duration {
+	if i == nil || !i.roll(i.config.SlowReadPercent) {
+		return 0
+	}
+	return time.Duration(i.config.SlowReadMs) * time.Millisecond
+}
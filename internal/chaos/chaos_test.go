@@ -0,0 +1,56 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	assert.Nil(t, New(config.Chaos{TelegramRateLimitPercent: 100}))
+	assert.NotNil(t, New(config.Chaos{Enabled: true}))
+}
+
+func TestInjector(t *testing.T) {
+	var disabled *Injector
+	assert.False(t, disabled.RateLimit())
+	assert.False(t, disabled.ServerError())
+	assert.False(t, disabled.Drop())
+	assert.Zero(t, disabled.SlowRead())
+
+	always := newInjector(config.Chaos{
+		Enabled:                    true,
+		TelegramRateLimitPercent:   100,
+		TelegramServerErrorPercent: 100,
+		WebsocketDropPercent:       100,
+		SlowReadPercent:            100,
+		SlowReadMs:                 250,
+	}, 1)
+	never := newInjector(config.Chaos{Enabled: true, SlowReadMs: 250}, 1)
+	for i := 0; i < 100; i++ {
+		assert.True(t, always.RateLimit())
+		assert.True(t, always.ServerError())
+		assert.True(t, always.Drop())
+		assert.Equal(t, 250*time.Millisecond, always.SlowRead())
+
+		assert.False(t, never.RateLimit())
+		assert.False(t, never.ServerError())
+		assert.False(t, never.Drop())
+		assert.Zero(t, never.SlowRead())
+	}
+}
+
+func TestInjector_Probability(t *testing.T) {
+	injector := newInjector(config.Chaos{Enabled: true, WebsocketDropPercent: 25}, 42)
+
+	drops := 0
+	for i := 0; i < 10000; i++ {
+		if injector.Drop() {
+			drops++
+		}
+	}
+
+	assert.InDelta(t, 2500, drops, 250)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// chatUsernameRegex matches the username of a public channel or group without the leading @
+var chatUsernameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// chatIDLinkPrefixes are stripped from chat IDs given as a t.me link
+var chatIDLinkPrefixes = []string{"https://t.me/", "http://t.me/", "t.me/"}
+
+// NormalizeChatID validates a Telegram chat ID and returns it in the format expected by the
+// Telegram API. Accepted formats are user IDs (123456789), group IDs (-123456789), supergroup
+// and channel IDs (-1001234567890) and usernames of public channels (@channel or t.me/channel)
+func NormalizeChatID(chatID string) (string, error) {
+	id := strings.TrimSpace(chatID)
+	if id == "" {
+		return "", fmt.Errorf("chat ID is empty")
+	}
+
+	for _, prefix := range chatIDLinkPrefixes {
+		if strings.HasPrefix(strings.ToLower(id), prefix) {
+			id = "@" + strings.TrimSuffix(id[len(prefix):], "/")
+			break
+		}
+	}
+
+	if strings.HasPrefix(id, "@") {
+		if !chatUsernameRegex.MatchString(id[1:]) {
+			return "", fmt.Errorf("invalid chat username %q. Usernames are 5-32 characters long, start with a letter "+
+				"and only contain letters, digits and underscores", chatID)
+		}
+		return id, nil
+	}
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid chat ID %q. Use a numeric ID such as 123456789 or -1001234567890, "+
+			"or the username of a public channel such as @mychannel", chatID)
+	}
+	if n == 0 {
+		return "", fmt.Errorf("invalid chat ID %q. Chat IDs can not be 0", chatID)
+	}
+
+	return strconv.FormatInt(n, 10), nil
+}
+
+// normalizeChatIDs normalizes the chat IDs in place. The field is used in error messages
+func normalizeChatIDs(field string, chatIDs []string) error {
+	for i, chatID := range chatIDs {
+		normalized, err := NormalizeChatID(chatID)
+		if err != nil {
+			return fmt.Errorf("%s[%d]: %w", field, i, err)
+		}
+		chatIDs[i] = normalized
+	}
+
+	return nil
+}
+
+// normalizeChatIDs normalizes the chat IDs of the telegram settings. Chat IDs of the bots are normalized by validateBots
+func (t *Telegram) normalizeChatIDs() error {
+	if err := normalizeChatIDs("settings.telegram.default_chat_ids", t.DefaultChatIDs); err != nil {
+		return err
+	}
+	if err := normalizeChatIDs("settings.telegram.admin_chat_ids", t.AdminChatIDs); err != nil {
+		return err
+	}
+	if err := normalizeChatIDs("settings.telegram.heartbeat.chat_ids", t.Heartbeat.ChatIDs); err != nil {
+		return err
+	}
+	if t.DefaultFallbackChatID != "" {
+		fallback, err := NormalizeChatID(t.DefaultFallbackChatID)
+		if err != nil {
+			return fmt.Errorf("settings.telegram.default_fallback_chat_id: %w", err)
+		}
+		t.DefaultFallbackChatID = fallback
+	}
+
+	return nil
+}
+
+// validateChatLanguages validates the chat languages and normalizes their chat IDs
+func (t *Telegram) validateChatLanguages() error {
+	if len(t.ChatLanguages) == 0 {
+		return nil
+	}
+
+	languages := make(map[string]string, len(t.ChatLanguages))
+	for chatID, language := range t.ChatLanguages {
+		normalized, err := NormalizeChatID(chatID)
+		if err != nil {
+			return fmt.Errorf("settings.telegram.chat_languages: %w", err)
+		}
+		if err := validateLanguage(language); err != nil {
+			return fmt.Errorf("settings.telegram.chat_languages.%s: %w", chatID, err)
+		}
+		languages[normalized] = strings.ToLower(language)
+	}
+	t.ChatLanguages = languages
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeChatID(t *testing.T) {
+	tests := []struct {
+		name      string
+		chatID    string
+		expected  string
+		wantError string
+	}{
+		{name: "user ID", chatID: "123456789", expected: "123456789"},
+		{name: "group ID", chatID: "-123456789", expected: "-123456789"},
+		{name: "supergroup ID", chatID: " -1001234567890 ", expected: "-1001234567890"},
+		{name: "leading plus", chatID: "+123456789", expected: "123456789"},
+		{name: "channel username", chatID: "@my_channel", expected: "@my_channel"},
+		{name: "t.me link", chatID: "https://t.me/my_channel/", expected: "@my_channel"},
+		{name: "empty", chatID: " ", wantError: "chat ID is empty"},
+		{name: "zero", chatID: "0", wantError: `invalid chat ID "0". Chat IDs can not be 0`},
+		{
+			name:      "username without @",
+			chatID:    "my_channel",
+			wantError: `invalid chat ID "my_channel". Use a numeric ID such as 123456789 or -1001234567890, or the username of a public channel such as @mychannel`,
+		},
+		{
+			name:      "short username",
+			chatID:    "@abc",
+			wantError: `invalid chat username "@abc". Usernames are 5-32 characters long, start with a letter and only contain letters, digits and underscores`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatID, err := NormalizeChatID(tt.chatID)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, chatID)
+		})
+	}
+}
+
+func TestPlugin_Validate_NormalizesChatIDs(t *testing.T) {
+	cfg := &Plugin{
+		Settings: Settings{
+			Telegram: Telegram{
+				DefaultBotToken:       "token",
+				DefaultChatIDs:        []string{" 123", "t.me/alerts"},
+				AdminChatIDs:          []string{"-1001234567890"},
+				DefaultFallbackChatID: "+789",
+				Bots: map[string]TelegramBot{
+					"backups": {Token: "backups-token", ChatIDs: []string{"+456"}, FallbackChatID: " 789"},
+				},
+			},
+			GotifyServer: GotifyServer{
+				RawUrl:      "http://valid.com",
+				ClientToken: "client-token",
+			},
+		},
+	}
+
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"123", "@alerts"}, cfg.Settings.Telegram.DefaultChatIDs)
+	assert.Equal(t, []string{"-1001234567890"}, cfg.Settings.Telegram.AdminChatIDs)
+	assert.Equal(t, []string{"456"}, cfg.Settings.Telegram.Bots["backups"].ChatIDs)
+	assert.Equal(t, "789", cfg.Settings.Telegram.DefaultFallbackChatID)
+	assert.Equal(t, "789", cfg.Settings.Telegram.Bots["backups"].FallbackChatID)
+
+	cfg.Settings.Telegram.Bots["backups"] = TelegramBot{Token: "backups-token", ChatIDs: []string{"456", "backups"}}
+	assert.EqualError(t, cfg.Validate(), `settings.telegram.bots.backups.chat_ids[1]: invalid chat ID "backups". `+
+		`Use a numeric ID such as 123456789 or -1001234567890, or the username of a public channel such as @mychannel`)
+
+	cfg.Settings.Telegram.Bots["backups"] = TelegramBot{Token: "backups-token", ChatIDs: []string{"456"}, FallbackChatID: "0"}
+	assert.EqualError(t, cfg.Validate(), `settings.telegram.bots.backups.fallback_chat_id: invalid chat ID "0". Chat IDs can not be 0`)
+}
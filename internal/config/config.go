@@ -5,7 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	// embedded so that timezones can be loaded on hosts without a zoneinfo database
+	_ "time/tzdata"
 
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/utils"
 	"github.com/rs/zerolog"
@@ -13,6 +20,16 @@ import (
 
 const DefaultURL = "http://localhost:80"
 
+// Message format presets
+const (
+	// PresetMinimal only includes the message title and body
+	PresetMinimal = "minimal"
+	// PresetStandard includes the app name and timestamp
+	PresetStandard = "standard"
+	// PresetVerbose includes the app name, extras, priority and timestamp
+	PresetVerbose = "verbose"
+)
+
 // Settings represents global plugin settings
 type Settings struct {
 	// Ignores env variables when true
@@ -20,37 +37,432 @@ type Settings struct {
 	// Log options
 	LogOptions LogOptions `yaml:"log_options"`
 	// Gotify server settings
-	GotifyServer GotifyServer `yaml:"gotify_server"`
+	GotifyServer GotifyServer `yaml:"gotify_server" required:"true"`
 	// Telegram settings
-	Telegram Telegram `yaml:"telegram"`
+	Telegram Telegram `yaml:"telegram" required:"true"`
+	// Notifications about the plugin's own operational events posted into gotify
+	MetaNotifications MetaNotifications `yaml:"meta_notifications"`
+	// Fault injection for testing. Never enable it in production
+	Chaos Chaos `yaml:"chaos,omitempty"`
+}
+
+// Chaos settings. Randomly injects Telegram errors, websocket drops and slow reads to exercise
+// the retries, reconnects and queues in staging. Probabilities are in percent
+type Chaos struct {
+	// Whether to inject faults
+	Enabled bool `yaml:"enabled" env:"TG_PLUGIN__CHAOS_ENABLED"`
+	// Probability of Telegram requests failing with a 429 response
+	TelegramRateLimitPercent int `yaml:"telegram_rate_limit_percent" env:"TG_PLUGIN__CHAOS_TELEGRAM_RATE_LIMIT"`
+	// Probability of Telegram requests failing with a 500 response
+	TelegramServerErrorPercent int `yaml:"telegram_server_error_percent" env:"TG_PLUGIN__CHAOS_TELEGRAM_SERVER_ERROR"`
+	// Probability of the websocket connection being dropped after a message
+	WebsocketDropPercent int `yaml:"websocket_drop_percent" env:"TG_PLUGIN__CHAOS_WEBSOCKET_DROP"`
+	// Probability of a websocket message being read slowly
+	SlowReadPercent int `yaml:"slow_read_percent" env:"TG_PLUGIN__CHAOS_SLOW_READ"`
+	// Delay of slow reads (in milliseconds)
+	SlowReadMs int `yaml:"slow_read_ms" env:"TG_PLUGIN__CHAOS_SLOW_READ_MS"`
+}
+
+// MetaNotifications settings
+type MetaNotifications struct {
+	// Whether to post operational events of the plugin into gotify
+	Enabled bool `yaml:"enabled" env:"TG_PLUGIN__META_NOTIFICATIONS_ENABLED"`
+	// Priority of the gotify messages
+	Priority int `yaml:"priority" env:"TG_PLUGIN__META_NOTIFICATIONS_PRIORITY"`
+	// Minimum interval between two notifications for the same event (in seconds)
+	MinIntervalSeconds int `yaml:"min_interval_seconds" env:"TG_PLUGIN__META_NOTIFICATIONS_MIN_INTERVAL"`
 }
 
 // Log options
 type LogOptions struct {
 	// LogLevel can be "debug", "info", "warn", "error"
-	LogLevel string `yaml:"log_level" env:"TG_PLUGIN__LOG_LEVEL"`
+	LogLevel string `yaml:"log_level" env:"TG_PLUGIN__LOG_LEVEL" enum:"debug,info,warn,error"`
+	// Format can be "console" (default) or "json"
+	Format string `yaml:"format" env:"TG_PLUGIN__LOG_FORMAT" enum:",console,json"`
+	// Output can be "stdout" (default), "stderr" or a file in the log directory
+	Output string `yaml:"output" env:"TG_PLUGIN__LOG_OUTPUT"`
+}
+
+// Extras styles
+const (
+	ExtrasStyleAuto  = "auto"
+	ExtrasStyleList  = "list"
+	ExtrasStyleTable = "table"
+)
+
+// Gotify link styles
+const (
+	GotifyLinkText   = "link"
+	GotifyLinkButton = "button"
+)
+
+// Ways of sending messages longer than Telegram's limit
+const (
+	LongMessagesSplit    = "split"
+	LongMessagesDocument = "document"
+)
+
+// Large extras styles
+const (
+	LargeExtrasInline = "inline"
+	LargeExtrasJSON   = "json"
+	LargeExtrasFile   = "file"
+)
+
+// Languages of the labels generated by the plugin
+const (
+	LanguageEnglish = "en"
+	LanguageGerman  = "de"
+	LanguageFrench  = "fr"
+	LanguageSpanish = "es"
+)
+
+// SupportedLanguages are the languages of the labels generated by the plugin
+var SupportedLanguages = []string{LanguageEnglish, LanguageGerman, LanguageFrench, LanguageSpanish}
+
+// validateLanguage returns an error if the language is not supported. An empty language is English
+func validateLanguage(language string) error {
+	if language == "" {
+		return nil
+	}
+
+	for _, supported := range SupportedLanguages {
+		if strings.EqualFold(language, supported) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown language %q. Should be one of %s", language, strings.Join(SupportedLanguages, ", "))
 }
 
 // Message formatting options
 type MessageFormatOptions struct {
+	// Preset expands to a bundle of include options (minimal, standard, verbose).
+	// When set, it overrides the include_* options below
+	Preset string `yaml:"preset" env:"TG_PLUGIN__MESSAGE_PRESET" enum:",minimal,standard,verbose"`
+	// Whether to send a single "[App] Title" line without the body, extras and timestamp. The priority
+	// emoji is put in front with include_priority
+	Compact bool `yaml:"compact" env:"TG_PLUGIN__MESSAGE_COMPACT"`
 	// Whether to include app name in message
 	IncludeAppName bool `yaml:"include_app_name" env:"TG_PLUGIN__MESSAGE_INCLUDE_APP_NAME"`
+	// Text in front of the title, e.g. an emoji and a label such as "🐳 docker"
+	TitlePrefix string `yaml:"title_prefix" env:"TG_PLUGIN__MESSAGE_TITLE_PREFIX"`
+	// Hashtags appended to the title such as "docker" or "#backups"
+	Tags []string `yaml:"tags,omitempty" env:"TG_PLUGIN__MESSAGE_TAGS"`
 	// Whether to include timestamp in message
 	IncludeTimestamp bool `yaml:"include_timestamp" env:"TG_PLUGIN__MESSAGE_INCLUDE_TIMESTAMP"`
+	// IANA timezone of the timestamp such as Europe/Berlin. Empty keeps the timezone of the gotify server
+	Timezone string `yaml:"timezone" env:"TG_PLUGIN__MESSAGE_TIMEZONE"`
+	// Go layout of the timestamp such as "2006-01-02 15:04". Empty uses RFC3339
+	TimestampFormat string `yaml:"timestamp_format" env:"TG_PLUGIN__MESSAGE_TIMESTAMP_FORMAT"`
+	// Soft maximum length of the body in characters. Longer bodies are truncated and linked to the full
+	// message in Gotify. 0 means unlimited
+	MaxLength int `yaml:"max_length" env:"TG_PLUGIN__MESSAGE_MAX_LENGTH"`
+	// How text messages longer than Telegram's limit are sent: split (several messages) or document
+	// (a message.txt file captioned with the title)
+	LongMessages string `yaml:"long_messages" env:"TG_PLUGIN__MESSAGE_LONG_MESSAGES" enum:",split,document"`
+	// Whether to hide the body of the message behind a spoiler until it's tapped. Not supported by the
+	// Markdown and none parse modes
+	Spoiler bool `yaml:"spoiler" env:"TG_PLUGIN__MESSAGE_SPOILER"`
+	// Whether to send the image of the gotify app as a photo with messages without an image
+	AppImage bool `yaml:"app_image" env:"TG_PLUGIN__MESSAGE_APP_IMAGE"`
 	// Whether to include message extras in message
 	IncludeExtras bool `yaml:"include_extras" env:"TG_PLUGIN__MESSAGE_INCLUDE_EXTRAS"`
-	// Telegram parse mode (Markdown, MarkdownV2, HTML)
-	ParseMode string `yaml:"parse_mode" env:"TG_PLUGIN__MESSAGE_PARSE_MODE"`
+	// Telegram parse mode (Markdown, MarkdownV2, HTML, none for plain text or entities for plain text with message entities)
+	ParseMode string `yaml:"parse_mode" env:"TG_PLUGIN__MESSAGE_PARSE_MODE" enum:"Markdown,MarkdownV2,HTML,none,entities"`
 	// Whether to include the message priority in the message
 	IncludePriority bool `yaml:"include_priority" env:"TG_PLUGIN__MESSAGE_INCLUDE_PRIORITY"`
 	// Whether to include the message priority above a certain level
 	PriorityThreshold int `yaml:"priority_threshold" env:"TG_PLUGIN__MESSAGE_PRIORITY_THRESHOLD"`
+	// Custom priority indicators replacing the default red, orange, yellow and green levels
+	PriorityLevels []PriorityLevel `yaml:"priority_levels,omitempty"`
+	// How extras are rendered: auto (table for flat extras, list otherwise), list or table
+	ExtrasStyle string `yaml:"extras_style" env:"TG_PLUGIN__MESSAGE_EXTRAS_STYLE" enum:",auto,list,table"`
+	// Nesting depth of extras to render. Deeper objects are replaced by an ellipsis. 0 means unlimited
+	MaxExtrasDepth int `yaml:"max_extras_depth" env:"TG_PLUGIN__MESSAGE_MAX_EXTRAS_DEPTH"`
+	// Maximum length of a single extras value. Longer values are truncated with an ellipsis. 0 means unlimited
+	MaxExtrasLength int `yaml:"max_extras_length" env:"TG_PLUGIN__MESSAGE_MAX_EXTRAS_LENGTH"`
+	// Glob patterns of the extras keys to show such as "host" or "job.*". Nested keys are joined by dots.
+	// Empty shows all keys
+	ExtrasIncludeKeys []string `yaml:"extras_include_keys,omitempty" env:"TG_PLUGIN__MESSAGE_EXTRAS_INCLUDE_KEYS"`
+	// Glob patterns of the extras keys to hide such as "client::*". Applied after the include patterns
+	ExtrasExcludeKeys []string `yaml:"extras_exclude_keys,omitempty" env:"TG_PLUGIN__MESSAGE_EXTRAS_EXCLUDE_KEYS"`
+	// How extras larger than large_extras_size are sent: inline like smaller extras, json (a JSON code block)
+	// or file (an extras.json document)
+	LargeExtras string `yaml:"large_extras" env:"TG_PLUGIN__MESSAGE_LARGE_EXTRAS" enum:",inline,json,file"`
+	// Size in bytes of the JSON encoded extras above which they are large. 0 uses 2048
+	LargeExtrasSize int `yaml:"large_extras_size" env:"TG_PLUGIN__MESSAGE_LARGE_EXTRAS_SIZE"`
+	// Find and replace rules applied to the title and body of messages before they are formatted
+	Rewrites []RewriteRule `yaml:"rewrites,omitempty"`
+	// Go text/template rendering the message. When set, it replaces the default layout and the include_* options
+	Template string `yaml:"template" env:"TG_PLUGIN__MESSAGE_TEMPLATE"`
+	// Go text/template rendered above every message, e.g. a static "[prod-gotify]" prefix
+	Header string `yaml:"header" env:"TG_PLUGIN__MESSAGE_HEADER"`
+	// Go text/template rendered below every message, e.g. a link to a dashboard
+	Footer string `yaml:"footer" env:"TG_PLUGIN__MESSAGE_FOOTER"`
+	// Language of the generated labels such as "Additional Info". Overridden by the chat languages
+	Language string `yaml:"language" env:"TG_PLUGIN__MESSAGE_LANGUAGE" enum:",en,de,fr,es"`
+	// Whether to append a footer with the hostname, environment and plugin version to the message
+	IncludeFooter bool `yaml:"include_footer" env:"TG_PLUGIN__MESSAGE_INCLUDE_FOOTER"`
+	// How Telegram renders the preview of links in text messages
+	LinkPreview LinkPreview `yaml:"link_preview"`
+	// Whether to link the message to its app in the Gotify web UI with a link below the message or an inline button
+	GotifyLink string `yaml:"gotify_link" env:"TG_PLUGIN__MESSAGE_GOTIFY_LINK" enum:",link,button"`
+	// Inline buttons shown below every message
+	Buttons []Button `yaml:"buttons,omitempty"`
+	// Whether to protect the messages from being forwarded and saved
+	ProtectContent bool `yaml:"protect_content" env:"TG_PLUGIN__MESSAGE_PROTECT_CONTENT"`
+	// Forum topic of the chats the messages are sent to. 0 sends them to the general topic
+	MessageThreadID int64 `yaml:"message_thread_id" env:"TG_PLUGIN__MESSAGE_THREAD_ID"`
+	// Go text/template rendering a key of related messages, e.g. "{{ .AppID }}-{{ .Extras.service }}". Messages
+	// with the key of a message sent to the chat in the last 24 hours edit it instead of being sent
+	CorrelationKey string `yaml:"correlation_key" env:"TG_PLUGIN__MESSAGE_CORRELATION_KEY"`
+	// Messages of an app sent within this many minutes after a message of the app that wasn't a reply
+	// reply to it. 0 disables replies
+	ReplyWindowMinutes int `yaml:"reply_window_minutes" env:"TG_PLUGIN__MESSAGE_REPLY_WINDOW"`
+	// Messages at or above this priority are pinned in the chat. 0 disables pinning
+	PinPriority int `yaml:"pin_priority" env:"TG_PLUGIN__MESSAGE_PIN_PRIORITY"`
+}
+
+// Button is an inline button opening a URL
+type Button struct {
+	// Label of the button
+	Text string `yaml:"text"`
+	// Go text/template rendering the URL, e.g. "https://grafana.example.com/d/{{ .Extras.dashboard }}"
+	URL string `yaml:"url"`
+}
+
+// Fields of messages rewrite rules apply to
+const (
+	RewriteFieldTitle   = "title"
+	RewriteFieldMessage = "message"
+)
+
+// RewriteRule replaces the text matching a regular expression in the title or body of messages
+type RewriteRule struct {
+	// Regular expression of the text to replace
+	Pattern string `yaml:"pattern"`
+	// Text replacing the matches. Supports $1 style references to capture groups. Empty removes the matches
+	Replacement string `yaml:"replacement"`
+	// Field the rule applies to: title, message or empty for both
+	Field string `yaml:"field,omitempty" enum:",title,message"`
+	// Compiled pattern
+	re *regexp.Regexp
+}
+
+// compile checks the field and compiles the pattern of the rule
+func (r *RewriteRule) compile() error {
+	switch strings.ToLower(r.Field) {
+	case "", RewriteFieldTitle, RewriteFieldMessage:
+	default:
+		return fmt.Errorf("unknown field %q. Should be %s or %s", r.Field, RewriteFieldTitle, RewriteFieldMessage)
+	}
+
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	r.re = re
+	return nil
+}
+
+// AppliesTo returns true if the rule rewrites the field
+func (r *RewriteRule) AppliesTo(field string) bool {
+	return r.Field == "" || strings.EqualFold(r.Field, field)
+}
+
+// Apply replaces the text matching the rule. Rules with an invalid pattern leave the text unchanged
+func (r *RewriteRule) Apply(text string) string {
+	re := r.re
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(r.Pattern); err != nil {
+			return text
+		}
+	}
+	return re.ReplaceAllString(text, r.Replacement)
+}
+
+// PriorityLevel is the indicator of the priorities from MinPriority up to the next level
+type PriorityLevel struct {
+	// Lowest priority of the level
+	MinPriority int `yaml:"min_priority"`
+	// Emoji shown in front of the label
+	Emoji string `yaml:"emoji"`
+	// Label of the level. The labels of the default levels are translated, custom labels are not
+	Label string `yaml:"label"`
+}
+
+// LinkPreview settings. Mirrors Telegram's link_preview_options of text messages
+type LinkPreview struct {
+	// Whether to disable link previews
+	Disabled bool `yaml:"disabled" env:"TG_PLUGIN__MESSAGE_LINK_PREVIEW_DISABLED"`
+	// URL to preview instead of the first link of the message
+	URL string `yaml:"url" env:"TG_PLUGIN__MESSAGE_LINK_PREVIEW_URL"`
+	// Whether to shrink the media of the preview
+	PreferSmallMedia bool `yaml:"prefer_small_media" env:"TG_PLUGIN__MESSAGE_LINK_PREVIEW_PREFER_SMALL_MEDIA"`
+	// Whether to enlarge the media of the preview
+	PreferLargeMedia bool `yaml:"prefer_large_media" env:"TG_PLUGIN__MESSAGE_LINK_PREVIEW_PREFER_LARGE_MEDIA"`
+	// Whether to show the preview above the message text
+	ShowAboveText bool `yaml:"show_above_text" env:"TG_PLUGIN__MESSAGE_LINK_PREVIEW_SHOW_ABOVE_TEXT"`
+}
+
+// validate checks the link preview settings
+func (l *LinkPreview) validate() error {
+	if l.PreferSmallMedia && l.PreferLargeMedia {
+		return errors.New("link_preview: prefer_small_media and prefer_large_media are mutually exclusive")
+	}
+
+	if l.URL != "" {
+		u, err := url.Parse(l.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("link_preview: invalid url %q", l.URL)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the options and applies the preset
+func (m *MessageFormatOptions) validate() error {
+	switch strings.ToLower(m.ExtrasStyle) {
+	case "", ExtrasStyleAuto, ExtrasStyleList, ExtrasStyleTable:
+	default:
+		return fmt.Errorf("unknown extras style %q. Should be one of %s, %s or %s", m.ExtrasStyle, ExtrasStyleAuto, ExtrasStyleList, ExtrasStyleTable)
+	}
+
+	if m.MaxExtrasDepth < 0 || m.MaxExtrasLength < 0 {
+		return errors.New("max_extras_depth and max_extras_length must not be negative")
+	}
+
+	if m.MaxLength < 0 {
+		return errors.New("max_length must not be negative")
+	}
+
+	if m.MessageThreadID < 0 {
+		return errors.New("message_thread_id must not be negative")
+	}
+
+	if m.ReplyWindowMinutes < 0 {
+		return errors.New("reply_window_minutes must not be negative")
+	}
+
+	if m.PinPriority < 0 {
+		return errors.New("pin_priority must not be negative")
+	}
+
+	switch strings.ToLower(m.LongMessages) {
+	case "", LongMessagesSplit, LongMessagesDocument:
+	default:
+		return fmt.Errorf("unknown long messages style %q. Should be %s or %s", m.LongMessages, LongMessagesSplit, LongMessagesDocument)
+	}
+
+	if m.Spoiler && (m.ParseMode == "Markdown" || m.ParseMode == "none") {
+		return fmt.Errorf("spoiler is not supported by the %s parse mode", m.ParseMode)
+	}
+
+	switch strings.ToLower(m.GotifyLink) {
+	case "", GotifyLinkText, GotifyLinkButton:
+	default:
+		return fmt.Errorf("unknown gotify link style %q. Should be %s or %s", m.GotifyLink, GotifyLinkText, GotifyLinkButton)
+	}
+
+	switch strings.ToLower(m.LargeExtras) {
+	case "", LargeExtrasInline, LargeExtrasJSON, LargeExtrasFile:
+	default:
+		return fmt.Errorf("unknown large extras style %q. Should be one of %s, %s or %s", m.LargeExtras, LargeExtrasInline, LargeExtrasJSON, LargeExtrasFile)
+	}
+
+	if m.LargeExtrasSize < 0 {
+		return errors.New("large_extras_size must not be negative")
+	}
+
+	for _, pattern := range append(append([]string{}, m.ExtrasIncludeKeys...), m.ExtrasExcludeKeys...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid extras key pattern %q", pattern)
+		}
+	}
+
+	if err := validateLanguage(m.Language); err != nil {
+		return err
+	}
+
+	if m.Timezone != "" {
+		if _, err := time.LoadLocation(m.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", m.Timezone)
+		}
+	}
+
+	if err := m.LinkPreview.validate(); err != nil {
+		return err
+	}
+
+	minPriorities := make(map[int]bool, len(m.PriorityLevels))
+	for i, level := range m.PriorityLevels {
+		if level.Emoji == "" && level.Label == "" {
+			return fmt.Errorf("priority_levels[%d]: emoji or label is required", i)
+		}
+		if minPriorities[level.MinPriority] {
+			return fmt.Errorf("priority_levels[%d]: duplicate min_priority %d", i, level.MinPriority)
+		}
+		minPriorities[level.MinPriority] = true
+	}
+
+	for i := range m.Rewrites {
+		if err := m.Rewrites[i].compile(); err != nil {
+			return fmt.Errorf("rewrites[%d]: %w", i, err)
+		}
+	}
+
+	for i, button := range m.Buttons {
+		if strings.TrimSpace(button.Text) == "" {
+			return fmt.Errorf("buttons[%d]: text is required", i)
+		}
+		if strings.TrimSpace(button.URL) == "" {
+			return fmt.Errorf("buttons[%d]: url is required", i)
+		}
+	}
+
+	return m.ApplyPreset()
+}
+
+// ApplyPreset sets the include options according to the configured preset
+func (m *MessageFormatOptions) ApplyPreset() error {
+	switch strings.ToLower(m.Preset) {
+	case "":
+		return nil
+	case PresetMinimal:
+		m.IncludeAppName = false
+		m.IncludeTimestamp = false
+		m.IncludeExtras = false
+		m.IncludePriority = false
+	case PresetStandard:
+		m.IncludeAppName = true
+		m.IncludeTimestamp = true
+		m.IncludeExtras = false
+		m.IncludePriority = false
+	case PresetVerbose:
+		m.IncludeAppName = true
+		m.IncludeTimestamp = true
+		m.IncludeExtras = true
+		m.IncludePriority = true
+	default:
+		return fmt.Errorf("unknown preset %q. Should be one of %s, %s or %s", m.Preset, PresetMinimal, PresetStandard, PresetVerbose)
+	}
+
+	return nil
 }
 
 // Websocket settings
 type Websocket struct {
 	// Timeout for initial connection (in seconds)
 	HandshakeTimeout int `yaml:"handshake_timeout" env:"TG_PLUGIN__WS_HANDSHAKE_TIMEOUT"`
+	// Number of reconnects within the alert window that triggers a reconnect storm alert. 0 disables the alert
+	ReconnectAlertThreshold int `yaml:"reconnect_alert_threshold" env:"TG_PLUGIN__WS_RECONNECT_ALERT_THRESHOLD"`
+	// Reconnect storm alert window (in minutes)
+	ReconnectAlertWindowMinutes int `yaml:"reconnect_alert_window_minutes" env:"TG_PLUGIN__WS_RECONNECT_ALERT_WINDOW"`
+	// Alert if no messages are received for this duration while connected (in minutes). 0 disables the watchdog
+	NoTrafficAlertMinutes int `yaml:"no_traffic_alert_minutes" env:"TG_PLUGIN__WS_NO_TRAFFIC_ALERT"`
 }
 
 // GotifyServer settings
@@ -58,9 +470,11 @@ type GotifyServer struct {
 	// Gotify server in url.URL format
 	Url *url.URL `yaml:"-"`
 	// Gotify server URL
-	RawUrl string `yaml:"url" env:"TG_PLUGIN__GOTIFY_URL" envDefault:"http://localhost:80"`
+	RawUrl string `yaml:"url" env:"TG_PLUGIN__GOTIFY_URL" envDefault:"http://localhost:80" required:"true"`
 	// Gotify client token
-	ClientToken string `yaml:"client_token" env:"TG_PLUGIN__GOTIFY_CLIENT_TOKEN" envDefault:""`
+	ClientToken string `yaml:"client_token" env:"TG_PLUGIN__GOTIFY_CLIENT_TOKEN" envDefault:"" required:"true"`
+	// URL of the Gotify web UI messages link to. Defaults to the server URL
+	WebURL string `yaml:"web_url" env:"TG_PLUGIN__GOTIFY_WEB_URL"`
 	// Websocket settings
 	Websocket Websocket `yaml:"websocket"`
 }
@@ -79,33 +493,403 @@ func (g *GotifyServer) URL() *url.URL {
 	return g.Url
 }
 
+// WebUIURL returns the URL of the Gotify web UI. It defaults to the server URL
+func (g *GotifyServer) WebUIURL() *url.URL {
+	if g.WebURL != "" {
+		if parsedURL, err := url.Parse(g.WebURL); err == nil {
+			return parsedURL
+		}
+	}
+	return g.URL()
+}
+
 // Telegram settings
 type Telegram struct {
 	// Default bot token
-	DefaultBotToken string `yaml:"default_bot_token" env:"TG_PLUGIN__TELEGRAM_DEFAULT_BOT_TOKEN" envDefault:""`
+	DefaultBotToken string `yaml:"default_bot_token" env:"TG_PLUGIN__TELEGRAM_DEFAULT_BOT_TOKEN" envDefault:"" required:"true"`
 	// Default chat ID
-	DefaultChatIDs []string `yaml:"default_chat_ids" env:"TG_PLUGIN__TELEGRAM_DEFAULT_CHAT_IDS" envDefault:""`
+	DefaultChatIDs []string `yaml:"default_chat_ids" env:"TG_PLUGIN__TELEGRAM_DEFAULT_CHAT_IDS" envDefault:"" required:"true"`
 	// Mapping of bot names to bot tokens/chat IDs
 	Bots map[string]TelegramBot `yaml:"bots"`
 	// Message formatting options
 	MessageFormatOptions MessageFormatOptions `yaml:"default_message_format_options"`
+	// Chat IDs receiving the plugin's own notifications. Defaults to the default chat IDs
+	AdminChatIDs []string `yaml:"admin_chat_ids" env:"TG_PLUGIN__TELEGRAM_ADMIN_CHAT_IDS"`
+	// Whether to notify the admin chats when the plugin starts and shuts down
+	LifecycleNotifications bool `yaml:"lifecycle_notifications" env:"TG_PLUGIN__TELEGRAM_LIFECYCLE_NOTIFICATIONS"`
+	// Periodic heartbeat message settings
+	Heartbeat Heartbeat `yaml:"heartbeat"`
+	// Timeout of a single request to the Telegram API (in seconds). 0 uses the default of 30 seconds
+	RequestTimeoutSeconds int `yaml:"request_timeout_seconds" env:"TG_PLUGIN__TELEGRAM_REQUEST_TIMEOUT"`
+	// Retry settings of failed requests to the Telegram API
+	Retry Retry `yaml:"retry"`
+	// Proxy of the requests to the Telegram API
+	Proxy Proxy `yaml:"proxy"`
+	// Rate limits of the messages sent to a chat
+	RateLimit RateLimit `yaml:"rate_limit"`
+	// Circuit breaker settings of Telegram outages
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker"`
+	// Number of workers sending messages to Telegram concurrently
+	SendWorkers int `yaml:"send_workers" env:"TG_PLUGIN__TELEGRAM_SEND_WORKERS"`
+	// Order queued messages are sent in. Defaults to fifo
+	SendOrder string `yaml:"send_order" env:"TG_PLUGIN__TELEGRAM_SEND_ORDER" enum:",fifo,priority"`
+	// Whether queued messages are persisted in the plugin storage so that they are sent after a restart
+	PersistQueue bool `yaml:"persist_queue" env:"TG_PLUGIN__TELEGRAM_PERSIST_QUEUE"`
+	// Seconds the queued messages are sent for when the plugin is disabled before it stops. 0 stops right away
+	ShutdownDrainSeconds int `yaml:"shutdown_drain_seconds" env:"TG_PLUGIN__TELEGRAM_SHUTDOWN_DRAIN_SECONDS"`
+	// Number of consecutive 403 responses after which messages are no longer sent to a chat. 0 disables it
+	BlockedChatThreshold int `yaml:"blocked_chat_threshold" env:"TG_PLUGIN__TELEGRAM_BLOCKED_CHAT_THRESHOLD"`
+	// Quarantine settings of persistently failing chats
+	Quarantine Quarantine `yaml:"quarantine"`
+	// Dead letter settings of messages that failed to send
+	DeadLetter DeadLetter `yaml:"dead_letter"`
+	// Load shedding settings for sustained backlogs
+	LoadShedding LoadShedding `yaml:"load_shedding"`
+	// Language of the generated labels per chat ID. Overrides the language of the message format options
+	ChatLanguages map[string]string `yaml:"chat_languages"`
+	// Deletion sync settings of messages deleted on the gotify server
+	DeletionSync DeletionSync `yaml:"deletion_sync"`
+	// Settings of files referenced by messages that are uploaded as documents
+	Attachments Attachments `yaml:"attachments"`
+	// Downscaling settings of photos
+	Images Images `yaml:"images"`
+	// Rules masking sensitive text in the title, body and extras of messages before they are sent
+	RedactionRules []RedactionRule `yaml:"redaction_rules"`
+	// Whether to scrub emails, phone numbers and IPv4 addresses from messages sent with the default bot
+	DefaultScrubPII bool `yaml:"default_scrub_pii" env:"TG_PLUGIN__TELEGRAM_DEFAULT_SCRUB_PII"`
+	// Bot tokens the chats of the default bot fail over to, in order, after the default bot token failed repeatedly
+	DefaultBackupTokens []string `yaml:"default_backup_tokens,omitempty" env:"TG_PLUGIN__TELEGRAM_DEFAULT_BACKUP_TOKENS"`
+	// Consecutive auth and 403 errors of a bot token in a chat after which the chat fails over to a backup token.
+	// 0 disables the failover
+	FailoverThreshold int `yaml:"failover_threshold" env:"TG_PLUGIN__TELEGRAM_FAILOVER_THRESHOLD"`
+	// Chat that messages of the default bot are forwarded to when they can't be delivered to a default chat
+	DefaultFallbackChatID string `yaml:"default_fallback_chat_id,omitempty" env:"TG_PLUGIN__TELEGRAM_FALLBACK_CHAT_ID"`
+	// Footer identifying the plugin instance in messages with include_footer
+	Footer Footer `yaml:"footer"`
+}
+
+// DefaultRedactionReplacement replaces the text matched by redaction rules without a replacement
+const DefaultRedactionReplacement = "[REDACTED]"
+
+// RedactionRule masks the text matching a regular expression
+type RedactionRule struct {
+	// Regular expression of the text to mask
+	Pattern string `yaml:"pattern"`
+	// Text replacing the matches. Supports $1 style references to capture groups. Defaults to [REDACTED]
+	Replacement string `yaml:"replacement,omitempty"`
+	// Compiled pattern
+	re *regexp.Regexp
+}
+
+// compile compiles the pattern of the rule
+func (r *RedactionRule) compile() error {
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	r.re = re
+	return nil
+}
+
+// Apply replaces the text matching the rule. Rules with an invalid pattern leave the text unchanged
+func (r *RedactionRule) Apply(text string) string {
+	re := r.re
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(r.Pattern); err != nil {
+			return text
+		}
+	}
+
+	replacement := r.Replacement
+	if replacement == "" {
+		replacement = DefaultRedactionReplacement
+	}
+	return re.ReplaceAllString(text, replacement)
+}
+
+// mustRedactionRule returns a compiled rule of a built-in pattern
+func mustRedactionRule(pattern, replacement string) RedactionRule {
+	return RedactionRule{Pattern: pattern, Replacement: replacement, re: regexp.MustCompile(pattern)}
+}
+
+// piiRedactionRules mask emails, IPv4 addresses and phone numbers. Emails and addresses are
+// masked first so that their digits are not taken for phone numbers
+var piiRedactionRules = []RedactionRule{
+	mustRedactionRule(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, "[EMAIL]"),
+	mustRedactionRule(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`, "[IP]"),
+	mustRedactionRule(`\+\d{1,3}[ .-]?(?:\(\d{1,4}\)|\d{1,4})(?:[ .-]?\d{2,4}){2,4}\b`, "[PHONE]"),
+	mustRedactionRule(`(?:\(\d{3}\) ?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`, "[PHONE]"),
+}
+
+// PIIRedactionRules returns the built-in rules scrubbing emails, phone numbers and IPv4 addresses
+func PIIRedactionRules() []RedactionRule {
+	return piiRedactionRules
+}
+
+// LanguageForChat returns the language of the chat or the fallback if the chat has no language
+func (t *Telegram) LanguageForChat(chatID, fallback string) string {
+	if language, ok := t.ChatLanguages[chatID]; ok && language != "" {
+		return language
+	}
+	return fallback
+}
+
+const (
+	// SendOrderFIFO sends queued messages in the order they were received
+	SendOrderFIFO = "fifo"
+	// SendOrderPriority sends the queued messages with the highest priority first
+	SendOrderPriority = "priority"
+)
+
+const (
+	// LoadSheddingDrop drops shed messages
+	LoadSheddingDrop = "drop"
+	// LoadSheddingDigest sends a digest of the shed messages to the admin chats
+	LoadSheddingDigest = "digest"
+)
+
+const (
+	// LoadSheddingPriorityFloor sheds new messages below the priority floor while the backlog is too deep
+	LoadSheddingPriorityFloor = "priority_floor"
+	// LoadSheddingLowestPriority sheds the queued sends with the lowest priority while too many are queued
+	LoadSheddingLowestPriority = "lowest_priority"
+	// LoadSheddingOldest sheds the oldest queued sends while too many are queued
+	LoadSheddingOldest = "oldest"
+)
+
+// LoadShedding settings. Messages are shed according to the policy while the backlog is too deep
+type LoadShedding struct {
+	// Number of queued and in-flight messages above which messages are shed. 0 disables it
+	MaxBacklog int `yaml:"max_backlog" env:"TG_PLUGIN__LOAD_SHEDDING_MAX_BACKLOG"`
+	// Which messages are shed. Defaults to priority_floor
+	Policy string `yaml:"policy" env:"TG_PLUGIN__LOAD_SHEDDING_POLICY" enum:",priority_floor,lowest_priority,oldest"`
+	// Messages with a priority below the floor are shed by the priority_floor policy
+	PriorityFloor int `yaml:"priority_floor" env:"TG_PLUGIN__LOAD_SHEDDING_PRIORITY_FLOOR"`
+	// What happens to shed messages
+	Mode string `yaml:"mode" env:"TG_PLUGIN__LOAD_SHEDDING_MODE" enum:"drop,digest"`
+}
+
+// Footer settings. The footer is appended to the messages of the bots whose format options include it
+type Footer struct {
+	// Hostname shown in the footer. Defaults to the hostname of the machine
+	Hostname string `yaml:"hostname" env:"TG_PLUGIN__FOOTER_HOSTNAME"`
+	// Environment label shown in the footer, such as production or staging
+	Environment string `yaml:"environment" env:"TG_PLUGIN__FOOTER_ENVIRONMENT"`
+}
+
+// Images settings. Downscaled photos are downloaded by the plugin and uploaded instead of being fetched by Telegram
+type Images struct {
+	// Whether to downscale photos before they are sent
+	Downscale bool `yaml:"downscale" env:"TG_PLUGIN__IMAGES_DOWNSCALE"`
+	// Maximum width and height of downscaled photos (in pixels)
+	MaxDimension int `yaml:"max_dimension" env:"TG_PLUGIN__IMAGES_MAX_DIMENSION"`
+	// JPEG quality of downscaled photos (1-100)
+	JPEGQuality int `yaml:"jpeg_quality" env:"TG_PLUGIN__IMAGES_JPEG_QUALITY"`
+	// Hosts photos are downloaded from in addition to the gotify server. Photos of other hosts are sent by URL
+	AllowedHosts []string `yaml:"allowed_hosts" env:"TG_PLUGIN__IMAGES_ALLOWED_HOSTS"`
+	// Whether the allowed hosts may resolve to private addresses, e.g. cameras on the local network
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" env:"TG_PLUGIN__IMAGES_ALLOW_PRIVATE"`
+}
+
+// maxAttachmentSizeMB is the largest file bots can upload to Telegram
+const maxAttachmentSizeMB = 50
+
+// Attachments settings. Files referenced by the telegram::attachment extra are downloaded and uploaded as documents
+type Attachments struct {
+	// Whether to upload attachments
+	Enabled bool `yaml:"enabled" env:"TG_PLUGIN__ATTACHMENTS_ENABLED"`
+	// Hosts attachments are downloaded from in addition to the gotify server
+	AllowedHosts []string `yaml:"allowed_hosts" env:"TG_PLUGIN__ATTACHMENTS_ALLOWED_HOSTS"`
+	// Whether the allowed hosts may resolve to private addresses, e.g. file servers on the local network
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" env:"TG_PLUGIN__ATTACHMENTS_ALLOW_PRIVATE"`
+	// Maximum size of an attachment (in megabytes)
+	MaxSizeMB int `yaml:"max_size_mb" env:"TG_PLUGIN__ATTACHMENTS_MAX_SIZE_MB"`
+}
+
+const (
+	// DeletionSyncDelete deletes the Telegram messages of deleted gotify messages
+	DeletionSyncDelete = "delete"
+	// DeletionSyncStrikethrough strikes the text of the Telegram messages of deleted gotify messages through
+	DeletionSyncStrikethrough = "strikethrough"
+)
+
+// DeletionSync settings. The gotify server is checked for deleted messages periodically
+type DeletionSync struct {
+	// What happens to the Telegram messages of deleted gotify messages. Empty disables it
+	Mode string `yaml:"mode" env:"TG_PLUGIN__DELETION_SYNC_MODE" enum:",delete,strikethrough"`
+	// Interval between checks for deleted messages (in minutes)
+	IntervalMinutes int `yaml:"interval_minutes" env:"TG_PLUGIN__DELETION_SYNC_INTERVAL"`
+}
+
+// Quarantine settings. Messages are no longer sent to quarantined chats until they are released
+type Quarantine struct {
+	// Number of consecutive failures within the window after which a chat is quarantined. 0 disables it
+	FailureThreshold int `yaml:"failure_threshold" env:"TG_PLUGIN__QUARANTINE_FAILURE_THRESHOLD"`
+	// Window in which failures are counted (in minutes)
+	WindowMinutes int `yaml:"window_minutes" env:"TG_PLUGIN__QUARANTINE_WINDOW"`
+}
+
+// DeadLetter settings. Messages that failed to send are kept as dead letters and retried periodically
+type DeadLetter struct {
+	// Whether to keep messages that failed to send as dead letters
+	Enabled bool `yaml:"enabled" env:"TG_PLUGIN__DEAD_LETTER_ENABLED"`
+	// Interval between retries of the dead letters (in minutes)
+	RetryIntervalMinutes int `yaml:"retry_interval_minutes" env:"TG_PLUGIN__DEAD_LETTER_RETRY_INTERVAL"`
+	// Number of failed sends after which a dead letter is no longer retried. 0 retries it until it is sent
+	MaxAttempts int `yaml:"max_attempts" env:"TG_PLUGIN__DEAD_LETTER_MAX_ATTEMPTS"`
+}
+
+// CircuitBreaker settings. Messages stay queued while the Telegram API is unavailable
+type CircuitBreaker struct {
+	// Consecutive network errors and 5xx responses after which no more requests are made. 0 disables it
+	FailureThreshold int `yaml:"failure_threshold" env:"TG_PLUGIN__CIRCUIT_BREAKER_THRESHOLD"`
+	// Time after which a single request probes whether the Telegram API is available again (in seconds)
+	OpenSeconds int `yaml:"open_seconds" env:"TG_PLUGIN__CIRCUIT_BREAKER_OPEN_SECONDS"`
+}
+
+// RateLimit settings. Messages over the limits are delayed instead of being rejected by Telegram
+type RateLimit struct {
+	// Whether to rate limit the messages sent to a chat
+	Enabled bool `yaml:"enabled" env:"TG_PLUGIN__RATE_LIMIT_ENABLED"`
+	// Messages per second sent to a chat. 0 disables the limit
+	ChatMessagesPerSecond int `yaml:"chat_messages_per_second" env:"TG_PLUGIN__RATE_LIMIT_CHAT_PER_SECOND"`
+	// Messages per minute sent to a group or channel. 0 disables the limit
+	GroupMessagesPerMinute int `yaml:"group_messages_per_minute" env:"TG_PLUGIN__RATE_LIMIT_GROUP_PER_MINUTE"`
+}
+
+// Proxy settings. Only requests to the Telegram API use the proxy, requests to the gotify server connect directly
+type Proxy struct {
+	// URL of the proxy such as http://proxy:3128 or socks5://proxy:1080. Empty disables it
+	URL string `yaml:"url" env:"TG_PLUGIN__TELEGRAM_PROXY_URL"`
+	// Username of the proxy authentication. Overrides the user of the URL
+	Username string `yaml:"username" env:"TG_PLUGIN__TELEGRAM_PROXY_USERNAME"`
+	// Password of the proxy authentication. Overrides the password of the URL
+	Password string `yaml:"password" env:"TG_PLUGIN__TELEGRAM_PROXY_PASSWORD"`
+}
+
+// proxySchemes are the proxy protocols supported by the HTTP transport
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// ProxyURL returns the URL of the proxy including the credentials or nil if no proxy is set
+func (p Proxy) ProxyURL() (*url.URL, error) {
+	if p.URL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if !slices.Contains(proxySchemes, proxyURL.Scheme) {
+		return nil, fmt.Errorf("url scheme %q is invalid. Should be one of %s", proxyURL.Scheme, strings.Join(proxySchemes, ", "))
+	}
+	if proxyURL.Host == "" {
+		return nil, errors.New("url has no host")
+	}
+
+	if p.Username != "" {
+		if p.Password != "" {
+			proxyURL.User = url.UserPassword(p.Username, p.Password)
+		} else {
+			proxyURL.User = url.User(p.Username)
+		}
+	}
+	return proxyURL, nil
+}
+
+// Retry settings. Requests rejected with 400, 401 or 403 are never retried
+type Retry struct {
+	// Retries after connection resets and timeouts
+	NetworkRetries int `yaml:"network_retries" env:"TG_PLUGIN__RETRY_NETWORK"`
+	// Retries after 5xx responses
+	ServerErrorRetries int `yaml:"server_error_retries" env:"TG_PLUGIN__RETRY_SERVER_ERROR"`
+	// Retries after 429 responses. Telegram's retry_after is respected
+	RateLimitRetries int `yaml:"rate_limit_retries" env:"TG_PLUGIN__RETRY_RATE_LIMIT"`
+	// Delay before the first retry (in milliseconds)
+	InitialBackoffMs int `yaml:"initial_backoff_ms" env:"TG_PLUGIN__RETRY_INITIAL_BACKOFF_MS"`
+	// Upper bound of the exponential backoff (in seconds)
+	MaxBackoffSeconds int `yaml:"max_backoff_seconds" env:"TG_PLUGIN__RETRY_MAX_BACKOFF"`
+	// Percentage (0-100) the backoff is randomly shortened or lengthened by
+	JitterPercent int `yaml:"jitter_percent" env:"TG_PLUGIN__RETRY_JITTER_PERCENT"`
+}
+
+// Heartbeat settings
+type Heartbeat struct {
+	// Whether to send periodic heartbeat messages
+	Enabled bool `yaml:"enabled" env:"TG_PLUGIN__HEARTBEAT_ENABLED"`
+	// Interval between heartbeat messages (in minutes)
+	IntervalMinutes int `yaml:"interval_minutes" env:"TG_PLUGIN__HEARTBEAT_INTERVAL"`
+	// Chat IDs receiving the heartbeat messages. Defaults to the admin chat IDs
+	ChatIDs []string `yaml:"chat_ids" env:"TG_PLUGIN__HEARTBEAT_CHAT_IDS"`
+}
+
+// GetAdminChatIDs returns the admin chat IDs or the default chat IDs if none are set
+func (t *Telegram) GetAdminChatIDs() []string {
+	if len(t.AdminChatIDs) > 0 {
+		return t.AdminChatIDs
+	}
+	return t.DefaultChatIDs
 }
 
 // TelegramBot settings
 type TelegramBot struct {
 	// Bot token
 	Token string `yaml:"token"`
+	// Additional bot tokens for the same chats. Messages are sent with the tokens in turn to spread the rate limits
+	Tokens []string `yaml:"tokens,omitempty"`
+	// Bot tokens a chat fails over to, in order, after the bot token used for it failed repeatedly
+	BackupTokens []string `yaml:"backup_tokens,omitempty"`
 	// Chat IDs
 	ChatIDs []string `yaml:"chat_ids"`
+	// Chat that messages are forwarded to when they can't be delivered to one of the chat IDs, e.g. an admin DM
+	FallbackChatID string `yaml:"fallback_chat_id,omitempty"`
 	// Gotify app ids
-	AppIDs []uint32 `yaml:"gotify_app_ids"`
+	AppIDs []uint32 `yaml:"gotify_app_ids,omitempty"`
+	// Gotify app names. Resolved to app ids using the applications on the gotify server
+	AppNames []string `yaml:"gotify_app_names,omitempty"`
 	// Bot message formatting options
-	MessageFormatOptions *MessageFormatOptions `yaml:"message_format_options"`
+	MessageFormatOptions *MessageFormatOptions `yaml:"message_format_options,omitempty"`
+	// Per-app message formatting options keyed by gotify app id or app name
+	AppMessageFormatOptions map[string]*MessageFormatOptions `yaml:"app_message_format_options,omitempty"`
+	// Whether to scrub emails, phone numbers and IPv4 addresses from the messages
+	ScrubPII bool `yaml:"scrub_pii,omitempty"`
+}
+
+// GetTokens returns the bot token followed by the additional tokens without empty and duplicate tokens
+func (t *TelegramBot) GetTokens() []string {
+	tokens := make([]string, 0, len(t.Tokens)+1)
+	seen := make(map[string]bool, len(t.Tokens)+1)
+	for _, token := range append([]string{t.Token}, t.Tokens...) {
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+// FormatOptionsForApp returns the per-app message formatting options for the given app
+// or the bot message formatting options if no override exists
+func (t *TelegramBot) FormatOptionsForApp(appID uint32, appName string) *MessageFormatOptions {
+	if opts, ok := t.AppMessageFormatOptions[strconv.FormatUint(uint64(appID), 10)]; ok && opts != nil {
+		return opts
+	}
+
+	for key, opts := range t.AppMessageFormatOptions {
+		if opts != nil && appName != "" && strings.EqualFold(key, appName) {
+			return opts
+		}
+	}
+
+	return t.MessageFormatOptions
 }
 
 // Plugin settings
 type Plugin struct {
-	Settings Settings `yaml:"settings"`
+	Settings Settings `yaml:"settings" required:"true"`
 }
 
 // Validate validates that required fields are set and valid
@@ -114,6 +898,17 @@ func (p *Plugin) Validate() error {
 		return errors.New("settings.telegram.default_bot_token is required")
 	}
 
+	if chaos := p.Settings.Chaos; chaos.Enabled {
+		for _, percent := range []int{chaos.TelegramRateLimitPercent, chaos.TelegramServerErrorPercent, chaos.WebsocketDropPercent, chaos.SlowReadPercent} {
+			if percent < 0 || percent > 100 {
+				return errors.New("settings.chaos probabilities must be between 0 and 100")
+			}
+		}
+		if chaos.SlowReadMs < 0 {
+			return errors.New("settings.chaos.slow_read_ms must not be negative")
+		}
+	}
+
 	if len(p.Settings.Telegram.DefaultChatIDs) == 0 {
 		return errors.New("settings.telegram.default_chat_ids is required")
 	}
@@ -137,6 +932,165 @@ func (p *Plugin) Validate() error {
 		return errors.New("settings.gotify_server.client_token is required")
 	}
 
+	if webURL := p.Settings.GotifyServer.WebURL; webURL != "" {
+		u, err := url.Parse(webURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("settings.gotify_server.web_url %q is invalid. Should be in format https://gotify.example.com", webURL)
+		}
+	}
+
+	switch strings.ToLower(p.Settings.LogOptions.Format) {
+	case "", "console", "json":
+	default:
+		return fmt.Errorf("settings.log_options.format %s is invalid. Should be console or json", p.Settings.LogOptions.Format)
+	}
+
+	if heartbeat := p.Settings.Telegram.Heartbeat; heartbeat.Enabled && heartbeat.IntervalMinutes <= 0 {
+		return errors.New("settings.telegram.heartbeat.interval_minutes must be greater than 0")
+	}
+
+	if p.Settings.Telegram.SendWorkers < 0 {
+		return errors.New("settings.telegram.send_workers must not be negative")
+	}
+
+	if p.Settings.Telegram.ShutdownDrainSeconds < 0 {
+		return errors.New("settings.telegram.shutdown_drain_seconds must not be negative")
+	}
+
+	switch order := p.Settings.Telegram.SendOrder; order {
+	case "", SendOrderFIFO, SendOrderPriority:
+	default:
+		return fmt.Errorf("settings.telegram.send_order %q is invalid. Should be fifo or priority", order)
+	}
+
+	if p.Settings.Telegram.RequestTimeoutSeconds < 0 {
+		return errors.New("settings.telegram.request_timeout_seconds must not be negative")
+	}
+
+	if retry := p.Settings.Telegram.Retry; retry.NetworkRetries < 0 || retry.ServerErrorRetries < 0 || retry.RateLimitRetries < 0 ||
+		retry.InitialBackoffMs < 0 || retry.MaxBackoffSeconds < 0 {
+		return errors.New("settings.telegram.retry values must not be negative")
+	} else if retry.JitterPercent > 100 || retry.JitterPercent < 0 {
+		return errors.New("settings.telegram.retry.jitter_percent must be between 0 and 100")
+	}
+
+	if limit := p.Settings.Telegram.RateLimit; limit.ChatMessagesPerSecond < 0 || limit.GroupMessagesPerMinute < 0 {
+		return errors.New("settings.telegram.rate_limit values must not be negative")
+	}
+
+	if _, err := p.Settings.Telegram.Proxy.ProxyURL(); err != nil {
+		return fmt.Errorf("settings.telegram.proxy: %w", err)
+	}
+
+	if quarantine := p.Settings.Telegram.Quarantine; quarantine.FailureThreshold > 0 && quarantine.WindowMinutes <= 0 {
+		return errors.New("settings.telegram.quarantine.window_minutes must be greater than 0")
+	}
+
+	if p.Settings.Telegram.FailoverThreshold < 0 {
+		return errors.New("settings.telegram.failover_threshold must not be negative")
+	}
+
+	if breaker := p.Settings.Telegram.CircuitBreaker; breaker.FailureThreshold < 0 {
+		return errors.New("settings.telegram.circuit_breaker.failure_threshold must not be negative")
+	} else if breaker.FailureThreshold > 0 && breaker.OpenSeconds <= 0 {
+		return errors.New("settings.telegram.circuit_breaker.open_seconds must be greater than 0")
+	}
+
+	if deadLetter := p.Settings.Telegram.DeadLetter; deadLetter.Enabled && deadLetter.RetryIntervalMinutes <= 0 {
+		return errors.New("settings.telegram.dead_letter.retry_interval_minutes must be greater than 0")
+	} else if deadLetter.MaxAttempts < 0 {
+		return errors.New("settings.telegram.dead_letter.max_attempts must not be negative")
+	}
+
+	if shedding := p.Settings.Telegram.LoadShedding; shedding.MaxBacklog < 0 {
+		return errors.New("settings.telegram.load_shedding.max_backlog must not be negative")
+	} else if shedding.MaxBacklog > 0 && shedding.Mode != LoadSheddingDrop && shedding.Mode != LoadSheddingDigest {
+		return fmt.Errorf("settings.telegram.load_shedding.mode %q is invalid. Should be drop or digest", shedding.Mode)
+	}
+
+	switch policy := p.Settings.Telegram.LoadShedding.Policy; policy {
+	case "", LoadSheddingPriorityFloor, LoadSheddingLowestPriority, LoadSheddingOldest:
+	default:
+		return fmt.Errorf("settings.telegram.load_shedding.policy %q is invalid. Should be priority_floor, "+
+			"lowest_priority or oldest", policy)
+	}
+
+	switch sync := p.Settings.Telegram.DeletionSync; sync.Mode {
+	case "":
+	case DeletionSyncDelete, DeletionSyncStrikethrough:
+		if sync.IntervalMinutes <= 0 {
+			return errors.New("settings.telegram.deletion_sync.interval_minutes must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("settings.telegram.deletion_sync.mode %q is invalid. Should be delete or strikethrough", sync.Mode)
+	}
+
+	if attachments := p.Settings.Telegram.Attachments; attachments.Enabled &&
+		(attachments.MaxSizeMB <= 0 || attachments.MaxSizeMB > maxAttachmentSizeMB) {
+		return fmt.Errorf("settings.telegram.attachments.max_size_mb must be between 1 and %d", maxAttachmentSizeMB)
+	}
+
+	if images := p.Settings.Telegram.Images; images.Downscale {
+		if images.MaxDimension <= 0 {
+			return errors.New("settings.telegram.images.max_dimension must be greater than 0")
+		}
+		if images.JPEGQuality < 1 || images.JPEGQuality > 100 {
+			return errors.New("settings.telegram.images.jpeg_quality must be between 1 and 100")
+		}
+	}
+
+	for i := range p.Settings.Telegram.RedactionRules {
+		if err := p.Settings.Telegram.RedactionRules[i].compile(); err != nil {
+			return fmt.Errorf("settings.telegram.redaction_rules[%d]: %w", i, err)
+		}
+	}
+
+	if err := p.Settings.Telegram.MessageFormatOptions.validate(); err != nil {
+		return fmt.Errorf("settings.telegram.default_message_format_options: %w", err)
+	}
+
+	if err := p.Settings.Telegram.normalizeChatIDs(); err != nil {
+		return err
+	}
+
+	if err := p.Settings.Telegram.validateChatLanguages(); err != nil {
+		return err
+	}
+
+	return validateBots(p.Settings.Telegram.Bots)
+}
+
+// validateBots validates the bots and normalizes their chat IDs
+func validateBots(bots map[string]TelegramBot) error {
+	for botName, bot := range bots {
+		if err := normalizeChatIDs(fmt.Sprintf("settings.telegram.bots.%s.chat_ids", botName), bot.ChatIDs); err != nil {
+			return err
+		}
+		if bot.FallbackChatID != "" {
+			fallback, err := NormalizeChatID(bot.FallbackChatID)
+			if err != nil {
+				return fmt.Errorf("settings.telegram.bots.%s.fallback_chat_id: %w", botName, err)
+			}
+			bot.FallbackChatID = fallback
+			bots[botName] = bot
+		}
+
+		if bot.MessageFormatOptions != nil {
+			if err := bot.MessageFormatOptions.validate(); err != nil {
+				return fmt.Errorf("settings.telegram.bots.%s.message_format_options: %w", botName, err)
+			}
+		}
+
+		for app, opts := range bot.AppMessageFormatOptions {
+			if opts == nil {
+				continue
+			}
+			if err := opts.validate(); err != nil {
+				return fmt.Errorf("settings.telegram.bots.%s.app_message_format_options.%s: %w", botName, app, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -159,10 +1113,28 @@ func (p *Plugin) SafeString() string {
 	// Mask default Telegram bot token
 	configCopy.Settings.Telegram.DefaultBotToken = utils.MaskToken(configCopy.Settings.Telegram.DefaultBotToken)
 
+	for i, token := range configCopy.Settings.Telegram.DefaultBackupTokens {
+		configCopy.Settings.Telegram.DefaultBackupTokens[i] = utils.MaskToken(token)
+	}
+
+	// Mask the proxy credentials
+	if configCopy.Settings.Telegram.Proxy.Password != "" {
+		configCopy.Settings.Telegram.Proxy.Password = "xxxxx"
+	}
+	if proxyURL, err := url.Parse(configCopy.Settings.Telegram.Proxy.URL); err == nil {
+		configCopy.Settings.Telegram.Proxy.URL = proxyURL.Redacted()
+	}
+
 	// Mask tokens for all configured bots
 	for botName, bot := range configCopy.Settings.Telegram.Bots {
 		botCopy := bot
 		botCopy.Token = utils.MaskToken(bot.Token)
+		for i, token := range botCopy.Tokens {
+			botCopy.Tokens[i] = utils.MaskToken(token)
+		}
+		for i, token := range botCopy.BackupTokens {
+			botCopy.BackupTokens[i] = utils.MaskToken(token)
+		}
 		configCopy.Settings.Telegram.Bots[botName] = botCopy
 	}
 
@@ -201,10 +1173,61 @@ func DefaultConfig() *Plugin {
 		DefaultBotToken: "",
 		DefaultChatIDs:  []string{},
 		Bots:            botMap,
+		Heartbeat: Heartbeat{
+			Enabled:         false,
+			IntervalMinutes: 1440,
+		},
+		RequestTimeoutSeconds: 30,
+		Retry: Retry{
+			NetworkRetries:     5,
+			ServerErrorRetries: 3,
+			RateLimitRetries:   3,
+			InitialBackoffMs:   500,
+			MaxBackoffSeconds:  30,
+			JitterPercent:      20,
+		},
+		RateLimit: RateLimit{
+			Enabled:                true,
+			ChatMessagesPerSecond:  1,
+			GroupMessagesPerMinute: 20,
+		},
+		CircuitBreaker: CircuitBreaker{
+			FailureThreshold: 5,
+			OpenSeconds:      30,
+		},
+		SendWorkers:          4,
+		ShutdownDrainSeconds: 10,
+		SendOrder:            SendOrderFIFO,
+		BlockedChatThreshold: 3,
+		FailoverThreshold:    3,
+		Quarantine: Quarantine{
+			FailureThreshold: 10,
+			WindowMinutes:    60,
+		},
+		DeadLetter: DeadLetter{
+			RetryIntervalMinutes: 15,
+			MaxAttempts:          5,
+		},
+		LoadShedding: LoadShedding{
+			PriorityFloor: 5,
+			Mode:          LoadSheddingDigest,
+		},
+		DeletionSync: DeletionSync{
+			IntervalMinutes: 5,
+		},
+		Attachments: Attachments{
+			MaxSizeMB: 10,
+		},
+		Images: Images{
+			MaxDimension: 1280,
+			JPEGQuality:  80,
+		},
 		MessageFormatOptions: MessageFormatOptions{
 			IncludeAppName:   false,
 			IncludeTimestamp: false,
 			ParseMode:        "MarkdownV2",
+			MaxExtrasDepth:   3,
+			MaxExtrasLength:  256,
 		},
 	}
 
@@ -213,14 +1236,23 @@ func DefaultConfig() *Plugin {
 		RawUrl:      DefaultURL,
 		ClientToken: "",
 		Websocket: Websocket{
-			HandshakeTimeout: 10,
+			HandshakeTimeout:            10,
+			ReconnectAlertThreshold:     5,
+			ReconnectAlertWindowMinutes: 10,
 		},
 	}
 
+	metaNotifications := MetaNotifications{
+		Enabled:            false,
+		Priority:           5,
+		MinIntervalSeconds: 300,
+	}
+
 	settings := Settings{
-		LogOptions:   LogOptions{LogLevel: "info"},
-		Telegram:     telegram,
-		GotifyServer: gotifyServer,
+		LogOptions:        LogOptions{LogLevel: "info", Format: "console", Output: "stdout"},
+		Telegram:          telegram,
+		GotifyServer:      gotifyServer,
+		MetaNotifications: metaNotifications,
 	}
 	return &Plugin{
 		Settings: settings,
@@ -243,11 +1275,29 @@ func (l *LogOptions) GetZerologLevel() zerolog.Level {
 	}
 }
 
-// Load loads the yaml (and optionally) the environment variables into the plugin config
+// Load loads the yaml (and optionally) the unscoped environment variables into the plugin config
 func Load(newCfg *Plugin) (*Plugin, error) {
+	return LoadWithScope(newCfg, GlobalEnvScope)
+}
+
+// LoadWithScope loads the yaml (and optionally) the environment variables visible to the scope into the plugin config.
+// If a config file is visible to the scope, it replaces the yaml config
+func LoadWithScope(newCfg *Plugin, scope EnvScope) (*Plugin, error) {
+	if path, ok := scope.ConfigFile(); ok {
+		fileCfg, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		newCfg = fileCfg
+	}
+
+	if err := expandEnvReferences(newCfg, scope); err != nil {
+		return nil, err
+	}
+
 	// Optionally load config from env vars
 	if !newCfg.Settings.IgnoreEnvVars {
-		if err := overlayEnvVars(newCfg); err != nil {
+		if err := overlayEnvVars(newCfg, scope); err != nil {
 			return nil, err
 		}
 	}
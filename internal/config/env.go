@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"net/url"
 	"os"
 	"reflect"
@@ -8,17 +9,57 @@ import (
 	"strings"
 )
 
+// envPrefix is the prefix shared by all plugin env vars
+const envPrefix = "TG_PLUGIN__"
+
+// lookupFunc looks up the value of an env var
+type lookupFunc func(name string) (string, bool)
+
+// EnvScope determines which env vars are visible to a plugin instance.
+// Each gotify user gets their own plugin instance so env vars can be scoped per user
+// by inserting USER_<ID>__ after the prefix, e.g. TG_PLUGIN__USER_2__TELEGRAM_DEFAULT_BOT_TOKEN
+type EnvScope struct {
+	// Gotify user id of the plugin instance
+	UserID uint
+	// Whether the unscoped TG_PLUGIN__ env vars are visible to the plugin instance
+	Global bool
+}
+
+// GlobalEnvScope only reads the unscoped env vars
+var GlobalEnvScope = EnvScope{Global: true}
+
+// ScopedEnvName returns the user scoped name of an env var
+func (s EnvScope) ScopedEnvName(envName string) string {
+	return fmt.Sprintf("%sUSER_%d__%s", envPrefix, s.UserID, strings.TrimPrefix(envName, envPrefix))
+}
+
+// lookup looks up the user scoped env var first and falls back to the unscoped
+// env var if the scope is global
+func (s EnvScope) lookup(envName string) (string, bool) {
+	if s.UserID != 0 {
+		if value, exists := os.LookupEnv(s.ScopedEnvName(envName)); exists {
+			return value, true
+		}
+	}
+
+	if s.Global {
+		return os.LookupEnv(envName)
+	}
+
+	return "", false
+}
+
 func getEnvName(field reflect.StructField) string {
 	return field.Tag.Get("env")
 }
 
-func setFieldFromEnv(field reflect.Value, envName string) {
+func setFieldFromEnv(field reflect.Value, envName string, lookup lookupFunc) {
 	if envName == "" {
 		return
 	}
 
 	// Check if env var is actually set
-	envValue, exists := os.LookupEnv(envName)
+	envValue, exists := lookup(envName)
 	if !exists {
 		return
 	}
@@ -50,7 +91,7 @@ func setFieldFromEnv(field reflect.Value, envName string) {
 	}
 }
 
-func processStruct(val reflect.Value) {
+func processStruct(val reflect.Value, lookup lookupFunc) {
 	typ := val.Type()
 
 	for i := 0; i < val.NumField(); i++ {
@@ -64,18 +105,18 @@ func processStruct(val reflect.Value) {
 
 		// Handle nested structs recursively
 		if field.Kind() == reflect.Struct {
-			processStruct(field)
+			processStruct(field, lookup)
 			continue
 		}
 
 		envName := getEnvName(typeField)
-		setFieldFromEnv(field, envName)
+		setFieldFromEnv(field, envName, lookup)
 	}
 }
 
-func overlayEnvVars(cfg *Plugin) error {
+func overlayEnvVars(cfg *Plugin, scope EnvScope) error {
 	// Special handling for Gotify URL
-	if urlStr, exists := os.LookupEnv("TG_PLUGIN__GOTIFY_URL"); exists {
+	if urlStr, exists := scope.lookup("TG_PLUGIN__GOTIFY_URL"); exists {
 		parsedURL, err := url.Parse(urlStr)
 		if err != nil {
 			return err
@@ -87,7 +128,7 @@ func overlayEnvVars(cfg *Plugin) error {
 
 	// Process all other fields dynamically
 	val := reflect.ValueOf(cfg).Elem()
-	processStruct(val)
+	processStruct(val, scope.lookup)
 
 	return nil
 }
@@ -133,7 +133,7 @@ func TestSetFieldFromEnv(t *testing.T) {
 			val := reflect.ValueOf(&testStruct).Elem()
 			field := val.FieldByName(tc.field)
 
-			setFieldFromEnv(field, tc.envName)
+			setFieldFromEnv(field, tc.envName, os.LookupEnv)
 
 			// Check result
 			switch field.Kind() {
@@ -173,7 +173,7 @@ func TestProcessStruct(t *testing.T) {
 	}
 
 	val := reflect.ValueOf(&testStruct).Elem()
-	processStruct(val)
+	processStruct(val, os.LookupEnv)
 
 	assert.Equal(t, "test value", testStruct.StringField)
 	assert.Equal(t, true, testStruct.BoolField)
@@ -269,8 +269,58 @@ func TestOverlayEnvVars(t *testing.T) {
 
 			// Run test
 			cfg := tc.setup()
-			err := overlayEnvVars(cfg)
+			err := overlayEnvVars(cfg, GlobalEnvScope)
 			tc.verify(t, cfg, err)
 		})
 	}
 }
+
+func TestEnvScopeStruct_lookup(t *testing.T) {
+	t.Setenv("TG_PLUGIN__LOG_LEVEL", "debug")
+	t.Setenv("TG_PLUGIN__USER_2__LOG_LEVEL", "warn")
+
+	tests := []struct {
+		name       string
+		scope      EnvScope
+		wantValue  string
+		wantExists bool
+	}{
+		{
+			name:       "should read unscoped env var for global scope",
+			scope:      GlobalEnvScope,
+			wantValue:  "debug",
+			wantExists: true,
+		},
+		{
+			name:       "should prefer user scoped env var",
+			scope:      EnvScope{UserID: 2, Global: true},
+			wantValue:  "warn",
+			wantExists: true,
+		},
+		{
+			name:       "should fall back to unscoped env var for global scope",
+			scope:      EnvScope{UserID: 3, Global: true},
+			wantValue:  "debug",
+			wantExists: true,
+		},
+		{
+			name:       "should read user scoped env var for non global scope",
+			scope:      EnvScope{UserID: 2},
+			wantValue:  "warn",
+			wantExists: true,
+		},
+		{
+			name:       "should ignore unscoped env var for non global scope",
+			scope:      EnvScope{UserID: 3},
+			wantExists: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			value, exists := tc.scope.lookup("TG_PLUGIN__LOG_LEVEL")
+			assert.Equal(t, tc.wantExists, exists)
+			assert.Equal(t, tc.wantValue, value)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// envReferenceRegex matches ${VAR} and ${VAR:-default} references in config values
+var envReferenceRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandString replaces ${VAR} references with the value of the env var visible to the scope.
+// ${VAR:-default} falls back to default when the env var is not set
+func expandString(value string, lookup lookupFunc) (string, error) {
+	var missing []string
+
+	expanded := envReferenceRegex.ReplaceAllStringFunc(value, func(match string) string {
+		groups := envReferenceRegex.FindStringSubmatch(match)
+		if envValue, exists := lookup(groups[1]); exists {
+			return envValue
+		}
+		if groups[2] != "" {
+			return groups[3]
+		}
+		missing = append(missing, groups[1])
+		return match
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("env var %s is not set", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
+
+// expandValue recursively expands the env var references in all string values
+func expandValue(val reflect.Value, path string, lookup lookupFunc) error {
+	switch val.Kind() {
+	case reflect.String:
+		expanded, err := expandString(val.String(), lookup)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		val.SetString(expanded)
+
+	case reflect.Ptr:
+		if !val.IsNil() {
+			return expandValue(val.Elem(), path, lookup)
+		}
+
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			if err := expandValue(val.Index(i), fmt.Sprintf("%s[%d]", path, i), lookup); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		// Map values are not addressable so they are expanded on a copy
+		iter := val.MapRange()
+		for iter.Next() {
+			item := reflect.New(iter.Value().Type()).Elem()
+			item.Set(iter.Value())
+			if err := expandValue(item, fmt.Sprintf("%s.%v", path, iter.Key()), lookup); err != nil {
+				return err
+			}
+			val.SetMapIndex(iter.Key(), item)
+		}
+
+	case reflect.Struct:
+		typ := val.Type()
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Field(i)
+			name := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+
+			// Skip unexported and derived fields
+			if !field.CanSet() || name == "-" {
+				continue
+			}
+
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			if err := expandValue(field, fieldPath, lookup); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandEnvReferences expands the ${VAR} references in all string values of the config.
+// Only env vars visible to the scope are expanded
+func expandEnvReferences(cfg *Plugin, scope EnvScope) error {
+	return expandValue(reflect.ValueOf(cfg).Elem(), "", scope.lookup)
+}
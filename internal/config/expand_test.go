@@ -0,0 +1,97 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandString(t *testing.T) {
+	t.Setenv("TEST_BOT_TOKEN", "123:abc")
+
+	tests := []struct {
+		name      string
+		value     string
+		want      string
+		wantError string
+	}{
+		{
+			name:  "should expand env var",
+			value: "${TEST_BOT_TOKEN}",
+			want:  "123:abc",
+		},
+		{
+			name:  "should expand env var inside value",
+			value: "http://${TEST_HOST:-localhost}:80/${TEST_BOT_TOKEN}",
+			want:  "http://localhost:80/123:abc",
+		},
+		{
+			name:  "should ignore values without references",
+			value: "token$with{braces}",
+			want:  "token$with{braces}",
+		},
+		{
+			name:      "should error for unset env var",
+			value:     "${TEST_UNSET_VAR}",
+			wantError: "env var TEST_UNSET_VAR is not set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandString(tt.value, GlobalEnvScope.lookup)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandEnvReferences(t *testing.T) {
+	t.Setenv("TEST_BOT_TOKEN", "123:abc")
+	t.Setenv("TEST_CHAT_ID", "-100123")
+	t.Setenv("TG_PLUGIN__USER_2__TEST_CHAT_ID", "-100456")
+
+	newConfig := func() *Plugin {
+		return &Plugin{
+			Settings: Settings{
+				Telegram: Telegram{
+					DefaultBotToken: "${TEST_BOT_TOKEN}",
+					DefaultChatIDs:  []string{"${TEST_CHAT_ID}"},
+					Bots: map[string]TelegramBot{
+						"bot": {
+							Token:   "${TEST_BOT_TOKEN}",
+							ChatIDs: []string{"${TEST_CHAT_ID}", "42"},
+							MessageFormatOptions: &MessageFormatOptions{
+								ParseMode: "${TEST_PARSE_MODE:-MarkdownV2}",
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	cfg := newConfig()
+	require.NoError(t, expandEnvReferences(cfg, GlobalEnvScope))
+	assert.Equal(t, "123:abc", cfg.Settings.Telegram.DefaultBotToken)
+	assert.Equal(t, []string{"-100123"}, cfg.Settings.Telegram.DefaultChatIDs)
+	assert.Equal(t, "123:abc", cfg.Settings.Telegram.Bots["bot"].Token)
+	assert.Equal(t, []string{"-100123", "42"}, cfg.Settings.Telegram.Bots["bot"].ChatIDs)
+	assert.Equal(t, "MarkdownV2", cfg.Settings.Telegram.Bots["bot"].MessageFormatOptions.ParseMode)
+
+	// Non-admin users can only reference env vars scoped to their user id
+	cfg = newConfig()
+	err := expandEnvReferences(cfg, EnvScope{UserID: 2})
+	assert.EqualError(t, err, "settings.telegram.default_bot_token: env var TEST_BOT_TOKEN is not set")
+
+	cfg = newConfig()
+	cfg.Settings.Telegram.DefaultBotToken = "token"
+	cfg.Settings.Telegram.Bots = nil
+	require.NoError(t, expandEnvReferences(cfg, EnvScope{UserID: 2}))
+	assert.Equal(t, []string{"-100456"}, cfg.Settings.Telegram.DefaultChatIDs)
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported config formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// ConfigFileEnv is the env var pointing to a config file. When set, the file is used
+// instead of the config from the Gotify UI
+const ConfigFileEnv = "TG_PLUGIN__CONFIG_FILE"
+
+// LogDirEnv is the env var of the directory log files are written to
+const LogDirEnv = "TG_PLUGIN__LOG_DIR"
+
+// DefaultLogDir is the log directory of admin users when LogDirEnv isn't set
+const DefaultLogDir = "data/logs"
+
+// tomlTableRegex matches toml table headers such as [settings.telegram]
+var tomlTableRegex = regexp.MustCompile(`(?m)^\s*\[\[?[A-Za-z0-9_."-]+\]\]?\s*(#.*)?$`)
+
+// DetectFormat detects the format of a config from the file name and falls back to sniffing the content
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSON
+	case tomlTableRegex.Match(trimmed):
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// Parse parses a yaml, json or toml config into the plugin config.
+// Json and toml are converted to yaml first so that all formats share the yaml field names.
+func Parse(data []byte, format string) (*Plugin, error) {
+	switch format {
+	case FormatYAML:
+	case FormatJSON, FormatTOML:
+		var raw map[string]interface{}
+		if format == FormatJSON {
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, fmt.Errorf("failed to parse json config: %w", err)
+			}
+			raw = normalizeJSONNumbers(raw).(map[string]interface{})
+		} else {
+			if err := toml.Unmarshal(data, &raw); err != nil {
+				return nil, fmt.Errorf("failed to parse toml config: %w", err)
+			}
+		}
+
+		converted, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s config: %w", format, err)
+		}
+		data = converted
+	default:
+		return nil, fmt.Errorf("config format %s is not supported", format)
+	}
+
+	cfg := DefaultConfig()
+	// Don't merge the example bot into the parsed bots
+	cfg.Settings.Telegram.Bots = nil
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s config: %w", format, err)
+	}
+
+	return cfg, nil
+}
+
+// normalizeJSONNumbers converts json numbers to ints or floats so that large ids
+// are not marshaled to yaml in exponent notation
+func normalizeJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeJSONNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeJSONNumbers(item)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// LoadFile reads and parses a config file. The format is detected from the file extension or content
+func LoadFile(path string) (*Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return Parse(data, DetectFormat(path, data))
+}
+
+// ConfigFile returns the config file path visible to the scope, if any
+func (s EnvScope) ConfigFile() (string, bool) {
+	path, exists := s.lookup(ConfigFileEnv)
+	return path, exists && path != ""
+}
+
+// LogDir returns the directory log files of the scope are written to. Users without a log directory set
+// for their scope can't write log files, so only admins and users with a scoped log directory can
+func (s EnvScope) LogDir() string {
+	if dir, exists := s.lookup(LogDirEnv); exists && dir != "" {
+		return dir
+	}
+	if s.Global {
+		return DefaultLogDir
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlConfig = `
+settings:
+  log_options:
+    log_level: debug
+  gotify_server:
+    url: http://gotify.example.com
+    client_token: client-token
+  telegram:
+    default_bot_token: bot-token
+    default_chat_ids:
+      - "123"
+    bots:
+      backups:
+        token: backups-token
+        chat_ids:
+          - "456"
+        gotify_app_ids:
+          - 123456789
+`
+
+const jsonConfig = `{
+  "settings": {
+    "log_options": {"log_level": "debug"},
+    "gotify_server": {"url": "http://gotify.example.com", "client_token": "client-token"},
+    "telegram": {
+      "default_bot_token": "bot-token",
+      "default_chat_ids": ["123"],
+      "bots": {
+        "backups": {"token": "backups-token", "chat_ids": ["456"], "gotify_app_ids": [123456789]}
+      }
+    }
+  }
+}`
+
+const tomlConfig = `
+[settings.log_options]
+log_level = "debug"
+
+[settings.gotify_server]
+url = "http://gotify.example.com"
+client_token = "client-token"
+
+[settings.telegram]
+default_bot_token = "bot-token"
+default_chat_ids = ["123"]
+
+[settings.telegram.bots.backups]
+token = "backups-token"
+chat_ids = ["456"]
+gotify_app_ids = [123456789]
+`
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		want     string
+	}{
+		{name: "json extension", filename: "config.json", want: FormatJSON},
+		{name: "toml extension", filename: "config.TOML", want: FormatTOML},
+		{name: "yml extension", filename: "config.yml", want: FormatYAML},
+		{name: "json content", data: jsonConfig, want: FormatJSON},
+		{name: "toml content", data: tomlConfig, want: FormatTOML},
+		{name: "yaml content", data: yamlConfig, want: FormatYAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectFormat(tt.filename, []byte(tt.data)))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{name: "yaml", data: yamlConfig, format: FormatYAML},
+		{name: "json", data: jsonConfig, format: FormatJSON},
+		{name: "toml", data: tomlConfig, format: FormatTOML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.data), tt.format)
+			require.NoError(t, err)
+			require.NoError(t, cfg.Validate())
+
+			assert.Equal(t, "debug", cfg.Settings.LogOptions.LogLevel)
+			assert.Equal(t, "http://gotify.example.com", cfg.Settings.GotifyServer.RawUrl)
+			assert.Equal(t, "client-token", cfg.Settings.GotifyServer.ClientToken)
+			assert.Equal(t, "bot-token", cfg.Settings.Telegram.DefaultBotToken)
+			assert.Equal(t, []string{"123"}, cfg.Settings.Telegram.DefaultChatIDs)
+			assert.Equal(t, 10, cfg.Settings.GotifyServer.Websocket.HandshakeTimeout)
+
+			require.Len(t, cfg.Settings.Telegram.Bots, 1)
+			bot := cfg.Settings.Telegram.Bots["backups"]
+			assert.Equal(t, "backups-token", bot.Token)
+			assert.Equal(t, []string{"456"}, bot.ChatIDs)
+			assert.Equal(t, []uint32{123456789}, bot.AppIDs)
+		})
+	}
+
+	_, err := Parse([]byte("{"), FormatJSON)
+	assert.Error(t, err)
+}
+
+func TestLoadWithScope_ConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(tomlConfig), 0o600))
+	t.Setenv(ConfigFileEnv, path)
+
+	cfg, err := LoadWithScope(DefaultConfig(), GlobalEnvScope)
+	require.NoError(t, err)
+	assert.Equal(t, "bot-token", cfg.Settings.Telegram.DefaultBotToken)
+	assert.Contains(t, cfg.Settings.Telegram.Bots, "backups")
+}
+
+func TestEnvScope_LogDir(t *testing.T) {
+	t.Setenv(LogDirEnv, "")
+	assert.Equal(t, DefaultLogDir, GlobalEnvScope.LogDir())
+	assert.Empty(t, EnvScope{UserID: 2}.LogDir(), "non-admin users can't write log files by default")
+
+	t.Setenv(LogDirEnv, "/var/log/gotify")
+	t.Setenv("TG_PLUGIN__USER_2__LOG_DIR", "/var/log/gotify/2")
+	assert.Equal(t, "/var/log/gotify", GlobalEnvScope.LogDir())
+	assert.Equal(t, "/var/log/gotify/2", EnvScope{UserID: 2}.LogDir())
+	assert.Empty(t, EnvScope{UserID: 3}.LogDir())
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint returns non-fatal warnings about bots that never receive messages or compete for the same apps.
+// Messages of an app claimed by several bots are only sent with one of them
+func (t *Telegram) Lint() []string {
+	var warnings []string
+	idClaims := make(map[uint32][]string)
+	nameClaims := make(map[string][]string)
+
+	for _, botName := range t.botNames() {
+		bot := t.Bots[botName]
+		if len(bot.ChatIDs) == 0 {
+			warnings = append(warnings, fmt.Sprintf("bot %q has no chat ids", botName))
+		}
+		if len(bot.AppIDs) == 0 && len(bot.AppNames) == 0 {
+			warnings = append(warnings, fmt.Sprintf("bot %q has no gotify_app_ids or gotify_app_names and never receives messages", botName))
+		}
+		if len(bot.BackupTokens) > 0 && t.FailoverThreshold == 0 {
+			warnings = append(warnings, fmt.Sprintf("bot %q has backup_tokens but failover_threshold is 0. They are never used", botName))
+		}
+
+		for _, id := range uniqueAppIDs(bot.AppIDs) {
+			idClaims[id] = append(idClaims[id], botName)
+		}
+		for _, name := range uniqueAppNames(bot.AppNames) {
+			nameClaims[name] = append(nameClaims[name], botName)
+		}
+	}
+
+	for _, id := range sortedAppIDs(idClaims) {
+		if bots := idClaims[id]; len(bots) > 1 {
+			warnings = append(warnings, fmt.Sprintf("gotify app id %d is routed by several bots (%s). Its messages are only sent with one of them", id, strings.Join(bots, ", ")))
+		}
+	}
+	names := make([]string, 0, len(nameClaims))
+	for name := range nameClaims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if bots := nameClaims[name]; len(bots) > 1 {
+			warnings = append(warnings, fmt.Sprintf("gotify app name %q is routed by several bots (%s). Its messages are only sent with one of them", name, strings.Join(bots, ", ")))
+		}
+	}
+
+	return warnings
+}
+
+// LintApps returns non-fatal warnings about the routes that need the applications of the gotify server:
+// app ids and names that don't exist and app names resolving to app ids routed by other bots.
+// appIDsByName maps the lowercase names of the applications to their ids
+func (t *Telegram) LintApps(appIDsByName map[string]uint32) []string {
+	var warnings []string
+	existing := make(map[uint32]bool, len(appIDsByName))
+	for _, id := range appIDsByName {
+		existing[id] = true
+	}
+
+	// bots routing each app id and the app ids routed through app names. Ids only routed by
+	// app ids are reported by Lint
+	claims := make(map[uint32][]string)
+	byName := make(map[uint32]bool)
+
+	for _, botName := range t.botNames() {
+		bot := t.Bots[botName]
+		for _, id := range uniqueAppIDs(bot.AppIDs) {
+			if !existing[id] {
+				warnings = append(warnings, fmt.Sprintf("bot %q routes gotify app id %d which does not exist on the gotify server", botName, id))
+			}
+			claims[id] = appendBot(claims[id], botName)
+		}
+		for _, name := range uniqueAppNames(bot.AppNames) {
+			id, ok := appIDsByName[name]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("bot %q routes gotify app name %q which does not exist on the gotify server", botName, name))
+				continue
+			}
+			claims[id] = appendBot(claims[id], botName)
+			byName[id] = true
+		}
+	}
+
+	for _, id := range sortedAppIDs(claims) {
+		if bots := claims[id]; byName[id] && len(bots) > 1 {
+			warnings = append(warnings, fmt.Sprintf("gotify app id %d is routed by several bots (%s). Its messages are only sent with one of them", id, strings.Join(bots, ", ")))
+		}
+	}
+
+	return warnings
+}
+
+// botNames returns the names of the bots in alphabetical order
+func (t *Telegram) botNames() []string {
+	names := make([]string, 0, len(t.Bots))
+	for name := range t.Bots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// appendBot adds the bot to the bots unless it's the last one already
+func appendBot(bots []string, bot string) []string {
+	if len(bots) > 0 && bots[len(bots)-1] == bot {
+		return bots
+	}
+	return append(bots, bot)
+}
+
+// sortedAppIDs returns the app ids of the claims in ascending order
+func sortedAppIDs(claims map[uint32][]string) []uint32 {
+	ids := make([]uint32, 0, len(claims))
+	for id := range claims {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func uniqueAppIDs(ids []uint32) []uint32 {
+	seen := make(map[uint32]bool, len(ids))
+	unique := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// uniqueAppNames returns the lowercase app names without duplicates. App names are matched case-insensitively
+func uniqueAppNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
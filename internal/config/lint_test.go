@@ -0,0 +1,110 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelegramStruct_Lint(t *testing.T) {
+	tests := []struct {
+		name     string
+		bots     map[string]TelegramBot
+		expected []string
+	}{
+		{name: "no bots"},
+		{
+			name: "valid routes",
+			bots: map[string]TelegramBot{
+				"backups": {ChatIDs: []string{"1"}, AppIDs: []uint32{1, 1}, AppNames: []string{"Restic", "restic"}},
+				"media":   {ChatIDs: []string{"2"}, AppIDs: []uint32{2}},
+			},
+		},
+		{
+			name: "dead routes",
+			bots: map[string]TelegramBot{
+				"silent":  {AppIDs: []uint32{1}},
+				"unused":  {ChatIDs: []string{"1"}},
+				"backups": {ChatIDs: []string{"2"}, AppIDs: []uint32{2}},
+			},
+			expected: []string{
+				`bot "silent" has no chat ids`,
+				`bot "unused" has no gotify_app_ids or gotify_app_names and never receives messages`,
+			},
+		},
+		{
+			name: "unused backup tokens",
+			bots: map[string]TelegramBot{
+				"backups": {ChatIDs: []string{"1"}, AppIDs: []uint32{1}, BackupTokens: []string{"backup-token"}},
+			},
+			expected: []string{`bot "backups" has backup_tokens but failover_threshold is 0. They are never used`},
+		},
+		{
+			name: "conflicting routes",
+			bots: map[string]TelegramBot{
+				"ops":     {ChatIDs: []string{"1"}, AppIDs: []uint32{3, 1}, AppNames: []string{"Backups"}},
+				"backups": {ChatIDs: []string{"2"}, AppIDs: []uint32{1}, AppNames: []string{"backups"}},
+				"media":   {ChatIDs: []string{"3"}, AppIDs: []uint32{3, 1}},
+			},
+			expected: []string{
+				"gotify app id 1 is routed by several bots (backups, media, ops). Its messages are only sent with one of them",
+				"gotify app id 3 is routed by several bots (media, ops). Its messages are only sent with one of them",
+				`gotify app name "backups" is routed by several bots (backups, ops). Its messages are only sent with one of them`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telegram := Telegram{Bots: tt.bots}
+			assert.Equal(t, tt.expected, telegram.Lint())
+		})
+	}
+}
+
+func TestTelegramStruct_LintApps(t *testing.T) {
+	appIDsByName := map[string]uint32{"restic": 1, "sonarr": 2, "radarr": 3}
+
+	tests := []struct {
+		name     string
+		bots     map[string]TelegramBot
+		expected []string
+	}{
+		{
+			name: "existing apps",
+			bots: map[string]TelegramBot{
+				"backups": {AppIDs: []uint32{1}, AppNames: []string{"Restic"}},
+				"media":   {AppNames: []string{"Sonarr"}, AppIDs: []uint32{3}},
+			},
+		},
+		{
+			name: "unknown apps",
+			bots: map[string]TelegramBot{
+				"backups": {AppIDs: []uint32{1, 9}, AppNames: []string{"Borg"}},
+			},
+			expected: []string{
+				`bot "backups" routes gotify app id 9 which does not exist on the gotify server`,
+				`bot "backups" routes gotify app name "borg" which does not exist on the gotify server`,
+			},
+		},
+		{
+			name: "app names shadowing app ids",
+			bots: map[string]TelegramBot{
+				"backups": {AppIDs: []uint32{2}},
+				"media":   {AppNames: []string{"Sonarr"}},
+				"ops":     {AppIDs: []uint32{3}},
+				"tv":      {AppIDs: []uint32{3}},
+			},
+			expected: []string{
+				"gotify app id 2 is routed by several bots (backups, media). Its messages are only sent with one of them",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telegram := Telegram{Bots: tt.bots}
+			assert.Equal(t, tt.expected, telegram.LintApps(appIDsByName))
+		})
+	}
+}
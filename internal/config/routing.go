@@ -0,0 +1,59 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Routing is the routing section of the config. It can be managed separately from the
+// rest of the config through the routing webhook of the plugin
+type Routing struct {
+	Bots map[string]TelegramBot `yaml:"bots"`
+}
+
+// ParseRouting parses a routing section from json. Like config files, the json uses the yaml field names
+func ParseRouting(data []byte) (*Routing, error) {
+	var raw map[string]interface{}
+	jsonDecoder := json.NewDecoder(bytes.NewReader(data))
+	jsonDecoder.UseNumber()
+	if err := jsonDecoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse json routing: %w", err)
+	}
+
+	converted, err := yaml.Marshal(normalizeJSONNumbers(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert json routing: %w", err)
+	}
+
+	var routing Routing
+	yamlDecoder := yaml.NewDecoder(bytes.NewReader(converted))
+	yamlDecoder.KnownFields(true)
+	if err := yamlDecoder.Decode(&routing); err != nil {
+		return nil, fmt.Errorf("failed to parse json routing: %w", err)
+	}
+
+	return &routing, nil
+}
+
+// JSON returns the routing section as json using the yaml field names
+func (r *Routing) JSON() ([]byte, error) {
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(raw)
+}
+
+// Validate validates the bots and normalizes their chat IDs
+func (r *Routing) Validate() error {
+	return validateBots(r.Bots)
+}
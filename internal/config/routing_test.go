@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRouting(t *testing.T) {
+	data := `{
+		"bots": {
+			"backups": {
+				"token": "backups-token",
+				"chat_ids": ["+456"],
+				"gotify_app_ids": [123456789],
+				"gotify_app_names": ["Restic"],
+				"message_format_options": {"parse_mode": "HTML", "include_extras": true}
+			}
+		}
+	}`
+
+	routing, err := ParseRouting([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, routing.Validate())
+
+	bot := routing.Bots["backups"]
+	assert.Equal(t, "backups-token", bot.Token)
+	assert.Equal(t, []string{"456"}, bot.ChatIDs)
+	assert.Equal(t, []uint32{123456789}, bot.AppIDs)
+	assert.Equal(t, []string{"Restic"}, bot.AppNames)
+	require.NotNil(t, bot.MessageFormatOptions)
+	assert.Equal(t, "HTML", bot.MessageFormatOptions.ParseMode)
+
+	// the json uses the yaml field names
+	exported, err := routing.JSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(exported), `"chat_ids":["456"]`)
+
+	reparsed, err := ParseRouting(exported)
+	require.NoError(t, err)
+	assert.Equal(t, routing, reparsed)
+}
+
+func TestParseRouting_Invalid(t *testing.T) {
+	_, err := ParseRouting([]byte(`{"bots": `))
+	assert.ErrorContains(t, err, "failed to parse json routing")
+
+	_, err = ParseRouting([]byte(`{"bots": {"backups": {"chat_id": "123"}}}`))
+	assert.ErrorContains(t, err, "field chat_id not found")
+
+	routing, err := ParseRouting([]byte(`{"bots": {"backups": {"chat_ids": ["backups"]}}}`))
+	require.NoError(t, err)
+	assert.ErrorContains(t, routing.Validate(), `settings.telegram.bots.backups.chat_ids[0]: invalid chat ID "backups"`)
+}
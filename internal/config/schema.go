@@ -0,0 +1,103 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// SchemaURI is the JSON schema draft used by the generated config schema
+const SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+var urlType = reflect.TypeOf(url.URL{})
+
+// Schema generates a JSON schema describing the yaml plugin config.
+// Field names are taken from the yaml tags, enums from the enum tags and
+// required fields from the required tags.
+func Schema() map[string]interface{} {
+	schema := schemaForType(reflect.TypeOf(Plugin{}))
+	schema["$schema"] = SchemaURI
+	schema["title"] = "gotify-to-telegram plugin config"
+	return schema
+}
+
+func schemaForType(typ reflect.Type) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaForType(typ.Elem()),
+		}
+
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaForType(typ.Elem()),
+		}
+
+	case reflect.Struct:
+		return schemaForStruct(typ)
+
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func schemaForStruct(typ reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		// Skip unexported fields
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" || field.Type == urlType {
+			continue
+		}
+
+		property := schemaForType(field.Type)
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = property
+
+		if field.Tag.Get("required") == "true" {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
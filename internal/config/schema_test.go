@@ -0,0 +1,42 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+
+	// Schema must be serializable
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+
+	assert.Equal(t, SchemaURI, schema["$schema"])
+	assert.Equal(t, []string{"settings"}, schema["required"])
+
+	settings := schema["properties"].(map[string]interface{})["settings"].(map[string]interface{})
+	settingsProps := settings["properties"].(map[string]interface{})
+	assert.ElementsMatch(t, []string{"gotify_server", "telegram"}, settings["required"])
+
+	logOptions := settingsProps["log_options"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, []string{"debug", "info", "warn", "error"}, logOptions["log_level"].(map[string]interface{})["enum"])
+
+	gotifyServer := settingsProps["gotify_server"].(map[string]interface{})
+	assert.ElementsMatch(t, []string{"url", "client_token"}, gotifyServer["required"])
+	assert.NotContains(t, gotifyServer["properties"], "-")
+
+	telegram := settingsProps["telegram"].(map[string]interface{})
+	telegramProps := telegram["properties"].(map[string]interface{})
+	bots := telegramProps["bots"].(map[string]interface{})
+	assert.Equal(t, "object", bots["type"])
+
+	bot := bots["additionalProperties"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "integer", "minimum": 0},
+	}, bot["gotify_app_ids"])
+}
@@ -1,73 +1,137 @@
 package logger
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/gotify/plugin-api"
 	"github.com/rs/zerolog"
 )
 
+// Log formats
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
 var (
-	globalLogger *zerolog.Logger
-	once         sync.Once
-	mu           sync.RWMutex
-	globalLevel  zerolog.Level = zerolog.InfoLevel
+	defaultLogger *zerolog.Logger
+	once          sync.Once
 )
 
-// Init initializes the global logger with initial configuration
-func Init(pluginName string, pluginVersion string, userCtx plugin.UserContext) *zerolog.Logger {
-	once.Do(func() {
-		logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).
-			With().
-			Str("plugin", pluginName).
-			Str("plugin_version", pluginVersion).
-			Uint("user_id", userCtx.ID).
-			Str("user_name", userCtx.Name).
-			Bool("is_admin", userCtx.Admin).
-			Caller().
-			Timestamp().
-			Logger().
-			Level(globalLevel)
+// errFileOutputDisabled is returned for log files of plugin instances without a log directory
+var errFileOutputDisabled = errors.New("log file output is disabled")
 
-		globalLogger = &logger
-	})
+// FilePath returns the path of the log file of the output in the log directory, or an empty path for
+// "stdout" and "stderr". Log files must be relative paths that stay inside the log directory. Without
+// a log directory, file output is disabled
+func FilePath(output string, dir string) (string, error) {
+	switch strings.ToLower(output) {
+	case "", "stdout", "stderr":
+		return "", nil
+	}
+
+	if dir == "" {
+		return "", fmt.Errorf("log output %s: %w", output, errFileOutputDisabled)
+	}
+	if !filepath.IsLocal(output) {
+		return "", fmt.Errorf("log output %s must be a relative path inside the log directory", output)
+	}
+
+	return filepath.Join(dir, output), nil
+}
+
+// Writer returns the log writer for the format and output. Output can be "stdout" (default),
+// "stderr" or a file in the log directory, in which case the returned closer closes the file
+func Writer(format string, output string, dir string) (io.Writer, io.Closer, error) {
+	var (
+		out    io.Writer
+		closer io.Closer
+	)
+
+	path, err := FilePath(output, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case path != "":
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out = file
+		closer = file
+	case strings.EqualFold(output, "stderr"):
+		out = os.Stderr
+	default:
+		out = os.Stdout
+	}
+
+	switch strings.ToLower(format) {
+	case "", FormatConsole:
+		return zerolog.ConsoleWriter{Out: out, NoColor: closer != nil}, closer, nil
+	case FormatJSON:
+		return out, closer, nil
+	default:
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, fmt.Errorf("log format %s is not supported", format)
+	}
+}
 
-	return globalLogger
+// New creates a logger for a single plugin instance. Every gotify user gets
+// their own plugin instance, so loggers are never shared between instances.
+func New(pluginName string, pluginVersion string, userCtx plugin.UserContext, level zerolog.Level, w io.Writer) *zerolog.Logger {
+	logger := zerolog.New(w).
+		With().
+		Str("plugin", pluginName).
+		Str("plugin_version", pluginVersion).
+		Uint("user_id", userCtx.ID).
+		Str("user_name", userCtx.Name).
+		Bool("is_admin", userCtx.Admin).
+		Caller().
+		Timestamp().
+		Logger().
+		Level(level)
+
+	return &logger
 }
 
-// Get returns the global logger instance
+// Get returns the default logger used when no instance logger is provided
 func Get() *zerolog.Logger {
-	if globalLogger == nil {
-		// If not initialized, create a default logger
+	once.Do(func() {
 		logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).
 			With().
 			Timestamp().
 			Logger()
-		globalLogger = &logger
-	}
-	return globalLogger
+		defaultLogger = &logger
+	})
+	return defaultLogger
 }
 
-// UpdateLogLevel updates the log level of the global logger
-func UpdateLogLevel(level zerolog.Level) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	if globalLogger != nil {
-		globalLevel = level
-		newLogger := globalLogger.Level(level)
-		globalLogger = &newLogger
-	}
+// WithLevel returns a copy of the logger with the given log level
+func WithLevel(parent *zerolog.Logger, level zerolog.Level) *zerolog.Logger {
+	logger := parent.Level(level)
+	return &logger
 }
 
 // WithComponent adds a component field to the logger
-// Useful for package-specific logging
-func WithComponent(component string) *zerolog.Logger {
-	mu.RLock()
-	level := globalLevel
-	mu.RUnlock()
+// Useful for package-specific logging. Falls back to the default logger if parent is nil
+func WithComponent(parent *zerolog.Logger, component string) *zerolog.Logger {
+	if parent == nil {
+		parent = Get()
+	}
 
-	logger := Get().Level(level).With().Str("component", component).Logger()
+	logger := parent.With().Str("component", component).Logger()
 	return &logger
 }
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		dir      string
+		expected string
+		wantErr  bool
+	}{
+		{name: "stdout", output: "stdout", dir: "", expected: ""},
+		{name: "stderr", output: "STDERR", dir: "", expected: ""},
+		{name: "file in log directory", output: "plugin.log", dir: "/var/log/gotify", expected: "/var/log/gotify/plugin.log"},
+		{name: "file in sub directory", output: "users/2.log", dir: "/var/log/gotify", expected: "/var/log/gotify/users/2.log"},
+		{name: "file without log directory", output: "plugin.log", dir: "", wantErr: true},
+		{name: "absolute path", output: "/etc/passwd", dir: "/var/log/gotify", wantErr: true},
+		{name: "path leaving log directory", output: "../../etc/passwd", dir: "/var/log/gotify", wantErr: true},
+		{name: "cleaned path leaving log directory", output: "users/../../plugin.log", dir: "/var/log/gotify", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := FilePath(tt.output, tt.dir)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, path)
+		})
+	}
+}
+
+func TestWriter_File(t *testing.T) {
+	dir := t.TempDir()
+
+	w, closer, err := Writer(FormatJSON, "users/plugin.log", dir)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("{}\n"))
+	require.NoError(t, err)
+	require.NoError(t, closer.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "users", "plugin.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}\n", string(data))
+
+	_, _, err = Writer(FormatJSON, "../plugin.log", dir)
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(dir), "plugin.log"))
+}
@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"time"
+)
+
+// maxRecentErrors is the number of errors kept by the tracker
+const maxRecentErrors = 20
+
+// ErrorEntry describes an error that occurred while forwarding messages
+type ErrorEntry struct {
+	// Component that produced the error, e.g. "telegram" or "gotify"
+	Component string
+	Time      time.Time
+	Message   string
+	// Affected Telegram chat. Empty if the error is not related to a chat
+	ChatID string
+	// Affected gotify application. Zero if the error is not related to an app
+	AppID   uint32
+	AppName string
+}
+
+// RecordError records an error. Only the most recent errors are kept
+func (t *Tracker) RecordError(entry ErrorEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.errors) < maxRecentErrors {
+		t.errors = append(t.errors, entry)
+	} else {
+		t.errors[t.nextError] = entry
+	}
+	t.nextError = (t.nextError + 1) % maxRecentErrors
+}
+
+// RecentErrors returns the recorded errors, most recent first
+func (t *Tracker) RecentErrors() []ErrorEntry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	errors := make([]ErrorEntry, 0, len(t.errors))
+	for i := 1; i <= len(t.errors); i++ {
+		idx := (t.nextError - i + len(t.errors)) % len(t.errors)
+		errors = append(errors, t.errors[idx])
+	}
+
+	return errors
+}
@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackerStruct_RecentErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		wantLen   int
+		wantFirst string
+		wantLast  string
+	}{
+		{
+			name:    "no errors",
+			count:   0,
+			wantLen: 0,
+		},
+		{
+			name:      "less than capacity",
+			count:     3,
+			wantLen:   3,
+			wantFirst: "error 3",
+			wantLast:  "error 1",
+		},
+		{
+			name:      "overwrites oldest errors",
+			count:     maxRecentErrors + 5,
+			wantLen:   maxRecentErrors,
+			wantFirst: fmt.Sprintf("error %d", maxRecentErrors+5),
+			wantLast:  "error 6",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewTracker()
+			for i := 1; i <= tt.count; i++ {
+				tracker.RecordError(ErrorEntry{Component: "telegram", Message: fmt.Sprintf("error %d", i)})
+			}
+
+			errors := tracker.RecentErrors()
+			assert.Len(t, errors, tt.wantLen)
+			if tt.wantLen == 0 {
+				return
+			}
+
+			assert.Equal(t, tt.wantFirst, errors[0].Message)
+			assert.Equal(t, tt.wantLast, errors[len(errors)-1].Message)
+			assert.False(t, errors[0].Time.IsZero())
+		})
+	}
+}
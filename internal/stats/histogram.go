@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds (in seconds) of the delivery latency histogram buckets
+var LatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Histogram counts observed durations in fixed buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	// counts holds the number of observations per bucket. The last count is the +Inf bucket
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramSnapshot is a copy of the state of a histogram
+type HistogramSnapshot struct {
+	// Upper bounds of the buckets in seconds
+	Buckets []float64
+	// Cumulative number of observations less than or equal to each bucket's upper bound
+	Cumulative []uint64
+	// Number of observations
+	Count uint64
+	// Sum of all observations in seconds
+	Sum float64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds in seconds
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+// Observe records a duration. Negative durations, e.g. caused by clock skew, are recorded as 0
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := 0
+	for i < len(h.buckets) && seconds > h.buckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += seconds
+}
+
+// Snapshot returns a copy of the state of the histogram
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := HistogramSnapshot{
+		Buckets:    append([]float64(nil), h.buckets...),
+		Cumulative: make([]uint64, len(h.buckets)),
+		Count:      h.count,
+		Sum:        h.sum,
+	}
+
+	var cumulative uint64
+	for i := range h.buckets {
+		cumulative += h.counts[i]
+		snapshot.Cumulative[i] = cumulative
+	}
+
+	return snapshot
+}
+
+// Quantile estimates the q-quantile (0 < q < 1) by linear interpolation within the bucket
+// that contains it. Quantiles in the +Inf bucket are reported as the largest upper bound
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 || len(s.Buckets) == 0 {
+		return 0
+	}
+
+	rank := q * float64(s.Count)
+	lower, below := 0.0, uint64(0)
+	for i, upper := range s.Buckets {
+		if float64(s.Cumulative[i]) >= rank {
+			inBucket := s.Cumulative[i] - below
+			seconds := upper
+			if inBucket > 0 {
+				seconds = lower + (upper-lower)*(rank-float64(below))/float64(inBucket)
+			}
+			return time.Duration(seconds * float64(time.Second))
+		}
+		lower, below = upper, s.Cumulative[i]
+	}
+
+	return time.Duration(s.Buckets[len(s.Buckets)-1] * float64(time.Second))
+}
@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 1, 10})
+
+	assert.Equal(t, time.Duration(0), h.Snapshot().Quantile(0.5))
+
+	for _, d := range []time.Duration{
+		-time.Second,
+		50 * time.Millisecond,
+		500 * time.Millisecond,
+		600 * time.Millisecond,
+		2 * time.Second,
+		time.Minute,
+	} {
+		h.Observe(d)
+	}
+
+	snapshot := h.Snapshot()
+	assert.Equal(t, []float64{0.1, 1, 10}, snapshot.Buckets)
+	assert.Equal(t, []uint64{2, 4, 5}, snapshot.Cumulative)
+	assert.Equal(t, uint64(6), snapshot.Count)
+	assert.InDelta(t, 63.15, snapshot.Sum, 0.0001)
+
+	// rank 3 is the first of the two observations in the (0.1, 1] bucket
+	assert.Equal(t, 550*time.Millisecond, snapshot.Quantile(0.5))
+	// quantiles in the +Inf bucket are reported as the largest upper bound
+	assert.Equal(t, 10*time.Second, snapshot.Quantile(0.95))
+}
+
+func TestTracker_Latency(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.RecordForwarded(api.Message{AppID: 1}, "123")
+	assert.Equal(t, uint64(0), tracker.Latency().Count)
+	assert.Equal(t, uint64(0), tracker.Age().Count)
+
+	now := time.Now()
+	tracker.RecordForwarded(api.Message{AppID: 1, ReceivedAt: now, Date: now.Add(-time.Minute)}, "123")
+
+	latency := tracker.Latency()
+	assert.Equal(t, uint64(1), latency.Count)
+	assert.Equal(t, uint64(1), latency.Cumulative[0])
+
+	age := tracker.Age()
+	assert.Equal(t, uint64(1), age.Count)
+	assert.Less(t, age.Quantile(0.5), 2*time.Minute)
+	assert.GreaterOrEqual(t, age.Quantile(0.5), 30*time.Second)
+}
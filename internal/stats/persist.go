@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"errors"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+)
+
+// storageBucket is the storage bucket of the statistics
+const storageBucket = "stats"
+
+// snapshot is the persisted state of the tracker
+type snapshot struct {
+	Forwarded uint64     `json:"forwarded"`
+	Shed      uint64     `json:"shed"`
+	Apps      []AppStats `json:"apps"`
+}
+
+// Save persists the message counters in the store
+func (t *Tracker) Save(store storage.Store) error {
+	t.mu.RLock()
+	data := snapshot{Forwarded: t.forwarded, Shed: t.shed}
+	for _, app := range t.apps {
+		data.Apps = append(data.Apps, *app)
+	}
+	t.mu.RUnlock()
+
+	return storage.PutJSON(store, storageBucket, "snapshot", data)
+}
+
+// Load restores the message counters from the store. Statistics recorded
+// since the tracker was created are added to the stored ones
+func (t *Tracker) Load(store storage.Store) error {
+	var data snapshot
+	if err := storage.GetJSON(store, storageBucket, "snapshot", &data); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.forwarded += data.Forwarded
+	t.shed += data.Shed
+	for _, stored := range data.Apps {
+		app, ok := t.apps[stored.AppID]
+		if !ok {
+			stored := stored
+			t.apps[stored.AppID] = &stored
+			continue
+		}
+
+		app.Received += stored.Received
+		app.Forwarded += stored.Forwarded
+		app.Shed += stored.Shed
+		if app.AppName == "" {
+			app.AppName = stored.AppName
+		}
+		if app.LastReceived.IsZero() {
+			app.LastReceived = stored.LastReceived
+		}
+		if app.LastForwarded.IsZero() {
+			app.LastForwarded = stored.LastForwarded
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerStruct_SaveLoad(t *testing.T) {
+	store := storage.NewMemory()
+
+	// loading from an empty store is a no-op
+	tracker := NewTracker()
+	require.NoError(t, tracker.Load(store))
+	assert.Empty(t, tracker.Apps())
+
+	sonarr := api.Message{AppID: 2, AppName: "Sonarr"}
+	tracker.RecordReceived(sonarr)
+	tracker.RecordForwarded(sonarr, "123")
+	tracker.RecordShed(sonarr)
+	require.NoError(t, tracker.Save(store))
+
+	restored := NewTracker()
+	restored.RecordForwarded(sonarr, "456")
+	restored.RecordReceived(api.Message{AppID: 1, AppName: "backups"})
+	require.NoError(t, restored.Load(store))
+
+	assert.Equal(t, uint64(2), restored.Forwarded())
+	assert.Equal(t, uint64(1), restored.Shed())
+
+	apps := restored.Apps()
+	require.Len(t, apps, 2)
+	assert.Equal(t, "backups", apps[0].AppName)
+	assert.Equal(t, "Sonarr", apps[1].AppName)
+	assert.Equal(t, uint64(1), apps[1].Received)
+	assert.Equal(t, uint64(2), apps[1].Forwarded)
+	assert.Equal(t, uint64(1), apps[1].Shed)
+	assert.False(t, apps[1].LastReceived.IsZero())
+}
@@ -0,0 +1,158 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+)
+
+// AppStats contains the statistics of a single gotify application
+type AppStats struct {
+	AppID   uint32
+	AppName string
+	// Number of messages received from the gotify server
+	Received uint64
+	// Number of messages successfully sent to Telegram chats. A message sent to two chats counts twice
+	Forwarded uint64
+	// Number of messages dropped or digested because of load shedding
+	Shed uint64
+	// Time the last message was received
+	LastReceived time.Time
+	// Time the last message was successfully sent to a Telegram chat
+	LastForwarded time.Time
+}
+
+// Tracker tracks statistics about forwarded messages
+type Tracker struct {
+	mu        sync.RWMutex
+	forwarded uint64
+	shed      uint64
+	startedAt time.Time
+	apps      map[uint32]*AppStats
+	// errors is a ring buffer of the most recent errors
+	errors    []ErrorEntry
+	nextError int
+	// latency is the time from receiving a message from the websocket to sending it to a chat
+	latency *Histogram
+	// age is the time from creating a message in gotify to sending it to a chat
+	age *Histogram
+}
+
+// NewTracker creates a new statistics tracker
+func NewTracker() *Tracker {
+	return &Tracker{
+		startedAt: time.Now(),
+		apps:      make(map[uint32]*AppStats),
+		latency:   NewHistogram(LatencyBuckets),
+		age:       NewHistogram(LatencyBuckets),
+	}
+}
+
+// app returns the statistics of the message's app. Callers must hold the write lock
+func (t *Tracker) app(msg api.Message) *AppStats {
+	app, ok := t.apps[msg.AppID]
+	if !ok {
+		app = &AppStats{AppID: msg.AppID}
+		t.apps[msg.AppID] = app
+	}
+
+	if msg.AppName != "" {
+		app.AppName = msg.AppName
+	}
+
+	return app
+}
+
+// RecordReceived records a message that was received from the gotify server
+func (t *Tracker) RecordReceived(msg api.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	app := t.app(msg)
+	app.Received++
+	app.LastReceived = time.Now()
+}
+
+// RecordForwarded records a message that was successfully forwarded to a Telegram chat
+func (t *Tracker) RecordForwarded(msg api.Message, chatID string) {
+	now := time.Now()
+	if !msg.ReceivedAt.IsZero() {
+		t.latency.Observe(now.Sub(msg.ReceivedAt))
+	}
+	if !msg.Date.IsZero() {
+		t.age.Observe(now.Sub(msg.Date))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.forwarded++
+
+	app := t.app(msg)
+	app.Forwarded++
+	app.LastForwarded = now
+}
+
+// RecordShed records a message that was not forwarded because of load shedding
+func (t *Tracker) RecordShed(msg api.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.shed++
+	t.app(msg).Shed++
+}
+
+// Shed returns the total number of messages shed
+func (t *Tracker) Shed() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.shed
+}
+
+// Latency returns the histogram of the time from receiving messages from the websocket to sending them to a chat
+func (t *Tracker) Latency() HistogramSnapshot {
+	return t.latency.Snapshot()
+}
+
+// Age returns the histogram of the time from creating messages in gotify to sending them to a chat
+func (t *Tracker) Age() HistogramSnapshot {
+	return t.age.Snapshot()
+}
+
+// Forwarded returns the total number of forwarded messages
+func (t *Tracker) Forwarded() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.forwarded
+}
+
+// Apps returns a copy of the statistics of all applications sorted by app name
+func (t *Tracker) Apps() []AppStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	apps := make([]AppStats, 0, len(t.apps))
+	for _, app := range t.apps {
+		apps = append(apps, *app)
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		nameI, nameJ := strings.ToLower(apps[i].AppName), strings.ToLower(apps[j].AppName)
+		if nameI != nameJ {
+			return nameI < nameJ
+		}
+		return apps[i].AppID < apps[j].AppID
+	})
+
+	return apps
+}
+
+// StartedAt returns the time the tracker was created
+func (t *Tracker) StartedAt() time.Time {
+	return t.startedAt
+}
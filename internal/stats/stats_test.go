@@ -0,0 +1,45 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackerStruct_RecordForwarded(t *testing.T) {
+	tracker := NewTracker()
+	assert.Equal(t, uint64(0), tracker.Forwarded())
+	assert.False(t, tracker.StartedAt().IsZero())
+
+	tracker.RecordForwarded(api.Message{AppID: 1}, "123")
+	tracker.RecordForwarded(api.Message{AppID: 2}, "456")
+
+	assert.Equal(t, uint64(2), tracker.Forwarded())
+}
+
+func TestTrackerStruct_Apps(t *testing.T) {
+	tracker := NewTracker()
+
+	sonarr := api.Message{AppID: 2, AppName: "Sonarr"}
+	backups := api.Message{AppID: 1, AppName: "backups"}
+
+	tracker.RecordReceived(sonarr)
+	tracker.RecordForwarded(sonarr, "123")
+	tracker.RecordForwarded(sonarr, "456")
+	tracker.RecordReceived(backups)
+
+	apps := tracker.Apps()
+	assert.Len(t, apps, 2)
+
+	assert.Equal(t, "backups", apps[0].AppName)
+	assert.Equal(t, uint64(1), apps[0].Received)
+	assert.Equal(t, uint64(0), apps[0].Forwarded)
+	assert.False(t, apps[0].LastReceived.IsZero())
+	assert.True(t, apps[0].LastForwarded.IsZero())
+
+	assert.Equal(t, "Sonarr", apps[1].AppName)
+	assert.Equal(t, uint64(1), apps[1].Received)
+	assert.Equal(t, uint64(2), apps[1].Forwarded)
+	assert.False(t, apps[1].LastForwarded.IsZero())
+}
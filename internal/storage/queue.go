@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Queue is a persistent FIFO queue stored in a bucket of a store
+type Queue struct {
+	mu     sync.Mutex
+	store  Store
+	bucket string
+	next   uint64
+}
+
+// NewQueue creates a queue in the bucket. Items already stored in the bucket are kept
+func NewQueue(store Store, bucket string) (*Queue, error) {
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{store: store, bucket: bucket}
+	if len(keys) > 0 {
+		last, err := strconv.ParseUint(keys[len(keys)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid queue key %q: %w", keys[len(keys)-1], err)
+		}
+		q.next = last + 1
+	}
+
+	return q, nil
+}
+
+// Push appends a value to the queue
+func (q *Queue) Push(value []byte) error {
+	_, err := q.Add(value)
+	return err
+}
+
+// Add appends a value to the queue and returns its key
+func (q *Queue) Add(value []byte) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// keys are zero padded so that their lexical order is the insertion order
+	key := fmt.Sprintf("%020d", q.next)
+	if err := q.store.Put(q.bucket, key, value); err != nil {
+		return "", err
+	}
+	q.next++
+
+	return key, nil
+}
+
+// Remove removes the value of the key from the queue. Removing a missing key is not an error
+func (q *Queue) Remove(key string) error {
+	return q.store.Delete(q.bucket, key)
+}
+
+// Pop removes and returns the oldest value. Returns ErrNotFound if the queue is empty
+func (q *Queue) Pop() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keys, err := q.store.Keys(q.bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+
+	value, err := q.store.Get(q.bucket, keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.store.Delete(q.bucket, keys[0]); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Len returns the number of queued values
+func (q *Queue) Len() (int, error) {
+	keys, err := q.store.Keys(q.bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Items returns all queued values from the oldest to the newest without removing them
+func (q *Queue) Items() ([][]byte, error) {
+	entries, err := q.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, entry.Value)
+	}
+
+	return items, nil
+}
+
+// Entry is a queued value and its key
+type Entry struct {
+	Key   string
+	Value []byte
+}
+
+// Entries returns all queued values and their keys from the oldest to the newest without removing them
+func (q *Queue) Entries() ([]Entry, error) {
+	keys, err := q.store.Keys(q.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		value, err := q.store.Get(q.bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Key: key, Value: value})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,228 @@
+// Package storage provides the persistence layer of the plugin. Data is
+// organized in buckets of keys, each bucket holding a set of byte values.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSnapshotDelay is how long changes are collected before a snapshot is written to the backend
+const DefaultSnapshotDelay = time.Second
+
+// ErrNotFound is returned when a key does not exist in a bucket
+var ErrNotFound = errors.New("key not found")
+
+// Store is a key-value store organized in buckets
+type Store interface {
+	// Get returns the value of the key. Returns ErrNotFound if the key does not exist
+	Get(bucket, key string) ([]byte, error)
+	// Put sets the value of the key
+	Put(bucket, key string, value []byte) error
+	// Delete removes the key. Deleting a missing key is not an error
+	Delete(bucket, key string) error
+	// Keys returns the keys of the bucket in ascending order
+	Keys(bucket string) ([]string, error)
+	// Flush persists the pending changes
+	Flush() error
+}
+
+// Backend persists a snapshot of the whole store. The gotify plugin.StorageHandler
+// implements this interface
+type Backend interface {
+	Save(b []byte) error
+	Load() ([]byte, error)
+}
+
+// SnapshotStore keeps all data in memory and optionally writes a snapshot
+// to a backend. Changes are batched: a snapshot is written once the snapshot
+// delay elapsed after a change, without blocking the readers and writers
+type SnapshotStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+	backend Backend
+	delay   time.Duration
+
+	// dirty is true while changes are not persisted. Guarded by mu
+	dirty bool
+	// scheduled is true while a snapshot is scheduled. Guarded by mu
+	scheduled bool
+	// saveErr is the error of the last snapshot. Guarded by mu
+	saveErr error
+	// saveMu serializes the snapshot writes
+	saveMu sync.Mutex
+}
+
+// NewMemory creates a store that only keeps data in memory
+func NewMemory() *SnapshotStore {
+	return &SnapshotStore{
+		buckets: make(map[string]map[string][]byte),
+	}
+}
+
+// NewPersistent creates a store that loads its data from the backend and
+// saves a snapshot to the backend DefaultSnapshotDelay after changes
+func NewPersistent(backend Backend) (*SnapshotStore, error) {
+	s := NewMemory()
+	s.backend = backend
+	s.delay = DefaultSnapshotDelay
+
+	data, err := backend.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.buckets); err != nil {
+			return nil, fmt.Errorf("failed to decode storage: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Get returns the value of the key
+func (s *SnapshotStore) Get(bucket, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+// Put sets the value of the key
+func (s *SnapshotStore) Put(bucket, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string][]byte)
+	}
+	s.buckets[bucket][key] = append([]byte(nil), value...)
+
+	return s.changed()
+}
+
+// Delete removes the key
+func (s *SnapshotStore) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[bucket][key]; !ok {
+		return nil
+	}
+
+	delete(s.buckets[bucket], key)
+	if len(s.buckets[bucket]) == 0 {
+		delete(s.buckets, bucket)
+	}
+
+	return s.changed()
+}
+
+// Keys returns the keys of the bucket in ascending order
+func (s *SnapshotStore) Keys(bucket string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// changed schedules a snapshot after a change and returns the error of the last snapshot, so that
+// failing writes are still reported to the callers. Callers must hold the write lock
+func (s *SnapshotStore) changed() error {
+	if s.backend == nil {
+		return nil
+	}
+
+	s.dirty = true
+	s.schedule()
+
+	return s.saveErr
+}
+
+// schedule writes a snapshot after the delay unless one is scheduled already. Callers must hold the write lock
+func (s *SnapshotStore) schedule() {
+	if s.scheduled {
+		return
+	}
+	s.scheduled = true
+	time.AfterFunc(s.delay, func() { _ = s.Flush() })
+}
+
+// Flush writes a snapshot to the backend if there are pending changes. The data
+// is encoded under the read lock and written without holding the lock, so the
+// backend IO doesn't block the readers and writers
+func (s *SnapshotStore) Flush() error {
+	if s.backend == nil {
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	s.scheduled = false
+	if !s.dirty {
+		err := s.saveErr
+		s.mu.Unlock()
+		return err
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	s.mu.RLock()
+	data, err := json.Marshal(s.buckets)
+	s.mu.RUnlock()
+
+	if err != nil {
+		err = fmt.Errorf("failed to encode storage: %w", err)
+	} else if err = s.backend.Save(data); err != nil {
+		err = fmt.Errorf("failed to save storage: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveErr = err
+	if err != nil {
+		// the snapshot is retried after the delay
+		s.dirty = true
+		s.schedule()
+	}
+
+	return err
+}
+
+// GetJSON decodes the JSON value of the key into v
+func GetJSON(s Store, bucket, key string, v interface{}) error {
+	data, err := s.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// PutJSON stores v as JSON
+func PutJSON(s Store, bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(bucket, key, data)
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBackend is an in-memory Backend like the gotify storage handler
+type memoryBackend struct {
+	data    []byte
+	saves   int
+	loadErr error
+}
+
+func (m *memoryBackend) Save(b []byte) error {
+	m.data = b
+	m.saves++
+	return nil
+}
+
+func (m *memoryBackend) Load() ([]byte, error) {
+	return m.data, m.loadErr
+}
+
+func TestSnapshotStore(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(t *testing.T) Store
+	}{
+		{
+			name:  "memory",
+			store: func(t *testing.T) Store { return NewMemory() },
+		},
+		{
+			name: "persistent",
+			store: func(t *testing.T) Store {
+				s, err := NewPersistent(&memoryBackend{})
+				require.NoError(t, err)
+				return s
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.store(t)
+
+			_, err := s.Get("bucket", "missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, s.Put("bucket", "b", []byte("2")))
+			require.NoError(t, s.Put("bucket", "a", []byte("1")))
+			require.NoError(t, s.Put("other", "c", []byte("3")))
+
+			value, err := s.Get("bucket", "a")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("1"), value)
+
+			keys, err := s.Keys("bucket")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a", "b"}, keys)
+
+			require.NoError(t, s.Delete("bucket", "a"))
+			require.NoError(t, s.Delete("bucket", "missing"))
+			_, err = s.Get("bucket", "a")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			keys, err = s.Keys("empty")
+			require.NoError(t, err)
+			assert.Empty(t, keys)
+		})
+	}
+}
+
+func TestNewPersistent(t *testing.T) {
+	backend := &memoryBackend{}
+	s, err := NewPersistent(backend)
+	require.NoError(t, err)
+
+	require.NoError(t, PutJSON(s, "stats", "forwarded", 42))
+	require.NoError(t, PutJSON(s, "stats", "received", 43))
+	assert.Zero(t, backend.saves, "changes are written after the snapshot delay")
+	require.NoError(t, s.Flush())
+	require.NoError(t, s.Flush())
+	assert.Equal(t, 1, backend.saves, "changes are written in one snapshot")
+
+	reloaded, err := NewPersistent(backend)
+	require.NoError(t, err)
+
+	var forwarded int
+	require.NoError(t, GetJSON(reloaded, "stats", "forwarded", &forwarded))
+	assert.Equal(t, 42, forwarded)
+
+	_, err = NewPersistent(&memoryBackend{loadErr: errors.New("boom")})
+	assert.ErrorContains(t, err, "failed to load storage: boom")
+
+	_, err = NewPersistent(&memoryBackend{data: []byte("not json")})
+	assert.ErrorContains(t, err, "failed to decode storage")
+}
+
+func TestSnapshotStore_ScheduledSnapshot(t *testing.T) {
+	backend := &syncBackend{err: errors.New("disk full")}
+	s, err := NewPersistent(backend)
+	require.NoError(t, err)
+	s.delay = time.Millisecond
+
+	require.NoError(t, s.Put("bucket", "a", []byte("1")))
+	assert.Eventually(t, func() bool {
+		return s.Put("bucket", "b", []byte("2")) != nil
+	}, time.Second, time.Millisecond, "failed snapshots are reported by the next change")
+
+	backend.setErr(nil)
+	assert.Eventually(t, func() bool {
+		return string(backend.snapshot()) == `{"bucket":{"a":"MQ==","b":"Mg=="}}`
+	}, time.Second, time.Millisecond, "the changes of a failed snapshot are written with the next one")
+	assert.NoError(t, s.Put("bucket", "c", []byte("3")))
+	assert.NoError(t, s.Flush())
+}
+
+// syncBackend is a Backend that can be used by the scheduled snapshots concurrently
+type syncBackend struct {
+	mu   sync.Mutex
+	err  error
+	data []byte
+}
+
+func (b *syncBackend) Save(data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.data = data
+	}
+	return b.err
+}
+
+func (b *syncBackend) Load() ([]byte, error) {
+	return nil, nil
+}
+
+func (b *syncBackend) snapshot() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data
+}
+
+func (b *syncBackend) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+func TestFileBackend(t *testing.T) {
+	backend := FileBackend{Path: filepath.Join(t.TempDir(), "storage.json")}
+
+	data, err := backend.Load()
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	s, err := NewPersistent(backend)
+	require.NoError(t, err)
+	require.NoError(t, s.Put("bucket", "key", []byte("value")))
+	require.NoError(t, s.Flush())
+
+	reloaded, err := NewPersistent(backend)
+	require.NoError(t, err)
+	value, err := reloaded.Get("bucket", "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestQueue(t *testing.T) {
+	s := NewMemory()
+	q, err := NewQueue(s, "queue")
+	require.NoError(t, err)
+
+	_, err = q.Pop()
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, value := range []string{"1", "2", "3"} {
+		require.NoError(t, q.Push([]byte(value)))
+	}
+
+	value, err := q.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+
+	// a new queue on the same bucket continues after the stored items
+	q, err = NewQueue(s, "queue")
+	require.NoError(t, err)
+	require.NoError(t, q.Push([]byte("4")))
+
+	length, err := q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 3, length)
+
+	items, err := q.Items()
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("2"), []byte("3"), []byte("4")}, items)
+
+	for _, expected := range []string{"2", "3", "4"} {
+		value, err := q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, []byte(expected), value)
+	}
+}
+
+func TestQueue_Remove(t *testing.T) {
+	q, err := NewQueue(NewMemory(), "queue")
+	require.NoError(t, err)
+
+	var keys []string
+	for _, value := range []string{"1", "2", "3"} {
+		key, err := q.Add([]byte(value))
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	require.NoError(t, q.Remove(keys[1]))
+	require.NoError(t, q.Remove(keys[1]), "removing a missing key")
+
+	entries, err := q.Entries()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{Key: keys[0], Value: []byte("1")}, {Key: keys[2], Value: []byte("3")}}, entries)
+}
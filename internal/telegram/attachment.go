@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// attachmentExtra is the extras key of a file that is uploaded to Telegram as a document. The value is
+// either the URL of the file or an object with the url and an optional filename
+const attachmentExtra = "telegram::attachment"
+
+// Filenames of the documents large extras and long messages are sent as
+const (
+	extrasFilename  = "extras.json"
+	messageFilename = "message.txt"
+)
+
+// AttachmentPolicy limits the files that are downloaded and uploaded to Telegram as documents
+type AttachmentPolicy struct {
+	// AllowedHosts are the hosts files are downloaded from. No hosts disables attachments
+	AllowedHosts []string
+	// PrivateHosts are the allowed hosts that may resolve to loopback, private or link-local addresses.
+	// Downloads from other hosts resolving to such addresses are rejected
+	PrivateHosts []string
+	// MaxSize is the maximum size of a file in bytes
+	MaxSize int64
+}
+
+// scope returns the hosts attachments and their redirects may be downloaded from
+func (p AttachmentPolicy) scope() *downloadScope {
+	return &downloadScope{allowedHosts: p.AllowedHosts, privateHosts: p.PrivateHosts}
+}
+
+// attachment is a file referenced by the attachment extra of a message
+type attachment struct {
+	url      *url.URL
+	filename string
+}
+
+// attachmentOf returns the attachment of the message, if any
+func attachmentOf(msg api.Message) (*attachment, error) {
+	var rawURL, filename string
+	switch value := msg.Extras[attachmentExtra].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		rawURL = value
+	case map[string]interface{}:
+		rawURL, _ = value["url"].(string)
+		filename, _ = value["filename"].(string)
+	default:
+		return nil, fmt.Errorf("%s must be a url or an object with a url", attachmentExtra)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%s has an invalid url %q", attachmentExtra, rawURL)
+	}
+
+	if filename == "" {
+		filename = path.Base(u.Path)
+	}
+	if filename == "" || filename == "/" || filename == "." {
+		filename = "attachment"
+	}
+
+	return &attachment{url: u, filename: filename}, nil
+}
+
+// downloadAttachment downloads the file of the attachment if its host is allowed
+func (c *Client) downloadAttachment(a *attachment) ([]byte, error) {
+	return c.download(a.url.String(), c.attachments.scope(), c.attachments.MaxSize)
+}
+
+// fetchAttachment returns the attachment of the message and its file. Messages without an attachment or
+// with attachments that can't be downloaded return nil, so the message is sent without the attachment
+func (c *Client) fetchAttachment(message api.Message, chatID string) (*attachment, []byte) {
+	if len(c.attachments.AllowedHosts) == 0 {
+		return nil, nil
+	}
+
+	a, err := attachmentOf(message)
+	if err == nil && a != nil {
+		var data []byte
+		if data, err = c.downloadAttachment(a); err == nil {
+			return a, data
+		}
+	}
+	if err != nil {
+		c.logger.Warn().
+			Err(err).
+			Uint32("message_id", message.Id).
+			Str("chat_id", chatID).
+			Msg("failed to fetch attachment. Sending the message without it")
+	}
+
+	return nil, nil
+}
+
+// sendLongMessage uploads the message as a plain text document captioned with its title
+func (c *Client) sendLongMessage(message api.Message, token, chatID string, formatOpts config.MessageFormatOptions, keyboard *InlineKeyboardMarkup, replyTo int64) ([]SentPart, error) {
+	plainOpts := formatOpts
+	plainOpts.ParseMode = ParseModeNone
+	text, err := FormatMessage(message, plainOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	caption, err := FormatCaption(message, formatOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.sendDocument(message, token, chatID, &attachment{filename: messageFilename}, []byte(text), caption, formatOpts, keyboard, replyTo)
+}
+
+// sendDocument uploads the file of the attachment with the formatted message as the caption. Text that
+// doesn't fit into the caption is sent as a follow-up message. The keyboard is shown below the last message
+func (c *Client) sendDocument(message api.Message, token, chatID string, a *attachment, data []byte, text string, formatOpts config.MessageFormatOptions, keyboard *InlineKeyboardMarkup, replyTo int64) ([]SentPart, error) {
+	title, err := FormatCaption(message, formatOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	caption, overflow := splitCaption(text, title)
+	captionKeyboard := keyboard
+	if overflow != "" {
+		captionKeyboard = nil
+	}
+
+	c.logger.Debug().
+		Str("chat_id", chatID).
+		Str("filename", a.filename).
+		Int("size", len(data)).
+		Msg("uploading attachment to Telegram API")
+
+	var result messageResult
+	captionText, entities := messageText(caption, formatOpts.ParseMode)
+	fields := [][2]string{
+		{"chat_id", chatID},
+		{"message_thread_id", threadField(formatOpts.MessageThreadID)},
+		{"caption", captionText},
+		{"parse_mode", apiParseMode(formatOpts.ParseMode)},
+		{"caption_entities", entitiesField(entities)},
+		{"protect_content", boolField(formatOpts.ProtectContent)},
+		{"reply_parameters", replyParametersField(replyTo)},
+		{"reply_markup", replyMarkupField(captionKeyboard)},
+	}
+	c.waitForChat(chatID, 1)
+	if err := c.callMethodWithFile(token, "sendDocument", fields, "document", a.filename, data, &result); err != nil {
+		return nil, err
+	}
+
+	parts := []SentPart{{MessageID: result.MessageID, Text: caption, Caption: true}}
+	if overflow == "" {
+		return parts, nil
+	}
+
+	overflowParts, err := c.sendMessage(token, chatID, overflow, formatOpts, keyboard, 0)
+	return append(parts, overflowParts...), err
+}
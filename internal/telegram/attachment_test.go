@@ -0,0 +1,327 @@
+package telegram
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachmentOf(t *testing.T) {
+	tests := []struct {
+		name             string
+		extra            interface{}
+		expectedURL      string
+		expectedFilename string
+		expectedError    string
+	}{
+		{name: "no attachment"},
+		{
+			name:             "url",
+			extra:            "https://files.example.com/reports/backup.log",
+			expectedURL:      "https://files.example.com/reports/backup.log",
+			expectedFilename: "backup.log",
+		},
+		{
+			name:             "object with filename",
+			extra:            map[string]interface{}{"url": "https://files.example.com/download?id=1", "filename": "report.pdf"},
+			expectedURL:      "https://files.example.com/download?id=1",
+			expectedFilename: "report.pdf",
+		},
+		{
+			name:             "url without path",
+			extra:            "https://files.example.com",
+			expectedURL:      "https://files.example.com",
+			expectedFilename: "attachment",
+		},
+		{name: "relative url", extra: "/reports/backup.log", expectedError: `telegram::attachment has an invalid url "/reports/backup.log"`},
+		{name: "invalid value", extra: 42.0, expectedError: "telegram::attachment must be a url or an object with a url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := api.Message{Extras: map[string]interface{}{}}
+			if tt.extra != nil {
+				msg.Extras[attachmentExtra] = tt.extra
+			}
+
+			a, err := attachmentOf(msg)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			if tt.expectedURL == "" {
+				assert.Nil(t, a)
+				return
+			}
+			assert.Equal(t, tt.expectedURL, a.url.String())
+			assert.Equal(t, tt.expectedFilename, a.filename)
+		})
+	}
+}
+
+func TestAttachmentPolicy_scope(t *testing.T) {
+	policy := AttachmentPolicy{AllowedHosts: []string{"gotify.local:8080", "files.example.com"}}
+
+	for rawURL, expected := range map[string]bool{
+		"http://gotify.local:8080/file":      true,
+		"http://gotify.local/file":           false,
+		"https://FILES.example.com/file":     true,
+		"https://files.example.com:443/f":    true,
+		"https://evil.example.com/file":      false,
+		"ftp://files.example.com/file":       false,
+		"file://files.example.com/etc/hosts": false,
+	} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		assert.Equal(t, expected, policy.scope().allows(u), rawURL)
+	}
+}
+
+func TestClientStruct_Send_Attachment(t *testing.T) {
+	msg := api.Message{
+		Title:   "Backup",
+		Message: "finished",
+		Extras:  map[string]interface{}{attachmentExtra: "https://files.example.com/backup.log"},
+	}
+
+	tests := []struct {
+		name           string
+		policy         AttachmentPolicy
+		file           string
+		expectedMethod string
+	}{
+		{
+			name:           "uploads the attachment",
+			policy:         AttachmentPolicy{AllowedHosts: []string{"files.example.com"}, MaxSize: 1024},
+			file:           "backup ok",
+			expectedMethod: "sendDocument",
+		},
+		{
+			name:           "attachments disabled",
+			file:           "backup ok",
+			expectedMethod: "sendMessage",
+		},
+		{
+			name:           "host not allowed",
+			policy:         AttachmentPolicy{AllowedHosts: []string{"gotify.local"}, MaxSize: 1024},
+			file:           "backup ok",
+			expectedMethod: "sendMessage",
+		},
+		{
+			name:           "file too large",
+			policy:         AttachmentPolicy{AllowedHosts: []string{"files.example.com"}, MaxSize: 4},
+			file:           "backup ok",
+			expectedMethod: "sendMessage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var methods []string
+			var document, caption string
+			client := NewClient(Config{ErrChan: make(chan error, 1), Attachments: tt.policy})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					if req.URL.Host == "files.example.com" {
+						return &http.Response{
+							StatusCode:    http.StatusOK,
+							ContentLength: -1,
+							Body:          io.NopCloser(strings.NewReader(tt.file)),
+						}, nil
+					}
+
+					methods = append(methods, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+					if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+						form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(1 << 20)
+						require.NoError(t, err)
+						caption = form.Value["caption"][0]
+						file, err := form.File["document"][0].Open()
+						require.NoError(t, err)
+						data, err := io.ReadAll(file)
+						require.NoError(t, err)
+						document = form.File["document"][0].Filename + ":" + string(data)
+					}
+
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":1}}`)),
+					}, nil
+				},
+			}
+
+			client.Send(msg, "token", "123", config.MessageFormatOptions{ParseMode: ParseModeHTML})
+
+			assert.Equal(t, []string{tt.expectedMethod}, methods)
+			if tt.expectedMethod == "sendDocument" {
+				assert.Equal(t, "backup.log:backup ok", document)
+				assert.Equal(t, "<b>Backup</b>\n\nfinished\n\n", caption)
+			}
+		})
+	}
+}
+
+func TestClientStruct_Send_ExtrasFile(t *testing.T) {
+	extras := map[string]interface{}{"log": strings.Repeat("x", 40)}
+	withAttachment := map[string]interface{}{"log": strings.Repeat("x", 40), attachmentExtra: "https://files.example.com/backup.log"}
+
+	tests := []struct {
+		name              string
+		extras            map[string]interface{}
+		size              int
+		expectedMethods   []string
+		expectedCaptions  []string
+		expectedDocuments []string
+	}{
+		{
+			name:            "small extras are inlined",
+			extras:          extras,
+			size:            100,
+			expectedMethods: []string{"sendMessage"},
+		},
+		{
+			name:              "large extras are uploaded with the message as the caption",
+			extras:            extras,
+			size:              10,
+			expectedMethods:   []string{"sendDocument"},
+			expectedCaptions:  []string{"<b>Backup</b>\n\nfinished\n\n"},
+			expectedDocuments: []string{"extras.json:{\n  \"log\": \"" + strings.Repeat("x", 40) + "\"\n}"},
+		},
+		{
+			name:             "large extras follow an attachment",
+			extras:           withAttachment,
+			size:             10,
+			expectedMethods:  []string{"sendDocument", "sendDocument"},
+			expectedCaptions: []string{"<b>Backup</b>\n\nfinished\n\n", ""},
+			expectedDocuments: []string{
+				"backup.log:backup ok",
+				"extras.json:{\n  \"log\": \"" + strings.Repeat("x", 40) + "\",\n  \"telegram::attachment\": \"https://files.example.com/backup.log\"\n}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var methods, captions, documents []string
+			policy := AttachmentPolicy{AllowedHosts: []string{"files.example.com"}, MaxSize: 1024}
+			client := NewClient(Config{ErrChan: make(chan error, 1), Attachments: policy})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					if req.URL.Host == "files.example.com" {
+						return &http.Response{
+							StatusCode:    http.StatusOK,
+							ContentLength: -1,
+							Body:          io.NopCloser(strings.NewReader("backup ok")),
+						}, nil
+					}
+
+					methods = append(methods, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+					if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+						form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(1 << 20)
+						require.NoError(t, err)
+						captions = append(captions, strings.Join(form.Value["caption"], ""))
+						file, err := form.File["document"][0].Open()
+						require.NoError(t, err)
+						data, err := io.ReadAll(file)
+						require.NoError(t, err)
+						documents = append(documents, form.File["document"][0].Filename+":"+string(data))
+					}
+
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":1}}`)),
+					}, nil
+				},
+			}
+
+			msg := api.Message{Title: "Backup", Message: "finished", Extras: tt.extras}
+			client.Send(msg, "token", "123", config.MessageFormatOptions{
+				ParseMode:       ParseModeHTML,
+				IncludeExtras:   true,
+				LargeExtras:     config.LargeExtrasFile,
+				LargeExtrasSize: tt.size,
+			})
+
+			assert.Equal(t, tt.expectedMethods, methods)
+			assert.Equal(t, tt.expectedCaptions, captions)
+			assert.Equal(t, tt.expectedDocuments, documents)
+		})
+	}
+}
+
+func TestClientStruct_Send_LongMessageDocument(t *testing.T) {
+	long := strings.Repeat("log line\n", 500)
+
+	tests := []struct {
+		name              string
+		body              string
+		style             string
+		expectedMethods   []string
+		expectedCaptions  []string
+		expectedDocuments []string
+	}{
+		{
+			name:            "long messages are split by default",
+			body:            long,
+			expectedMethods: []string{"sendMessage", "sendMessage"},
+		},
+		{
+			name:            "short messages are sent as text",
+			body:            "finished",
+			style:           config.LongMessagesDocument,
+			expectedMethods: []string{"sendMessage"},
+		},
+		{
+			name:              "long messages are uploaded as a document",
+			body:              long,
+			style:             config.LongMessagesDocument,
+			expectedMethods:   []string{"sendDocument"},
+			expectedCaptions:  []string{"<b>Backup &lt;1&gt;</b>"},
+			expectedDocuments: []string{"message.txt:Backup <1>\n\n" + long + "\n\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var methods, captions, documents []string
+			client := NewClient(Config{ErrChan: make(chan error, 1)})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					methods = append(methods, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+					if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+						form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(1 << 20)
+						require.NoError(t, err)
+						captions = append(captions, strings.Join(form.Value["caption"], ""))
+						file, err := form.File["document"][0].Open()
+						require.NoError(t, err)
+						data, err := io.ReadAll(file)
+						require.NoError(t, err)
+						documents = append(documents, form.File["document"][0].Filename+":"+string(data))
+					}
+
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":1}}`)),
+					}, nil
+				},
+			}
+
+			msg := api.Message{Title: "Backup <1>", Message: tt.body}
+			client.Send(msg, "token", "123", config.MessageFormatOptions{ParseMode: ParseModeHTML, LongMessages: tt.style})
+
+			assert.Equal(t, tt.expectedMethods, methods)
+			assert.Equal(t, tt.expectedCaptions, captions)
+			assert.Equal(t, tt.expectedDocuments, documents)
+		})
+	}
+}
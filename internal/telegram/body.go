@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"regexp"
+	"strings"
+)
+
+// codeRegex matches fenced code blocks and code spans
+var codeRegex = regexp.MustCompile("(?s)```[^\n`]*\n(.*?)\n?```|`([^`\n]+)`")
+
+// formatBody renders the code blocks, code spans and inline links of a message body with the markup
+// and escapes the text around them
+func formatBody(text string, m markup) string {
+	var builder strings.Builder
+
+	last := 0
+	for _, match := range codeRegex.FindAllStringSubmatchIndex(text, -1) {
+		builder.WriteString(formatLinks(text[last:match[0]], m))
+		last = match[1]
+
+		if match[2] >= 0 {
+			builder.WriteString(m.pre(text[match[2]:match[3]]))
+		} else {
+			builder.WriteString(m.code(text[match[4]:match[5]]))
+		}
+	}
+	builder.WriteString(formatLinks(text[last:], m))
+
+	return builder.String()
+}
+
+// formatLinks renders the inline links of the text with the markup and escapes the text around them.
+// Images and links without a label are replaced by their URL
+func formatLinks(text string, m markup) string {
+	var builder strings.Builder
+
+	last := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '[' && !strings.HasPrefix(text[i:], "![") {
+			continue
+		}
+
+		n, label, url, image := parseLink(text[i:])
+		if n == 0 {
+			continue
+		}
+
+		builder.WriteString(m.escape(text[last:i]))
+		if image || label == "" {
+			builder.WriteString(m.escape(url))
+		} else {
+			builder.WriteString(m.link(label, url))
+		}
+		i += n - 1
+		last = i + 1
+	}
+	builder.WriteString(m.escape(text[last:]))
+
+	return builder.String()
+}
+
+// parseLink parses the inline link or image at the start of the text. It returns the length
+// of the link or 0 if the text doesn't start with a link. Labels may span several lines and
+// contain balanced brackets, URLs may contain balanced parentheses but no whitespace
+func parseLink(text string) (n int, label, url string, image bool) {
+	start := 0
+	if strings.HasPrefix(text, "!") {
+		image = true
+		start = 1
+	}
+	if !strings.HasPrefix(text[start:], "[") {
+		return 0, "", "", false
+	}
+
+	labelEnd := matchingBracket(text, start, '[', ']')
+	if labelEnd < 0 || !strings.HasPrefix(text[labelEnd+1:], "(") {
+		return 0, "", "", false
+	}
+
+	urlEnd := matchingBracket(text, labelEnd+1, '(', ')')
+	if urlEnd < 0 || urlEnd == labelEnd+2 || strings.ContainsAny(text[labelEnd+2:urlEnd], " \t\r\n") {
+		return 0, "", "", false
+	}
+
+	return urlEnd + 1, text[start+1 : labelEnd], text[labelEnd+2 : urlEnd], image
+}
+
+// matchingBracket returns the index of the bracket closing the one at open or -1 if it isn't closed
+func matchingBracket(text string, open int, opening, closing byte) int {
+	depth := 0
+	for i := open; i < len(text); i++ {
+		switch text[i] {
+		case opening:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrCircuitOpen is returned for requests that are not made because the Telegram API is unavailable
+var ErrCircuitOpen = errors.New("telegram API is unavailable. Circuit breaker is open")
+
+// circuitProbeInterval is how often callers waiting for the circuit check again while a probe request is made
+const circuitProbeInterval = time.Second
+
+// CircuitBreakerPolicy stops requests to the Telegram API after consecutive network errors and 5xx responses
+// until the API is available again. The zero value disables the circuit breaker
+type CircuitBreakerPolicy struct {
+	// Consecutive failed requests after which the circuit opens
+	FailureThreshold int
+	// Duration the circuit stays open before a single probe request is allowed
+	OpenDuration time.Duration
+}
+
+// circuitState is the state of the circuit breaker
+type circuitState int
+
+const (
+	// circuitClosed allows all requests
+	circuitClosed circuitState = iota
+	// circuitOpen rejects all requests until the open duration elapsed
+	circuitOpen
+	// circuitHalfOpen allows a single probe request. Its result closes or opens the circuit again
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker tracks the consecutive failures of the requests to the Telegram API. A nil circuit breaker
+// allows all requests
+type circuitBreaker struct {
+	policy CircuitBreakerPolicy
+	logger *zerolog.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(policy CircuitBreakerPolicy, logger *zerolog.Logger) *circuitBreaker {
+	return &circuitBreaker{policy: policy, logger: logger, now: time.Now}
+}
+
+// allow returns ErrCircuitOpen if a request must not be made. Once the open duration elapsed, the next
+// request is allowed as a probe while other requests are still rejected
+func (b *circuitBreaker) allow() error {
+	if b == nil || b.policy.FailureThreshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.policy.OpenDuration {
+			return ErrCircuitOpen
+		}
+		b.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
+
+// record records the result of a request. Network errors and 5xx responses are failures. Any other response
+// shows that the API is available
+func (b *circuitBreaker) record(err error) {
+	if b == nil || b.policy.FailureThreshold <= 0 {
+		return
+	}
+
+	failed := false
+	if err != nil {
+		class := classify(err)
+		failed = class == classNetwork || class == classServer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		if b.state != circuitClosed {
+			b.logger.Info().Msg("Telegram API is available again. Closing circuit breaker")
+		}
+		b.state, b.failures = circuitClosed, 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= b.policy.FailureThreshold) {
+		if b.state == circuitClosed {
+			b.logger.Warn().
+				Int("failures", b.failures).
+				Dur("open_duration", b.policy.OpenDuration).
+				Msg("Telegram API is unavailable. Opening circuit breaker")
+		}
+		b.state, b.openedAt = circuitOpen, b.now()
+	}
+}
+
+// wait returns how long to wait until a request may be made, 0 if a request may be made now
+func (b *circuitBreaker) wait() time.Duration {
+	if b == nil || b.policy.FailureThreshold <= 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		return max(0, b.policy.OpenDuration-b.now().Sub(b.openedAt))
+	case circuitHalfOpen:
+		return circuitProbeInterval
+	default:
+		return 0
+	}
+}
+
+// currentState returns the state of the circuit breaker
+func (b *circuitBreaker) currentState() circuitState {
+	if b == nil {
+		return circuitClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// CircuitState returns the state of the circuit breaker around the Telegram API: closed, open or half-open
+func (c *Client) CircuitState() string {
+	return c.breaker.currentState().String()
+}
+
+// WaitForAPI blocks while the circuit breaker is open, so that messages stay queued during a Telegram outage
+// instead of failing. It returns false if the context is done first
+func (c *Client) WaitForAPI(ctx context.Context) bool {
+	for {
+		d := c.breaker.wait()
+		if d <= 0 {
+			return true
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStruct_CircuitBreaker(t *testing.T) {
+	var (
+		requests int
+		status   = http.StatusBadGateway
+	)
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	client := NewClient(Config{
+		ErrChan:        make(chan error, 1),
+		Retry:          RetryPolicy{ServerErrorRetries: 5},
+		CircuitBreaker: CircuitBreakerPolicy{FailureThreshold: 3, OpenDuration: time.Minute},
+	})
+	client.sleep = func(time.Duration) {}
+	client.breaker.now = func() time.Time { return now }
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			requests++
+			return response(status, `{"ok":false}`), nil
+		},
+	}
+	request := func() error {
+		_, err := client.makeRequestWithRetry("https://api.telegram.org/bottoken/sendMessage", jsonContentType, []byte("{}"))
+		return err
+	}
+
+	// the retries stop once the circuit opens
+	assert.ErrorIs(t, request(), ErrCircuitOpen)
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, "open", client.CircuitState())
+	assert.Equal(t, time.Minute, client.breaker.wait())
+
+	assert.ErrorIs(t, request(), ErrCircuitOpen)
+	assert.Equal(t, 3, requests, "no requests are made while the circuit is open")
+
+	// a failed probe opens the circuit again
+	now = now.Add(time.Minute)
+	assert.Zero(t, client.breaker.wait())
+	assert.ErrorIs(t, request(), ErrCircuitOpen)
+	assert.Equal(t, 4, requests)
+	assert.Equal(t, "open", client.CircuitState())
+
+	// a successful probe closes the circuit
+	now = now.Add(time.Minute)
+	status = http.StatusOK
+	assert.NoError(t, request())
+	assert.Equal(t, 5, requests)
+	assert.Equal(t, "closed", client.CircuitState())
+	assert.Zero(t, client.breaker.wait())
+}
+
+func TestCircuitBreakerStruct_Record(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	breaker := newCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 2, OpenDuration: time.Minute}, &logger)
+
+	breaker.record(&APIError{StatusCode: http.StatusForbidden})
+	breaker.record(&APIError{StatusCode: http.StatusTooManyRequests})
+	breaker.record(&networkError{errors.New("connection reset by peer")})
+	assert.Equal(t, circuitClosed, breaker.currentState(), "client errors and rate limits don't count")
+
+	breaker.record(nil)
+	breaker.record(&networkError{errors.New("connection reset by peer")})
+	assert.Equal(t, circuitClosed, breaker.currentState(), "successful requests reset the failures")
+
+	breaker.record(&APIError{StatusCode: http.StatusServiceUnavailable})
+	assert.Equal(t, circuitOpen, breaker.currentState())
+
+	// only a single probe is made while half-open
+	breaker.now = func() time.Time { return time.Now().Add(time.Minute) }
+	assert.NoError(t, breaker.allow())
+	assert.Equal(t, circuitHalfOpen, breaker.currentState())
+	assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+	assert.Equal(t, circuitProbeInterval, breaker.wait())
+
+	disabled := newCircuitBreaker(CircuitBreakerPolicy{}, &logger)
+	for i := 0; i < 10; i++ {
+		disabled.record(&networkError{errors.New("timeout")})
+	}
+	assert.NoError(t, disabled.allow())
+}
+
+func TestClientStruct_WaitForAPI(t *testing.T) {
+	client := NewClient(Config{
+		ErrChan:        make(chan error, 1),
+		CircuitBreaker: CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: time.Hour},
+	})
+	assert.True(t, client.WaitForAPI(context.Background()))
+
+	client.breaker.record(&networkError{errors.New("timeout")})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, client.WaitForAPI(ctx))
+}
+
+func TestClientStruct_Send_SplitMessageCircuitBreaker(t *testing.T) {
+	var chunks int
+	client := NewClient(Config{
+		ErrChan:        make(chan error, 2),
+		RateLimit:      RateLimitPolicy{ChatPerSecond: 1},
+		CircuitBreaker: CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: time.Minute},
+	})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			chunks++
+			return response(http.StatusOK, `{"ok":true,"result":{"message_id":1}}`), nil
+		},
+	}
+	// the circuit opens while the next chunk waits for the rate limit, e.g. because of failed sends to other chats
+	client.sleep = func(time.Duration) {
+		client.breaker.record(&networkError{errors.New("connection reset by peer")})
+	}
+
+	long := api.Message{Message: strings.Repeat("log line\n", 1000)}
+	formatOpts := config.MessageFormatOptions{ParseMode: ParseModeHTML}
+	require.NoError(t, client.Send(long, "token", "123", formatOpts))
+	assert.Equal(t, 3, chunks, "the remaining chunks of a message are sent once the first was sent")
+
+	// messages aren't started while the circuit is open
+	chunks = 0
+	client.sleep = func(time.Duration) {}
+	client.breaker.record(&networkError{errors.New("connection reset by peer")})
+	assert.ErrorIs(t, client.Send(long, "token", "123", formatOpts), ErrCircuitOpen)
+	assert.Zero(t, chunks)
+	assert.Empty(t, client.errChan, "messages stopped by the circuit breaker aren't reported as errors")
+}
@@ -0,0 +1,50 @@
+package telegram
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/chaos"
+)
+
+// chaosHTTPClient fails requests to the Telegram API with injected 429 and 500 responses. Other requests
+// made by the client, such as attachment and photo downloads, are passed through
+type chaosHTTPClient struct {
+	next  HTTPClient
+	chaos *chaos.Injector
+}
+
+func (c *chaosHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	switch {
+	case !isBotAPIRequest(req):
+		return c.next.Do(req)
+	case c.chaos.RateLimit():
+		return injectedResponse(req, http.StatusTooManyRequests,
+			`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1 (injected by chaos testing)","parameters":{"retry_after":1}}`), nil
+	case c.chaos.ServerError():
+		return injectedResponse(req, http.StatusInternalServerError,
+			`{"ok":false,"error_code":500,"description":"Internal Server Error (injected by chaos testing)"}`), nil
+	default:
+		return c.next.Do(req)
+	}
+}
+
+// isBotAPIRequest returns true for requests to the methods of the Telegram Bot API
+func isBotAPIRequest(req *http.Request) bool {
+	return req.URL.Host == apiHost && strings.HasPrefix(req.URL.Path, "/bot")
+}
+
+func injectedResponse(req *http.Request, statusCode int, body string) *http.Response {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{jsonContentType}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Request:    req,
+	}
+}
@@ -0,0 +1,91 @@
+package telegram
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/chaos"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosHTTPClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		chaos      config.Chaos
+		statusCode int
+		retryAfter int
+		requests   int
+	}{
+		{name: "no faults", chaos: config.Chaos{Enabled: true}, requests: 1},
+		{
+			name:       "rate limit",
+			chaos:      config.Chaos{Enabled: true, TelegramRateLimitPercent: 100},
+			statusCode: http.StatusTooManyRequests,
+			retryAfter: 1,
+		},
+		{
+			name:       "server error",
+			chaos:      config.Chaos{Enabled: true, TelegramServerErrorPercent: 100},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := NewClient(Config{Chaos: chaos.New(tt.chaos)})
+			client.httpClient.(*chaosHTTPClient).next = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					requests++
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+					}, nil
+				},
+			}
+
+			_, err := client.sendMessage("valid-token", "123456", "test", config.MessageFormatOptions{ParseMode: ParseModeHTML}, nil, 0)
+			assert.Equal(t, tt.requests, requests)
+			if tt.statusCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.statusCode, apiErr.StatusCode)
+			assert.Equal(t, tt.retryAfter, apiErr.RetryAfter)
+			assert.Contains(t, apiErr.Description, "injected by chaos testing")
+		})
+	}
+}
+
+func TestChaosHTTPClient_Downloads(t *testing.T) {
+	var downloads int
+	client := NewClient(Config{Chaos: chaos.New(config.Chaos{Enabled: true, TelegramRateLimitPercent: 100})})
+	client.httpClient.(*chaosHTTPClient).next = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			downloads++
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("file"))}, nil
+		},
+	}
+
+	data, err := client.download("https://files.example.com/report.pdf", &downloadScope{allowedHosts: []string{"files.example.com"}}, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, "file", string(data))
+	assert.Equal(t, 1, downloads, "downloads aren't failed by chaos testing")
+
+	_, err = client.sendMessage("valid-token", "123456", "test", config.MessageFormatOptions{ParseMode: ParseModeHTML}, nil, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 1, downloads, "bot API requests are failed before they are sent")
+}
+
+func TestNewClient_WithoutChaos(t *testing.T) {
+	client := NewClient(Config{})
+	assert.IsType(t, &http.Client{}, client.httpClient)
+}
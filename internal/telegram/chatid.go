@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chatResolveRetryInterval is how long usernames that couldn't be resolved are sent as is before they are
+// resolved again
+const chatResolveRetryInterval = 10 * time.Minute
+
+// resolvedChat is a cached chat username
+type resolvedChat struct {
+	// id is the numeric chat ID or the username if it couldn't be resolved
+	id string
+	// expires is when an unresolved username is resolved again. Zero for resolved usernames
+	expires time.Time
+}
+
+// chatCache caches the numeric IDs of chat usernames
+type chatCache struct {
+	mu    sync.Mutex
+	chats map[string]resolvedChat
+}
+
+// ChatPayload is the payload of the getChat method
+type ChatPayload struct {
+	ChatID string `json:"chat_id"`
+}
+
+// ChatInfo is the result of the getChat method
+type ChatInfo struct {
+	ID int64 `json:"id"`
+}
+
+// resolveChatID returns the numeric ID of a chat given as @username. Usernames are resolved once with
+// getChat and cached. Numeric IDs and usernames that can't be resolved are returned unchanged, in which
+// case Telegram resolves the username itself
+func (c *Client) resolveChatID(token, chatID string) string {
+	if !strings.HasPrefix(chatID, "@") {
+		return chatID
+	}
+
+	key := strings.ToLower(chatID)
+	now := time.Now()
+	c.chats.mu.Lock()
+	cached, ok := c.chats.chats[key]
+	c.chats.mu.Unlock()
+	if ok && (cached.expires.IsZero() || now.Before(cached.expires)) {
+		return cached.id
+	}
+
+	resolved := resolvedChat{id: chatID}
+	var chat ChatInfo
+	if err := c.callMethod(token, "getChat", ChatPayload{ChatID: chatID}, &chat); err != nil || chat.ID == 0 {
+		c.logger.Warn().
+			Err(err).
+			Str("chat_id", chatID).
+			Msg("failed to resolve chat username. Sending to the username")
+		resolved.expires = now.Add(chatResolveRetryInterval)
+	} else {
+		resolved.id = strconv.FormatInt(chat.ID, 10)
+		c.logger.Debug().
+			Str("chat_id", chatID).
+			Str("resolved_chat_id", resolved.id).
+			Msg("resolved chat username")
+	}
+
+	c.chats.mu.Lock()
+	if c.chats.chats == nil {
+		c.chats.chats = make(map[string]resolvedChat)
+	}
+	c.chats.chats[key] = resolved
+	c.chats.mu.Unlock()
+
+	return resolved.id
+}
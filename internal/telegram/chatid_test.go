@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+func TestClientStruct_resolveChatID(t *testing.T) {
+	tests := []struct {
+		name             string
+		chatID           string
+		getChatStatus    int
+		expected         string
+		expectedRequests int
+	}{
+		{name: "numeric ID", chatID: "-1001234567890", expected: "-1001234567890"},
+		{name: "username", chatID: "@alerts", getChatStatus: http.StatusOK, expected: "-1009876543210", expectedRequests: 1},
+		{name: "unknown username", chatID: "@alerts", getChatStatus: http.StatusBadRequest, expected: "@alerts", expectedRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			client := NewClient(Config{ErrChan: make(chan error, 1)})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					requests++
+					assert.True(t, strings.HasSuffix(req.URL.Path, "/getChat"))
+					return &http.Response{
+						StatusCode: tt.getChatStatus,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"id":-1009876543210,"type":"channel"}}`)),
+					}, nil
+				},
+			}
+
+			// the result is cached
+			assert.Equal(t, tt.expected, client.resolveChatID("token", tt.chatID))
+			assert.Equal(t, tt.expected, client.resolveChatID("token", strings.ToUpper(tt.chatID)))
+			assert.Equal(t, tt.expectedRequests, requests)
+		})
+	}
+}
+
+func TestClientStruct_Send_ResolvesUsername(t *testing.T) {
+	var (
+		chatIDs []string
+		sent    SentMessage
+	)
+	client := NewClient(Config{ErrChan: make(chan error, 1), OnSent: func(_ api.Message, s SentMessage) { sent = s }})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var payload Payload
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			chatIDs = append(chatIDs, payload.ChatID)
+			result := `{"message_id":42}`
+			if strings.HasSuffix(req.URL.Path, "/getChat") {
+				result = `{"id":-1009876543210,"type":"channel"}`
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":` + result + `}`)),
+			}, nil
+		},
+	}
+
+	client.Send(api.Message{Message: "done"}, "token", "@alerts", config.MessageFormatOptions{ParseMode: ParseModeHTML})
+	client.Send(api.Message{Message: "done"}, "token", "@alerts", config.MessageFormatOptions{ParseMode: ParseModeHTML})
+
+	assert.Equal(t, []string{"@alerts", "-1009876543210", "-1009876543210"}, chatIDs)
+	assert.Equal(t, "@alerts", sent.ChatID)
+}
@@ -2,13 +2,21 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/chaos"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/logger"
 	"github.com/rs/zerolog"
@@ -19,39 +27,420 @@ type HTTPClient interface {
 }
 
 type Payload struct {
-	ChatID    string `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode"`
+	ChatID             string                `json:"chat_id"`
+	MessageThreadID    int64                 `json:"message_thread_id,omitempty"`
+	Text               string                `json:"text"`
+	ParseMode          string                `json:"parse_mode,omitempty"`
+	Entities           []MessageEntity       `json:"entities,omitempty"`
+	LinkPreviewOptions *LinkPreviewOptions   `json:"link_preview_options,omitempty"`
+	ProtectContent     bool                  `json:"protect_content,omitempty"`
+	ReplyParameters    *ReplyParameters      `json:"reply_parameters,omitempty"`
+	ReplyMarkup        *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// ReplyParameters are the reply_parameters of the send methods
+type ReplyParameters struct {
+	MessageID                int64 `json:"message_id"`
+	AllowSendingWithoutReply bool  `json:"allow_sending_without_reply"`
+}
+
+// replyParameters returns the parameters replying to the message or nil for 0. The message is sent
+// without a reply if the replied message was deleted
+func replyParameters(messageID int64) *ReplyParameters {
+	if messageID == 0 {
+		return nil
+	}
+	return &ReplyParameters{MessageID: messageID, AllowSendingWithoutReply: true}
+}
+
+// replyParametersField encodes the reply parameters for a multipart form field. It is empty without a reply
+func replyParametersField(messageID int64) string {
+	if messageID == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(replyParameters(messageID))
+	return string(data)
+}
+
+// LinkPreviewOptions are the link_preview_options of the sendMessage method
+type LinkPreviewOptions struct {
+	IsDisabled       bool   `json:"is_disabled,omitempty"`
+	URL              string `json:"url,omitempty"`
+	PreferSmallMedia bool   `json:"prefer_small_media,omitempty"`
+	PreferLargeMedia bool   `json:"prefer_large_media,omitempty"`
+	ShowAboveText    bool   `json:"show_above_text,omitempty"`
+}
+
+// linkPreviewOptions returns the link preview options of the settings or nil to keep Telegram's defaults
+func linkPreviewOptions(preview config.LinkPreview) *LinkPreviewOptions {
+	if preview == (config.LinkPreview{}) {
+		return nil
+	}
+
+	return &LinkPreviewOptions{
+		IsDisabled:       preview.Disabled,
+		URL:              preview.URL,
+		PreferSmallMedia: preview.PreferSmallMedia,
+		PreferLargeMedia: preview.PreferLargeMedia,
+		ShowAboveText:    preview.ShowAboveText,
+	}
+}
+
+// APIError is returned when the Telegram API responds with a non 200 status code
+type APIError struct {
+	StatusCode  int
+	Description string
+	Body        string
+	// Seconds to wait before retrying. Only set for 429 responses
+	RetryAfter int
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Body:       string(body),
+	}
+
+	var res struct {
+		Description string `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(body, &res); err == nil {
+		apiErr.Description = res.Description
+		apiErr.RetryAfter = res.Parameters.RetryAfter
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// IsAuthError returns true if the bot token was rejected by the Telegram API
+func (e *APIError) IsAuthError() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusNotFound
+}
+
+// SentMessage describes a gotify message that was sent to a chat
+type SentMessage struct {
+	ChatID string
+	// Token is the bot token the message was sent with
+	Token     string
+	ParseMode string
+	// Parts are the Telegram messages the gotify message was sent as
+	Parts []SentPart
+	// ReplyTo is the id of the message the first part replies to. 0 if it isn't a reply
+	ReplyTo int64
+	// CorrelationKey is the rendered correlation key of the message. Empty without a correlation key
+	CorrelationKey string
+}
+
+// SentPart is a single Telegram message a gotify message was sent as
+type SentPart struct {
+	MessageID int64 `json:"message_id"`
+	// Text is the formatted text of the message or the caption of the photo
+	Text string `json:"text,omitempty"`
+	// Caption is set if the message is a photo
+	Caption bool `json:"caption,omitempty"`
+	// Pinned is set if the message was pinned in the chat
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// messageResult is the message returned by the methods sending or editing messages
+type messageResult struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// SendError is sent to the error channel when a message could not be sent to a chat
+type SendError struct {
+	MessageID uint32
+	// Token is the bot token the message was sent with. It is not part of the error message
+	Token   string
+	ChatID  string
+	AppID   uint32
+	AppName string
+	Err     error
+}
+
+func (e *SendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
 }
 
 type Client struct {
-	logger     *zerolog.Logger
-	httpClient HTTPClient
-	errChan    chan error
+	logger      *zerolog.Logger
+	httpClient  HTTPClient
+	errChan     chan error
+	onSent      func(message api.Message, sent SentMessage)
+	timeout     time.Duration
+	retry       RetryPolicy
+	attachments AttachmentPolicy
+	images      ImagePolicy
+	footer      Footer
+	gotifyURL   *url.URL
+	appImage    func(path string, maxSize int64) ([]byte, error)
+	sleep       func(time.Duration)
+	chats       chatCache
+	limiter     *chatLimiter
+	breaker     *circuitBreaker
 }
 
+type Config struct {
+	ErrChan chan error
+	// Logger is the parent logger of the client. Defaults to the package logger
+	Logger *zerolog.Logger
+	// OnSent is called every time a message was successfully sent to a chat
+	OnSent func(message api.Message, sent SentMessage)
+	// RequestTimeout limits the duration of a single request to the Telegram API.
+	// Defaults to DefaultRequestTimeout
+	RequestTimeout time.Duration
+	// Retry configures how failed requests are retried. Defaults to no retries
+	Retry RetryPolicy
+	// Attachments limits the files uploaded as documents. Defaults to no attachments
+	Attachments AttachmentPolicy
+	// Images configures the downscaling of photos. Defaults to no downscaling
+	Images ImagePolicy
+	// Footer is appended to the messages whose format options include it
+	Footer Footer
+	// GotifyURL is the URL of the Gotify web UI messages link to. Defaults to no links
+	GotifyURL *url.URL
+	// FetchAppImage downloads the image of an app from the Gotify server. Defaults to no app images
+	FetchAppImage func(path string, maxSize int64) ([]byte, error)
+	// Chaos injects Telegram API errors for testing. Defaults to no faults
+	Chaos *chaos.Injector
+	// Proxy is the URL of the proxy requests are sent through. Defaults to the proxy of the environment
+	Proxy *url.URL
+	// HTTPClient sends the requests to the Telegram API. Defaults to an HTTP client using Proxy
+	HTTPClient HTTPClient
+	// RateLimit delays messages to stay below the limits of Telegram. Defaults to no rate limiting
+	RateLimit RateLimitPolicy
+	// CircuitBreaker stops requests during a Telegram outage. Defaults to no circuit breaker
+	CircuitBreaker CircuitBreakerPolicy
+}
+
+// apiHost is the host of the Telegram Bot API
+const apiHost = "api.telegram.org"
+
+// jsonContentType is the content type of requests with a json payload
+const jsonContentType = "application/json"
+
+// DefaultRequestTimeout is the timeout of requests to the Telegram API if none is configured
+const DefaultRequestTimeout = 30 * time.Second
+
 // NewClient creates a new Telegram client
-func NewClient(errChan chan error) *Client {
+func NewClient(c Config) *Client {
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = DefaultRequestTimeout
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = newHTTPClient(c.Proxy)
+	}
+	if c.Chaos != nil {
+		httpClient = &chaosHTTPClient{next: httpClient, chaos: c.Chaos}
+	}
+
+	log := logger.WithComponent(c.Logger, "telegram")
 	return &Client{
-		logger:     logger.WithComponent("telegram"),
-		httpClient: &http.Client{},
-		errChan:    errChan,
+		logger:      log,
+		httpClient:  httpClient,
+		errChan:     c.ErrChan,
+		onSent:      c.OnSent,
+		timeout:     c.RequestTimeout,
+		retry:       c.Retry,
+		attachments: c.Attachments,
+		images:      c.Images,
+		footer:      c.Footer,
+		gotifyURL:   c.GotifyURL,
+		appImage:    c.FetchAppImage,
+		sleep:       time.Sleep,
+		limiter:     newChatLimiter(c.RateLimit),
+		breaker:     newCircuitBreaker(c.CircuitBreaker, log),
 	}
 }
 
 func (c *Client) buildBotEndpoint(token string) string {
-	return "https://api.telegram.org/bot" + token + "/sendMessage"
+	return c.buildMethodEndpoint(token, "sendMessage")
+}
+
+func (c *Client) buildMethodEndpoint(token, method string) string {
+	return "https://" + apiHost + "/bot" + token + "/" + method
+}
+
+// sendMessage sends formatted text to a chat. Text longer than Telegram's limit is sent as several messages.
+// The keyboard is shown below the last message
+func (c *Client) sendMessage(token, chatID, text string, formatOpts config.MessageFormatOptions, keyboard *InlineKeyboardMarkup, replyTo int64) ([]SentPart, error) {
+	var parts []SentPart
+	chunks := splitText(text, maxMessageLength, formatOpts.ParseMode)
+	for i, chunk := range chunks {
+		payload := Payload{
+			ChatID:             chatID,
+			MessageThreadID:    formatOpts.MessageThreadID,
+			ParseMode:          apiParseMode(formatOpts.ParseMode),
+			LinkPreviewOptions: linkPreviewOptions(formatOpts.LinkPreview),
+			ProtectContent:     formatOpts.ProtectContent,
+		}
+		payload.Text, payload.Entities = messageText(chunk, formatOpts.ParseMode)
+		if i == 0 {
+			payload.ReplyParameters = replyParameters(replyTo)
+		}
+		if i == len(chunks)-1 {
+			payload.ReplyMarkup = keyboard
+		}
+
+		// the circuit breaker is only checked before the first chunk, so that a message is never sent in part
+		// and resent from the start once the circuit closes again
+		var result messageResult
+		c.waitForChat(chatID, 1)
+		err := c.invokeMethod(token, "sendMessage", payload, &result, i == 0)
+		if err != nil {
+			return parts, err
+		}
+		parts = append(parts, SentPart{MessageID: result.MessageID, Text: chunk})
+	}
+
+	return parts, nil
+}
+
+// callMethod marshals the payload and calls a method of the Telegram bot API. The result of
+// the method is decoded into result unless it is nil
+func (c *Client) callMethod(token, method string, payload interface{}, result interface{}) error {
+	return c.invokeMethod(token, method, payload, result, true)
+}
+
+// invokeMethod calls a method of the Telegram bot API like callMethod. The circuit breaker is only checked if
+// checkBreaker is set
+func (c *Client) invokeMethod(token, method string, payload interface{}, result interface{}, checkBreaker bool) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	endpoint := c.buildMethodEndpoint(token, method)
+	c.logger.Debug().
+		Str("endpoint", redactEndpoint(endpoint)).
+		Str("payload", string(body)).
+		Msg("sending request to Telegram API")
+
+	resBody, err := c.retryRequest(endpoint, jsonContentType, body, checkBreaker)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+
+	return decodeResult(method, resBody, result)
+}
+
+// decodeResult decodes the result of a method from the response body unless result is nil
+func decodeResult(method string, resBody []byte, result interface{}) error {
+	if result == nil {
+		return nil
+	}
+
+	response := struct {
+		Result interface{} `json:"result"`
+	}{Result: result}
+	if err := json.Unmarshal(resBody, &response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+
+	return nil
+}
+
+// callMethodWithFile uploads a file with the form fields to a method of the Telegram bot API. Empty
+// fields are omitted. The result of the method is decoded into result unless it is nil
+func (c *Client) callMethodWithFile(token, method string, fields [][2]string, fileField, filename string, data []byte, result interface{}) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range fields {
+		if field[1] == "" {
+			continue
+		}
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", field[0], err)
+		}
+	}
+	file, err := writer.CreateFormFile(fileField, filename)
+	if err == nil {
+		_, err = file.Write(data)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", fileField, err)
+	}
+
+	resBody, err := c.makeRequestWithRetry(c.buildMethodEndpoint(token, method), writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+
+	return decodeResult(method, resBody, result)
+}
+
+// threadField encodes the forum topic for a multipart form field. It is empty for the general topic
+func threadField(threadID int64) string {
+	if threadID == 0 {
+		return ""
+	}
+	return strconv.FormatInt(threadID, 10)
+}
+
+// boolField encodes a flag for a multipart form field. It is empty if the flag isn't set
+func boolField(b bool) string {
+	if !b {
+		return ""
+	}
+	return "true"
+}
+
+// sendError sends an error about a message to the error channel and returns it. Messages that weren't sent
+// because the circuit breaker is open aren't reported, they stay queued until the Telegram API is available
+func (c *Client) sendError(message api.Message, token, chatID string, err error) error {
+	sendErr := &SendError{
+		MessageID: message.Id,
+		Token:     token,
+		ChatID:    chatID,
+		AppID:     message.AppID,
+		AppName:   message.AppName,
+		Err:       err,
+	}
+	if !errors.Is(err, ErrCircuitOpen) {
+		c.errChan <- sendErr
+	}
+
+	return sendErr
+}
+
+// Related are the earlier Telegram messages of a chat a message relates to
+type Related struct {
+	// Previous is the message to edit instead of sending a new message
+	Previous *SentMessage
+	// ReplyTo is the id of the message to reply to. 0 sends the message without a reply
+	ReplyTo int64
 }
 
 // Send sends a message to Telegram
-func (c *Client) Send(message api.Message, token, chatID string, formatOpts config.MessageFormatOptions) {
+func (c *Client) Send(message api.Message, token, chatID string, formatOpts config.MessageFormatOptions) error {
+	return c.SendOrEdit(message, token, chatID, formatOpts, Related{})
+}
+
+// SendOrEdit sends a message to Telegram. If a previous message is given, it is edited to show the message instead
+// when possible, i.e. when both are a single text message. Otherwise the message is sent as a new message, as a
+// reply if a message to reply to is given. Errors are sent to the error channel too
+func (c *Client) SendOrEdit(message api.Message, token, chatID string, formatOpts config.MessageFormatOptions, related Related) error {
 	if token == "" {
-		c.errChan <- fmt.Errorf("telegram bot token is empty")
-		return
+		return c.sendError(message, token, chatID, fmt.Errorf("telegram bot token is empty"))
 	}
 	if chatID == "" {
-		c.errChan <- fmt.Errorf("telegram chat ID is empty")
-		return
+		return c.sendError(message, token, chatID, fmt.Errorf("telegram chat ID is empty"))
 	}
 
 	c.logger.Debug().
@@ -60,66 +449,193 @@ func (c *Client) Send(message api.Message, token, chatID string, formatOpts conf
 		Str("chat_id", chatID).
 		Msg("preparing to send message to Telegram")
 
-	formattedMessage, err := FormatMessage(message, formatOpts)
-	if err != nil {
-		c.errChan <- fmt.Errorf("failed to format message: %w", err)
-		return
+	// Messages are sent to the numeric ID of chats given as @username. Sent messages and errors keep the configured ID
+	target := c.resolveChatID(token, chatID)
+
+	correlationKey := CorrelationKey(formatOpts.CorrelationKey, message)
+	message = rewriteMessage(message, formatOpts.Rewrites)
+
+	// Images are sent as a photo or an album captioned with the message. Markdown images are removed from the body
+	var photoURLs []string
+	if photoURL := imageURL(message); photoURL != "" {
+		photoURLs = append(photoURLs, photoURL)
+	}
+	images, body := bodyImages(message.Message, maxMediaGroupSize-len(photoURLs))
+	photoURLs, message.Message = append(photoURLs, images...), body
+	var appImage *photoFile
+	if len(photoURLs) == 0 && formatOpts.AppImage {
+		appImage = c.fetchAppImage(message, chatID)
 	}
 
-	payload := Payload{
-		ChatID:    chatID,
-		Text:      formattedMessage,
-		ParseMode: formatOpts.ParseMode,
+	message, truncated := truncateBody(message, formatOpts.MaxLength)
+	formattedMessage, err := FormatMessage(message, formatOpts)
+
+	// Truncated messages always link to the full message in Gotify, as a link unless a button is configured
+	l := labelsFor(formatOpts.Language)
+	linkStyle, label := strings.ToLower(formatOpts.GotifyLink), l.OpenInGotify
+	if truncated {
+		label = l.ShowFullMessage
+		if linkStyle == "" {
+			linkStyle = config.GotifyLinkText
+		}
 	}
 
-	body, err := json.Marshal(payload)
+	// The click URL of gotify's notification extras and the configured buttons are opened by buttons
+	var keyboard *InlineKeyboardMarkup
+	if link := clickURL(message); link != "" {
+		keyboard = addButton(keyboard, l.Open, link)
+	}
+	for _, button := range formatOpts.Buttons {
+		if link := buttonURL(button, message); link != "" {
+			keyboard = addButton(keyboard, button.Text, link)
+		} else {
+			c.logger.Debug().
+				Str("button", button.Text).
+				Msg("skipping button without a http(s) URL")
+		}
+	}
+	if link := gotifyLink(c.gotifyURL, message); link != "" && err == nil {
+		switch linkStyle {
+		case config.GotifyLinkText:
+			formattedMessage, err = appendLink(formattedMessage, label, link, formatOpts.ParseMode)
+		case config.GotifyLinkButton:
+			keyboard = addButton(keyboard, label, link)
+		}
+	}
+	if err == nil && formatOpts.IncludeFooter {
+		formattedMessage, err = appendFooter(formattedMessage, c.footer, formatOpts.ParseMode)
+	}
 	if err != nil {
-		c.errChan <- fmt.Errorf("failed to marshal payload: %w", err)
-		return
+		return c.sendError(message, token, chatID, fmt.Errorf("failed to format message: %w", err))
 	}
 
-	endpoint := c.buildBotEndpoint(token)
-	c.logger.Debug().
-		Str("endpoint", strings.Replace(endpoint, token, "***", 1)).
-		Str("formattedMessage", formattedMessage).
-		Str("payload", string(body)).
-		Msg("sending request to Telegram API")
+	// Large extras are uploaded as a document. It's captioned with the message unless the message
+	// already has an attachment or a photo, in which case it follows the message
+	extras := extrasFile(message, formatOpts)
+	extrasAttachment := &attachment{filename: extrasFilename}
 
-	if err := c.makeRequest(endpoint, bytes.NewBuffer(body)); err != nil {
-		c.errChan <- fmt.Errorf("failed to make request: %w", err)
-		return
+	var parts []SentPart
+	if a, data := c.fetchAttachment(message, chatID); a != nil {
+		parts, err = c.sendDocument(message, token, target, a, data, formattedMessage, formatOpts, keyboard, related.ReplyTo)
+	} else if len(photoURLs) > 1 {
+		parts, err = c.sendMediaGroup(message, token, target, photoURLs, formattedMessage, formatOpts, keyboard, related.ReplyTo)
+	} else if len(photoURLs) == 1 {
+		parts, err = c.sendPhoto(message, token, target, photoURLs[0], nil, formattedMessage, formatOpts, keyboard, related.ReplyTo)
+	} else if appImage != nil {
+		parts, err = c.sendPhoto(message, token, target, "", appImage, formattedMessage, formatOpts, keyboard, related.ReplyTo)
+	} else if extras != nil {
+		parts, err = c.sendDocument(message, token, target, extrasAttachment, extras, formattedMessage, formatOpts, keyboard, related.ReplyTo)
+		extras = nil
+	} else if edited := c.editMessage(related.Previous, formattedMessage, formatOpts, keyboard); edited != nil {
+		parts, token, related.ReplyTo = edited, related.Previous.Token, 0
+	} else if strings.EqualFold(formatOpts.LongMessages, config.LongMessagesDocument) && utf16Length(formattedMessage) > maxMessageLength {
+		parts, err = c.sendLongMessage(message, token, target, formatOpts, keyboard, related.ReplyTo)
+	} else {
+		parts, err = c.sendMessage(token, target, formattedMessage, formatOpts, keyboard, related.ReplyTo)
+	}
+	if err == nil && extras != nil {
+		var extrasParts []SentPart
+		extrasParts, err = c.sendDocument(message, token, target, extrasAttachment, extras, "", formatOpts, nil, 0)
+		parts = append(parts, extrasParts...)
+	}
+	if err != nil {
+		return c.sendError(message, token, chatID, err)
 	}
 
 	c.logger.Info().Msg("message successfully sent to Telegram")
+
+	if formatOpts.PinPriority > 0 && int(message.Priority) >= formatOpts.PinPriority &&
+		len(parts) > 0 && !parts[0].Pinned {
+		c.pinMessage(token, target, parts)
+	}
+
+	if c.onSent != nil {
+		c.onSent(message, SentMessage{
+			ChatID:         chatID,
+			Token:          token,
+			ParseMode:      formatOpts.ParseMode,
+			Parts:          parts,
+			ReplyTo:        related.ReplyTo,
+			CorrelationKey: correlationKey,
+		})
+	}
+
+	return nil
+}
+
+// SendText sends a plain text message to Telegram without any formatting
+func (c *Client) SendText(token, chatID, text string) error {
+	if token == "" {
+		return fmt.Errorf("telegram bot token is empty")
+	}
+	if chatID == "" {
+		return fmt.Errorf("telegram chat ID is empty")
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"chat_id": chatID,
+		"text":    text,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	c.waitForChat(chatID, 1)
+	if _, err := c.makeRequestWithRetry(c.buildBotEndpoint(token), jsonContentType, body); err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+
+	return nil
 }
 
-// makeRequest makes a request to the Telegram API
-func (c *Client) makeRequest(endpoint string, body *bytes.Buffer) error {
-	req, err := http.NewRequest("POST", endpoint, body)
+// botTokenRegex matches the bot token in the path of Bot API endpoints
+var botTokenRegex = regexp.MustCompile(`/bot[^/]*`)
+
+// redactEndpoint returns the Bot API endpoint with the bot token masked
+func redactEndpoint(endpoint string) string {
+	return botTokenRegex.ReplaceAllString(endpoint, "/bot***")
+}
+
+// redactURLError masks the bot token in the URL of errors returned by the http client, e.g. timeouts and
+// connection resets, so that it doesn't end up in logs, recent errors and dead letters
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactEndpoint(urlErr.URL)
+	}
+	return err
+}
+
+// makeRequest makes a request to the Telegram API and returns the response body
+func (c *Client) makeRequest(endpoint, contentType string, body *bytes.Buffer) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, body)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", redactURLError(err))
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 
 	res, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		return nil, &networkError{fmt.Errorf("failed to execute request: %w", redactURLError(err))}
 	}
 	defer res.Body.Close()
 
 	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return nil, &networkError{fmt.Errorf("failed to read response body: %w", redactURLError(err))}
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API error (status %d): %s", res.StatusCode, string(resBody))
+		return nil, newAPIError(res.StatusCode, resBody)
 	}
 
 	c.logger.Debug().
 		Str("response", string(resBody)).
 		Msg("received response from Telegram API")
 
-	return nil
+	return resBody, nil
 }
@@ -2,9 +2,18 @@ package telegram
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,16 +34,20 @@ func (m *MockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 
 func TestNewClient(t *testing.T) {
 	errChan := make(chan error, 1)
-	client := NewClient(errChan)
+	client := NewClient(Config{ErrChan: errChan})
 
 	assert.NotNil(t, client)
 	assert.NotNil(t, client.httpClient)
 	assert.NotNil(t, client.logger)
 	assert.Equal(t, errChan, client.errChan)
+	assert.Equal(t, DefaultRequestTimeout, client.timeout)
+
+	client = NewClient(Config{ErrChan: errChan, RequestTimeout: 5 * time.Second})
+	assert.Equal(t, 5*time.Second, client.timeout)
 }
 
 func TestClientStruct_BuildBotEndpoint(t *testing.T) {
-	client := NewClient(make(chan error, 1))
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
 
 	tests := []struct {
 		name     string
@@ -127,7 +140,7 @@ func TestClientStruct_Send(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			errChan := make(chan error, 1)
-			client := NewClient(errChan)
+			client := NewClient(Config{ErrChan: errChan})
 
 			// Mock HTTP client if a response is provided
 			if tt.mockResponse != nil {
@@ -203,7 +216,7 @@ func TestClientStruct_MakeRequest(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := NewClient(make(chan error, 1))
+			client := NewClient(Config{ErrChan: make(chan error, 1)})
 
 			if tt.mockResponse != nil {
 				client.httpClient = &MockHTTPClient{
@@ -216,7 +229,7 @@ func TestClientStruct_MakeRequest(t *testing.T) {
 				}
 			}
 
-			err := client.makeRequest(tt.endpoint, tt.payload)
+			_, err := client.makeRequest(tt.endpoint, jsonContentType, tt.payload)
 
 			if tt.expectedError {
 				require.Error(t, err)
@@ -251,7 +264,18 @@ func TestPayload_Marshal(t *testing.T) {
 				ChatID: "123456",
 				Text:   "test message",
 			},
-			expected: `{"chat_id":"123456","text":"test message","parse_mode":""}`,
+			expected: `{"chat_id":"123456","text":"test message"}`,
+		},
+		{
+			name: "link preview options",
+			payload: Payload{
+				ChatID:             "123456",
+				Text:               "test message",
+				ParseMode:          "HTML",
+				LinkPreviewOptions: &LinkPreviewOptions{URL: "https://example.com", PreferLargeMedia: true},
+			},
+			expected: `{"chat_id":"123456","text":"test message","parse_mode":"HTML",` +
+				`"link_preview_options":{"url":"https://example.com","prefer_large_media":true}}`,
 		},
 	}
 
@@ -263,3 +287,359 @@ func TestPayload_Marshal(t *testing.T) {
 		})
 	}
 }
+
+func TestClientStruct_SendText(t *testing.T) {
+	var payload map[string]interface{}
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "https://api.telegram.org/botvalid-token/sendMessage", req.URL.String())
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true}`)),
+			}, nil
+		},
+	}
+
+	require.NoError(t, client.SendText("valid-token", "123456", "plugin started (v1.0.0)"))
+	assert.Equal(t, map[string]interface{}{
+		"chat_id": "123456",
+		"text":    "plugin started (v1.0.0)",
+	}, payload)
+
+	assert.EqualError(t, client.SendText("", "123456", "text"), "telegram bot token is empty")
+	assert.EqualError(t, client.SendText("valid-token", "", "text"), "telegram chat ID is empty")
+}
+
+func TestClientStruct_Send_OnSent(t *testing.T) {
+	var sent []SentMessage
+	client := NewClient(Config{
+		ErrChan: make(chan error, 1),
+		OnSent: func(message api.Message, s SentMessage) {
+			sent = append(sent, s)
+		},
+	})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+			}, nil
+		},
+	}
+
+	client.Send(api.Message{Message: "Test"}, "valid-token", "123456", config.MessageFormatOptions{ParseMode: "MarkdownV2"})
+	assert.Equal(t, []SentMessage{{
+		ChatID:    "123456",
+		Token:     "valid-token",
+		ParseMode: "MarkdownV2",
+		Parts:     []SentPart{{MessageID: 42, Text: "Test\n\n"}},
+	}}, sent)
+}
+
+func TestClientStruct_Send_SendError(t *testing.T) {
+	errChan := make(chan error, 1)
+	client := NewClient(Config{ErrChan: errChan})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusForbidden,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":false,"description":"Forbidden: bot was blocked by the user"}`)),
+			}, nil
+		},
+	}
+
+	message := api.Message{AppID: 3, AppName: "backups", Message: "Test"}
+	client.Send(message, "valid-token", "123456", config.MessageFormatOptions{ParseMode: "MarkdownV2"})
+
+	err := <-errChan
+	var sendErr *SendError
+	require.ErrorAs(t, err, &sendErr)
+	assert.Equal(t, "123456", sendErr.ChatID)
+	assert.Equal(t, "valid-token", sendErr.Token)
+	assert.NotContains(t, sendErr.Error(), "valid-token")
+	assert.Equal(t, uint32(3), sendErr.AppID)
+	assert.Equal(t, "backups", sendErr.AppName)
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestClientStruct_MakeRequest_Timeout(t *testing.T) {
+	client := NewClient(Config{ErrChan: make(chan error, 1), RequestTimeout: 10 * time.Millisecond})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			// simulate a hung connection that only returns when the request is canceled
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		},
+	}
+
+	_, err := client.makeRequest("https://api.telegram.org/bottoken/sendMessage", jsonContentType, bytes.NewBufferString("{}"))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNewClient_Proxy(t *testing.T) {
+	var requestURL, authorization string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURL, authorization = r.URL.String(), r.Header.Get("Proxy-Authorization")
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":42}}`))
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	proxyURL.User = url.UserPassword("user", "secret")
+
+	client := NewClient(Config{ErrChan: make(chan error, 1), Proxy: proxyURL})
+	_, err = client.makeRequest("http://api.telegram.org/bottoken/sendMessage", jsonContentType, bytes.NewBufferString("{}"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://api.telegram.org/bottoken/sendMessage", requestURL)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:secret")), authorization)
+}
+
+func TestClientStruct_Send_LinkPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		preview  config.LinkPreview
+		expected interface{}
+	}{
+		{name: "telegram defaults", expected: nil},
+		{
+			name:     "disabled",
+			preview:  config.LinkPreview{Disabled: true},
+			expected: map[string]interface{}{"is_disabled": true},
+		},
+		{
+			name:    "small media above text",
+			preview: config.LinkPreview{URL: "https://example.com", PreferSmallMedia: true, ShowAboveText: true},
+			expected: map[string]interface{}{
+				"url":                "https://example.com",
+				"prefer_small_media": true,
+				"show_above_text":    true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload map[string]interface{}
+			client := NewClient(Config{ErrChan: make(chan error, 1)})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+					}, nil
+				},
+			}
+
+			client.Send(api.Message{Message: "https://example.com"}, "valid-token", "123456",
+				config.MessageFormatOptions{ParseMode: ParseModeHTML, LinkPreview: tt.preview})
+			assert.Equal(t, tt.expected, payload["link_preview_options"])
+		})
+	}
+}
+
+func TestClientStruct_SendOrEdit_LinkPreviewEveryPart(t *testing.T) {
+	var methods []string
+	var previews []interface{}
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var payload map[string]interface{}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			methods = append(methods, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+			previews = append(previews, payload["link_preview_options"])
+			return response(http.StatusOK, `{"ok":true,"result":{"message_id":42}}`), nil
+		},
+	}
+	formatOpts := config.MessageFormatOptions{ParseMode: ParseModeHTML, LinkPreview: config.LinkPreview{Disabled: true}}
+	disabled := map[string]interface{}{"is_disabled": true}
+
+	// long messages are split into several messages
+	long := strings.Repeat("https://example.com/status\n", 300)
+	require.NoError(t, client.Send(api.Message{Message: long}, "valid-token", "123456", formatOpts))
+	require.Greater(t, len(methods), 1)
+	for i, method := range methods {
+		assert.Equal(t, "sendMessage", method)
+		assert.Equal(t, disabled, previews[i])
+	}
+
+	// edits of correlated messages
+	methods, previews = nil, nil
+	previous := &SentMessage{ChatID: "123456", Token: "valid-token", Parts: []SentPart{{MessageID: 42}}}
+	require.NoError(t, client.SendOrEdit(api.Message{Message: "https://example.com/status"}, "valid-token", "123456", formatOpts, Related{Previous: previous}))
+	assert.Equal(t, []string{"editMessageText"}, methods)
+	assert.Equal(t, []interface{}{disabled}, previews)
+}
+
+// failingTransport fails every request like a connection reset
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func TestClientStruct_Send_RedactsTokenInNetworkErrors(t *testing.T) {
+	errChan := make(chan error, 2)
+	client := NewClient(Config{ErrChan: errChan})
+	client.httpClient = &http.Client{Transport: failingTransport{}}
+
+	err := client.Send(api.Message{Title: "test"}, "123456:secret-token", "123", config.MessageFormatOptions{ParseMode: ParseModeHTML})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "https://api.telegram.org/bot***/sendMessage")
+	assert.NotContains(t, err.Error(), "secret-token")
+
+	// invalid tokens fail before the request is sent
+	err = client.Send(api.Message{Title: "test"}, "secret\x7ftoken", "123", config.MessageFormatOptions{ParseMode: ParseModeHTML})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+func TestClientStruct_Send_ProtectContentAndThread(t *testing.T) {
+	long := strings.Repeat("log line\n", 500)
+
+	tests := []struct {
+		name     string
+		message  api.Message
+		protect  bool
+		threadID int64
+	}{
+		{name: "defaults", message: api.Message{Message: "done"}},
+		{name: "protected message in a topic", message: api.Message{Message: "done"}, protect: true, threadID: 42},
+		{name: "protected document in a topic", message: api.Message{Message: long}, protect: true, threadID: 42},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				protected bool
+				threadID  int64
+			)
+			client := NewClient(Config{ErrChan: make(chan error, 1)})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+						form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(1 << 20)
+						require.NoError(t, err)
+						protected = strings.Join(form.Value["protect_content"], "") == "true"
+						if thread := strings.Join(form.Value["message_thread_id"], ""); thread != "" {
+							threadID, err = strconv.ParseInt(thread, 10, 64)
+							require.NoError(t, err)
+						}
+					} else {
+						var payload Payload
+						require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+						protected, threadID = payload.ProtectContent, payload.MessageThreadID
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+					}, nil
+				},
+			}
+
+			client.Send(tt.message, "valid-token", "123456", config.MessageFormatOptions{
+				ParseMode:       ParseModeHTML,
+				ProtectContent:  tt.protect,
+				MessageThreadID: tt.threadID,
+				LongMessages:    config.LongMessagesDocument,
+			})
+			assert.Equal(t, tt.protect, protected)
+			assert.Equal(t, tt.threadID, threadID)
+		})
+	}
+}
+
+func TestClientStruct_SendOrEdit_Reply(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  api.Message
+		replyTo  int64
+		expected []string
+	}{
+		{name: "no reply", message: api.Message{Message: "done"}, expected: []string{""}},
+		{name: "reply", message: api.Message{Message: "done"}, replyTo: 7, expected: []string{"7"}},
+		{
+			name:     "only the first chunk replies",
+			message:  api.Message{Message: strings.Repeat("log line\n", 1000)},
+			replyTo:  7,
+			expected: []string{"7", "", ""},
+		},
+		{
+			name:     "photo",
+			message:  api.Message{Message: "![graph](https://example.com/graph.png)"},
+			replyTo:  7,
+			expected: []string{"7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				replies []string
+				sent    SentMessage
+			)
+			client := NewClient(Config{ErrChan: make(chan error, 1), OnSent: func(_ api.Message, s SentMessage) { sent = s }})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					var reply string
+					if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+						form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(1 << 20)
+						require.NoError(t, err)
+						if field := strings.Join(form.Value["reply_parameters"], ""); field != "" {
+							var parameters ReplyParameters
+							require.NoError(t, json.Unmarshal([]byte(field), &parameters))
+							assert.True(t, parameters.AllowSendingWithoutReply)
+							reply = strconv.FormatInt(parameters.MessageID, 10)
+						}
+					} else {
+						var payload Payload
+						require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+						if payload.ReplyParameters != nil {
+							assert.True(t, payload.ReplyParameters.AllowSendingWithoutReply)
+							reply = strconv.FormatInt(payload.ReplyParameters.MessageID, 10)
+						}
+					}
+					replies = append(replies, reply)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+					}, nil
+				},
+			}
+
+			client.SendOrEdit(tt.message, "valid-token", "123456", config.MessageFormatOptions{
+				ParseMode: ParseModeMarkdownV2,
+			}, Related{ReplyTo: tt.replyTo})
+			assert.Equal(t, tt.expected, replies)
+			assert.Equal(t, tt.replyTo, sent.ReplyTo)
+		})
+	}
+}
+
+func TestClientStruct_Send_PlainText(t *testing.T) {
+	var payload map[string]interface{}
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+			}, nil
+		},
+	}
+
+	client.Send(api.Message{Title: "*Title*", Message: "a_b <c> [d](e)"}, "valid-token", "123456",
+		config.MessageFormatOptions{ParseMode: ParseModeNone})
+	assert.Equal(t, map[string]interface{}{
+		"chat_id": "123456",
+		"text":    "*Title*\n\na_b <c> [d](e)\n\n",
+	}, payload)
+}
@@ -0,0 +1,80 @@
+package telegram
+
+import "fmt"
+
+// DeletePayload is the payload of the deleteMessage method
+type DeletePayload struct {
+	ChatID    string `json:"chat_id"`
+	MessageID int64  `json:"message_id"`
+}
+
+// EditPayload is the payload of the editMessageText and editMessageCaption methods
+type EditPayload struct {
+	ChatID             string                `json:"chat_id"`
+	MessageID          int64                 `json:"message_id"`
+	Text               string                `json:"text,omitempty"`
+	Caption            string                `json:"caption,omitempty"`
+	ParseMode          string                `json:"parse_mode,omitempty"`
+	Entities           []MessageEntity       `json:"entities,omitempty"`
+	CaptionEntities    []MessageEntity       `json:"caption_entities,omitempty"`
+	LinkPreviewOptions *LinkPreviewOptions   `json:"link_preview_options,omitempty"`
+	ReplyMarkup        *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// DeleteMessage deletes the Telegram messages a gotify message was sent as. Bots can only delete messages
+// that are less than 48 hours old
+func (c *Client) DeleteMessage(sent SentMessage) error {
+	for _, part := range sent.Parts {
+		err := c.callMethod(sent.Token, "deleteMessage", DeletePayload{
+			ChatID:    sent.ChatID,
+			MessageID: part.MessageID,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete message %d: %w", part.MessageID, err)
+		}
+	}
+
+	return nil
+}
+
+// StrikeThroughMessage edits the Telegram messages a gotify message was sent as to strike their text through.
+// Pinned messages are unpinned
+func (c *Client) StrikeThroughMessage(sent SentMessage) error {
+	m, err := markupFor(sent.ParseMode)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range sent.Parts {
+		if part.Pinned {
+			if err := c.unpinMessage(sent.Token, sent.ChatID, part.MessageID); err != nil {
+				return fmt.Errorf("failed to unpin message %d: %w", part.MessageID, err)
+			}
+		}
+		if part.Text == "" {
+			continue
+		}
+
+		payload := EditPayload{
+			ChatID:    sent.ChatID,
+			MessageID: part.MessageID,
+			ParseMode: apiParseMode(sent.ParseMode),
+		}
+		method := "editMessageText"
+		text, entities := messageText(m.strikethrough(part.Text), sent.ParseMode)
+		if part.Caption {
+			method = "editMessageCaption"
+			payload.Caption = text
+			payload.CaptionEntities = entities
+		} else {
+			payload.Text = text
+			payload.Entities = entities
+		}
+
+		if err := c.callMethod(sent.Token, method, payload, nil); err != nil {
+			return fmt.Errorf("failed to strike message %d through: %w", part.MessageID, err)
+		}
+	}
+
+	return nil
+}
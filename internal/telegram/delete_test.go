@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordRequests makes the client record the methods and payloads of its requests
+func recordRequests(t *testing.T, client *Client, statusCode int) *[]string {
+	var requests []string
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+			requests = append(requests, method+" "+string(body))
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":true}`)),
+			}, nil
+		},
+	}
+	return &requests
+}
+
+func TestClientStruct_DeleteMessage(t *testing.T) {
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	requests := recordRequests(t, client, http.StatusOK)
+
+	err := client.DeleteMessage(SentMessage{
+		ChatID: "123",
+		Token:  "token",
+		Parts:  []SentPart{{MessageID: 1}, {MessageID: 2}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`deleteMessage {"chat_id":"123","message_id":1}`,
+		`deleteMessage {"chat_id":"123","message_id":2}`,
+	}, *requests)
+
+	client = NewClient(Config{ErrChan: make(chan error, 1)})
+	recordRequests(t, client, http.StatusBadRequest)
+	err = client.DeleteMessage(SentMessage{ChatID: "123", Token: "token", Parts: []SentPart{{MessageID: 1}}})
+	assert.ErrorContains(t, err, "failed to delete message 1")
+}
+
+func TestClientStruct_StrikeThroughMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		parseMode string
+		parts     []SentPart
+		expected  []EditPayload
+	}{
+		{
+			name:      "markdown text and caption",
+			parseMode: ParseModeMarkdownV2,
+			parts: []SentPart{
+				{MessageID: 1, Text: "*Title*", Caption: true},
+				{MessageID: 2, Text: "body"},
+				{MessageID: 3},
+			},
+			expected: []EditPayload{
+				{ChatID: "123", MessageID: 1, Caption: "~*Title*~", ParseMode: ParseModeMarkdownV2},
+				{ChatID: "123", MessageID: 2, Text: "~body~", ParseMode: ParseModeMarkdownV2},
+			},
+		},
+		{
+			name:      "html text",
+			parseMode: ParseModeHTML,
+			parts:     []SentPart{{MessageID: 2, Text: "<b>Title</b>"}},
+			expected: []EditPayload{
+				{ChatID: "123", MessageID: 2, Text: "<s><b>Title</b></s>", ParseMode: ParseModeHTML},
+			},
+		},
+		{
+			name:      "plain text",
+			parseMode: ParseModeNone,
+			parts:     []SentPart{{MessageID: 2, Text: "a_b"}},
+			expected: []EditPayload{
+				{ChatID: "123", MessageID: 2, Text: "a\u0336_b\u0336"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(Config{ErrChan: make(chan error, 1)})
+			requests := recordRequests(t, client, http.StatusOK)
+
+			err := client.StrikeThroughMessage(SentMessage{ChatID: "123", Token: "token", ParseMode: tt.parseMode, Parts: tt.parts})
+			require.NoError(t, err)
+
+			var expected []string
+			for _, payload := range tt.expected {
+				method := "editMessageText"
+				if payload.Caption != "" {
+					method = "editMessageCaption"
+				}
+				body, err := json.Marshal(payload)
+				require.NoError(t, err)
+				expected = append(expected, method+" "+string(body))
+			}
+			assert.Equal(t, expected, *requests)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package telegram
+
+import "fmt"
+
+// Chat is a chat the bot received an update from
+type Chat struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	Title     string `json:"title,omitempty"`
+	Username  string `json:"username,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// updateMessage is the part of a message of an update that identifies its chat
+type updateMessage struct {
+	Chat Chat `json:"chat"`
+}
+
+// Update is an update of the getUpdates method. Only the updates carrying a chat are decoded
+type Update struct {
+	Message           *updateMessage `json:"message,omitempty"`
+	EditedMessage     *updateMessage `json:"edited_message,omitempty"`
+	ChannelPost       *updateMessage `json:"channel_post,omitempty"`
+	EditedChannelPost *updateMessage `json:"edited_channel_post,omitempty"`
+	MyChatMember      *updateMessage `json:"my_chat_member,omitempty"`
+	ChatMember        *updateMessage `json:"chat_member,omitempty"`
+	ChatJoinRequest   *updateMessage `json:"chat_join_request,omitempty"`
+}
+
+// chat returns the chat of the update or nil if the update has no chat
+func (u Update) chat() *Chat {
+	for _, message := range []*updateMessage{
+		u.Message, u.EditedMessage, u.ChannelPost, u.EditedChannelPost, u.MyChatMember, u.ChatMember, u.ChatJoinRequest,
+	} {
+		if message != nil {
+			return &message.Chat
+		}
+	}
+	return nil
+}
+
+// DiscoverChats returns the chats of the pending updates of the bot in the order they were first seen. Updates are
+// not confirmed, so they are still pending for the next call. Telegram keeps pending updates for 24 hours and rejects
+// getUpdates while a webhook is set for the bot
+func (c *Client) DiscoverChats(token string) ([]Chat, error) {
+	if token == "" {
+		return nil, fmt.Errorf("telegram bot token is empty")
+	}
+
+	var updates []Update
+	if err := c.callMethod(token, "getUpdates", struct{}{}, &updates); err != nil {
+		return nil, fmt.Errorf("failed to get updates: %w", err)
+	}
+
+	chats := []Chat{}
+	seen := make(map[int64]bool)
+	for _, update := range updates {
+		chat := update.chat()
+		if chat == nil || seen[chat.ID] {
+			continue
+		}
+		seen[chat.ID] = true
+		chats = append(chats, *chat)
+	}
+
+	return chats, nil
+}
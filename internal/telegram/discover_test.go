@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStruct_DiscoverChats(t *testing.T) {
+	var method string
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			method = req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":[
+					{"update_id":1,"message":{"message_id":1,"chat":{"id":123,"type":"private","first_name":"Jane","username":"jane"}}},
+					{"update_id":2,"my_chat_member":{"chat":{"id":-1001234567890,"type":"channel","title":"Alerts"}}},
+					{"update_id":3,"edited_message":{"message_id":1,"chat":{"id":123,"type":"private","first_name":"Jane","username":"jane"}}},
+					{"update_id":4,"channel_post":{"message_id":5,"chat":{"id":-1001234567890,"type":"channel","title":"Alerts"}}},
+					{"update_id":5,"message":{"message_id":7,"chat":{"id":-456,"type":"group","title":"Homelab"}}},
+					{"update_id":6,"poll":{"id":"1"}}
+				]}`)),
+			}, nil
+		},
+	}
+
+	chats, err := client.DiscoverChats("token")
+	require.NoError(t, err)
+	assert.Equal(t, "getUpdates", method)
+	assert.Equal(t, []Chat{
+		{ID: 123, Type: "private", Username: "jane", FirstName: "Jane"},
+		{ID: -1001234567890, Type: "channel", Title: "Alerts"},
+		{ID: -456, Type: "group", Title: "Homelab"},
+	}, chats)
+}
+
+func TestClientStruct_DiscoverChats_Errors(t *testing.T) {
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusConflict,
+				Body: io.NopCloser(bytes.NewBufferString(
+					`{"ok":false,"error_code":409,"description":"Conflict: can't use getUpdates method while webhook is active"}`)),
+			}, nil
+		},
+	}
+
+	_, err := client.DiscoverChats("")
+	assert.EqualError(t, err, "telegram bot token is empty")
+
+	_, err = client.DiscoverChats("token")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "webhook is active")
+}
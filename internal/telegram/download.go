@@ -0,0 +1,215 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxDownloadRedirects is the number of redirects followed by a download
+const maxDownloadRedirects = 5
+
+// errPrivateAddress is returned for downloads from hosts resolving to loopback, private or link-local addresses
+var errPrivateAddress = errors.New("host resolves to a private address")
+
+// downloadScope limits the hosts a download and its redirects may reach
+type downloadScope struct {
+	// allowedHosts are the hosts files are downloaded from
+	allowedHosts []string
+	// privateHosts are the allowed hosts that may resolve to private addresses, e.g. the Gotify server
+	privateHosts []string
+}
+
+// downloadScopeKey is the context key of the scope of a download request
+type downloadScopeKey struct{}
+
+// allows returns true if files may be downloaded from the URL
+func (s *downloadScope) allows(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && matchesHost(s.allowedHosts, u.Host)
+}
+
+// allowsPrivate returns true if the host, with or without port, may resolve to a private address
+func (s *downloadScope) allowsPrivate(hostport string) bool {
+	return matchesHost(s.privateHosts, hostport)
+}
+
+// matchesHost returns true if the host, with or without port, is one of the hosts
+func matchesHost(hosts []string, hostport string) bool {
+	hostname := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		hostname = h
+	}
+
+	for _, host := range hosts {
+		if strings.EqualFold(host, hostport) || strings.EqualFold(host, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivateIP returns true for addresses that must not be reached with URLs taken from messages
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// publicIPs resolves the host and returns its addresses. It fails if any address is private
+func publicIPs(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return nil, fmt.Errorf("%s: %w", host, errPrivateAddress)
+		}
+		ips = append(ips, addr.IP)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s: no addresses found", host)
+	}
+
+	return ips, nil
+}
+
+// guardDial wraps the dial function so that downloads only connect to public addresses unless their host
+// may be private. The resolved address is dialed, so the host can't resolve to another address in between.
+// Connections to the proxies are not checked, the targets of proxied downloads are checked by guardProxy
+func guardDial(dial func(ctx context.Context, network, addr string) (net.Conn, error), proxies []string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		scope, ok := ctx.Value(downloadScopeKey{}).(*downloadScope)
+		if !ok || scope.allowsPrivate(addr) || matchesHost(proxies, addr) {
+			return dial(ctx, network, addr)
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := publicIPs(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		return dial(ctx, network, net.JoinHostPort(ips[0].String(), port))
+	}
+}
+
+// guardProxy wraps the proxy function so that the targets of proxied downloads are checked for private
+// addresses before the request is sent to the proxy
+func guardProxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+
+		scope, ok := req.Context().Value(downloadScopeKey{}).(*downloadScope)
+		if ok && !scope.allowsPrivate(req.URL.Host) {
+			if _, err := publicIPs(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+		}
+
+		return proxyURL, nil
+	}
+}
+
+// proxyHosts returns the hosts of the proxies requests may be sent through
+func proxyHosts(proxy *url.URL) []string {
+	if proxy != nil {
+		return []string{proxy.Host}
+	}
+
+	var hosts []string
+	for _, target := range []string{"http://example.com", "https://example.com"} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		if proxyURL, err := http.ProxyFromEnvironment(req); err == nil && proxyURL != nil {
+			hosts = append(hosts, proxyURL.Host)
+		}
+	}
+	return hosts
+}
+
+// newHTTPClient returns the HTTP client of the Telegram API requests and downloads. Downloads are guarded
+// against private addresses and redirects leaving their allowed hosts
+func newHTTPClient(proxy *url.URL) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	transport.Proxy = guardProxy(transport.Proxy)
+	transport.DialContext = guardDial((&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext, proxyHosts(proxy))
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			scope, ok := req.Context().Value(downloadScopeKey{}).(*downloadScope)
+			if !ok {
+				if len(via) >= 10 {
+					return errors.New("stopped after 10 redirects")
+				}
+				return nil
+			}
+			if len(via) > maxDownloadRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxDownloadRedirects)
+			}
+			if !scope.allows(req.URL) {
+				return fmt.Errorf("redirect to host %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+}
+
+// download downloads the file at the URL if its host is allowed by the scope. Files larger than the
+// maximum size are rejected
+func (c *Client) download(rawURL string, scope *downloadScope, maxSize int64) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if !scope.allows(u) {
+		return nil, fmt.Errorf("host %s is not allowed", u.Host)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), downloadScopeKey{}, scope), c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", req.URL.Redacted(), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %d", req.URL.Redacted(), res.StatusCode)
+	}
+	if res.ContentLength > maxSize {
+		return nil, fmt.Errorf("file of %d bytes exceeds the maximum size of %d bytes", res.ContentLength, maxSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", req.URL.Redacted(), err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file exceeds the maximum size of %d bytes", maxSize)
+	}
+
+	return data, nil
+}
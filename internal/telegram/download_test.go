@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStruct_Send_DownscaleDisallowedHost(t *testing.T) {
+	msg := api.Message{
+		Title:   "Metadata",
+		Message: "leak",
+		Extras: map[string]interface{}{
+			"client::notification": map[string]interface{}{"bigImageUrl": "http://169.254.169.254/latest/meta-data"},
+		},
+	}
+
+	var fetched []string
+	var photo string
+	client := NewClient(Config{
+		ErrChan: make(chan error, 1),
+		Images:  ImagePolicy{MaxDimension: 100, JPEGQuality: 80, AllowedHosts: []string{"camera.local"}},
+	})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host != "api.telegram.org" {
+				fetched = append(fetched, req.URL.String())
+				return response(http.StatusOK, "secret"), nil
+			}
+
+			var payload PhotoPayload
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			photo = payload.Photo
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":1}}`)),
+			}, nil
+		},
+	}
+
+	client.Send(msg, "token", "123", config.MessageFormatOptions{ParseMode: ParseModeHTML})
+
+	assert.Empty(t, fetched, "photos of hosts that aren't allowed are never downloaded")
+	assert.Equal(t, "http://169.254.169.254/latest/meta-data", photo, "the photo is sent by URL instead")
+}
+
+func TestClientStruct_download_PrivateAddress(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+
+	_, err := client.download(server.URL, &downloadScope{allowedHosts: []string{host}}, 1024)
+	assert.ErrorIs(t, err, errPrivateAddress)
+	assert.Zero(t, requests, "loopback addresses are not reached")
+
+	data, err := client.download(server.URL, &downloadScope{allowedHosts: []string{host}, privateHosts: []string{host}}, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, "internal", string(data))
+	assert.Equal(t, 1, requests)
+}
+
+func TestClientStruct_download_Redirect(t *testing.T) {
+	var internalRequests int
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalRequests++
+		_, _ = w.Write([]byte("internal"))
+	}))
+	defer internal.Close()
+	_, port, err := net.SplitHostPort(internal.Listener.Addr().String())
+	require.NoError(t, err)
+	internalURL := "http://localhost:" + port + "/secret"
+
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			http.Redirect(w, r, internalURL, http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/file", http.StatusFound)
+	}))
+	defer allowed.Close()
+	host := allowed.Listener.Addr().String()
+	scope := &downloadScope{allowedHosts: []string{host}, privateHosts: []string{host, "localhost"}}
+
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+
+	_, err = client.download(allowed.URL+"/internal", scope, 1024)
+	assert.ErrorContains(t, err, "redirect to host localhost:"+port+" is not allowed")
+	assert.Zero(t, internalRequests, "redirects leaving the allowed hosts are not followed")
+
+	_, err = client.download(allowed.URL+"/loop", scope, 1024)
+	assert.ErrorContains(t, err, "stopped after 5 redirects")
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	for ip, expected := range map[string]bool{
+		"127.0.0.1":       true,
+		"10.0.0.1":        true,
+		"192.168.1.10":    true,
+		"172.16.0.1":      true,
+		"169.254.169.254": true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fe80::1":         true,
+		"fd00::1":         true,
+		"1.1.1.1":         false,
+		"2606:4700::1111": false,
+	} {
+		assert.Equal(t, expected, isPrivateIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestDownloadScope_allows(t *testing.T) {
+	scope := &downloadScope{allowedHosts: []string{"camera.local"}}
+	for rawURL, expected := range map[string]bool{
+		"http://camera.local/snapshot.jpg":      true,
+		"https://camera.local:8443/a.jpg":       true,
+		"http://169.254.169.254/latest":         false,
+		"file://camera.local/etc/passwd":        false,
+		"http://camera.local.evil.com/snapshot": false,
+	} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		assert.Equal(t, expected, scope.allows(u), rawURL)
+	}
+}
@@ -0,0 +1,45 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// errorNotModified is the description of the error Telegram returns when an edit doesn't change the message
+const errorNotModified = "message is not modified"
+
+// editMessage edits the previous message to show the formatted text. It returns the parts of the edited message
+// or nil if the previous message can't be edited, e.g. because it is a photo or the text doesn't fit into a single
+// message, or the edit failed
+func (c *Client) editMessage(previous *SentMessage, text string, formatOpts config.MessageFormatOptions, keyboard *InlineKeyboardMarkup) []SentPart {
+	if previous == nil || len(previous.Parts) != 1 || previous.Parts[0].Caption || utf16Length(text) > maxMessageLength {
+		return nil
+	}
+
+	part := previous.Parts[0]
+	payload := EditPayload{
+		ChatID:             previous.ChatID,
+		MessageID:          part.MessageID,
+		ParseMode:          apiParseMode(formatOpts.ParseMode),
+		LinkPreviewOptions: linkPreviewOptions(formatOpts.LinkPreview),
+		ReplyMarkup:        keyboard,
+	}
+	payload.Text, payload.Entities = messageText(text, formatOpts.ParseMode)
+
+	c.waitForChat(previous.ChatID, 1)
+	err := c.callMethod(previous.Token, "editMessageText", payload, nil)
+	var apiErr *APIError
+	if err != nil && !(errors.As(err, &apiErr) && strings.Contains(apiErr.Description, errorNotModified)) {
+		c.logger.Warn().
+			Err(err).
+			Str("chat_id", previous.ChatID).
+			Int64("telegram_message_id", part.MessageID).
+			Msg("failed to edit correlated message. Sending a new message")
+		return nil
+	}
+
+	part.Text = text
+	return []SentPart{part}
+}
@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+func TestClientStruct_SendOrEdit(t *testing.T) {
+	previous := func(parts ...SentPart) *SentMessage {
+		return &SentMessage{ChatID: "123", Token: "old-token", ParseMode: ParseModeMarkdownV2, Parts: parts}
+	}
+
+	tests := []struct {
+		name             string
+		previous         *SentMessage
+		body             string
+		editStatus       int
+		editResponse     string
+		expectedRequests []string
+		expectedSent     SentMessage
+	}{
+		{
+			name:             "no previous message",
+			body:             "down",
+			expectedRequests: []string{"token sendMessage"},
+			expectedSent: SentMessage{ChatID: "123", Token: "token", ParseMode: ParseModeMarkdownV2, CorrelationKey: "nginx",
+				Parts: []SentPart{{MessageID: 2, Text: "*nginx*\n\ndown\n\n"}}},
+		},
+		{
+			name:         "edits the previous text message",
+			previous:     previous(SentPart{MessageID: 1, Pinned: true}),
+			body:         "up",
+			editStatus:   http.StatusOK,
+			editResponse: `{"ok":true,"result":{"message_id":1}}`,
+			expectedRequests: []string{
+				`old-token editMessageText {"chat_id":"123","message_id":1,"text":"*nginx*\n\nup\n\n","parse_mode":"MarkdownV2"}`,
+			},
+			expectedSent: SentMessage{ChatID: "123", Token: "old-token", ParseMode: ParseModeMarkdownV2, CorrelationKey: "nginx",
+				Parts: []SentPart{{MessageID: 1, Text: "*nginx*\n\nup\n\n", Pinned: true}}},
+		},
+		{
+			name:         "unchanged message",
+			previous:     previous(SentPart{MessageID: 1}),
+			body:         "up",
+			editStatus:   http.StatusBadRequest,
+			editResponse: `{"ok":false,"description":"Bad Request: message is not modified"}`,
+			expectedRequests: []string{
+				`old-token editMessageText {"chat_id":"123","message_id":1,"text":"*nginx*\n\nup\n\n","parse_mode":"MarkdownV2"}`,
+			},
+			expectedSent: SentMessage{ChatID: "123", Token: "old-token", ParseMode: ParseModeMarkdownV2, CorrelationKey: "nginx",
+				Parts: []SentPart{{MessageID: 1, Text: "*nginx*\n\nup\n\n"}}},
+		},
+		{
+			name:         "failed edit sends a new message",
+			previous:     previous(SentPart{MessageID: 1}),
+			body:         "up",
+			editStatus:   http.StatusBadRequest,
+			editResponse: `{"ok":false,"description":"Bad Request: message to edit not found"}`,
+			expectedRequests: []string{
+				`old-token editMessageText {"chat_id":"123","message_id":1,"text":"*nginx*\n\nup\n\n","parse_mode":"MarkdownV2"}`,
+				"token sendMessage",
+			},
+			expectedSent: SentMessage{ChatID: "123", Token: "token", ParseMode: ParseModeMarkdownV2, CorrelationKey: "nginx",
+				Parts: []SentPart{{MessageID: 2, Text: "*nginx*\n\nup\n\n"}}},
+		},
+		{
+			name:             "photos aren't edited",
+			previous:         previous(SentPart{MessageID: 1, Caption: true}),
+			body:             "up",
+			expectedRequests: []string{"token sendMessage"},
+			expectedSent: SentMessage{ChatID: "123", Token: "token", ParseMode: ParseModeMarkdownV2, CorrelationKey: "nginx",
+				Parts: []SentPart{{MessageID: 2, Text: "*nginx*\n\nup\n\n"}}},
+		},
+		{
+			name:             "messages split into several messages aren't edited",
+			previous:         previous(SentPart{MessageID: 1}, SentPart{MessageID: 3}),
+			body:             "up",
+			expectedRequests: []string{"token sendMessage"},
+			expectedSent: SentMessage{ChatID: "123", Token: "token", ParseMode: ParseModeMarkdownV2, CorrelationKey: "nginx",
+				Parts: []SentPart{{MessageID: 2, Text: "*nginx*\n\nup\n\n"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				requests []string
+				sent     SentMessage
+			)
+			errChan := make(chan error, 1)
+			client := NewClient(Config{ErrChan: errChan, OnSent: func(_ api.Message, s SentMessage) { sent = s }})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					body, err := io.ReadAll(req.Body)
+					require.NoError(t, err)
+					token := strings.TrimPrefix(strings.Split(req.URL.Path, "/")[1], "bot")
+					method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+					if method == "editMessageText" {
+						requests = append(requests, token+" "+method+" "+string(body))
+						return &http.Response{
+							StatusCode: tt.editStatus,
+							Body:       io.NopCloser(bytes.NewBufferString(tt.editResponse)),
+						}, nil
+					}
+
+					requests = append(requests, token+" "+method)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":2}}`)),
+					}, nil
+				},
+			}
+
+			msg := api.Message{Title: "nginx", Message: tt.body, Extras: map[string]interface{}{"service": "nginx"}}
+			client.SendOrEdit(msg, "token", "123", config.MessageFormatOptions{
+				ParseMode:      ParseModeMarkdownV2,
+				CorrelationKey: "{{ .Extras.service }}",
+			}, Related{Previous: tt.previous})
+
+			assert.Empty(t, errChan)
+			assert.Equal(t, tt.expectedRequests, requests)
+			assert.Equal(t, tt.expectedSent, sent)
+		})
+	}
+}
+
+func TestCorrelationKey(t *testing.T) {
+	msg := api.Message{AppID: 3, Extras: map[string]interface{}{"service": "nginx"}}
+
+	assert.Equal(t, "3-nginx", CorrelationKey("{{ .AppID }}-{{ .Extras.service }}", msg))
+	assert.Equal(t, "", CorrelationKey("", msg))
+	assert.Equal(t, "", CorrelationKey("{{ .Extras.missing }}", msg))
+	assert.Equal(t, "", CorrelationKey("{{ .Missing }}", msg))
+}
@@ -0,0 +1,170 @@
+package telegram
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Markers of formatted text in the entities parse mode. Entities are marked with private use
+// characters while the message is formatted and turned into message entities when it's sent
+const (
+	// entityOpen starts the header of an entity holding its type and URL
+	entityOpen = "\uE000"
+	// entityStart ends the header. The text of the entity follows
+	entityStart = "\uE001"
+	// entityClose ends the text of the entity
+	entityClose = "\uE002"
+)
+
+// MessageEntity is a formatted part of the text of a message such as bold text or a link
+type MessageEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url,omitempty"`
+}
+
+// entityMarkerRemover removes the markers from text, so that user content can't add entities
+var entityMarkerRemover = strings.NewReplacer(entityOpen, "", entityStart, "", entityClose, "")
+
+// entitiesMarkup renders text with entity markers for the entities parse mode
+type entitiesMarkup struct{}
+
+// markEntity marks already escaped text as an entity of the type
+func markEntity(entityType, text string) string {
+	return entityOpen + entityType + entityStart + text + entityClose
+}
+
+func (entitiesMarkup) escape(text string) string {
+	return entityMarkerRemover.Replace(text)
+}
+
+func (entitiesMarkup) bold(text string) string {
+	return markEntity("bold", text)
+}
+
+func (entitiesMarkup) italic(text string) string {
+	return markEntity("italic", text)
+}
+
+func (entitiesMarkup) strikethrough(text string) string {
+	return markEntity("strikethrough", text)
+}
+
+func (entitiesMarkup) spoiler(text string) string {
+	return markEntity("spoiler", text)
+}
+
+func (entitiesMarkup) code(text string) string {
+	return markEntity("code", entityMarkerRemover.Replace(text))
+}
+
+func (entitiesMarkup) pre(text string) string {
+	return markEntity("pre", entityMarkerRemover.Replace(text))
+}
+
+func (entitiesMarkup) link(label, url string) string {
+	return markEntity("text_link "+entityMarkerRemover.Replace(url), entityMarkerRemover.Replace(label))
+}
+
+func (m entitiesMarkup) body(text string) string {
+	return formatBody(text, m)
+}
+
+// parseEntities removes the entity markers from the text and returns the plain text and its entities.
+// Offsets and lengths are in UTF-16 code units. Entities that aren't closed end at the end of the text
+func parseEntities(text string) (string, []MessageEntity) {
+	var (
+		plain    strings.Builder
+		entities []MessageEntity
+		open     []int
+		offset   int
+	)
+
+	for rest := text; rest != ""; {
+		switch {
+		case strings.HasPrefix(rest, entityOpen):
+			end := strings.Index(rest, entityStart)
+			if end < 0 {
+				rest = rest[len(entityOpen):]
+				continue
+			}
+			entityType, url, _ := strings.Cut(rest[len(entityOpen):end], " ")
+			open = append(open, len(entities))
+			entities = append(entities, MessageEntity{Type: entityType, Offset: offset, URL: url})
+			rest = rest[end+len(entityStart):]
+		case strings.HasPrefix(rest, entityClose):
+			if len(open) > 0 {
+				entities[open[len(open)-1]].Length = offset - entities[open[len(open)-1]].Offset
+				open = open[:len(open)-1]
+			}
+			rest = rest[len(entityClose):]
+		default:
+			r, size := utf8.DecodeRuneInString(rest)
+			plain.WriteString(rest[:size])
+			offset += utf16.RuneLen(r)
+			rest = rest[size:]
+		}
+	}
+
+	for _, i := range open {
+		entities[i].Length = offset - entities[i].Offset
+	}
+
+	// Telegram rejects empty entities
+	nonEmpty := entities[:0]
+	for _, e := range entities {
+		if e.Length > 0 {
+			nonEmpty = append(nonEmpty, e)
+		}
+	}
+
+	return plain.String(), nonEmpty
+}
+
+// messageText returns the text to send and its entities. Only text formatted for the entities
+// parse mode has entities
+func messageText(text, parseMode string) (string, []MessageEntity) {
+	if parseMode != ParseModeEntities {
+		return text, nil
+	}
+	return parseEntities(text)
+}
+
+// entitiesField encodes the entities for a multipart form field. It is empty without entities
+func entitiesField(entities []MessageEntity) string {
+	if len(entities) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(entities)
+	return string(data)
+}
+
+// segmentEntities splits text with entity markers into segments. Markers are never split
+// and entities open at the end of a chunk are closed and opened again in the next one
+func segmentEntities(text string) []segment {
+	var segments []segment
+	var open []entity
+
+	for rest := text; rest != ""; {
+		n := graphemeLength(rest)
+
+		switch {
+		case strings.HasPrefix(rest, entityOpen):
+			if end := strings.Index(rest, entityStart); end >= 0 {
+				n = end + len(entityStart)
+				open = pushEntity(open, entity{close: entityClose, reopen: rest[:n]})
+			}
+		case strings.HasPrefix(rest, entityClose):
+			n = len(entityClose)
+			open = closeEntity(open, entityClose)
+		}
+
+		segments = append(segments, segment{text: rest[:n], open: open})
+		rest = rest[n:]
+	}
+
+	return segments
+}
@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+func TestParseEntities(t *testing.T) {
+	m := entitiesMarkup{}
+
+	tests := []struct {
+		name     string
+		text     string
+		plain    string
+		entities []MessageEntity
+	}{
+		{
+			name:  "plain text",
+			text:  "a *b* <c>",
+			plain: "a *b* <c>",
+		},
+		{
+			name:     "bold title",
+			text:     m.bold("Title") + "\n\nbody",
+			plain:    "Title\n\nbody",
+			entities: []MessageEntity{{Type: "bold", Offset: 0, Length: 5}},
+		},
+		{
+			name:  "offsets in UTF-16 code units",
+			text:  "🔥 " + m.bold("é🌡️") + " " + m.code("x"),
+			plain: "🔥 é🌡️ x",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 3, Length: 4},
+				{Type: "code", Offset: 8, Length: 1},
+			},
+		},
+		{
+			name:  "nested entities",
+			text:  m.bold("a " + m.italic("b")),
+			plain: "a b",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 0, Length: 3},
+				{Type: "italic", Offset: 2, Length: 1},
+			},
+		},
+		{
+			name:     "links",
+			text:     m.body("see [the docs](https://example.com/a?b=1)"),
+			plain:    "see the docs",
+			entities: []MessageEntity{{Type: "text_link", Offset: 4, Length: 8, URL: "https://example.com/a?b=1"}},
+		},
+		{
+			name:     "unclosed entities end at the end of the text",
+			text:     entityOpen + "bold" + entityStart + "abc",
+			plain:    "abc",
+			entities: []MessageEntity{{Type: "bold", Offset: 0, Length: 3}},
+		},
+		{
+			name:  "drops empty entities",
+			text:  m.bold("") + "a",
+			plain: "a",
+		},
+		{
+			name:  "user content can't add entities",
+			text:  m.escape(entityOpen + "bold" + entityStart + "a" + entityClose),
+			plain: "bolda",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, entities := parseEntities(tt.text)
+			assert.Equal(t, tt.plain, plain)
+			if tt.entities == nil {
+				assert.Empty(t, entities)
+			} else {
+				assert.Equal(t, tt.entities, entities)
+			}
+		})
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	text := entitiesMarkup{}.bold("a")
+
+	plain, entities := messageText(text, ParseModeMarkdownV2)
+	assert.Equal(t, text, plain)
+	assert.Nil(t, entities)
+
+	plain, entities = messageText(text, ParseModeEntities)
+	assert.Equal(t, "a", plain)
+	assert.Equal(t, []MessageEntity{{Type: "bold", Offset: 0, Length: 1}}, entities)
+	assert.Equal(t, `[{"type":"bold","offset":0,"length":1}]`, entitiesField(entities))
+	assert.Equal(t, "", entitiesField(nil))
+}
+
+func TestSplitText_Entities(t *testing.T) {
+	m := entitiesMarkup{}
+	chunks := splitText(m.bold("bold text")+" tail", 14, ParseModeEntities)
+	require.Len(t, chunks, 2)
+
+	plain, entities := parseEntities(chunks[0])
+	assert.Equal(t, "bold te", plain)
+	assert.Equal(t, []MessageEntity{{Type: "bold", Offset: 0, Length: 7}}, entities)
+
+	plain, entities = parseEntities(chunks[1])
+	assert.Equal(t, "xt tail", plain)
+	assert.Equal(t, []MessageEntity{{Type: "bold", Offset: 0, Length: 2}}, entities)
+}
+
+func TestClientStruct_Send_Entities(t *testing.T) {
+	var payload map[string]interface{}
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+			}, nil
+		},
+	}
+
+	client.Send(api.Message{Title: "*Title*", Message: "a_b <c> [d](https://e.com)"}, "valid-token", "123456",
+		config.MessageFormatOptions{ParseMode: ParseModeEntities})
+	assert.Equal(t, map[string]interface{}{
+		"chat_id": "123456",
+		"text":    "*Title*\n\na_b <c> d\n\n",
+		"entities": []interface{}{
+			map[string]interface{}{"type": "bold", "offset": float64(0), "length": float64(7)},
+			map[string]interface{}{"type": "text_link", "offset": float64(17), "length": float64(1), "url": "https://e.com"},
+		},
+	}, payload)
+}
@@ -0,0 +1,47 @@
+package telegram
+
+import "strings"
+
+// Footer identifies the plugin instance a message was forwarded by
+type Footer struct {
+	Hostname    string
+	Environment string
+	Version     string
+}
+
+// format returns the footer line. Empty fields are left out
+func (f Footer) format(m markup) string {
+	var fields []string
+	for _, field := range []string{f.Hostname, f.Environment} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if f.Version != "" {
+		fields = append(fields, "gotify-to-telegram "+f.Version)
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return m.escape("— " + strings.Join(fields, " · "))
+}
+
+// appendFooter appends the footer line to the formatted message
+func appendFooter(text string, footer Footer, parseMode string) (string, error) {
+	m, err := markupFor(parseMode)
+	if err != nil {
+		return "", err
+	}
+
+	line := footer.format(m)
+	if line == "" {
+		return text, nil
+	}
+
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return line, nil
+	}
+	return text + "\n\n" + line, nil
+}
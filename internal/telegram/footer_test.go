@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendFooter(t *testing.T) {
+	footer := Footer{Hostname: "web-1", Environment: "prod", Version: "1.2.3"}
+
+	tests := []struct {
+		name      string
+		text      string
+		footer    Footer
+		parseMode string
+		expected  string
+	}{
+		{
+			name:      "markdownv2",
+			text:      "*Title*\n\nBody\n\n",
+			footer:    footer,
+			parseMode: ParseModeMarkdownV2,
+			expected:  "*Title*\n\nBody\n\n— web\\-1 · prod · gotify\\-to\\-telegram 1\\.2\\.3",
+		},
+		{
+			name:      "html",
+			text:      "<b>Title</b>\n\nBody\n",
+			footer:    footer,
+			parseMode: ParseModeHTML,
+			expected:  "<b>Title</b>\n\nBody\n\n— web-1 · prod · gotify-to-telegram 1.2.3",
+		},
+		{
+			name:      "empty fields",
+			text:      "Body",
+			footer:    Footer{Hostname: "web-1"},
+			parseMode: ParseModeHTML,
+			expected:  "Body\n\n— web-1",
+		},
+		{
+			name:      "empty footer",
+			text:      "Body\n\n",
+			parseMode: ParseModeHTML,
+			expected:  "Body\n\n",
+		},
+		{
+			name:      "empty text",
+			footer:    footer,
+			parseMode: ParseModeHTML,
+			expected:  "— web-1 · prod · gotify-to-telegram 1.2.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := appendFooter(tt.text, tt.footer, tt.parseMode)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, text)
+		})
+	}
+}
@@ -1,125 +1,79 @@
 package telegram
 
 import (
+	"encoding/json"
 	"fmt"
-	"regexp"
+	"path"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
 )
 
-// charactersToEscape contains all special characters that need to be escaped in regular text
-var charactersToEscape = []string{"_", "*", "[", "]", "(", ")", "~", "`", ">", "#", "+", "-", "=", "|", "{", "}", ".", "!"}
-
-// urlRegex matches URLs in the text
-var urlRegex = regexp.MustCompile(`https?://[^\s]+`)
-
-// imageMarkdownRegex matches image markdown syntax
-var imageMarkdownRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)`)
-
-// inlineURLRegex matches inline URL markdown syntax
-var inlineURLRegex = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+// markdownV2Escaper escapes the characters that are reserved in MarkdownV2 text
+var markdownV2Escaper = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)", "~", "\\~", "`", "\\`",
+	">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
 
 // escapeMarkdownV2 escapes all special characters in a text string
 func escapeMarkdownV2(text string) string {
-	escaped := text
-	for _, char := range charactersToEscape {
-		// Don't escape backslashes that are part of \n
-		if strings.Contains(escaped, `\n`) {
-			parts := strings.Split(escaped, `\n`)
-			for i, part := range parts {
-				if char != `\` { // Don't escape backslashes
-					parts[i] = strings.ReplaceAll(part, char, `\`+char)
-				}
-			}
-			escaped = strings.Join(parts, `\n`)
-		} else {
-			escaped = strings.ReplaceAll(escaped, char, `\`+char)
-		}
-	}
-	return escaped
+	return markdownV2Escaper.Replace(text)
 }
 
-// formatPlainURL escapes special characters in a URL
-func formatPlainURL(url string) string {
-	return escapeMarkdownV2(url)
+// formatTitle formats the title for Telegram
+func formatTitle(msg api.Message) string {
+	return fmt.Sprintf("[%s] %s", msg.AppName, msg.Title)
 }
 
-// extractAndFormatImageURL extracts the URL from an image markdown and formats it
-func extractAndFormatImageURL(imageMarkdown string) string {
-	matches := imageMarkdownRegex.FindStringSubmatch(imageMarkdown)
-	if len(matches) < 3 {
-		return imageMarkdown
+// titleLine returns the title of the message with the app name, prefix and tags of the format options
+func titleLine(msg api.Message, formatOpts config.MessageFormatOptions) string {
+	title := msg.Title
+	if formatOpts.IncludeAppName {
+		title = formatTitle(msg)
 	}
-	return matches[2]
-}
 
-// preserveInlineURL keeps the inline URL markdown format intact
-func preserveInlineURL(inlineURL string) string {
-	// Since the inline URL format is allowed, we return it as is
-	return inlineURL
-}
+	if formatOpts.TitlePrefix != "" {
+		title = formatOpts.TitlePrefix + " " + title
+	}
 
-func formatMessageAsMarkdownV2(input string) string {
-	// First, collect all inline URLs to preserve them
-	inlineURLs := make(map[string]string)
-	currentText := input
+	for _, tag := range formatOpts.Tags {
+		// hashtags end at whitespace
+		if tag = strings.Join(strings.Fields(strings.TrimPrefix(tag, "#")), "_"); tag != "" {
+			title += " #" + tag
+		}
+	}
 
-	// Preserve inline URLs by replacing them with placeholders
-	currentText = inlineURLRegex.ReplaceAllStringFunc(currentText, func(match string) string {
-		placeholder := "INLINEURL" + strconv.Itoa(len(inlineURLs))
-		inlineURLs[placeholder] = match
-		return placeholder
-	})
+	return title
+}
 
-	// Handle image markdown by extracting and formatting URLs
-	currentText = imageMarkdownRegex.ReplaceAllStringFunc(currentText, func(match string) string {
-		return extractAndFormatImageURL(match)
-	})
+// formatCompact renders the message as a single "[App] Title" line. Messages without a title use the
+// first line of their body
+func formatCompact(msg api.Message, formatOpts config.MessageFormatOptions, m markup) string {
+	if msg.Title == "" {
+		msg.Title, _, _ = strings.Cut(strings.TrimSpace(msg.Message), "\n")
+	}
+	formatOpts.IncludeAppName = msg.AppName != ""
+	line := titleLine(msg, formatOpts)
 
-	// Format plain URLs
-	currentText = urlRegex.ReplaceAllStringFunc(currentText, func(match string) string {
-		// Skip if this URL is part of our preserved inline URLs
-		for _, preserved := range inlineURLs {
-			if strings.Contains(preserved, match) {
-				return match
-			}
+	if int(msg.Priority) > formatOpts.PriorityThreshold && formatOpts.IncludePriority {
+		emoji, label := priorityLevel(int(msg.Priority), labelsFor(formatOpts.Language), formatOpts.PriorityLevels)
+		if emoji == "" {
+			emoji = label
 		}
-		return formatPlainURL(match)
-	})
-
-	// Escape special characters in the remaining text
-	words := strings.Split(currentText, " ")
-	for i, word := range words {
-		// Skip if this is one of our placeholders
-		if _, isPreserved := inlineURLs[word]; !isPreserved {
-			// Skip if this word is already a formatted URL
-			if !urlRegex.MatchString(word) {
-				words[i] = escapeMarkdownV2(word)
-			}
+		if emoji != "" {
+			line = emoji + " " + line
 		}
 	}
-	currentText = strings.Join(words, " ")
 
-	// Restore preserved inline URLs
-	for placeholder, original := range inlineURLs {
-		currentText = strings.ReplaceAll(currentText, placeholder, original)
-	}
-
-	return currentText
-}
-
-// formatTitle formats the title for Telegram
-func formatTitle(msg api.Message) string {
-	return fmt.Sprintf("[%s] %s", msg.AppName, msg.Title)
+	return m.escape(line)
 }
 
 // formatExtras handles the recursive formatting of nested maps
-func formatExtras(builder *strings.Builder, extras map[string]interface{}, prefix string) {
+func formatExtras(builder *strings.Builder, m markup, extras map[string]interface{}, prefix string) {
 	// Get keys and sort them
 	keys := make([]string, 0, len(extras))
 	for key := range extras {
@@ -129,76 +83,337 @@ func formatExtras(builder *strings.Builder, extras map[string]interface{}, prefi
 
 	for _, key := range keys {
 		value := extras[key]
-		escapedKey := escapeMarkdownV2(key)
+		escapedKey := m.escape(key)
 
 		// Handle nested maps
 		if nestedMap, ok := value.(map[string]interface{}); ok {
 			builder.WriteString(fmt.Sprintf("\n%s• %s:", prefix, escapedKey))
-			formatExtras(builder, nestedMap, prefix+"  ") // Increase indentation for nested items
+			formatExtras(builder, m, nestedMap, prefix+"  ") // Increase indentation for nested items
 		} else {
 			// Format simple values
-			escapedValue := escapeMarkdownV2(fmt.Sprint(value))
-			builder.WriteString(fmt.Sprintf("\n%s• %s: `%s`", prefix, escapedKey, escapedValue))
+			builder.WriteString(fmt.Sprintf("\n%s• %s: %s", prefix, escapedKey, m.code(fmt.Sprint(value))))
 		}
 	}
 
 	builder.WriteString("\n\n")
 }
 
-// getPriorityIndicator returns the emoji indicator for the priority
-func getPriorityIndicator(priority int) string {
+// elidedMarker replaces extras content that exceeds the depth or length limits
+const elidedMarker = "…"
+
+// limitExtras returns a copy of the extras with objects nested deeper than maxDepth replaced
+// by the elided marker and values longer than maxLength truncated. Limits of 0 are unlimited
+func limitExtras(extras map[string]interface{}, maxDepth, maxLength, depth int) map[string]interface{} {
+	limited := make(map[string]interface{}, len(extras))
+	for key, value := range extras {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if maxDepth > 0 && depth >= maxDepth {
+				limited[key] = elidedMarker
+			} else {
+				limited[key] = limitExtras(nested, maxDepth, maxLength, depth+1)
+			}
+			continue
+		}
+
+		if maxLength > 0 {
+			if s := fmt.Sprint(value); graphemeCount(s) > maxLength {
+				value = truncate(maxLength, s)
+			}
+		}
+		limited[key] = value
+	}
+
+	return limited
+}
+
+// filterExtras returns a copy of the extras with the keys matching the include patterns and not
+// matching the exclude patterns. Nested keys are matched joined by dots and nested objects whose key
+// matches an include pattern are kept as a whole. Without include patterns all keys are included
+func filterExtras(extras map[string]interface{}, include, exclude []string, prefix string) map[string]interface{} {
+	filtered := make(map[string]interface{}, len(extras))
+	for key, value := range extras {
+		fullKey := prefix + key
+		if matchesAny(exclude, fullKey) {
+			continue
+		}
+
+		if len(include) == 0 || matchesAny(include, fullKey) {
+			if nested, ok := value.(map[string]interface{}); ok && len(exclude) > 0 {
+				value = filterExtras(nested, nil, exclude, fullKey+".")
+			}
+			filtered[key] = value
+			continue
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			if nestedFiltered := filterExtras(nested, include, exclude, fullKey+"."); len(nestedFiltered) > 0 {
+				filtered[key] = nestedFiltered
+			}
+		}
+	}
+
+	return filtered
+}
+
+// matchesAny returns true if the key matches one of the glob patterns
+func matchesAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultLargeExtrasSize is the size in bytes of the JSON encoded extras above which they are large
+const defaultLargeExtrasSize = 2048
+
+// largeExtrasStyle returns how the extras are rendered. Extras up to the large extras size are always
+// rendered inline
+func largeExtrasStyle(extras map[string]interface{}, formatOpts config.MessageFormatOptions) string {
+	style := strings.ToLower(formatOpts.LargeExtras)
+	if style == "" || style == config.LargeExtrasInline {
+		return config.LargeExtrasInline
+	}
+
+	size := formatOpts.LargeExtrasSize
+	if size == 0 {
+		size = defaultLargeExtrasSize
+	}
+	if data, err := json.Marshal(extras); err != nil || len(data) <= size {
+		return config.LargeExtrasInline
+	}
+
+	return style
+}
+
+// includedExtras returns the extras of the message that are shown with the format options
+func includedExtras(msg api.Message, formatOpts config.MessageFormatOptions) map[string]interface{} {
+	if !formatOpts.IncludeExtras || formatOpts.Template != "" || formatOpts.Compact {
+		return nil
+	}
+	return filterExtras(msg.Extras, formatOpts.ExtrasIncludeKeys, formatOpts.ExtrasExcludeKeys, "")
+}
+
+// extrasFile returns the extras of the message encoded as an indented JSON document if they are sent
+// as a file. It returns nil if the extras are part of the message text
+func extrasFile(msg api.Message, formatOpts config.MessageFormatOptions) []byte {
+	extras := includedExtras(msg, formatOpts)
+	if len(extras) == 0 || largeExtrasStyle(extras, formatOpts) != config.LargeExtrasFile {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(extras, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// isFlat returns true if none of the extras values is a nested map
+func isFlat(extras map[string]interface{}) bool {
+	for _, value := range extras {
+		if _, ok := value.(map[string]interface{}); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// useExtrasTable returns true if the extras should be rendered as a table for the style
+func useExtrasTable(extras map[string]interface{}, style string) bool {
+	switch strings.ToLower(style) {
+	case config.ExtrasStyleList:
+		return false
+	case config.ExtrasStyleTable:
+		return true
+	default:
+		return isFlat(extras)
+	}
+}
+
+// formatExtrasTable renders extras as key/value lines with aligned values
+func formatExtrasTable(fields []ExtraField) string {
+	width := 0
+	for _, field := range fields {
+		if n := utf8.RuneCountInString(field.Key); n > width {
+			width = n
+		}
+	}
+
+	lines := make([]string, 0, len(fields))
+	for _, field := range fields {
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(field.Key))
+		lines = append(lines, fmt.Sprintf("%s:%s %v", field.Key, padding, field.Value))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// getPriorityIndicator returns the emoji indicator for the priority. It is empty for priorities
+// below the lowest custom level
+func getPriorityIndicator(priority int, l labels, levels []config.PriorityLevel) string {
+	emoji, label := priorityLevel(priority, l, levels)
+	return strings.TrimSpace(emoji + " " + label)
+}
+
+// priorityLevel returns the emoji and the label of the priority. Custom levels replace the default levels
+func priorityLevel(priority int, l labels, levels []config.PriorityLevel) (string, string) {
+	if len(levels) > 0 {
+		var match *config.PriorityLevel
+		for i, level := range levels {
+			if level.MinPriority <= priority && (match == nil || level.MinPriority > match.MinPriority) {
+				match = &levels[i]
+			}
+		}
+		if match == nil {
+			return "", ""
+		}
+		return match.Emoji, match.Label
+	}
+
 	switch {
 	case priority >= 8:
-		return "🔴 Critical Priority"
+		return "🔴", l.CriticalPriority
 	case priority >= 6:
-		return "🟠 High Priority"
+		return "🟠", l.HighPriority
 	case priority >= 4:
-		return "🟡 Medium Priority"
+		return "🟡", l.MediumPriority
 	default:
-		return "🟢 Low Priority"
+		return "🟢", l.LowPriority
+	}
+}
+
+// formatTimestamp formats the time in the timezone and with the layout of the format options
+func formatTimestamp(t time.Time, formatOpts config.MessageFormatOptions) string {
+	if formatOpts.Timezone != "" {
+		if location, err := time.LoadLocation(formatOpts.Timezone); err == nil {
+			t = t.In(location)
+		}
+	}
+
+	layout := formatOpts.TimestampFormat
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	return t.Format(layout)
+}
+
+// FormatCaption formats the title of the message as a short caption for media messages
+func FormatCaption(msg api.Message, formatOpts config.MessageFormatOptions) (string, error) {
+	m, err := markupFor(formatOpts.ParseMode)
+	if err != nil {
+		return "", err
+	}
+
+	if msg.Title == "" {
+		return "", nil
 	}
+
+	return m.bold(m.escape(titleLine(msg, formatOpts))), nil
 }
 
-// FormatMessage formats the input text according to Telegram MarkdownV2 rules
+// FormatMessage formats the input text according to the rules of the parse mode
 func FormatMessage(msg api.Message, formatOpts config.MessageFormatOptions) (string, error) {
-	var (
-		builder      strings.Builder
-		messageTitle string
-	)
+	m, err := markupFor(formatOpts.ParseMode)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := formatLayout(msg, formatOpts, m)
+	if err != nil {
+		return "", err
+	}
+
+	return addSnippets(text, msg, formatOpts, m)
+}
+
+// addSnippets renders the header and footer snippets of the format options around the formatted message
+func addSnippets(text string, msg api.Message, formatOpts config.MessageFormatOptions, m markup) (string, error) {
+	if formatOpts.Header != "" {
+		header, err := renderTemplate(formatOpts.Header, msg, m, formatOpts.PriorityLevels)
+		if err != nil {
+			return "", fmt.Errorf("header: %w", err)
+		}
+		text = header + "\n\n" + text
+	}
+
+	if formatOpts.Footer != "" {
+		footer, err := renderTemplate(formatOpts.Footer, msg, m, formatOpts.PriorityLevels)
+		if err != nil {
+			return "", fmt.Errorf("footer: %w", err)
+		}
+		text = strings.TrimRight(text, "\n") + "\n\n" + footer
+	}
+
+	return text, nil
+}
+
+// formatLayout formats the message with the template or the default layout
+func formatLayout(msg api.Message, formatOpts config.MessageFormatOptions, m markup) (string, error) {
+	var builder strings.Builder
+
+	if formatOpts.Template != "" {
+		return renderTemplate(formatOpts.Template, msg, m, formatOpts.PriorityLevels)
+	}
+
+	if formatOpts.Compact {
+		return formatCompact(msg, formatOpts, m), nil
+	}
 
 	// Title in bold
 	if msg.Title != "" {
-		if formatOpts.IncludeAppName {
-			messageTitle = formatTitle(msg)
-		} else {
-			messageTitle = msg.Title
-		}
-		builder.WriteString(fmt.Sprintf("*%s*\n\n", escapeMarkdownV2(messageTitle)))
+		builder.WriteString(m.bold(m.escape(titleLine(msg, formatOpts))) + "\n\n")
 	}
 
-	switch formatOpts.ParseMode {
-	case "MarkdownV2":
-		message := formatMessageAsMarkdownV2(msg.Message)
-		builder.WriteString(message + "\n\n")
-	default:
-		return "", fmt.Errorf("parse mode %s is not supported", formatOpts.ParseMode)
+	var body string
+	if isMarkdown(msg) {
+		body = renderMarkdown(msg.Message, m)
+	} else {
+		body = m.body(msg.Message)
 	}
+	if formatOpts.Spoiler && strings.TrimSpace(msg.Message) != "" {
+		body = m.spoiler(body)
+	}
+	builder.WriteString(body + "\n\n")
+
+	l := labelsFor(formatOpts.Language)
 
 	// Priority indicator using emojis
 	if int(msg.Priority) > formatOpts.PriorityThreshold && formatOpts.IncludePriority {
-		builder.WriteString(escapeMarkdownV2(getPriorityIndicator(int(msg.Priority))) + "\n\n")
+		if indicator := getPriorityIndicator(int(msg.Priority), l, formatOpts.PriorityLevels); indicator != "" {
+			builder.WriteString(m.escape(indicator) + "\n\n")
+		}
 	}
 
-	// Add any extras if present and not empty
-	if len(msg.Extras) > 0 && formatOpts.IncludeExtras {
-		builder.WriteString("*Additional Info:*")
-		formatExtras(&builder, msg.Extras, "")
+	// Add any extras if present and not empty. Large extras sent as a file are left out
+	extras := includedExtras(msg, formatOpts)
+	if style := largeExtrasStyle(extras, formatOpts); len(extras) > 0 && style != config.LargeExtrasFile {
+		extras = limitExtras(extras, formatOpts.MaxExtrasDepth, formatOpts.MaxExtrasLength, 1)
+
+		builder.WriteString(m.bold(m.escape(l.AdditionalInfo + ":")))
+		if style == config.LargeExtrasJSON {
+			data, err := toJSONIndent(extras)
+			if err != nil {
+				return "", fmt.Errorf("failed to encode extras: %w", err)
+			}
+			builder.WriteString("\n" + m.pre(data) + "\n\n")
+		} else if useExtrasTable(extras, formatOpts.ExtrasStyle) {
+			builder.WriteString("\n" + m.pre(formatExtrasTable(flattenExtras(extras))) + "\n\n")
+		} else {
+			formatExtras(&builder, m, extras, "")
+		}
 	}
 
-	// Add timestamp
+	// Add timestamp of the gotify message. Messages without a date are stamped with the current time
 	if formatOpts.IncludeTimestamp {
-		formattedTimestamp := time.Now().Format(time.RFC3339)
-		builder.WriteString(fmt.Sprintf("timestamp: %s", escapeMarkdownV2(formattedTimestamp)) + "\n")
+		timestamp := msg.Date
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		formattedTimestamp := formatTimestamp(timestamp, formatOpts)
+		builder.WriteString(fmt.Sprintf("%s: %s", m.escape(l.Timestamp), m.escape(formattedTimestamp)) + "\n")
 	}
 
 	return builder.String(), nil
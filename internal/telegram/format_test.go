@@ -3,10 +3,12 @@ package telegram
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEscapeMarkdownV2(t *testing.T) {
@@ -40,59 +42,46 @@ func TestEscapeMarkdownV2(t *testing.T) {
 	}
 }
 
-func TestFormatPlainURL(t *testing.T) {
+func TestParseLink(t *testing.T) {
 	tests := []struct {
-		name     string
-		url      string
-		expected string
+		name  string
+		input string
+		n     int
+		label string
+		url   string
+		image bool
 	}{
+		{name: "link", input: "[docs](https://example.com) rest", n: 27, label: "docs", url: "https://example.com"},
+		{name: "image", input: "![alt](https://example.com/a.png)", n: 33, label: "alt", url: "https://example.com/a.png", image: true},
+		{name: "empty label", input: "[](https://example.com)", n: 23, url: "https://example.com"},
+		{name: "balanced brackets", input: "[a [b] c](https://example.com)", n: 30, label: "a [b] c", url: "https://example.com"},
 		{
-			name:     "it should escape special characters in a simple url",
-			url:      "https://example.com",
-			expected: "https://example\\.com",
-		},
-		{
-			name:     "it should escape special characters in a url with special characters",
-			url:      "https://example.com/path_to/file.txt",
-			expected: "https://example\\.com/path\\_to/file\\.txt",
+			name:  "balanced parentheses",
+			input: "[Go](https://en.wikipedia.org/wiki/Go_(programming_language)).",
+			n:     61,
+			label: "Go",
+			url:   "https://en.wikipedia.org/wiki/Go_(programming_language)",
 		},
+		{name: "multi-line label", input: "[multi\nline](https://example.com)", n: 33, label: "multi\nline", url: "https://example.com"},
+		{name: "no link", input: "[x] (y)"},
+		{name: "whitespace in the URL", input: "[x](not a url)"},
+		{name: "empty URL", input: "[x]()"},
+		{name: "unclosed URL", input: "[x](https://example.com"},
+		{name: "unclosed label", input: "[x(https://example.com)"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatPlainURL(tt.url)
-			assert.Equal(t, tt.expected, result)
+			n, label, url, image := parseLink(tt.input)
+			assert.Equal(t, tt.n, n)
+			assert.Equal(t, tt.label, label)
+			assert.Equal(t, tt.url, url)
+			assert.Equal(t, tt.image, image)
 		})
 	}
 }
 
-func TestExtractAndFormatImageURL(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "it should return the URL from valid image markdown",
-			input:    "![alt text](https://example.com/image.jpg)",
-			expected: "https://example.com/image.jpg",
-		},
-		{
-			name:     "it should return the entire string from invalid image markdown",
-			input:    "invalid markdown",
-			expected: "invalid markdown",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := extractAndFormatImageURL(tt.input)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
-func TestFormatMessageAsMarkdownV2(t *testing.T) {
+func TestMarkdownV2Markup_Body(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -113,12 +102,66 @@ func TestFormatMessageAsMarkdownV2(t *testing.T) {
 			input:    "Hello_World*Test",
 			expected: "Hello\\_World\\*Test",
 		},
+		{
+			name:     "it should escape all reserved characters",
+			input:    "_*[]()~`>#+-=|{}.!\\",
+			expected: "\\_\\*\\[\\]\\(\\)\\~\\`\\>\\#\\+\\-\\=\\|\\{\\}\\.\\!\\\\",
+		},
+		{
+			name:     "it should escape backslashes",
+			input:    `C:\Users\me`,
+			expected: `C:\\Users\\me`,
+		},
+		{
+			name:     "it should escape URLs next to punctuation",
+			input:    "(see https://example.com/a_b).",
+			expected: "\\(see https://example\\.com/a\\_b\\)\\.",
+		},
+		{
+			name:     "it should escape the labels of links",
+			input:    "[v1.2 (beta)!](https://example.com)",
+			expected: "[v1\\.2 \\(beta\\)\\!](https://example.com)",
+		},
+		{
+			name:     "it should escape parentheses in the URLs of links",
+			input:    "[Go](https://en.wikipedia.org/wiki/Go_(programming_language)).",
+			expected: "[Go](https://en.wikipedia.org/wiki/Go_(programming_language\\))\\.",
+		},
+		{
+			name:     "it should keep links spanning several lines",
+			input:    "[first\nsecond](https://example.com)",
+			expected: "[first\nsecond](https://example.com)",
+		},
+		{
+			name:     "it should keep links inside other markdown",
+			input:    "**bold [link](https://example.com)**",
+			expected: "\\*\\*bold [link](https://example.com)\\*\\*",
+		},
+		{
+			name:     "it should keep several links without spaces between them",
+			input:    "[a](https://a.com)[b](https://b.com)",
+			expected: "[a](https://a.com)[b](https://b.com)",
+		},
+		{
+			name:     "it should escape brackets that aren't links",
+			input:    "[x] (y) [z](not a url) [w](https://example.com",
+			expected: "\\[x\\] \\(y\\) \\[z\\]\\(not a url\\) \\[w\\]\\(https://example\\.com",
+		},
+		{
+			name:     "it should keep code",
+			input:    "Run `make test-all` in ```\n./a.b\n```",
+			expected: "Run `make test-all` in ```\n./a.b\n```",
+		},
+		{
+			name:     "it should preserve newlines and spacing",
+			input:    "a  b\n\n\tc.",
+			expected: "a  b\n\n\tc\\.",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatMessageAsMarkdownV2(tt.input)
-			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.expected, markdownV2Markup{}.body(tt.input))
 		})
 	}
 }
@@ -137,12 +180,37 @@ func TestGetPriorityIndicator(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := getPriorityIndicator(tt.priority)
+			result := getPriorityIndicator(tt.priority, labelsFor(""), nil)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
 
+func TestGetPriorityIndicator_CustomLevels(t *testing.T) {
+	levels := []config.PriorityLevel{
+		{MinPriority: 8, Emoji: "🚨", Label: "SEV1"},
+		{MinPriority: 2, Label: "SEV3"},
+		{MinPriority: 5, Emoji: "⚠️"},
+	}
+
+	tests := []struct {
+		name     string
+		priority int
+		expected string
+	}{
+		{"highest level", 10, "🚨 SEV1"},
+		{"level without emoji", 4, "SEV3"},
+		{"level without label", 5, "⚠️"},
+		{"below the lowest level", 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getPriorityIndicator(tt.priority, labelsFor("de"), levels))
+		})
+	}
+}
+
 func TestFormatExtras(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -162,7 +230,7 @@ func TestFormatExtras(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var builder strings.Builder
-			formatExtras(&builder, tt.extras, "")
+			formatExtras(&builder, markdownV2Markup{}, tt.extras, "")
 			assert.Equal(t, tt.expected, builder.String())
 		})
 	}
@@ -194,10 +262,204 @@ func TestFormatMessage_Integration(t *testing.T) {
 	assert.Contains(t, result, "Hello\\_World")
 	assert.Contains(t, result, "[link](https://example.com)")
 	assert.Contains(t, result, "🔴 Critical Priority")
-	assert.Contains(t, result, "key: `value`")
+	assert.Contains(t, result, "```\nkey: value\n```")
 	assert.Contains(t, result, "timestamp:")
 }
 
+func TestFormatMessage_Spoiler(t *testing.T) {
+	msg := api.Message{Title: "Login", Message: "token=abc.123"}
+
+	tests := []struct {
+		parseMode string
+		expected  string
+	}{
+		{parseMode: ParseModeMarkdownV2, expected: "*Login*\n\n||token\\=abc\\.123||\n\n"},
+		{parseMode: ParseModeHTML, expected: "<b>Login</b>\n\n<tg-spoiler>token=abc.123</tg-spoiler>\n\n"},
+		{parseMode: ParseModeEntities, expected: markEntity("bold", "Login") + "\n\n" + markEntity("spoiler", "token=abc.123") + "\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.parseMode, func(t *testing.T) {
+			result, err := FormatMessage(msg, config.MessageFormatOptions{ParseMode: tt.parseMode, Spoiler: true})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	result, err := FormatMessage(api.Message{Title: "Login"}, config.MessageFormatOptions{ParseMode: ParseModeHTML, Spoiler: true})
+	require.NoError(t, err)
+	assert.Equal(t, "<b>Login</b>\n\n\n\n", result)
+}
+
+func TestFormatMessage_Compact(t *testing.T) {
+	msg := api.Message{
+		AppName:  "Backup",
+		Title:    "Nightly backup failed",
+		Message:  "disk full",
+		Priority: 8,
+		Extras:   map[string]interface{}{"host": "nas-01"},
+	}
+
+	tests := []struct {
+		name     string
+		msg      api.Message
+		opts     config.MessageFormatOptions
+		expected string
+	}{
+		{
+			name:     "app name and title",
+			msg:      msg,
+			opts:     config.MessageFormatOptions{IncludeExtras: true, IncludeTimestamp: true},
+			expected: "\\[Backup\\] Nightly backup failed",
+		},
+		{
+			name:     "priority emoji",
+			msg:      msg,
+			opts:     config.MessageFormatOptions{IncludePriority: true},
+			expected: "🔴 \\[Backup\\] Nightly backup failed",
+		},
+		{
+			name:     "first line of the body without a title",
+			msg:      api.Message{AppName: "Backup", Message: "disk full\nretrying"},
+			expected: "\\[Backup\\] disk full",
+		},
+		{
+			name:     "title without an app name",
+			msg:      api.Message{Title: "Nightly backup failed"},
+			expected: "Nightly backup failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.ParseMode = ParseModeMarkdownV2
+			opts.Compact = true
+			result, err := FormatMessage(tt.msg, opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestTitleLine(t *testing.T) {
+	msg := api.Message{AppName: "Watchtower", Title: "Image updated"}
+
+	tests := []struct {
+		name     string
+		opts     config.MessageFormatOptions
+		expected string
+	}{
+		{name: "title", expected: "Image updated"},
+		{
+			name:     "prefix and app name",
+			opts:     config.MessageFormatOptions{IncludeAppName: true, TitlePrefix: "🐳 docker"},
+			expected: "🐳 docker [Watchtower] Image updated",
+		},
+		{
+			name:     "tags",
+			opts:     config.MessageFormatOptions{Tags: []string{"docker", "#prod", "home lab", " "}},
+			expected: "Image updated #docker #prod #home_lab",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, titleLine(msg, tt.opts))
+		})
+	}
+
+	caption, err := FormatCaption(msg, config.MessageFormatOptions{ParseMode: ParseModeMarkdownV2, Tags: []string{"docker"}})
+	require.NoError(t, err)
+	assert.Equal(t, "*Image updated \\#docker*", caption)
+}
+
+func TestFormatMessage_LargeExtras(t *testing.T) {
+	extras := map[string]interface{}{"host": "nas-01", "load": float64(2)}
+
+	tests := []struct {
+		name     string
+		style    string
+		size     int
+		expected string
+	}{
+		{
+			name:     "inline",
+			size:     10,
+			expected: "*Additional Info:*\n```\nhost: nas-01\nload: 2\n```\n\n",
+		},
+		{
+			name:     "json",
+			style:    config.LargeExtrasJSON,
+			size:     10,
+			expected: "*Additional Info:*\n```\n{\n  \"host\": \"nas-01\",\n  \"load\": 2\n}\n```\n\n",
+		},
+		{
+			name:     "json below the size",
+			style:    config.LargeExtrasJSON,
+			expected: "*Additional Info:*\n```\nhost: nas-01\nload: 2\n```\n\n",
+		},
+		{
+			name:  "file leaves out the extras",
+			style: config.LargeExtrasFile,
+			size:  10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatMessage(api.Message{Extras: extras}, config.MessageFormatOptions{
+				ParseMode:       ParseModeMarkdownV2,
+				IncludeExtras:   true,
+				LargeExtras:     tt.style,
+				LargeExtrasSize: tt.size,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "\n\n"+tt.expected, result)
+		})
+	}
+}
+
+func TestFormatMessage_Timestamp(t *testing.T) {
+	opts := config.MessageFormatOptions{ParseMode: ParseModeHTML, IncludeTimestamp: true}
+
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	result, err := FormatMessage(api.Message{Message: "Backup finished", Date: date}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "Backup finished\n\ntimestamp: 2024-01-02T03:04:05+01:00\n", result)
+
+	before := time.Now().Truncate(time.Second)
+	result, err = FormatMessage(api.Message{Message: "Backup finished"}, opts)
+	require.NoError(t, err)
+	stamp, err := time.Parse(time.RFC3339, strings.TrimSpace(strings.TrimPrefix(result, "Backup finished\n\ntimestamp: ")))
+	require.NoError(t, err)
+	assert.False(t, stamp.Before(before))
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		opts     config.MessageFormatOptions
+		expected string
+	}{
+		{name: "defaults", expected: "2024-01-02T03:04:05Z"},
+		{name: "timezone", opts: config.MessageFormatOptions{Timezone: "Europe/Berlin"}, expected: "2024-01-02T04:04:05+01:00"},
+		{
+			name:     "timezone and format",
+			opts:     config.MessageFormatOptions{Timezone: "America/New_York", TimestampFormat: "Mon Jan 2 15:04 MST"},
+			expected: "Mon Jan 1 22:04 EST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatTimestamp(date, tt.opts))
+		})
+	}
+}
+
 func TestFormatMessage_InvalidParseMode(t *testing.T) {
 	msg := api.Message{
 		Title:   "Test",
@@ -212,3 +474,266 @@ func TestFormatMessage_InvalidParseMode(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "parse mode InvalidMode is not supported")
 }
+
+func TestFormatExtrasTable(t *testing.T) {
+	fields := []ExtraField{
+		{Key: "disk", Value: "/dev/sda1"},
+		{Key: "used_pct", Value: float64(93.5)},
+		{Key: "größe", Value: "1 TB"},
+	}
+
+	assert.Equal(t, "disk:     /dev/sda1\nused_pct: 93.5\ngröße:    1 TB", formatExtrasTable(fields))
+}
+
+func TestFormatMessage_ExtrasStyle(t *testing.T) {
+	flat := map[string]interface{}{"host": "nas-01", "load": float64(2)}
+	nested := map[string]interface{}{"host": "nas-01", "disk": map[string]interface{}{"used": "93%"}}
+
+	tests := []struct {
+		name     string
+		extras   map[string]interface{}
+		style    string
+		expected string
+	}{
+		{
+			name:     "auto renders flat extras as a table",
+			extras:   flat,
+			expected: "*Additional Info:*\n```\nhost: nas-01\nload: 2\n```\n\n",
+		},
+		{
+			name:     "auto renders nested extras as a list",
+			extras:   nested,
+			expected: "*Additional Info:*\n• disk:\n  • used: `93%`\n\n\n• host: `nas-01`\n\n",
+		},
+		{
+			name:     "list renders flat extras as a list",
+			extras:   flat,
+			style:    config.ExtrasStyleList,
+			expected: "*Additional Info:*\n• host: `nas-01`\n• load: `2`\n\n",
+		},
+		{
+			name:     "table flattens nested extras",
+			extras:   nested,
+			style:    config.ExtrasStyleTable,
+			expected: "*Additional Info:*\n```\ndisk.used: 93%\nhost:      nas-01\n```\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatMessage(api.Message{Extras: tt.extras}, config.MessageFormatOptions{
+				ParseMode:     "MarkdownV2",
+				IncludeExtras: true,
+				ExtrasStyle:   tt.style,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "\n\n"+tt.expected, result)
+		})
+	}
+}
+
+func TestLimitExtras(t *testing.T) {
+	extras := map[string]interface{}{
+		"host": "nas-01",
+		"log":  strings.Repeat("x", 20),
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"c": "deep",
+			},
+			"count": float64(3),
+		},
+	}
+
+	tests := []struct {
+		name      string
+		maxDepth  int
+		maxLength int
+		expected  map[string]interface{}
+	}{
+		{
+			name:     "no limits",
+			expected: extras,
+		},
+		{
+			name:     "depth limit elides nested objects",
+			maxDepth: 2,
+			expected: map[string]interface{}{
+				"host": "nas-01",
+				"log":  strings.Repeat("x", 20),
+				"a": map[string]interface{}{
+					"b":     "…",
+					"count": float64(3),
+				},
+			},
+		},
+		{
+			name:      "top level only and truncated values",
+			maxDepth:  1,
+			maxLength: 10,
+			expected: map[string]interface{}{
+				"host": "nas-01",
+				"log":  strings.Repeat("x", 9) + "…",
+				"a":    "…",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, limitExtras(extras, tt.maxDepth, tt.maxLength, 1))
+		})
+	}
+}
+
+func TestFilterExtras(t *testing.T) {
+	extras := map[string]interface{}{
+		"host":            "nas-01",
+		"client::display": map[string]interface{}{"contentType": "text/markdown"},
+		"job": map[string]interface{}{
+			"name":     "backup",
+			"internal": map[string]interface{}{"id": "42"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "no patterns",
+			expected: extras,
+		},
+		{
+			name:    "excludes keys",
+			exclude: []string{"client::*"},
+			expected: map[string]interface{}{
+				"host": "nas-01",
+				"job":  extras["job"],
+			},
+		},
+		{
+			name:    "includes nested keys",
+			include: []string{"host", "job.name"},
+			expected: map[string]interface{}{
+				"host": "nas-01",
+				"job":  map[string]interface{}{"name": "backup"},
+			},
+		},
+		{
+			name:    "excludes nested keys of included objects",
+			include: []string{"job"},
+			exclude: []string{"job.internal"},
+			expected: map[string]interface{}{
+				"job": map[string]interface{}{"name": "backup"},
+			},
+		},
+		{
+			name:     "drops objects without included keys",
+			include:  []string{"*.missing"},
+			expected: map[string]interface{}{},
+		},
+		{
+			name:    "stars match dots",
+			include: []string{"*.id"},
+			expected: map[string]interface{}{
+				"job": map[string]interface{}{"internal": map[string]interface{}{"id": "42"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, filterExtras(extras, tt.include, tt.exclude, ""))
+		})
+	}
+}
+
+func TestFormatMessage_Snippets(t *testing.T) {
+	msg := api.Message{AppName: "backup.sh", Title: "Backup finished", Message: "All good"}
+
+	tests := []struct {
+		name     string
+		opts     config.MessageFormatOptions
+		expected string
+		wantErr  string
+	}{
+		{
+			name:     "header and footer around the default layout",
+			opts:     config.MessageFormatOptions{ParseMode: ParseModeMarkdownV2, Header: "\\[prod\\-gotify\\]", Footer: "via {{ .AppName }}"},
+			expected: "\\[prod\\-gotify\\]\n\n*Backup finished*\n\nAll good\n\nvia backup\\.sh",
+		},
+		{
+			name:     "footer below a template",
+			opts:     config.MessageFormatOptions{ParseMode: ParseModeHTML, Template: "{{ bold .Title }}\n", Footer: `<a href="https://example.com">Dashboard</a>`},
+			expected: "<b>Backup finished</b>\n\n<a href=\"https://example.com\">Dashboard</a>",
+		},
+		{
+			name:    "invalid header",
+			opts:    config.MessageFormatOptions{ParseMode: ParseModeHTML, Header: "{{ .Subject }}"},
+			wantErr: "header: failed to execute template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatMessage(msg, tt.opts)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestFormatMessage_NestedExtrasDepth(t *testing.T) {
+	extras := map[string]interface{}{
+		"host": "nas-01",
+		"smart": map[string]interface{}{
+			"disk": map[string]interface{}{
+				"sda": map[string]interface{}{"temperature": float64(41)},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		style    string
+		maxDepth int
+		expected string
+	}{
+		{
+			name:     "flattened into dotted keys",
+			style:    config.ExtrasStyleTable,
+			expected: "*Additional Info:*\n```\nhost:                       nas-01\nsmart.disk.sda.temperature: 41\n```\n\n",
+		},
+		{
+			name:     "flattened up to the depth limit",
+			style:    config.ExtrasStyleTable,
+			maxDepth: 2,
+			expected: "*Additional Info:*\n```\nhost:       nas-01\nsmart.disk: …\n```\n\n",
+		},
+		{
+			name:     "list up to the depth limit",
+			style:    config.ExtrasStyleList,
+			maxDepth: 1,
+			expected: "*Additional Info:*\n• host: `nas-01`\n• smart: `…`\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatMessage(api.Message{Extras: extras}, config.MessageFormatOptions{
+				ParseMode:      "MarkdownV2",
+				IncludeExtras:  true,
+				ExtrasStyle:    tt.style,
+				MaxExtrasDepth: tt.maxDepth,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "\n\n"+tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// update regenerates the golden files: go test ./internal/telegram -run TestFormatMessage_Golden -update
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenParseModes are the parse modes every fixture is rendered with
+var goldenParseModes = []string{ParseModeMarkdown, ParseModeMarkdownV2, ParseModeHTML, ParseModeNone, ParseModeEntities}
+
+// formatFixture is a gotify message and the format options it is rendered with
+type formatFixture struct {
+	Message       api.Message                 `yaml:"message"`
+	FormatOptions config.MessageFormatOptions `yaml:"format_options"`
+}
+
+// TestFormatMessage_Golden renders every fixture in testdata/format with each parse mode
+// and compares the Telegram payload with the golden file <fixture>.<parse mode>.golden.json
+func TestFormatMessage_Golden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "format", "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+
+	for _, fixturePath := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixturePath), ".yaml")
+
+		data, err := os.ReadFile(fixturePath)
+		require.NoError(t, err)
+
+		var fixture formatFixture
+		require.NoError(t, yaml.Unmarshal(data, &fixture), fixturePath)
+		require.False(t, fixture.FormatOptions.IncludeTimestamp, "%s: timestamps are not reproducible", fixturePath)
+
+		for _, parseMode := range goldenParseModes {
+			t.Run(name+"/"+parseMode, func(t *testing.T) {
+				opts := fixture.FormatOptions
+				opts.ParseMode = parseMode
+
+				text, err := FormatMessage(fixture.Message, opts)
+				require.NoError(t, err)
+
+				// HTML escaping is disabled to keep the golden files readable
+				var buf bytes.Buffer
+				encoder := json.NewEncoder(&buf)
+				encoder.SetEscapeHTML(false)
+				encoder.SetIndent("", "  ")
+				text, entities := messageText(text, parseMode)
+				require.NoError(t, encoder.Encode(Payload{
+					ChatID:    "123456",
+					Text:      text,
+					ParseMode: apiParseMode(parseMode),
+					Entities:  entities,
+				}))
+				payload := buf.Bytes()
+
+				goldenPath := filepath.Join("testdata", "format", name+"."+parseMode+".golden.json")
+				if *update {
+					require.NoError(t, os.WriteFile(goldenPath, payload, 0o644))
+				}
+
+				expected, err := os.ReadFile(goldenPath)
+				require.NoError(t, err, "golden file is missing. Run the test with -update to create it")
+				assert.Equal(t, string(expected), string(payload))
+			})
+		}
+	}
+}
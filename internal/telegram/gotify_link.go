@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// InlineKeyboardMarkup is an inline keyboard shown below a message
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// InlineKeyboardButton is a button of an inline keyboard opening a URL
+type InlineKeyboardButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// addButton adds a row with a button opening the URL to the keyboard. It creates the keyboard if it is nil
+func addButton(keyboard *InlineKeyboardMarkup, text, url string) *InlineKeyboardMarkup {
+	if keyboard == nil {
+		keyboard = &InlineKeyboardMarkup{}
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []InlineKeyboardButton{{Text: text, URL: url}})
+	return keyboard
+}
+
+// buttonURL renders the URL template of a configured button. It is empty if the template fails,
+// e.g. because the extras it uses are missing, or doesn't render a http(s) URL
+func buttonURL(button config.Button, msg api.Message) string {
+	link, err := renderPlainTemplate("button", button.URL, msg)
+	if err != nil || (!strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://")) {
+		return ""
+	}
+	return link
+}
+
+// replyMarkupField encodes the keyboard for a multipart form field. It is empty without a keyboard
+func replyMarkupField(keyboard *InlineKeyboardMarkup) string {
+	if keyboard == nil {
+		return ""
+	}
+	data, _ := json.Marshal(keyboard)
+	return string(data)
+}
+
+// clickURL returns the http(s) URL set in gotify's client::notification click extra
+func clickURL(msg api.Message) string {
+	notification, ok := msg.Extras["client::notification"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	click, ok := notification["click"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	link, _ := click["url"].(string)
+	if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+		return ""
+	}
+	return link
+}
+
+// defaultAppImage is the image gotify shows for apps without an image
+const defaultAppImage = "static/defaultapp.png"
+
+// webUIBase returns the web UI URL without a query, fragment and trailing slash. It is empty
+// without a http(s) URL
+func webUIBase(webURL *url.URL) string {
+	if webURL == nil || (webURL.Scheme != "http" && webURL.Scheme != "https") || webURL.Host == "" {
+		return ""
+	}
+
+	base := *webURL
+	base.RawQuery = ""
+	base.Fragment = ""
+	return strings.TrimRight(base.String(), "/")
+}
+
+// gotifyLink returns the link to the messages of the app of the message in the Gotify web UI.
+// Messages without an app link to all messages. It is empty without a http(s) web UI URL
+func gotifyLink(webURL *url.URL, msg api.Message) string {
+	base := webUIBase(webURL)
+	if base == "" {
+		return ""
+	}
+
+	link := base + "/#/"
+	if msg.AppID != 0 {
+		link += "messages/" + strconv.FormatUint(uint64(msg.AppID), 10)
+	}
+
+	return link
+}
+
+// appImagePath returns the path of the image of the app of the message on the Gotify server. It is
+// empty for apps using gotify's default image
+func appImagePath(msg api.Message) string {
+	image := strings.TrimLeft(msg.AppImage, "/")
+	if image == defaultAppImage {
+		return ""
+	}
+
+	return image
+}
+
+// truncateBody returns the message with its body truncated to the max length and whether it was truncated
+func truncateBody(msg api.Message, maxLength int) (api.Message, bool) {
+	if maxLength <= 0 || graphemeCount(msg.Message) <= maxLength {
+		return msg, false
+	}
+
+	msg.Message = truncate(maxLength, msg.Message)
+	return msg, true
+}
+
+// appendLink appends a link line to the formatted message
+func appendLink(text, label, link, parseMode string) (string, error) {
+	m, err := markupFor(parseMode)
+	if err != nil {
+		return "", err
+	}
+
+	line := m.link(label, link)
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return line, nil
+	}
+	return text + "\n\n" + line, nil
+}
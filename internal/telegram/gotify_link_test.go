@@ -0,0 +1,232 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+func TestGotifyLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		webURL   string
+		appID    uint32
+		expected string
+	}{
+		{name: "app messages", webURL: "https://gotify.example.com", appID: 3, expected: "https://gotify.example.com/#/messages/3"},
+		{name: "sub-path", webURL: "https://example.com/gotify/", appID: 3, expected: "https://example.com/gotify/#/messages/3"},
+		{name: "all messages without an app", webURL: "http://localhost:80", expected: "http://localhost:80/#/"},
+		{name: "websocket url", webURL: "ws://localhost", appID: 3},
+		{name: "no url", appID: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var webURL *url.URL
+			if tt.webURL != "" {
+				var err error
+				webURL, err = url.Parse(tt.webURL)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, gotifyLink(webURL, api.Message{AppID: tt.appID}))
+		})
+	}
+}
+
+func TestClickURL(t *testing.T) {
+	click := func(click interface{}) api.Message {
+		return api.Message{Extras: map[string]interface{}{
+			"client::notification": map[string]interface{}{"click": click},
+		}}
+	}
+
+	assert.Equal(t, "https://grafana.example.com/d/1", clickURL(click(map[string]interface{}{"url": "https://grafana.example.com/d/1"})))
+	assert.Equal(t, "", clickURL(click(map[string]interface{}{"url": "javascript:alert(1)"})))
+	assert.Equal(t, "", clickURL(click("https://grafana.example.com/d/1")))
+	assert.Equal(t, "", clickURL(api.Message{}))
+}
+
+func TestButtonURL(t *testing.T) {
+	msg := api.Message{AppID: 3, Extras: map[string]interface{}{"dashboard": "cpu load"}}
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{name: "static", url: "https://grafana.example.com", expected: "https://grafana.example.com"},
+		{
+			name:     "extras",
+			url:      "https://grafana.example.com/d/{{ .Extras.dashboard | urlquery }}?app={{ .AppID }}",
+			expected: "https://grafana.example.com/d/cpu+load?app=3",
+		},
+		{name: "missing extras", url: "https://grafana.example.com/d/{{ .Extras.missing }}"},
+		{name: "not a http url", url: "{{ .Extras.dashboard }}"},
+		{name: "broken template", url: "https://grafana.example.com/{{ .Missing }}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, buttonURL(config.Button{Text: "Dashboard", URL: tt.url}, msg))
+		})
+	}
+}
+
+func TestAppImagePath(t *testing.T) {
+	assert.Equal(t, "image/abc.png", appImagePath(api.Message{AppImage: "image/abc.png"}))
+	assert.Equal(t, "image/abc.png", appImagePath(api.Message{AppImage: "/image/abc.png"}))
+	assert.Equal(t, "", appImagePath(api.Message{AppImage: defaultAppImage}))
+	assert.Equal(t, "", appImagePath(api.Message{}))
+}
+
+func TestTruncateBody(t *testing.T) {
+	msg := api.Message{Title: "Backup", Message: "finished 🔥"}
+
+	truncated, ok := truncateBody(msg, 0)
+	assert.False(t, ok)
+	assert.Equal(t, msg, truncated)
+
+	truncated, ok = truncateBody(msg, 10)
+	assert.False(t, ok)
+	assert.Equal(t, msg, truncated)
+
+	truncated, ok = truncateBody(msg, 5)
+	assert.True(t, ok)
+	assert.Equal(t, api.Message{Title: "Backup", Message: "fini…"}, truncated)
+}
+
+func TestClientStruct_Send_GotifyLink(t *testing.T) {
+	webURL, err := url.Parse("https://gotify.example.com")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		style     string
+		maxLength int
+		expected  map[string]interface{}
+	}{
+		{
+			name:  "no link",
+			style: "",
+			expected: map[string]interface{}{
+				"chat_id":    "123456",
+				"text":       "<b>Backup</b>\n\nfinished\n\n",
+				"parse_mode": "HTML",
+			},
+		},
+		{
+			name:  "link below the message",
+			style: config.GotifyLinkText,
+			expected: map[string]interface{}{
+				"chat_id":    "123456",
+				"text":       "<b>Backup</b>\n\nfinished\n\n<a href=\"https://gotify.example.com/#/messages/3\">Open in Gotify</a>",
+				"parse_mode": "HTML",
+			},
+		},
+		{
+			name:  "inline button",
+			style: config.GotifyLinkButton,
+			expected: map[string]interface{}{
+				"chat_id":    "123456",
+				"text":       "<b>Backup</b>\n\nfinished\n\n",
+				"parse_mode": "HTML",
+				"reply_markup": map[string]interface{}{
+					"inline_keyboard": []interface{}{[]interface{}{
+						map[string]interface{}{"text": "Open in Gotify", "url": "https://gotify.example.com/#/messages/3"},
+					}},
+				},
+			},
+		},
+		{
+			name:      "truncated body links to the full message",
+			maxLength: 5,
+			expected: map[string]interface{}{
+				"chat_id":    "123456",
+				"text":       "<b>Backup</b>\n\nfini…\n\n<a href=\"https://gotify.example.com/#/messages/3\">Show full message</a>",
+				"parse_mode": "HTML",
+			},
+		},
+		{
+			name:      "truncated body with a button",
+			style:     config.GotifyLinkButton,
+			maxLength: 5,
+			expected: map[string]interface{}{
+				"chat_id":    "123456",
+				"text":       "<b>Backup</b>\n\nfini…\n\n",
+				"parse_mode": "HTML",
+				"reply_markup": map[string]interface{}{
+					"inline_keyboard": []interface{}{[]interface{}{
+						map[string]interface{}{"text": "Show full message", "url": "https://gotify.example.com/#/messages/3"},
+					}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload map[string]interface{}
+			client := NewClient(Config{ErrChan: make(chan error, 1), GotifyURL: webURL})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+					}, nil
+				},
+			}
+
+			client.Send(api.Message{AppID: 3, Title: "Backup", Message: "finished"}, "valid-token", "123456",
+				config.MessageFormatOptions{ParseMode: ParseModeHTML, GotifyLink: tt.style, MaxLength: tt.maxLength})
+			assert.Equal(t, tt.expected, payload)
+		})
+	}
+}
+
+func TestClientStruct_Send_Buttons(t *testing.T) {
+	webURL, err := url.Parse("https://gotify.example.com")
+	require.NoError(t, err)
+
+	var payload map[string]interface{}
+	client := NewClient(Config{ErrChan: make(chan error, 1), GotifyURL: webURL})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+			}, nil
+		},
+	}
+
+	msg := api.Message{AppID: 3, Title: "CPU", Message: "high load", Extras: map[string]interface{}{
+		"client::notification": map[string]interface{}{
+			"click": map[string]interface{}{"url": "https://grafana.example.com/d/1"},
+		},
+	}}
+	client.Send(msg, "valid-token", "123456", config.MessageFormatOptions{
+		ParseMode:  ParseModeHTML,
+		GotifyLink: config.GotifyLinkButton,
+		Buttons: []config.Button{
+			{Text: "Runbook", URL: "https://wiki.example.com/{{ .Title | lower }}"},
+			{Text: "Logs", URL: "https://logs.example.com/{{ .Extras.host }}"},
+		},
+	})
+	assert.Equal(t, map[string]interface{}{
+		"inline_keyboard": []interface{}{
+			[]interface{}{map[string]interface{}{"text": "Open", "url": "https://grafana.example.com/d/1"}},
+			[]interface{}{map[string]interface{}{"text": "Runbook", "url": "https://wiki.example.com/cpu"}},
+			[]interface{}{map[string]interface{}{"text": "Open in Gotify", "url": "https://gotify.example.com/#/messages/3"}},
+		},
+	}, payload["reply_markup"])
+}
@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const zeroWidthJoiner = '\u200d'
+
+// graphemeLength returns the length in bytes of the first user-perceived character of s. Combining
+// marks, variation selectors, skin tone modifiers, tags and characters joined by a zero width joiner
+// stay with the preceding character, and regional indicators are kept in pairs so flags are not split
+func graphemeLength(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	r, i := utf8.DecodeRuneInString(s)
+	if r == '\r' && len(s) > 1 && s[1] == '\n' {
+		return 2
+	}
+	if isRegionalIndicator(r) {
+		if next, size := utf8.DecodeRuneInString(s[i:]); isRegionalIndicator(next) {
+			i += size
+		}
+	}
+
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == zeroWidthJoiner:
+			i += size
+			if i < len(s) {
+				_, size = utf8.DecodeRuneInString(s[i:])
+				i += size
+			}
+		case isGraphemeExtend(r):
+			i += size
+		default:
+			return i
+		}
+	}
+
+	return i
+}
+
+// graphemes splits s into user-perceived characters
+func graphemes(s string) []string {
+	var clusters []string
+	for s != "" {
+		n := graphemeLength(s)
+		clusters = append(clusters, s[:n])
+		s = s[n:]
+	}
+	return clusters
+}
+
+// graphemeCount returns the number of user-perceived characters of s
+func graphemeCount(s string) int {
+	n := 0
+	for s != "" {
+		s = s[graphemeLength(s):]
+		n++
+	}
+	return n
+}
+
+// isGraphemeExtend returns true for characters that extend the preceding character
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		(r >= 0xfe00 && r <= 0xfe0f) || // variation selectors
+		(r >= 0x1f3fb && r <= 0x1f3ff) || // skin tone modifiers
+		(r >= 0xe0020 && r <= 0xe007f) // tags of subdivision flags
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
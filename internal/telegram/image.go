@@ -0,0 +1,126 @@
+package telegram
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	// decoders of the image formats that are downscaled
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	// maxImageDownloadSize is the largest image that is downloaded to be downscaled
+	maxImageDownloadSize = 20 << 20
+	// maxImagePixels is the largest image that is decoded. Larger images are sent by URL
+	maxImagePixels = 50_000_000
+)
+
+// ImagePolicy configures how photos are downscaled before they are uploaded
+type ImagePolicy struct {
+	// MaxDimension is the maximum width and height of photos in pixels. 0 disables downscaling
+	// and lets Telegram fetch the photos itself
+	MaxDimension int
+	// JPEGQuality is the quality photos are encoded with (1-100)
+	JPEGQuality int
+	// AllowedHosts are the hosts photos are downloaded from. Photos of other hosts are sent by URL
+	AllowedHosts []string
+	// PrivateHosts are the allowed hosts that may resolve to loopback, private or link-local addresses
+	PrivateHosts []string
+}
+
+// scope returns the hosts photos and their redirects may be downloaded from
+func (p ImagePolicy) scope() *downloadScope {
+	return &downloadScope{allowedHosts: p.AllowedHosts, privateHosts: p.PrivateHosts}
+}
+
+// downscaleImage shrinks the image to fit into maxDimension and encodes it as JPEG. Transparent
+// pixels become white. JPEG images that fit already are kept if re-encoding doesn't make them smaller
+func downscaleImage(data []byte, maxDimension, quality int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large to downscale", cfg.Width, cfg.Height)
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := fitDimensions(bounds.Dx(), bounds.Dy(), maxDimension)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Over)
+
+	dst := rgba
+	resized := width != bounds.Dx() || height != bounds.Dy()
+	if resized {
+		dst = resizeArea(rgba, width, height)
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	if format == "jpeg" && !resized && encoded.Len() >= len(data) {
+		return data, nil
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// fitDimensions returns the dimensions of an image scaled down to fit into maxDimension keeping its aspect ratio
+func fitDimensions(width, height, maxDimension int) (int, int) {
+	if width <= maxDimension && height <= maxDimension {
+		return width, height
+	}
+
+	if width >= height {
+		return maxDimension, max(1, height*maxDimension/width)
+	}
+	return max(1, width*maxDimension/height), maxDimension
+}
+
+// resizeArea shrinks the image by averaging the source pixels covered by each destination pixel
+func resizeArea(src *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
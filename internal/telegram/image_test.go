@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeTestPNG returns a half transparent PNG of the size
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.NRGBA{R: 255, A: 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestFitDimensions(t *testing.T) {
+	tests := []struct {
+		width, height, maxDimension   int
+		expectedWidth, expectedHeight int
+	}{
+		{width: 800, height: 600, maxDimension: 1280, expectedWidth: 800, expectedHeight: 600},
+		{width: 4000, height: 3000, maxDimension: 1280, expectedWidth: 1280, expectedHeight: 960},
+		{width: 1080, height: 1920, maxDimension: 1280, expectedWidth: 720, expectedHeight: 1280},
+		{width: 5000, height: 2, maxDimension: 100, expectedWidth: 100, expectedHeight: 1},
+	}
+
+	for _, tt := range tests {
+		width, height := fitDimensions(tt.width, tt.height, tt.maxDimension)
+		assert.Equal(t, tt.expectedWidth, width)
+		assert.Equal(t, tt.expectedHeight, height)
+	}
+}
+
+func TestDownscaleImage(t *testing.T) {
+	data, err := downscaleImage(encodeTestPNG(t, 400, 200), 100, 80)
+	require.NoError(t, err)
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), img.Bounds())
+
+	// opaque pixels keep their color and transparent pixels become white
+	r, g, b, _ := img.At(10, 25).RGBA()
+	assert.Greater(t, r>>8, uint32(230))
+	assert.Less(t, g>>8, uint32(30))
+	assert.Less(t, b>>8, uint32(30))
+	r, g, b, _ = img.At(90, 25).RGBA()
+	assert.Greater(t, r>>8, uint32(230))
+	assert.Greater(t, g>>8, uint32(230))
+	assert.Greater(t, b>>8, uint32(230))
+
+	// small JPEG images are kept if re-encoding doesn't make them smaller
+	var small bytes.Buffer
+	require.NoError(t, jpeg.Encode(&small, image.NewRGBA(image.Rect(0, 0, 10, 10)), &jpeg.Options{Quality: 10}))
+	data, err = downscaleImage(small.Bytes(), 100, 100)
+	require.NoError(t, err)
+	assert.Equal(t, small.Bytes(), data)
+
+	_, err = downscaleImage([]byte("not an image"), 100, 80)
+	assert.ErrorContains(t, err, "failed to decode image")
+}
+
+func TestClientStruct_Send_DownscaledPhoto(t *testing.T) {
+	msg := api.Message{
+		Title: "Motion detected",
+		Extras: map[string]interface{}{
+			"client::notification": map[string]interface{}{"bigImageUrl": "http://camera.local/snapshot.png"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		image         []byte
+		expectedPhoto string
+	}{
+		{name: "uploads the downscaled photo", image: encodeTestPNG(t, 400, 200), expectedPhoto: "photo.jpg"},
+		{name: "sends the URL if the photo can't be decoded", image: []byte("not an image"), expectedPhoto: "http://camera.local/snapshot.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var photo string
+			var bounds image.Rectangle
+			client := NewClient(Config{ErrChan: make(chan error, 1), Images: ImagePolicy{MaxDimension: 100, JPEGQuality: 80, AllowedHosts: []string{"camera.local"}}})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					if req.URL.Host == "camera.local" {
+						return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(tt.image))}, nil
+					}
+
+					require.True(t, strings.HasSuffix(req.URL.Path, "/sendPhoto"))
+					mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+					require.NoError(t, err)
+					if mediaType == "multipart/form-data" {
+						form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(1 << 20)
+						require.NoError(t, err)
+						photo = form.File["photo"][0].Filename
+						file, err := form.File["photo"][0].Open()
+						require.NoError(t, err)
+						cfg, _, err := image.DecodeConfig(file)
+						require.NoError(t, err)
+						bounds = image.Rect(0, 0, cfg.Width, cfg.Height)
+					} else {
+						var payload PhotoPayload
+						require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+						photo = payload.Photo
+					}
+
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":1}}`)),
+					}, nil
+				},
+			}
+
+			client.Send(msg, "token", "123", config.MessageFormatOptions{ParseMode: ParseModeHTML})
+
+			assert.Equal(t, tt.expectedPhoto, photo)
+			if tt.expectedPhoto == "photo.jpg" {
+				assert.Equal(t, image.Rect(0, 0, 100, 50), bounds)
+			}
+		})
+	}
+}
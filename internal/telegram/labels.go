@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// labels are the texts generated by the formatter in a single language
+type labels struct {
+	AdditionalInfo   string
+	Timestamp        string
+	CriticalPriority string
+	HighPriority     string
+	MediumPriority   string
+	LowPriority      string
+	Open             string
+	OpenInGotify     string
+	ShowFullMessage  string
+}
+
+// labelsByLanguage holds the labels of all supported languages
+var labelsByLanguage = map[string]labels{
+	config.LanguageEnglish: {
+		AdditionalInfo:   "Additional Info",
+		Timestamp:        "timestamp",
+		CriticalPriority: "Critical Priority",
+		HighPriority:     "High Priority",
+		MediumPriority:   "Medium Priority",
+		LowPriority:      "Low Priority",
+		Open:             "Open",
+		OpenInGotify:     "Open in Gotify",
+		ShowFullMessage:  "Show full message",
+	},
+	config.LanguageGerman: {
+		AdditionalInfo:   "Weitere Informationen",
+		Timestamp:        "Zeitstempel",
+		CriticalPriority: "Kritische Priorität",
+		HighPriority:     "Hohe Priorität",
+		MediumPriority:   "Mittlere Priorität",
+		LowPriority:      "Niedrige Priorität",
+		Open:             "Öffnen",
+		OpenInGotify:     "In Gotify öffnen",
+		ShowFullMessage:  "Ganze Nachricht anzeigen",
+	},
+	config.LanguageFrench: {
+		AdditionalInfo:   "Informations supplémentaires",
+		Timestamp:        "horodatage",
+		CriticalPriority: "Priorité critique",
+		HighPriority:     "Priorité haute",
+		MediumPriority:   "Priorité moyenne",
+		LowPriority:      "Priorité basse",
+		Open:             "Ouvrir",
+		OpenInGotify:     "Ouvrir dans Gotify",
+		ShowFullMessage:  "Afficher le message complet",
+	},
+	config.LanguageSpanish: {
+		AdditionalInfo:   "Información adicional",
+		Timestamp:        "marca de tiempo",
+		CriticalPriority: "Prioridad crítica",
+		HighPriority:     "Prioridad alta",
+		MediumPriority:   "Prioridad media",
+		LowPriority:      "Prioridad baja",
+		Open:             "Abrir",
+		OpenInGotify:     "Abrir en Gotify",
+		ShowFullMessage:  "Mostrar el mensaje completo",
+	},
+}
+
+// labelsFor returns the labels of the language. Unknown languages fall back to English
+func labelsFor(language string) labels {
+	if l, ok := labelsByLanguage[strings.ToLower(language)]; ok {
+		return l
+	}
+	return labelsByLanguage[config.LanguageEnglish]
+}
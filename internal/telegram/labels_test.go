@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelsFor(t *testing.T) {
+	for _, language := range config.SupportedLanguages {
+		l, ok := labelsByLanguage[language]
+		require.True(t, ok, "missing labels for %s", language)
+		assert.NotEmpty(t, l.AdditionalInfo)
+		assert.NotEmpty(t, l.Timestamp)
+		assert.NotEmpty(t, l.CriticalPriority)
+		assert.NotEmpty(t, l.LowPriority)
+	}
+
+	assert.Equal(t, labelsByLanguage[config.LanguageGerman], labelsFor("DE"))
+	assert.Equal(t, labelsByLanguage[config.LanguageEnglish], labelsFor(""))
+	assert.Equal(t, labelsByLanguage[config.LanguageEnglish], labelsFor("xx"))
+}
+
+func TestFormatMessage_Language(t *testing.T) {
+	msg := api.Message{
+		Message:  "Backup failed",
+		Priority: 8,
+		Extras:   map[string]interface{}{"host": "nas-01"},
+	}
+
+	result, err := FormatMessage(msg, config.MessageFormatOptions{
+		ParseMode:       "MarkdownV2",
+		IncludeExtras:   true,
+		IncludePriority: true,
+		Language:        config.LanguageGerman,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, result, "🔴 Kritische Priorität")
+	assert.Contains(t, result, "*Weitere Informationen:*")
+	assert.NotContains(t, result, "Additional Info")
+}
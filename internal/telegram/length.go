@@ -0,0 +1,17 @@
+package telegram
+
+import "unicode/utf16"
+
+// maxMessageLength is the maximum length of a message text accepted by Telegram
+const maxMessageLength = 4096
+
+// utf16Length returns the length of s in UTF-16 code units. Telegram measures text limits and
+// entity offsets in UTF-16 code units, so characters outside the basic multilingual plane like
+// most emojis count twice
+func utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
@@ -0,0 +1,65 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUTF16Length(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{name: "empty", text: "", expected: 0},
+		{name: "ascii", text: "hello", expected: 5},
+		{name: "latin accents", text: "héllo", expected: 5},
+		{name: "cjk", text: "日本語", expected: 3},
+		{name: "emoji outside the basic plane", text: "🔥", expected: 2},
+		{name: "emoji with variation selector", text: "⚠️", expected: 2},
+		{name: "mixed", text: "a🔥b", expected: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, utf16Length(tt.text))
+		})
+	}
+}
+
+func TestClientStruct_Send_LongMessage(t *testing.T) {
+	var texts []string
+	errChan := make(chan error, 1)
+	client := NewClient(Config{ErrChan: errChan})
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var payload Payload
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			texts = append(texts, payload.Text)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true}`)),
+			}, nil
+		},
+	}
+
+	// 3000 runes but 6000 UTF-16 code units
+	body := strings.Repeat("🔥", 3000)
+	client.Send(api.Message{Message: body}, "valid-token", "123456", config.MessageFormatOptions{ParseMode: "HTML"})
+
+	require.Empty(t, errChan)
+	require.Len(t, texts, 2)
+	assert.Contains(t, strings.Join(texts, ""), body)
+	for _, text := range texts {
+		assert.LessOrEqual(t, utf16Length(text), maxMessageLength)
+	}
+}
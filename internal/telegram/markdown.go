@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+)
+
+// contentTypeMarkdown is the client::display content type of messages gotify clients render as markdown
+const contentTypeMarkdown = "text/markdown"
+
+var (
+	// markdownHeadingRegex matches ATX headings
+	markdownHeadingRegex = regexp.MustCompile(`^#{1,6}\s+(.*?)(?:\s+#+)?\s*$`)
+	// markdownBulletRegex matches unordered list items
+	markdownBulletRegex = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	// markdownOrderedRegex matches ordered list items
+	markdownOrderedRegex = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	// markdownRuleRegex matches thematic breaks
+	markdownRuleRegex = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	// markdownInlineRegex matches escaped characters, code spans, links, bold, strikethrough and italic text
+	markdownInlineRegex = regexp.MustCompile(`(\\[!-/:-@\[-` + "`" + `{-~])|` +
+		"`([^`]+)`|" +
+		`(!?)\[([^\]]*)\]\(([^)\s]+)\)|` +
+		`\*\*([^*\s](?:[^*]*[^*\s])?)\*\*|__([^_\s](?:[^_]*[^_\s])?)__|` +
+		`~~([^~\s](?:[^~]*[^~\s])?)~~|` +
+		`\*([^*\s](?:[^*]*[^*\s])?)\*|_([^_\s](?:(?:[^_]|_[\pL\pN])*[^_\s])?)_`)
+)
+
+// isMarkdown reports whether gotify clients render the message as markdown
+func isMarkdown(msg api.Message) bool {
+	display, ok := msg.Extras["client::display"].(map[string]interface{})
+	return ok && display["contentType"] == contentTypeMarkdown
+}
+
+// renderMarkdown converts markdown to the markup. Headings, lists, code blocks, thematic breaks,
+// emphasis, code spans and links are converted. Other markdown is shown as text
+func renderMarkdown(text string, m markup) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	rendered := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if fence := codeFence(line); fence != "" {
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			rendered = append(rendered, m.pre(strings.Join(code, "\n")))
+			continue
+		}
+
+		switch {
+		case markdownRuleRegex.MatchString(line):
+			rendered = append(rendered, m.escape("──────────"))
+		case markdownHeadingRegex.MatchString(line):
+			rendered = append(rendered, m.bold(m.escape(markdownHeadingRegex.FindStringSubmatch(line)[1])))
+		case markdownBulletRegex.MatchString(line):
+			match := markdownBulletRegex.FindStringSubmatch(line)
+			rendered = append(rendered, match[1]+m.escape("• ")+renderInlineMarkdown(match[2], m))
+		case markdownOrderedRegex.MatchString(line):
+			match := markdownOrderedRegex.FindStringSubmatch(line)
+			rendered = append(rendered, match[1]+m.escape(match[2]+". ")+renderInlineMarkdown(match[3], m))
+		default:
+			rendered = append(rendered, renderInlineMarkdown(line, m))
+		}
+	}
+
+	return strings.Join(rendered, "\n")
+}
+
+// codeFence returns the fence of a line opening a fenced code block
+func codeFence(line string) string {
+	trimmed := strings.TrimSpace(line)
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, fence) {
+			return fence
+		}
+	}
+	return ""
+}
+
+// renderInlineMarkdown converts the inline markdown of a line to the markup. Emphasis can't be nested
+// as the legacy Markdown parse mode doesn't support nested entities
+func renderInlineMarkdown(text string, m markup) string {
+	var builder strings.Builder
+
+	for text != "" {
+		loc := markdownInlineRegex.FindStringSubmatchIndex(text)
+		if loc == nil {
+			builder.WriteString(m.escape(text))
+			break
+		}
+
+		group := func(n int) string {
+			if loc[2*n] < 0 {
+				return ""
+			}
+			return text[loc[2*n]:loc[2*n+1]]
+		}
+
+		// underscores within words don't emphasize, e.g. in snake_case
+		if text[loc[0]] == '_' && (endsWithAlphanumeric(text[:loc[0]]) || startsWithAlphanumeric(text[loc[1]:])) {
+			builder.WriteString(m.escape(text[:loc[0]+1]))
+			text = text[loc[0]+1:]
+			continue
+		}
+
+		builder.WriteString(m.escape(text[:loc[0]]))
+		switch {
+		case loc[2] >= 0:
+			builder.WriteString(m.escape(group(1)[1:]))
+		case loc[4] >= 0:
+			builder.WriteString(m.code(group(2)))
+		case loc[8] >= 0:
+			label, url := group(4), group(5)
+			if group(3) != "" || label == "" {
+				builder.WriteString(m.escape(url))
+			} else {
+				builder.WriteString(m.link(label, url))
+			}
+		case loc[12] >= 0 || loc[14] >= 0:
+			builder.WriteString(m.bold(m.escape(group(6) + group(7))))
+		case loc[16] >= 0:
+			builder.WriteString(m.strikethrough(m.escape(group(8))))
+		default:
+			builder.WriteString(m.italic(m.escape(group(9) + group(10))))
+		}
+		text = text[loc[1]:]
+	}
+
+	return builder.String()
+}
+
+// endsWithAlphanumeric reports whether the text ends with a letter or digit
+func endsWithAlphanumeric(text string) bool {
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// startsWithAlphanumeric reports whether the text starts with a letter or digit
+func startsWithAlphanumeric(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
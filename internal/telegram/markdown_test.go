@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMarkdown(t *testing.T) {
+	assert.True(t, isMarkdown(api.Message{Extras: map[string]interface{}{
+		"client::display": map[string]interface{}{"contentType": "text/markdown"},
+	}}))
+	assert.False(t, isMarkdown(api.Message{Extras: map[string]interface{}{
+		"client::display": map[string]interface{}{"contentType": "text/plain"},
+	}}))
+	assert.False(t, isMarkdown(api.Message{}))
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		markdown string
+		html     string
+	}{
+		{
+			name:     "emphasis",
+			input:    "**bold** __bold__ *italic* _italic_ ~~struck~~",
+			markdown: "*bold* *bold* _italic_ _italic_ ~struck~",
+			html:     "<b>bold</b> <b>bold</b> <i>italic</i> <i>italic</i> <s>struck</s>",
+		},
+		{
+			name:     "underscores within words",
+			input:    "snake_case_name and _snake_case_",
+			markdown: "snake\\_case\\_name and _snake\\_case_",
+			html:     "snake_case_name and <i>snake_case</i>",
+		},
+		{
+			name:     "stray markers are text",
+			input:    "2 * 3 * 4 = 24",
+			markdown: "2 \\* 3 \\* 4 \\= 24",
+			html:     "2 * 3 * 4 = 24",
+		},
+		{
+			name:     "escaped characters",
+			input:    `\*not italic\* \# \a`,
+			markdown: `\*not italic\* \# \\a`,
+			html:     `*not italic* # \a`,
+		},
+		{
+			name:     "code spans",
+			input:    "run `a_b < c`",
+			markdown: "run `a_b < c`",
+			html:     "run <code>a_b &lt; c</code>",
+		},
+		{
+			name:     "links and images",
+			input:    "[docs](https://example.com/a_b) ![](https://example.com/i.png)",
+			markdown: "[docs](https://example.com/a_b) https://example\\.com/i\\.png",
+			html:     `<a href="https://example.com/a_b">docs</a> https://example.com/i.png`,
+		},
+		{
+			name:     "headings",
+			input:    "## Status (ok) ##",
+			markdown: "*Status \\(ok\\)*",
+			html:     "<b>Status (ok)</b>",
+		},
+		{
+			name:     "lists",
+			input:    "- one\n  * two\n3. three",
+			markdown: "• one\n  • two\n3\\. three",
+			html:     "• one\n  • two\n3. three",
+		},
+		{
+			name:     "code blocks",
+			input:    "```sh\necho *hi* `x`\n```\n~~~\nunclosed",
+			markdown: "```\necho *hi* \\`x\\`\n```\n```\nunclosed\n```",
+			html:     "<pre>echo *hi* `x`</pre>\n<pre>unclosed</pre>",
+		},
+		{
+			name:     "thematic breaks",
+			input:    "a\n- - -\nb",
+			markdown: "a\n──────────\nb",
+			html:     "a\n──────────\nb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.markdown, renderMarkdown(tt.input, markdownV2Markup{}))
+			assert.Equal(t, tt.html, renderMarkdown(tt.input, htmlMarkup{}))
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Parse modes supported by the formatter
+const (
+	ParseModeMarkdown   = "Markdown"
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
+	// ParseModeNone sends text verbatim without a parse_mode
+	ParseModeNone = "none"
+	// ParseModeEntities sends plain text with explicit message entities instead of a parse_mode
+	ParseModeEntities = "entities"
+)
+
+// markup renders text with the syntax and escape rules of a Telegram parse mode
+type markup interface {
+	// escape escapes plain text
+	escape(text string) string
+	// bold renders already escaped text in bold
+	bold(text string) string
+	// italic renders already escaped text in italics
+	italic(text string) string
+	// strikethrough renders already escaped text struck through
+	strikethrough(text string) string
+	// spoiler hides formatted text until it's tapped. Parse modes without spoilers return the text unchanged
+	spoiler(text string) string
+	// code renders plain text as inline code
+	code(text string) string
+	// pre renders plain text as a monospace block
+	pre(text string) string
+	// link renders a link with a plain text label
+	link(label, url string) string
+	// body formats the body of a gotify message
+	body(text string) string
+}
+
+// markupFor returns the markup of the parse mode
+func markupFor(parseMode string) (markup, error) {
+	switch parseMode {
+	case ParseModeMarkdown:
+		return markdownMarkup{}, nil
+	case ParseModeMarkdownV2:
+		return markdownV2Markup{}, nil
+	case ParseModeHTML:
+		return htmlMarkup{}, nil
+	case ParseModeNone:
+		return plainMarkup{}, nil
+	case ParseModeEntities:
+		return entitiesMarkup{}, nil
+	default:
+		return nil, fmt.Errorf("parse mode %s is not supported", parseMode)
+	}
+}
+
+// apiParseMode returns the parse_mode sent to Telegram. It is empty for plain text and text with entities
+func apiParseMode(parseMode string) string {
+	if parseMode == ParseModeNone || parseMode == ParseModeEntities {
+		return ""
+	}
+	return parseMode
+}
+
+// markdownV2Markup renders text for the MarkdownV2 parse mode
+type markdownV2Markup struct{}
+
+func (markdownV2Markup) escape(text string) string {
+	return escapeMarkdownV2(text)
+}
+
+func (markdownV2Markup) bold(text string) string {
+	return "*" + text + "*"
+}
+
+func (markdownV2Markup) italic(text string) string {
+	return "_" + text + "_"
+}
+
+func (markdownV2Markup) strikethrough(text string) string {
+	return "~" + text + "~"
+}
+
+func (markdownV2Markup) spoiler(text string) string {
+	return "||" + text + "||"
+}
+
+// markdownV2CodeEscaper escapes the only characters that have to be escaped inside code and pre entities
+var markdownV2CodeEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+func (markdownV2Markup) code(text string) string {
+	return "`" + markdownV2CodeEscaper.Replace(text) + "`"
+}
+
+func (markdownV2Markup) pre(text string) string {
+	return "```\n" + markdownV2CodeEscaper.Replace(text) + "\n```"
+}
+
+func (markdownV2Markup) link(label, url string) string {
+	// only ) and \ have to be escaped inside the URL of links
+	return "[" + escapeMarkdownV2(label) + "](" + strings.NewReplacer("\\", "\\\\", ")", "\\)").Replace(url) + ")"
+}
+
+func (m markdownV2Markup) body(text string) string {
+	return formatBody(text, m)
+}
+
+// markdownEscaper escapes the characters that start entities in the legacy Markdown parse mode
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// markdownMarkup renders text for the legacy Markdown parse mode. Entities can't be nested
+// and the content of code entities can't be escaped
+type markdownMarkup struct{}
+
+func (markdownMarkup) escape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+func (markdownMarkup) bold(text string) string {
+	return "*" + text + "*"
+}
+
+func (markdownMarkup) italic(text string) string {
+	return "_" + text + "_"
+}
+
+// strikethrough strikes the text through with combining characters as Markdown has no
+// strikethrough entity
+func (markdownMarkup) strikethrough(text string) string {
+	return overlayStrikethrough(text)
+}
+
+func (markdownMarkup) spoiler(text string) string {
+	return text
+}
+
+// overlayStrikethrough strikes letters and digits through with a combining overlay. Markup characters
+// and the URLs of links are left as they are
+func overlayStrikethrough(text string) string {
+	var builder strings.Builder
+	inURL := false
+	prev := rune(0)
+	for _, r := range text {
+		builder.WriteRune(r)
+		switch {
+		case inURL:
+			inURL = r != ')'
+		case r == '(' && prev == ']':
+			inURL = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			builder.WriteRune('\u0336')
+		}
+		prev = r
+	}
+	return builder.String()
+}
+
+func (markdownMarkup) code(text string) string {
+	// backticks can't be escaped inside code entities
+	return "`" + strings.ReplaceAll(text, "`", "'") + "`"
+}
+
+func (markdownMarkup) pre(text string) string {
+	return "```\n" + strings.ReplaceAll(text, "`", "'") + "\n```"
+}
+
+// link renders the label as is as the text of links can't be escaped
+func (markdownMarkup) link(label, url string) string {
+	return "[" + label + "](" + url + ")"
+}
+
+// body keeps code and inline markdown links and escapes the other text. Images are replaced by their URL
+func (m markdownMarkup) body(text string) string {
+	return formatBody(text, m)
+}
+
+// htmlEscaper escapes the characters Telegram requires to be escaped in HTML text
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// htmlMarkup renders text for the HTML parse mode
+type htmlMarkup struct{}
+
+func (htmlMarkup) escape(text string) string {
+	return htmlEscaper.Replace(text)
+}
+
+func (htmlMarkup) bold(text string) string {
+	return "<b>" + text + "</b>"
+}
+
+func (htmlMarkup) italic(text string) string {
+	return "<i>" + text + "</i>"
+}
+
+func (htmlMarkup) strikethrough(text string) string {
+	return "<s>" + text + "</s>"
+}
+
+func (htmlMarkup) spoiler(text string) string {
+	return "<tg-spoiler>" + text + "</tg-spoiler>"
+}
+
+func (htmlMarkup) code(text string) string {
+	return "<code>" + htmlEscaper.Replace(text) + "</code>"
+}
+
+func (htmlMarkup) pre(text string) string {
+	return "<pre>" + htmlEscaper.Replace(text) + "</pre>"
+}
+
+func (htmlMarkup) link(label, url string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), htmlEscaper.Replace(label))
+}
+
+// body converts code and inline markdown links to HTML and escapes the other text. Images are replaced by their URL
+func (m htmlMarkup) body(text string) string {
+	return formatBody(text, m)
+}
+
+// plainMarkup renders text without a parse mode. Nothing is escaped and the body is sent verbatim
+type plainMarkup struct{}
+
+func (plainMarkup) escape(text string) string {
+	return text
+}
+
+func (plainMarkup) bold(text string) string {
+	return text
+}
+
+func (plainMarkup) italic(text string) string {
+	return text
+}
+
+func (plainMarkup) strikethrough(text string) string {
+	return overlayStrikethrough(text)
+}
+
+func (plainMarkup) spoiler(text string) string {
+	return text
+}
+
+func (plainMarkup) code(text string) string {
+	return text
+}
+
+func (plainMarkup) pre(text string) string {
+	return text
+}
+
+// link shows the URL after the label unless the label is the URL
+func (plainMarkup) link(label, url string) string {
+	if label == url {
+		return url
+	}
+	return label + " (" + url + ")"
+}
+
+func (plainMarkup) body(text string) string {
+	return text
+}
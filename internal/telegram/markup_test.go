@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLMarkup_Body(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "it should escape reserved characters",
+			input:    "disk usage < 10% & load > 5",
+			expected: "disk usage &lt; 10% &amp; load &gt; 5",
+		},
+		{
+			name:     "it should not escape markdown characters",
+			input:    "Hello_World*Test.",
+			expected: "Hello_World*Test.",
+		},
+		{
+			name:     "it should convert inline URLs to anchors",
+			input:    "Check [this <link>](https://example.com/?a=1&b=\"2\")",
+			expected: `Check <a href="https://example.com/?a=1&amp;b=&#34;2&#34;">this &lt;link&gt;</a>`,
+		},
+		{
+			name:     "it should extract the URL from image markdown",
+			input:    "See this: ![alt](https://example.com/img.jpg)",
+			expected: "See this: https://example.com/img.jpg",
+		},
+		{
+			name:     "it should escape html tags in the body",
+			input:    "<script>alert(1)</script>",
+			expected: "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, htmlMarkup{}.body(tt.input))
+		})
+	}
+}
+
+func TestMarkupFor(t *testing.T) {
+	m, err := markupFor(ParseModeMarkdownV2)
+	require.NoError(t, err)
+	assert.Equal(t, "`a_b \\` \\\\`", m.code("a_b ` \\"))
+
+	m, err = markupFor(ParseModeHTML)
+	require.NoError(t, err)
+	assert.Equal(t, "<code>a_b &amp; c</code>", m.code("a_b & c"))
+	assert.Equal(t, "<b>a_b</b>", m.bold(m.escape("a_b")))
+	assert.Equal(t, "<pre>a &lt; b</pre>", m.pre("a < b"))
+
+	m, err = markupFor(ParseModeMarkdownV2)
+	require.NoError(t, err)
+	assert.Equal(t, "```\na_b \\` \\\\\n```", m.pre("a_b ` \\"))
+
+	m, err = markupFor(ParseModeMarkdown)
+	require.NoError(t, err)
+	assert.Equal(t, "`a_b 'c'`", m.code("a_b `c`"))
+	assert.Equal(t, "*a\\_b*", m.bold(m.escape("a_b")))
+	assert.Equal(t, "```\na_b ' \\\n```", m.pre("a_b ` \\"))
+
+	m, err = markupFor(ParseModeNone)
+	require.NoError(t, err)
+	assert.Equal(t, "*a_b* [x](https://example.com)", m.bold(m.escape("*a_b*"))+" "+m.body("[x](https://example.com)"))
+	assert.Equal(t, "a `b`", m.pre(m.code("a `b`")))
+
+	_, err = markupFor("BBCode")
+	assert.EqualError(t, err, "parse mode BBCode is not supported")
+}
+
+func TestMarkdownMarkup_Body(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "it should escape entity characters",
+			input:    "snake_case *glob* [x]",
+			expected: "snake\\_case \\*glob\\* \\[x]",
+		},
+		{
+			name:     "it should not escape MarkdownV2 reserved characters",
+			input:    "Done. 1-2 (ok)! #tag",
+			expected: "Done. 1-2 (ok)! #tag",
+		},
+		{
+			name:     "it should keep inline URLs",
+			input:    "Check [the docs](https://example.com/a_b) now",
+			expected: "Check [the docs](https://example.com/a_b) now",
+		},
+		{
+			name:     "it should extract the URL from image markdown",
+			input:    "See this: ![alt](https://example.com/img_1.jpg)",
+			expected: "See this: https://example.com/img\\_1.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, markdownMarkup{}.body(tt.input))
+		})
+	}
+}
+
+func TestMarkdownMarkup_Strikethrough(t *testing.T) {
+	assert.Equal(t, "*T\u0336i\u0336t\u0336l\u0336e\u0336*\n\nA\u0336 \\_ [l\u0336](https://example.com)",
+		markdownMarkup{}.strikethrough("*Title*\n\nA \\_ [l](https://example.com)"))
+}
+
+func TestFormatMessage_HTML(t *testing.T) {
+	msg := api.Message{
+		Title:    "Backup <nightly> & weekly",
+		Message:  "Hello_World with [link](https://example.com)",
+		AppName:  "TestApp",
+		Priority: 8,
+		Extras: map[string]interface{}{
+			"path": "/var/<backups>",
+		},
+	}
+
+	opts := config.MessageFormatOptions{
+		ParseMode:         ParseModeHTML,
+		IncludeAppName:    true,
+		IncludePriority:   true,
+		IncludeExtras:     true,
+		PriorityThreshold: 5,
+	}
+
+	result, err := FormatMessage(msg, opts)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"<b>[TestApp] Backup &lt;nightly&gt; &amp; weekly</b>\n\n"+
+			"Hello_World with <a href=\"https://example.com\">link</a>\n\n"+
+			"🔴 Critical Priority\n\n"+
+			"<b>Additional Info:</b>\n<pre>path: /var/&lt;backups&gt;</pre>\n\n",
+		result)
+}
+
+func TestMarkup_Link(t *testing.T) {
+	url := `https://example.com/a_(b)\c`
+	assert.Equal(t, `[a\.b](https://example.com/a_(b\)\\c)`, markdownV2Markup{}.link("a.b", url))
+	assert.Equal(t, "[a.b](https://example.com/a_(b)\\c)", markdownMarkup{}.link("a.b", url))
+	assert.Equal(t, `<a href="https://example.com/a_(b)\c">a&lt;b</a>`, htmlMarkup{}.link("a<b", url))
+	assert.Equal(t, "a.b (https://example.com)", plainMarkup{}.link("a.b", "https://example.com"))
+	assert.Equal(t, "https://example.com", plainMarkup{}.link("https://example.com", "https://example.com"))
+}
+
+func TestFormatCode(t *testing.T) {
+	input := "Run `rm -rf /tmp/*` now:\n```sh\necho \"a_b\" > log\n```\nSingle ` stays"
+
+	assert.Equal(t, "Run `rm -rf /tmp/*` now:\n```\necho \"a_b\" > log\n```\nSingle \\` stays", markdownV2Markup{}.body(input))
+	assert.Equal(t, "Run <code>rm -rf /tmp/*</code> now:\n<pre>echo \"a_b\" &gt; log</pre>\nSingle ` stays", htmlMarkup{}.body(input))
+}
@@ -0,0 +1,292 @@
+package telegram
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+const (
+	// maxCaptionLength is the maximum length of a media caption accepted by Telegram in UTF-16 code units
+	maxCaptionLength = 1024
+	// maxMediaGroupSize is the maximum number of photos of an album
+	maxMediaGroupSize = 10
+	// maxPhotoUploadSize is the largest photo accepted by Telegram as an upload
+	maxPhotoUploadSize = 10 << 20
+)
+
+// photoFile is a photo that is uploaded to Telegram instead of being fetched by Telegram from its URL
+type photoFile struct {
+	filename string
+	data     []byte
+}
+
+// PhotoPayload is the payload of the sendPhoto method
+type PhotoPayload struct {
+	ChatID          string                `json:"chat_id"`
+	MessageThreadID int64                 `json:"message_thread_id,omitempty"`
+	Photo           string                `json:"photo"`
+	Caption         string                `json:"caption,omitempty"`
+	ParseMode       string                `json:"parse_mode,omitempty"`
+	CaptionEntities []MessageEntity       `json:"caption_entities,omitempty"`
+	ProtectContent  bool                  `json:"protect_content,omitempty"`
+	ReplyParameters *ReplyParameters      `json:"reply_parameters,omitempty"`
+	ReplyMarkup     *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// MediaGroupPayload is the payload of the sendMediaGroup method
+type MediaGroupPayload struct {
+	ChatID          string            `json:"chat_id"`
+	MessageThreadID int64             `json:"message_thread_id,omitempty"`
+	Media           []InputMediaPhoto `json:"media"`
+	ProtectContent  bool              `json:"protect_content,omitempty"`
+	ReplyParameters *ReplyParameters  `json:"reply_parameters,omitempty"`
+}
+
+// InputMediaPhoto is a photo of an album
+type InputMediaPhoto struct {
+	Type            string          `json:"type"`
+	Media           string          `json:"media"`
+	Caption         string          `json:"caption,omitempty"`
+	ParseMode       string          `json:"parse_mode,omitempty"`
+	CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+}
+
+// imageURL returns the image of the message set in gotify's client::notification bigImageUrl extra
+func imageURL(msg api.Message) string {
+	notification, ok := msg.Extras["client::notification"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	url, _ := notification["bigImageUrl"].(string)
+	return url
+}
+
+// bodyImages returns the URLs of up to max http(s) markdown images of the text outside of code and the
+// text without the images
+func bodyImages(text string, max int) (urls []string, rest string) {
+	var builder strings.Builder
+
+	last := 0
+	code := codeRegex.FindAllStringIndex(text, -1)
+	for i := 0; i < len(text) && len(urls) < max; i++ {
+		if len(code) > 0 && i >= code[0][0] {
+			i = code[0][1] - 1
+			code = code[1:]
+			continue
+		}
+		if !strings.HasPrefix(text[i:], "![") {
+			continue
+		}
+
+		n, _, link, _ := parseLink(text[i:])
+		if n == 0 || (!strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://")) {
+			continue
+		}
+		urls = append(urls, link)
+		builder.WriteString(text[last:i])
+		i += n - 1
+		last = i + 1
+	}
+	if len(urls) == 0 {
+		return nil, text
+	}
+	builder.WriteString(text[last:])
+
+	return urls, strings.TrimSpace(builder.String())
+}
+
+// splitCaption returns the caption of a media message and the text that has to be sent
+// as a follow-up message because it doesn't fit into the caption. Formatted text is never
+// split to keep its markup valid. If it's too long, the caption only contains the title.
+func splitCaption(text, title string) (caption string, overflow string) {
+	if utf16Length(text) <= maxCaptionLength {
+		return text, ""
+	}
+
+	if utf16Length(title) <= maxCaptionLength {
+		return title, text
+	}
+
+	return "", text
+}
+
+// downscalePhoto downloads and downscales the photo if downscaling is enabled. Returns nil if the
+// photo is not downscaled, so Telegram fetches it from its URL
+func (c *Client) downscalePhoto(photoURL, chatID string) *photoFile {
+	if c.images.MaxDimension <= 0 {
+		return nil
+	}
+
+	data, err := c.download(photoURL, c.images.scope(), maxImageDownloadSize)
+	if err == nil {
+		data, err = downscaleImage(data, c.images.MaxDimension, c.images.JPEGQuality)
+	}
+	if err != nil {
+		c.logger.Warn().
+			Err(err).
+			Str("chat_id", chatID).
+			Msg("failed to downscale photo. Sending it by URL")
+		return nil
+	}
+
+	return &photoFile{filename: "photo.jpg", data: data}
+}
+
+// fetchAppImage downloads the image of the app of the message from the Gotify server, since Telegram
+// can't reach most Gotify servers. Returns nil for apps using gotify's default image and for images
+// that can't be downloaded, so the message is sent as text
+func (c *Client) fetchAppImage(message api.Message, chatID string) *photoFile {
+	imagePath := appImagePath(message)
+	if c.appImage == nil || imagePath == "" {
+		return nil
+	}
+
+	data, err := c.appImage(imagePath, maxPhotoUploadSize)
+	if err != nil {
+		c.logger.Warn().
+			Err(err).
+			Uint32("app_id", message.AppID).
+			Str("chat_id", chatID).
+			Msg("failed to download app image. Sending the message without it")
+		return nil
+	}
+
+	return &photoFile{filename: path.Base(imagePath), data: data}
+}
+
+// sendPhoto sends a photo with the formatted message as the caption. The file of the photo is uploaded if
+// given, otherwise the photo is downscaled or sent by its URL. Text that doesn't fit into the caption is sent
+// as a follow-up message. If Telegram rejects the photo, the text is sent without it. The keyboard is shown
+// below the last message
+func (c *Client) sendPhoto(message api.Message, token, chatID, photoURL string, photo *photoFile, text string, formatOpts config.MessageFormatOptions, keyboard *InlineKeyboardMarkup, replyTo int64) ([]SentPart, error) {
+	title, err := FormatCaption(message, formatOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	caption, overflow := splitCaption(text, title)
+	captionKeyboard := keyboard
+	if overflow != "" {
+		captionKeyboard = nil
+	}
+
+	var result messageResult
+	captionText, entities := messageText(caption, formatOpts.ParseMode)
+	if photo == nil {
+		photo = c.downscalePhoto(photoURL, chatID)
+	}
+	c.waitForChat(chatID, 1)
+	if photo != nil {
+		fields := [][2]string{
+			{"chat_id", chatID},
+			{"message_thread_id", threadField(formatOpts.MessageThreadID)},
+			{"caption", captionText},
+			{"parse_mode", apiParseMode(formatOpts.ParseMode)},
+			{"caption_entities", entitiesField(entities)},
+			{"protect_content", boolField(formatOpts.ProtectContent)},
+			{"reply_parameters", replyParametersField(replyTo)},
+			{"reply_markup", replyMarkupField(captionKeyboard)},
+		}
+		err = c.callMethodWithFile(token, "sendPhoto", fields, "photo", photo.filename, photo.data, &result)
+	} else {
+		err = c.callMethod(token, "sendPhoto", PhotoPayload{
+			ChatID:          chatID,
+			MessageThreadID: formatOpts.MessageThreadID,
+			Photo:           photoURL,
+			Caption:         captionText,
+			ParseMode:       apiParseMode(formatOpts.ParseMode),
+			CaptionEntities: entities,
+			ProtectContent:  formatOpts.ProtectContent,
+			ReplyParameters: replyParameters(replyTo),
+			ReplyMarkup:     captionKeyboard,
+		}, &result)
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		c.logger.Warn().
+			Err(err).
+			Str("chat_id", chatID).
+			Msg("telegram rejected the photo. Sending the message without it")
+		return c.sendMessage(token, chatID, text, formatOpts, keyboard, replyTo)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []SentPart{{MessageID: result.MessageID, Text: caption, Caption: true}}
+	if overflow == "" {
+		return parts, nil
+	}
+
+	overflowParts, err := c.sendMessage(token, chatID, overflow, formatOpts, keyboard, 0)
+	return append(parts, overflowParts...), err
+}
+
+// sendMediaGroup sends the photos as an album with the formatted message as the caption of the first
+// photo. Albums can't show a keyboard, so with a keyboard the text follows the album. Text that doesn't
+// fit into the caption is sent as a follow-up message. If Telegram rejects the album, the text is sent
+// without it
+func (c *Client) sendMediaGroup(message api.Message, token, chatID string, photoURLs []string, text string, formatOpts config.MessageFormatOptions, keyboard *InlineKeyboardMarkup, replyTo int64) ([]SentPart, error) {
+	title, err := FormatCaption(message, formatOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	caption, overflow := splitCaption(text, title)
+	if keyboard != nil {
+		caption, overflow = "", text
+	}
+
+	captionText, entities := messageText(caption, formatOpts.ParseMode)
+	media := make([]InputMediaPhoto, len(photoURLs))
+	for i, photoURL := range photoURLs {
+		media[i] = InputMediaPhoto{Type: "photo", Media: photoURL}
+	}
+	media[0].Caption = captionText
+	media[0].ParseMode = apiParseMode(formatOpts.ParseMode)
+	media[0].CaptionEntities = entities
+
+	var results []messageResult
+	// every photo of an album counts as a message
+	c.waitForChat(chatID, len(media))
+	err = c.callMethod(token, "sendMediaGroup", MediaGroupPayload{
+		ChatID:          chatID,
+		MessageThreadID: formatOpts.MessageThreadID,
+		Media:           media,
+		ProtectContent:  formatOpts.ProtectContent,
+		ReplyParameters: replyParameters(replyTo),
+	}, &results)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		c.logger.Warn().
+			Err(err).
+			Str("chat_id", chatID).
+			Msg("telegram rejected the album. Sending the message without it")
+		return c.sendMessage(token, chatID, text, formatOpts, keyboard, replyTo)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]SentPart, len(results))
+	for i, result := range results {
+		parts[i] = SentPart{MessageID: result.MessageID, Caption: true}
+	}
+	if len(parts) > 0 {
+		parts[0].Text = caption
+	}
+	if overflow == "" {
+		return parts, nil
+	}
+
+	overflowParts, err := c.sendMessage(token, chatID, overflow, formatOpts, keyboard, 0)
+	return append(parts, overflowParts...), err
+}
@@ -0,0 +1,408 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageURL(t *testing.T) {
+	assert.Equal(t, "", imageURL(api.Message{}))
+	assert.Equal(t, "", imageURL(api.Message{Extras: map[string]interface{}{"client::notification": "invalid"}}))
+	assert.Equal(t, "https://example.com/snapshot.jpg", imageURL(api.Message{
+		Extras: map[string]interface{}{
+			"client::notification": map[string]interface{}{
+				"bigImageUrl": "https://example.com/snapshot.jpg",
+			},
+		},
+	}))
+}
+
+func TestClientStruct_Send_AppImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		appImage      bool
+		message       api.Message
+		fetchErr      error
+		expectedPhoto string
+		expectedFile  string
+	}{
+		{
+			name:    "disabled",
+			message: api.Message{Title: "Backup", AppImage: "image/backup.png"},
+		},
+		{
+			name:         "app image",
+			appImage:     true,
+			message:      api.Message{Title: "Backup", AppImage: "image/backup.png"},
+			expectedFile: "backup.png",
+		},
+		{
+			name:     "default app image",
+			appImage: true,
+			message:  api.Message{Title: "Backup", AppImage: defaultAppImage},
+		},
+		{
+			name:     "app image can't be downloaded",
+			appImage: true,
+			message:  api.Message{Title: "Backup", AppImage: "image/backup.png"},
+			fetchErr: errors.New("unexpected status code 404"),
+		},
+		{
+			name:          "images of the message win",
+			appImage:      true,
+			message:       api.Message{Title: "Backup", Message: "![a](https://example.com/a.jpg)", AppImage: "image/backup.png"},
+			expectedPhoto: "https://example.com/a.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetched []string
+			var photo, file, content string
+			client := NewClient(Config{
+				ErrChan: make(chan error, 1),
+				FetchAppImage: func(path string, maxSize int64) ([]byte, error) {
+					assert.Equal(t, int64(maxPhotoUploadSize), maxSize)
+					fetched = append(fetched, path)
+					return []byte("png"), tt.fetchErr
+				},
+			})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					require.Equal(t, "api.telegram.org", req.URL.Host, "app images are never fetched by URL")
+					if strings.HasSuffix(req.URL.Path, "/sendPhoto") {
+						mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+						require.NoError(t, err)
+						if mediaType == "multipart/form-data" {
+							form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(1 << 20)
+							require.NoError(t, err)
+							file = form.File["photo"][0].Filename
+							f, err := form.File["photo"][0].Open()
+							require.NoError(t, err)
+							data, err := io.ReadAll(f)
+							require.NoError(t, err)
+							content = string(data)
+						} else {
+							var payload PhotoPayload
+							require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+							photo = payload.Photo
+						}
+					}
+					return response(http.StatusOK, `{"ok":true}`), nil
+				},
+			}
+
+			client.Send(tt.message, "valid-token", "123456", config.MessageFormatOptions{ParseMode: ParseModeHTML, AppImage: tt.appImage})
+			assert.Equal(t, tt.expectedPhoto, photo)
+			assert.Equal(t, tt.expectedFile, file)
+			if tt.expectedFile != "" {
+				assert.Equal(t, []string{"image/backup.png"}, fetched)
+				assert.Equal(t, "png", content)
+			}
+		})
+	}
+}
+
+func TestBodyImages(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		max          int
+		expectedURLs []string
+		expectedRest string
+	}{
+		{name: "no image", text: "front door", max: 10, expectedRest: "front door"},
+		{
+			name:         "image",
+			text:         "front door\n![snapshot](https://example.com/snapshot.jpg)",
+			max:          10,
+			expectedURLs: []string{"https://example.com/snapshot.jpg"},
+			expectedRest: "front door",
+		},
+		{
+			name:         "several images",
+			text:         "![a](https://example.com/a.jpg) and ![b](https://example.com/b.jpg)",
+			max:          10,
+			expectedURLs: []string{"https://example.com/a.jpg", "https://example.com/b.jpg"},
+			expectedRest: "and",
+		},
+		{
+			name:         "at most max images",
+			text:         "![a](https://example.com/a.jpg) and ![b](https://example.com/b.jpg)",
+			max:          1,
+			expectedURLs: []string{"https://example.com/a.jpg"},
+			expectedRest: "and ![b](https://example.com/b.jpg)",
+		},
+		{
+			name:         "no images without room",
+			text:         "![a](https://example.com/a.jpg)",
+			expectedRest: "![a](https://example.com/a.jpg)",
+		},
+		{
+			name:         "links aren't images",
+			text:         "[a](https://example.com/a.jpg)",
+			max:          10,
+			expectedRest: "[a](https://example.com/a.jpg)",
+		},
+		{
+			name:         "images in code are ignored",
+			text:         "`![a](https://example.com/a.jpg)` ![b](https://example.com/b.jpg)",
+			max:          10,
+			expectedURLs: []string{"https://example.com/b.jpg"},
+			expectedRest: "`![a](https://example.com/a.jpg)`",
+		},
+		{
+			name:         "non http images are ignored",
+			text:         "![a](file:///etc/passwd)",
+			max:          10,
+			expectedRest: "![a](file:///etc/passwd)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, rest := bodyImages(tt.text, tt.max)
+			assert.Equal(t, tt.expectedURLs, urls)
+			assert.Equal(t, tt.expectedRest, rest)
+		})
+	}
+}
+
+func TestClientStruct_Send_MediaGroup(t *testing.T) {
+	body := "garden\n![a](https://example.com/a.jpg)\n![b](https://example.com/b.jpg)"
+
+	tests := []struct {
+		name            string
+		keyboard        bool
+		albumStatus     int
+		expectedMethods []string
+		expectedCaption string
+		expectedParts   []SentPart
+	}{
+		{
+			name:            "album captioned with the message",
+			albumStatus:     http.StatusOK,
+			expectedMethods: []string{"sendMediaGroup"},
+			expectedCaption: "*Motion detected*\n\ngarden\n\n",
+			expectedParts: []SentPart{
+				{MessageID: 1, Text: "*Motion detected*\n\ngarden\n\n", Caption: true},
+				{MessageID: 2, Caption: true},
+			},
+		},
+		{
+			name:            "text with a keyboard follows the album",
+			keyboard:        true,
+			albumStatus:     http.StatusOK,
+			expectedMethods: []string{"sendMediaGroup", "sendMessage"},
+			expectedParts: []SentPart{
+				{MessageID: 1, Caption: true},
+				{MessageID: 2, Caption: true},
+				{MessageID: 3, Text: "*Motion detected*\n\ngarden\n\n"},
+			},
+		},
+		{
+			name:            "rejected album falls back to a text message",
+			albumStatus:     http.StatusBadRequest,
+			expectedMethods: []string{"sendMediaGroup", "sendMessage"},
+			expectedCaption: "*Motion detected*\n\ngarden\n\n",
+			expectedParts:   []SentPart{{MessageID: 3, Text: "*Motion detected*\n\ngarden\n\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				methods []string
+				payload MediaGroupPayload
+				parts   []SentPart
+			)
+
+			errChan := make(chan error, 1)
+			webURL, err := url.Parse("https://gotify.example.com")
+			require.NoError(t, err)
+			client := NewClient(Config{
+				ErrChan:   errChan,
+				GotifyURL: webURL,
+				OnSent:    func(_ api.Message, sent SentMessage) { parts = sent.Parts },
+			})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+					methods = append(methods, method)
+
+					if method == "sendMediaGroup" {
+						require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+						return response(tt.albumStatus, `{"ok":true,"result":[{"message_id":1},{"message_id":2}]}`), nil
+					}
+
+					_, _ = io.Copy(io.Discard, req.Body)
+					return response(http.StatusOK, `{"ok":true,"result":{"message_id":3}}`), nil
+				},
+			}
+
+			formatOpts := config.MessageFormatOptions{ParseMode: ParseModeMarkdownV2}
+			if tt.keyboard {
+				formatOpts.GotifyLink = config.GotifyLinkButton
+			}
+			client.Send(api.Message{AppID: 3, Title: "Motion detected", Message: body}, "valid-token", "123456", formatOpts)
+
+			assert.Empty(t, errChan)
+			assert.Equal(t, tt.expectedMethods, methods)
+			require.Len(t, payload.Media, 2)
+			assert.Equal(t, "https://example.com/a.jpg", payload.Media[0].Media)
+			assert.Equal(t, "https://example.com/b.jpg", payload.Media[1].Media)
+			assert.Equal(t, tt.expectedCaption, payload.Media[0].Caption)
+			assert.Equal(t, tt.expectedParts, parts)
+		})
+	}
+}
+
+func TestSplitCaption(t *testing.T) {
+	long := strings.Repeat("a", maxCaptionLength+1)
+
+	tests := []struct {
+		name             string
+		text             string
+		title            string
+		expectedCaption  string
+		expectedOverflow string
+	}{
+		{
+			name:            "text fits into the caption",
+			text:            "*Title*\n\nbody",
+			title:           "*Title*",
+			expectedCaption: "*Title*\n\nbody",
+		},
+		{
+			name:            "multibyte text at the limit fits into the caption",
+			text:            strings.Repeat("é", maxCaptionLength),
+			expectedCaption: strings.Repeat("é", maxCaptionLength),
+		},
+		{
+			name:             "emojis count as two UTF-16 code units",
+			text:             strings.Repeat("🔥", maxCaptionLength/2+1),
+			title:            "*Title*",
+			expectedCaption:  "*Title*",
+			expectedOverflow: strings.Repeat("🔥", maxCaptionLength/2+1),
+		},
+		{
+			name:             "long text overflows into a follow-up message",
+			text:             long,
+			title:            "*Title*",
+			expectedCaption:  "*Title*",
+			expectedOverflow: long,
+		},
+		{
+			name:             "long title is dropped",
+			text:             long,
+			title:            long,
+			expectedOverflow: long,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caption, overflow := splitCaption(tt.text, tt.title)
+			assert.Equal(t, tt.expectedCaption, caption)
+			assert.Equal(t, tt.expectedOverflow, overflow)
+		})
+	}
+}
+
+func TestClientStruct_Send_Photo(t *testing.T) {
+	photoMessage := func(body string) api.Message {
+		return api.Message{
+			Title:   "Motion detected",
+			Message: body,
+			Extras: map[string]interface{}{
+				"client::notification": map[string]interface{}{
+					"bigImageUrl": "https://example.com/snapshot.jpg",
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		message         api.Message
+		photoStatus     int
+		expectedMethods []string
+		expectedCaption string
+	}{
+		{
+			name:            "short message is sent as caption",
+			message:         photoMessage("front door"),
+			photoStatus:     http.StatusOK,
+			expectedMethods: []string{"sendPhoto"},
+			expectedCaption: "*Motion detected*\n\nfront door\n\n",
+		},
+		{
+			name:            "long message overflows into a follow-up message",
+			message:         photoMessage(strings.Repeat("a", maxCaptionLength)),
+			photoStatus:     http.StatusOK,
+			expectedMethods: []string{"sendPhoto", "sendMessage"},
+			expectedCaption: "*Motion detected*",
+		},
+		{
+			name:            "markdown image in the body is sent as the photo",
+			message:         api.Message{Title: "Motion detected", Message: "front door\n\n![snapshot](https://example.com/snapshot.jpg)"},
+			photoStatus:     http.StatusOK,
+			expectedMethods: []string{"sendPhoto"},
+			expectedCaption: "*Motion detected*\n\nfront door\n\n",
+		},
+		{
+			name:            "rejected photo falls back to a text message",
+			message:         photoMessage("front door"),
+			photoStatus:     http.StatusBadRequest,
+			expectedMethods: []string{"sendPhoto", "sendMessage"},
+			expectedCaption: "*Motion detected*\n\nfront door\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				methods []string
+				caption string
+			)
+
+			errChan := make(chan error, 1)
+			client := NewClient(Config{ErrChan: errChan})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+					methods = append(methods, method)
+
+					if method == "sendPhoto" {
+						var payload PhotoPayload
+						require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+						assert.Equal(t, "https://example.com/snapshot.jpg", payload.Photo)
+						caption = payload.Caption
+						return response(tt.photoStatus, `{"ok":true}`), nil
+					}
+
+					_, _ = io.Copy(io.Discard, req.Body)
+					return response(http.StatusOK, `{"ok":true}`), nil
+				},
+			}
+
+			client.Send(tt.message, "valid-token", "123456", config.MessageFormatOptions{ParseMode: ParseModeMarkdownV2})
+
+			assert.Empty(t, errChan)
+			assert.Equal(t, tt.expectedMethods, methods)
+			assert.Equal(t, tt.expectedCaption, caption)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package telegram
+
+// PinPayload is the payload of the pinChatMessage and unpinChatMessage methods
+type PinPayload struct {
+	ChatID              string `json:"chat_id"`
+	MessageID           int64  `json:"message_id"`
+	DisableNotification bool   `json:"disable_notification,omitempty"`
+}
+
+// pinMessage silently pins the first Telegram message a gotify message was sent as and marks it as pinned.
+// Failures are only logged as the bot may not be allowed to pin messages in the chat
+func (c *Client) pinMessage(token, chatID string, parts []SentPart) {
+	if len(parts) == 0 {
+		return
+	}
+
+	err := c.callMethod(token, "pinChatMessage", PinPayload{
+		ChatID:              chatID,
+		MessageID:           parts[0].MessageID,
+		DisableNotification: true,
+	}, nil)
+	if err != nil {
+		c.logger.Warn().
+			Err(err).
+			Str("chat_id", chatID).
+			Msg("failed to pin message. Is the bot allowed to pin messages?")
+		return
+	}
+
+	parts[0].Pinned = true
+}
+
+// unpinMessage unpins a Telegram message
+func (c *Client) unpinMessage(token, chatID string, messageID int64) error {
+	return c.callMethod(token, "unpinChatMessage", PinPayload{ChatID: chatID, MessageID: messageID}, nil)
+}
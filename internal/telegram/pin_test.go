@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+func TestClientStruct_Send_Pin(t *testing.T) {
+	tests := []struct {
+		name             string
+		priority         uint32
+		pinPriority      int
+		pinStatus        int
+		expectedRequests []string
+		expectedPinned   bool
+	}{
+		{
+			name:             "pinning disabled",
+			priority:         10,
+			expectedRequests: []string{"sendMessage"},
+		},
+		{
+			name:             "below the pin priority",
+			priority:         7,
+			pinPriority:      8,
+			expectedRequests: []string{"sendMessage"},
+		},
+		{
+			name:             "at the pin priority",
+			priority:         8,
+			pinPriority:      8,
+			pinStatus:        http.StatusOK,
+			expectedRequests: []string{"sendMessage", `pinChatMessage {"chat_id":"123","message_id":42,"disable_notification":true}`},
+			expectedPinned:   true,
+		},
+		{
+			name:             "bot not allowed to pin",
+			priority:         9,
+			pinPriority:      8,
+			pinStatus:        http.StatusBadRequest,
+			expectedRequests: []string{"sendMessage", `pinChatMessage {"chat_id":"123","message_id":42,"disable_notification":true}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				requests []string
+				sent     SentMessage
+			)
+			errChan := make(chan error, 1)
+			client := NewClient(Config{ErrChan: errChan, OnSent: func(_ api.Message, s SentMessage) { sent = s }})
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					body, err := io.ReadAll(req.Body)
+					require.NoError(t, err)
+					method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+					if method == "pinChatMessage" {
+						requests = append(requests, method+" "+string(body))
+						return &http.Response{
+							StatusCode: tt.pinStatus,
+							Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":true}`)),
+						}, nil
+					}
+
+					requests = append(requests, method)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":42}}`)),
+					}, nil
+				},
+			}
+
+			client.Send(api.Message{Title: "Disk full", Priority: tt.priority}, "token", "123",
+				config.MessageFormatOptions{ParseMode: ParseModeHTML, PinPriority: tt.pinPriority})
+
+			assert.Empty(t, errChan)
+			assert.Equal(t, tt.expectedRequests, requests)
+			require.Len(t, sent.Parts, 1)
+			assert.Equal(t, tt.expectedPinned, sent.Parts[0].Pinned)
+		})
+	}
+}
+
+func TestClientStruct_StrikeThroughMessage_Unpin(t *testing.T) {
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	requests := recordRequests(t, client, http.StatusOK)
+
+	err := client.StrikeThroughMessage(SentMessage{
+		ChatID:    "123",
+		Token:     "token",
+		ParseMode: ParseModeMarkdownV2,
+		Parts:     []SentPart{{MessageID: 1, Text: "*a*", Pinned: true}, {MessageID: 2, Text: "b"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`unpinChatMessage {"chat_id":"123","message_id":1}`,
+		`editMessageText {"chat_id":"123","message_id":1,"text":"~*a*~","parse_mode":"MarkdownV2"}`,
+		`editMessageText {"chat_id":"123","message_id":2,"text":"~b~","parse_mode":"MarkdownV2"}`,
+	}, *requests)
+}
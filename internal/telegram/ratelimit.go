@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitPolicy limits the messages sent to a chat to stay below the limits of Telegram instead of provoking
+// 429 responses. Messages over the limit are delayed. The zero value disables rate limiting
+type RateLimitPolicy struct {
+	// Messages per second sent to any chat
+	ChatPerSecond float64
+	// Messages per minute sent to a group or channel, in addition to ChatPerSecond
+	GroupPerMinute float64
+}
+
+// enabled returns true if messages are rate limited
+func (p RateLimitPolicy) enabled() bool {
+	return p.ChatPerSecond > 0 || p.GroupPerMinute > 0
+}
+
+// tokenBucket allows bursts of up to burst messages that are refilled at rate messages per second
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes n tokens from the bucket and returns how long to wait until they are available.
+// The tokens are reserved immediately, so later reservations wait for earlier ones
+func (b *tokenBucket) reserve(now time.Time, n, rate, burst float64) time.Duration {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if now.After(b.last) {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	b.tokens -= n
+
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// chatBuckets are the token buckets of a chat
+type chatBuckets struct {
+	chat  tokenBucket
+	group tokenBucket
+}
+
+// chatLimiter rate limits the messages sent to each chat
+type chatLimiter struct {
+	policy RateLimitPolicy
+	now    func() time.Time
+
+	mu    sync.Mutex
+	chats map[string]*chatBuckets
+}
+
+// newChatLimiter returns a limiter of the policy or nil if rate limiting is disabled
+func newChatLimiter(policy RateLimitPolicy) *chatLimiter {
+	if !policy.enabled() {
+		return nil
+	}
+	return &chatLimiter{policy: policy, now: time.Now, chats: make(map[string]*chatBuckets)}
+}
+
+// isGroupChat returns true if the chat is a group or channel. Their IDs are negative, unresolved channel usernames
+// start with @
+func isGroupChat(chatID string) bool {
+	return strings.HasPrefix(chatID, "-") || strings.HasPrefix(chatID, "@")
+}
+
+// reserve reserves n messages to the chat and returns how long to wait before they are sent
+func (l *chatLimiter) reserve(chatID string, n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets, ok := l.chats[chatID]
+	if !ok {
+		buckets = &chatBuckets{}
+		l.chats[chatID] = buckets
+	}
+
+	now := l.now()
+	var wait time.Duration
+	if rate := l.policy.ChatPerSecond; rate > 0 {
+		wait = buckets.chat.reserve(now, float64(n), rate, math.Max(1, rate))
+	}
+	if perMinute := l.policy.GroupPerMinute; perMinute > 0 && isGroupChat(chatID) {
+		wait = max(wait, buckets.group.reserve(now, float64(n), perMinute/60, perMinute))
+	}
+
+	return wait
+}
+
+// waitForChat blocks until n more messages can be sent to the chat without exceeding the rate limits
+func (c *Client) waitForChat(chatID string, n int) {
+	if c.limiter == nil {
+		return
+	}
+
+	if wait := c.limiter.reserve(chatID, n); wait > 0 {
+		c.logger.Debug().
+			Str("chat_id", chatID).
+			Dur("delay", wait).
+			Msg("rate limiting messages to chat")
+		c.sleep(wait)
+	}
+}
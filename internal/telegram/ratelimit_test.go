@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	limiter := newChatLimiter(RateLimitPolicy{ChatPerSecond: 1, GroupPerMinute: 20})
+	limiter.now = func() time.Time { return now }
+
+	// private chats are limited to a message per second
+	assert.Zero(t, limiter.reserve("123", 1))
+	assert.Equal(t, time.Second, limiter.reserve("123", 1))
+	assert.Equal(t, 2*time.Second, limiter.reserve("123", 1))
+	assert.Zero(t, limiter.reserve("456", 1), "other chat")
+
+	now = now.Add(10 * time.Second)
+	assert.Zero(t, limiter.reserve("123", 1), "bucket refilled")
+	assert.Equal(t, time.Second, limiter.reserve("123", 1), "burst is capped")
+
+	// groups are additionally limited to 20 messages per minute
+	groups := newChatLimiter(RateLimitPolicy{GroupPerMinute: 20})
+	groups.now = func() time.Time { return now }
+	for i := 0; i < 20; i++ {
+		assert.Zero(t, groups.reserve("-100123", 1))
+	}
+	assert.Equal(t, 3*time.Second, groups.reserve("-100123", 1))
+	assert.Equal(t, 3*time.Second, groups.reserve("@alerts", 21), "unresolved channel username")
+	assert.Zero(t, groups.reserve("123", 100), "private chat")
+
+	// albums count as several messages
+	assert.Equal(t, 9*time.Second, limiter.reserve("789", 10))
+}
+
+func TestNewChatLimiter_Disabled(t *testing.T) {
+	assert.Nil(t, newChatLimiter(RateLimitPolicy{}))
+
+	client := NewClient(Config{ErrChan: make(chan error, 1)})
+	client.sleep = func(time.Duration) { t.Fatal("unexpected sleep") }
+	client.waitForChat("123", 1)
+	client.waitForChat("123", 1)
+}
+
+func TestClientStruct_waitForChat(t *testing.T) {
+	var sleeps []time.Duration
+	client := NewClient(Config{ErrChan: make(chan error, 1), RateLimit: RateLimitPolicy{ChatPerSecond: 2}})
+	client.limiter.now = func() time.Time { return time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC) }
+	client.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	for i := 0; i < 4; i++ {
+		client.waitForChat("123", 1)
+	}
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, time.Second}, sleeps)
+}
@@ -0,0 +1,178 @@
+package telegram
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// errorClass groups failed requests by how they should be retried
+type errorClass int
+
+const (
+	// classNetwork are connection resets, timeouts and other transport errors
+	classNetwork errorClass = iota
+	// classRateLimited are 429 responses
+	classRateLimited
+	// classServer are 5xx responses
+	classServer
+	// classClient are 4xx responses that will fail again if retried
+	classClient
+)
+
+func (c errorClass) String() string {
+	switch c {
+	case classNetwork:
+		return "network"
+	case classRateLimited:
+		return "rate_limited"
+	case classServer:
+		return "server"
+	default:
+		return "client"
+	}
+}
+
+// networkError wraps errors that occurred before a response was received from the Telegram API
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+// classify returns the error class of a failed request
+func classify(err error) errorClass {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		var netErr *networkError
+		if errors.As(err, &netErr) {
+			return classNetwork
+		}
+		return classClient
+	}
+
+	switch {
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		return classRateLimited
+	case apiErr.StatusCode >= http.StatusInternalServerError:
+		return classServer
+	default:
+		return classClient
+	}
+}
+
+// RetryPolicy configures how failed requests to the Telegram API are retried.
+// Client errors such as 400, 401 and 403 are never retried. The zero value disables retries.
+type RetryPolicy struct {
+	// Retries after connection resets and timeouts. Retried quickly using InitialBackoff
+	NetworkRetries int
+	// Retries after 5xx responses. Retried with exponential backoff
+	ServerErrorRetries int
+	// Retries after 429 responses. Retried after the retry_after returned by Telegram
+	RateLimitRetries int
+	// Delay before the first retry
+	InitialBackoff time.Duration
+	// Upper bound of the exponential backoff
+	MaxBackoff time.Duration
+	// Fraction (0-1) of the delay it is randomly shortened or lengthened by, so that clients failing at the
+	// same time don't retry at the same time. Not applied to the retry_after returned by Telegram
+	Jitter float64
+}
+
+// retries returns the number of retries allowed for the error class
+func (p RetryPolicy) retries(class errorClass) int {
+	switch class {
+	case classNetwork:
+		return p.NetworkRetries
+	case classRateLimited:
+		return p.RateLimitRetries
+	case classServer:
+		return p.ServerErrorRetries
+	default:
+		return 0
+	}
+}
+
+// backoff returns the delay before the given retry (starting at 1) of a failed request
+func (p RetryPolicy) backoff(err error, class errorClass, retry int) time.Duration {
+	switch class {
+	case classRateLimited:
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			return time.Duration(apiErr.RetryAfter) * time.Second
+		}
+	case classNetwork:
+		return p.InitialBackoff
+	}
+
+	delay := p.InitialBackoff
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// jittered spreads the delay by up to Jitter of the delay in both directions. r is a random number in [0, 1)
+func (p RetryPolicy) jittered(delay time.Duration, r float64) time.Duration {
+	if p.Jitter <= 0 {
+		return delay
+	}
+	return delay + time.Duration((2*r-1)*p.Jitter*float64(delay))
+}
+
+// makeRequestWithRetry makes a request to the Telegram API and retries it
+// according to the retry policy of the error class. Requests are not made while the circuit breaker is open
+func (c *Client) makeRequestWithRetry(endpoint, contentType string, body []byte) ([]byte, error) {
+	return c.retryRequest(endpoint, contentType, body, true)
+}
+
+// retryRequest makes a request to the Telegram API and retries it according to the retry policy of the error
+// class. The circuit breaker is only checked if checkBreaker is set, so that the remaining parts of a message
+// whose first part was sent aren't stopped halfway when the circuit opens
+func (c *Client) retryRequest(endpoint, contentType string, body []byte, checkBreaker bool) ([]byte, error) {
+	retries := make(map[errorClass]int)
+	for {
+		if checkBreaker {
+			if err := c.breaker.allow(); err != nil {
+				return nil, err
+			}
+		}
+
+		resBody, err := c.makeRequest(endpoint, contentType, bytes.NewBuffer(body))
+		c.breaker.record(err)
+		if err == nil {
+			return resBody, nil
+		}
+
+		class := classify(err)
+		retries[class]++
+		if retries[class] > c.retry.retries(class) {
+			return nil, err
+		}
+
+		delay := c.retry.backoff(err, class, retries[class])
+		if class != classRateLimited {
+			delay = c.retry.jittered(delay, rand.Float64())
+		}
+		c.logger.Warn().
+			Err(err).
+			Str("error_class", class.String()).
+			Int("retry", retries[class]).
+			Dur("backoff", delay).
+			Msg("request to Telegram API failed. Retrying")
+
+		c.sleep(delay)
+	}
+}
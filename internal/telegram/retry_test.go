@@ -0,0 +1,231 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorClass
+	}{
+		{name: "network error", err: &networkError{errors.New("connection reset by peer")}, want: classNetwork},
+		{name: "rate limited", err: &APIError{StatusCode: http.StatusTooManyRequests}, want: classRateLimited},
+		{name: "server error", err: &APIError{StatusCode: http.StatusBadGateway}, want: classServer},
+		{name: "bad request", err: &APIError{StatusCode: http.StatusBadRequest}, want: classClient},
+		{name: "forbidden", err: &APIError{StatusCode: http.StatusForbidden}, want: classClient},
+		{name: "other error", err: errors.New("failed to create request"), want: classClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classify(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicyStruct_Backoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+
+	assert.Equal(t, time.Second, policy.backoff(&networkError{errors.New("timeout")}, classNetwork, 3))
+	assert.Equal(t, time.Second, policy.backoff(&APIError{StatusCode: 500}, classServer, 1))
+	assert.Equal(t, 4*time.Second, policy.backoff(&APIError{StatusCode: 500}, classServer, 3))
+	assert.Equal(t, 5*time.Second, policy.backoff(&APIError{StatusCode: 500}, classServer, 10))
+	assert.Equal(t, 30*time.Second, policy.backoff(&APIError{StatusCode: 429, RetryAfter: 30}, classRateLimited, 1))
+	assert.Equal(t, 2*time.Second, policy.backoff(&APIError{StatusCode: 429}, classRateLimited, 2))
+}
+
+func TestRetryPolicyStruct_Jittered(t *testing.T) {
+	assert.Equal(t, time.Second, RetryPolicy{}.jittered(time.Second, 0.9))
+
+	policy := RetryPolicy{Jitter: 0.2}
+	assert.Equal(t, 800*time.Millisecond, policy.jittered(time.Second, 0))
+	assert.Equal(t, time.Second, policy.jittered(time.Second, 0.5))
+	assert.Equal(t, 1100*time.Millisecond, policy.jittered(time.Second, 0.75))
+}
+
+func TestClientStruct_MakeRequestWithRetry(t *testing.T) {
+	policy := RetryPolicy{
+		NetworkRetries:     3,
+		ServerErrorRetries: 2,
+		RateLimitRetries:   1,
+		InitialBackoff:     time.Second,
+		MaxBackoff:         10 * time.Second,
+	}
+
+	tests := []struct {
+		name         string
+		responses    []*http.Response
+		transportErr error
+		wantErr      bool
+		wantRequests int
+		wantSleeps   []time.Duration
+	}{
+		{
+			name:         "network errors are retried quickly",
+			transportErr: errors.New("connection reset by peer"),
+			wantErr:      true,
+			wantRequests: 4,
+			wantSleeps:   []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name: "rate limit respects retry_after",
+			responses: []*http.Response{
+				response(http.StatusTooManyRequests, `{"ok":false,"parameters":{"retry_after":7}}`),
+				response(http.StatusOK, `{"ok":true}`),
+			},
+			wantRequests: 2,
+			wantSleeps:   []time.Duration{7 * time.Second},
+		},
+		{
+			name: "server errors back off exponentially",
+			responses: []*http.Response{
+				response(http.StatusBadGateway, `{"ok":false}`),
+				response(http.StatusInternalServerError, `{"ok":false}`),
+				response(http.StatusBadGateway, `{"ok":false}`),
+			},
+			wantErr:      true,
+			wantRequests: 3,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name: "client errors are not retried",
+			responses: []*http.Response{
+				response(http.StatusForbidden, `{"ok":false,"description":"Forbidden: bot was blocked by the user"}`),
+			},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				requests int
+				sleeps   []time.Duration
+			)
+
+			client := NewClient(Config{ErrChan: make(chan error, 1), Retry: policy})
+			client.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+			client.httpClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					requests++
+					if tt.transportErr != nil {
+						return nil, tt.transportErr
+					}
+					return tt.responses[requests-1], nil
+				},
+			}
+
+			_, err := client.makeRequestWithRetry("https://api.telegram.org/bottoken/sendMessage", jsonContentType, []byte("{}"))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRequests, requests)
+			assert.Equal(t, tt.wantSleeps, sleeps)
+		})
+	}
+}
+
+func TestClientStruct_MakeRequestWithRetry_Jitter(t *testing.T) {
+	var sleeps []time.Duration
+	client := NewClient(Config{ErrChan: make(chan error, 1), Retry: RetryPolicy{
+		NetworkRetries:   20,
+		RateLimitRetries: 1,
+		InitialBackoff:   time.Second,
+		Jitter:           0.5,
+	}})
+	client.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection reset by peer")
+		},
+	}
+
+	_, err := client.makeRequestWithRetry("https://api.telegram.org/bottoken/sendMessage", jsonContentType, []byte("{}"))
+	assert.Error(t, err)
+	require.Len(t, sleeps, 20)
+	for _, sleep := range sleeps {
+		assert.GreaterOrEqual(t, sleep, 500*time.Millisecond)
+		assert.Less(t, sleep, 1500*time.Millisecond)
+	}
+
+	// retry_after is respected exactly
+	sleeps = nil
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return response(http.StatusTooManyRequests, `{"ok":false,"parameters":{"retry_after":7}}`), nil
+		},
+	}
+	_, err = client.makeRequestWithRetry("https://api.telegram.org/bottoken/sendMessage", jsonContentType, []byte("{}"))
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{7 * time.Second}, sleeps)
+}
+
+func TestClientStruct_Send_RateLimitedBurst(t *testing.T) {
+	errChan := make(chan error, 10)
+	var sleeps []time.Duration
+	var sent []string
+	client := NewClient(Config{ErrChan: errChan, Retry: RetryPolicy{RateLimitRetries: 1}})
+	client.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	// Telegram rate limits every other request of the burst
+	requests := 0
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			requests++
+			if requests%2 == 1 {
+				return response(http.StatusTooManyRequests,
+					`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3","parameters":{"retry_after":3}}`), nil
+			}
+			var payload Payload
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			sent = append(sent, payload.Text)
+			return response(http.StatusOK, `{"ok":true,"result":{"message_id":1}}`), nil
+		},
+	}
+
+	for _, title := range []string{"one", "two", "three"} {
+		require.NoError(t, client.Send(api.Message{Title: title}, "token", "123", config.MessageFormatOptions{ParseMode: ParseModeNone}))
+	}
+	assert.Len(t, sent, 3, "rate limited messages are retried instead of failing")
+	assert.Equal(t, []time.Duration{3 * time.Second, 3 * time.Second, 3 * time.Second}, sleeps)
+	assert.Empty(t, errChan)
+
+	// once the retries are used up the error reports the retry_after
+	client.httpClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return response(http.StatusTooManyRequests, `{"ok":false,"error_code":429,"parameters":{"retry_after":3}}`), nil
+		},
+	}
+	err := client.Send(api.Message{Title: "four"}, "token", "123", config.MessageFormatOptions{ParseMode: ParseModeNone})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, 3, apiErr.RetryAfter)
+	assert.Len(t, errChan, 1)
+}
+
+func response(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+}
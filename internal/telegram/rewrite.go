@@ -0,0 +1,19 @@
+package telegram
+
+import (
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// rewriteMessage returns a copy of the message with the rewrite rules applied in order to its title and body
+func rewriteMessage(msg api.Message, rules []config.RewriteRule) api.Message {
+	for i := range rules {
+		if rules[i].AppliesTo(config.RewriteFieldTitle) {
+			msg.Title = rules[i].Apply(msg.Title)
+		}
+		if rules[i].AppliesTo(config.RewriteFieldMessage) {
+			msg.Message = rules[i].Apply(msg.Message)
+		}
+	}
+	return msg
+}
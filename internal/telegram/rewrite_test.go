@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+func TestRewriteMessage(t *testing.T) {
+	msg := api.Message{Title: "[cron] backup", Message: "[cron] backup of /data finished"}
+
+	tests := []struct {
+		name     string
+		rules    []config.RewriteRule
+		expected api.Message
+	}{
+		{
+			name:     "no rules",
+			expected: msg,
+		},
+		{
+			name:     "both fields",
+			rules:    []config.RewriteRule{{Pattern: `^\[cron\] `}},
+			expected: api.Message{Title: "backup", Message: "backup of /data finished"},
+		},
+		{
+			name: "single field with capture groups",
+			rules: []config.RewriteRule{
+				{Pattern: `^\[(\w+)\] (.*)$`, Replacement: "$2 ($1)", Field: config.RewriteFieldTitle},
+				{Pattern: `/data`, Replacement: "the data volume", Field: config.RewriteFieldMessage},
+			},
+			expected: api.Message{Title: "backup (cron)", Message: "[cron] backup of the data volume finished"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, rewriteMessage(msg, tt.rules))
+		})
+	}
+}
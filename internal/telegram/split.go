@@ -0,0 +1,245 @@
+package telegram
+
+import "strings"
+
+// entity is formatting that was opened but not closed yet at some point of the text
+type entity struct {
+	// markup closing the entity at the end of a chunk
+	close string
+	// markup opening the entity again at the start of the next chunk
+	reopen string
+	// code entities only end at their closing marker and don't contain other formatting
+	code bool
+}
+
+// segment is a piece of formatted text that is never split: a character, an escape
+// sequence, an HTML tag or character reference, or a MarkdownV2 link
+type segment struct {
+	text string
+	// entities that are open after the segment
+	open []entity
+}
+
+// splitText splits formatted text into chunks of at most limit UTF-16 code units. Chunks end at
+// line breaks where possible and are never cut inside a character, an escape sequence or a tag.
+// Entities open at the end of a chunk are closed and opened again at the start of the next one
+func splitText(text string, limit int, parseMode string) []string {
+	if limit <= 0 || utf16Length(text) <= limit {
+		return []string{text}
+	}
+
+	segments := segmentText(text, parseMode)
+
+	var chunks []string
+	for start := 0; start < len(segments); {
+		var open []entity
+		if start > 0 {
+			open = segments[start-1].open
+		}
+		prefix := reopenEntities(open)
+		length := utf16Length(prefix)
+
+		end, lineBreak := start, -1
+		for end < len(segments) {
+			n := utf16Length(segments[end].text)
+			if end > start && length+n+utf16Length(closeEntities(segments[end].open)) > limit {
+				break
+			}
+			length += n
+			end++
+			if strings.HasSuffix(segments[end-1].text, "\n") {
+				lineBreak = end
+			}
+		}
+		if end < len(segments) && lineBreak > start {
+			end = lineBreak
+		}
+
+		var chunk strings.Builder
+		chunk.WriteString(prefix)
+		for _, seg := range segments[start:end] {
+			chunk.WriteString(seg.text)
+		}
+		chunk.WriteString(closeEntities(segments[end-1].open))
+		chunks = append(chunks, chunk.String())
+		start = end
+	}
+
+	return chunks
+}
+
+// segmentText splits text into the segments of the parse mode. Text without a parse mode is split into characters
+func segmentText(text, parseMode string) []segment {
+	switch parseMode {
+	case ParseModeMarkdown:
+		return segmentMarkdown(text, false)
+	case ParseModeMarkdownV2:
+		return segmentMarkdown(text, true)
+	case ParseModeHTML:
+		return segmentHTML(text)
+	case ParseModeEntities:
+		return segmentEntities(text)
+	default:
+		var segments []segment
+		for _, cluster := range graphemes(text) {
+			segments = append(segments, segment{text: cluster})
+		}
+		return segments
+	}
+}
+
+// segmentMarkdown splits MarkdownV2 or legacy Markdown text. Legacy Markdown has no spoiler,
+// underline and strikethrough entities
+func segmentMarkdown(text string, v2 bool) []segment {
+	var segments []segment
+	var open []entity
+
+	for rest := text; rest != ""; {
+		var n int
+		code := len(open) > 0 && open[len(open)-1].code
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1:
+			n = 1 + graphemeLength(rest[1:])
+		case code:
+			n = graphemeLength(rest)
+			if closer := open[len(open)-1].close; strings.HasPrefix(rest, closer) {
+				n = len(closer)
+				open = closeEntity(open, closer)
+			}
+		case strings.HasPrefix(rest, "```"):
+			n = len("```")
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				n = i + 1
+			}
+			open = pushEntity(open, entity{close: "```", reopen: rest[:n], code: true})
+		case rest[0] == '`':
+			n = 1
+			open = pushEntity(open, entity{close: "`", reopen: "`", code: true})
+		case rest[0] == '[':
+			n = markdownLinkLength(rest)
+			if n == 0 {
+				n = 1
+			}
+		case v2 && (strings.HasPrefix(rest, "||") || strings.HasPrefix(rest, "__")):
+			n = 2
+			open = toggleEntity(open, rest[:n])
+		case rest[0] == '*', rest[0] == '_', v2 && rest[0] == '~':
+			n = 1
+			open = toggleEntity(open, rest[:n])
+		default:
+			n = graphemeLength(rest)
+		}
+
+		segments = append(segments, segment{text: rest[:n], open: open})
+		rest = rest[n:]
+	}
+
+	return segments
+}
+
+// markdownLinkLength returns the length of the [text](url) link at the start of s or 0 if s doesn't start with a link
+func markdownLinkLength(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n', '[':
+			return 0
+		case ']':
+			if !strings.HasPrefix(s[i+1:], "(") {
+				return 0
+			}
+			end := strings.IndexAny(s[i+1:], ")\n")
+			if end < 0 || s[i+1+end] != ')' {
+				return 0
+			}
+			return i + 1 + end + 1
+		}
+	}
+	return 0
+}
+
+func segmentHTML(text string) []segment {
+	var segments []segment
+	var open []entity
+
+	for rest := text; rest != ""; {
+		n := graphemeLength(rest)
+
+		switch rest[0] {
+		case '<':
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				break
+			}
+			n = end + 1
+			tag := rest[:n]
+			fields := strings.FieldsFunc(tag, func(r rune) bool {
+				return r == '<' || r == '>' || r == '/' || r == ' ' || r == '\n'
+			})
+			if len(fields) == 0 {
+				break
+			}
+			name := strings.ToLower(fields[0])
+			switch {
+			case strings.HasPrefix(tag, "</"):
+				open = closeEntity(open, "</"+name+">")
+			case !strings.HasSuffix(tag, "/>"):
+				open = pushEntity(open, entity{close: "</" + name + ">", reopen: tag})
+			}
+		case '&':
+			if end := strings.IndexByte(rest, ';'); end > 1 && !strings.ContainsAny(rest[1:end], " \n<&") {
+				n = end + 1
+			}
+		}
+
+		segments = append(segments, segment{text: rest[:n], open: open})
+		rest = rest[n:]
+	}
+
+	return segments
+}
+
+// pushEntity returns a copy of the open entities with the entity added. Segments keep
+// the entities open after them, so the slices are never modified in place
+func pushEntity(open []entity, e entity) []entity {
+	return append(open[:len(open):len(open)], e)
+}
+
+// closeEntity returns a copy of the open entities without the last one closed by the closer
+func closeEntity(open []entity, closer string) []entity {
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i].close == closer {
+			closed := make([]entity, 0, len(open)-1)
+			return append(append(closed, open[:i]...), open[i+1:]...)
+		}
+	}
+	return open
+}
+
+// toggleEntity closes the entity of a MarkdownV2 marker if it's open or opens it otherwise
+func toggleEntity(open []entity, marker string) []entity {
+	if closed := closeEntity(open, marker); len(closed) != len(open) {
+		return closed
+	}
+	return pushEntity(open, entity{close: marker, reopen: marker})
+}
+
+// reopenEntities returns the markup opening the entities again
+func reopenEntities(open []entity) string {
+	var markup strings.Builder
+	for _, e := range open {
+		markup.WriteString(e.reopen)
+	}
+	return markup.String()
+}
+
+// closeEntities returns the markup closing the entities
+func closeEntities(open []entity) string {
+	var markup strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		markup.WriteString(open[i].close)
+	}
+	return markup.String()
+}
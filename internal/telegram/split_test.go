@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		limit     int
+		parseMode string
+		expected  []string
+	}{
+		{
+			name:     "text within the limit",
+			text:     "line 1\nline 2",
+			limit:    20,
+			expected: []string{"line 1\nline 2"},
+		},
+		{
+			name:     "splits at line breaks",
+			text:     "line 1\nline 2\nline 3",
+			limit:    14,
+			expected: []string{"line 1\nline 2\n", "line 3"},
+		},
+		{
+			name:     "cuts long lines between characters",
+			text:     "abcdefgh",
+			limit:    3,
+			expected: []string{"abc", "def", "gh"},
+		},
+		{
+			name:     "counts emojis as two code units",
+			text:     "🔥🔥🔥",
+			limit:    4,
+			expected: []string{"🔥🔥", "🔥"},
+		},
+		{
+			name:     "never cuts an emoji in half",
+			text:     "a🔥🔥",
+			limit:    2,
+			expected: []string{"a", "🔥", "🔥"},
+		},
+		{
+			name:     "keeps emoji sequences together",
+			text:     "👨‍👩‍👧👍🏽🇩🇪",
+			limit:    8,
+			expected: []string{"👨‍👩‍👧", "👍🏽🇩🇪"},
+		},
+		{
+			name:      "never cuts a MarkdownV2 escape",
+			text:      "ab\\.cd",
+			limit:     3,
+			parseMode: ParseModeMarkdownV2,
+			expected:  []string{"ab", "\\.c", "d"},
+		},
+		{
+			name:      "closes and reopens MarkdownV2 entities",
+			text:      "*bold text*",
+			limit:     7,
+			parseMode: ParseModeMarkdownV2,
+			expected:  []string{"*bold *", "*text*"},
+		},
+		{
+			name:      "closes and reopens MarkdownV2 pre blocks",
+			text:      "```go\na := 1\nb := 2\n```",
+			limit:     20,
+			parseMode: ParseModeMarkdownV2,
+			expected:  []string{"```go\na := 1\n```", "```go\nb := 2\n```"},
+		},
+		{
+			name:      "keeps MarkdownV2 links together",
+			text:      "see the [docs](https://example.com)",
+			limit:     30,
+			parseMode: ParseModeMarkdownV2,
+			expected:  []string{"see the ", "[docs](https://example.com)"},
+		},
+		{
+			name:      "closes and reopens Markdown entities",
+			text:      "*bold text*",
+			limit:     7,
+			parseMode: ParseModeMarkdown,
+			expected:  []string{"*bold *", "*text*"},
+		},
+		{
+			name:      "treats tildes as text in Markdown",
+			text:      "~a b~",
+			limit:     3,
+			parseMode: ParseModeMarkdown,
+			expected:  []string{"~a ", "b~"},
+		},
+		{
+			name:      "closes and reopens HTML tags",
+			text:      "<b>bold <i>text</i></b>",
+			limit:     20,
+			parseMode: ParseModeHTML,
+			expected:  []string{"<b>bold <i>t</i></b>", "<b><i>ext</i></b>"},
+		},
+		{
+			name:      "never cuts an HTML character reference",
+			text:      "a &amp; b",
+			limit:     5,
+			parseMode: ParseModeHTML,
+			expected:  []string{"a ", "&amp;", " b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitText(tt.text, tt.limit, tt.parseMode)
+			assert.Equal(t, tt.expected, chunks)
+			for _, chunk := range chunks {
+				assert.LessOrEqual(t, utf16Length(chunk), tt.limit)
+			}
+			if tt.parseMode == "" {
+				assert.Equal(t, tt.text, strings.Join(chunks, ""))
+			}
+		})
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{name: "ascii", text: "abc", expected: []string{"a", "b", "c"}},
+		{name: "combining marks", text: "e\u0301a", expected: []string{"e\u0301", "a"}},
+		{name: "variation selector", text: "⚠️!", expected: []string{"⚠️", "!"}},
+		{name: "skin tone", text: "👍🏽👍", expected: []string{"👍🏽", "👍"}},
+		{name: "zero width joiner sequence", text: "👨‍👩‍👧x", expected: []string{"👨‍👩‍👧", "x"}},
+		{name: "flags", text: "🇩🇪🇫🇷", expected: []string{"🇩🇪", "🇫🇷"}},
+		{name: "keycap", text: "1️⃣2", expected: []string{"1️⃣", "2"}},
+		{name: "crlf", text: "a\r\nb", expected: []string{"a", "\r\n", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, graphemes(tt.text))
+			assert.Equal(t, len(tt.expected), graphemeCount(tt.text))
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// TemplateData is the data available in message templates
+type TemplateData struct {
+	Title    string
+	Message  string
+	AppName  string
+	AppID    uint32
+	Priority uint32
+	Extras   map[string]interface{}
+	// ExtrasFlat contains the extras with nested keys joined by dots, sorted by key
+	ExtrasFlat []ExtraField
+	Date       time.Time
+}
+
+// ExtraField is a single extras value
+type ExtraField struct {
+	Key   string
+	Value interface{}
+}
+
+// flattenExtras returns the extras with nested keys joined by dots, sorted by key
+func flattenExtras(extras map[string]interface{}) []ExtraField {
+	var fields []ExtraField
+
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for key, value := range m {
+			if nested, ok := value.(map[string]interface{}); ok {
+				walk(prefix+key+".", nested)
+				continue
+			}
+			fields = append(fields, ExtraField{Key: prefix + key, Value: value})
+		}
+	}
+	walk("", extras)
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Key < fields[j].Key
+	})
+
+	return fields
+}
+
+// safeText is text with markup that is printed without escaping
+type safeText string
+
+// escapeFunc is appended to every action of a template so that printed values
+// are escaped for the parse mode
+const escapeFunc = "escape"
+
+// templateFuncs returns the functions available in message templates
+func templateFuncs(m markup, levels []config.PriorityLevel) template.FuncMap {
+	return template.FuncMap{
+		escapeFunc: func(v interface{}) safeText {
+			if safe, ok := v.(safeText); ok {
+				return safe
+			}
+			return safeText(m.escape(fmt.Sprint(v)))
+		},
+		// markup helpers. Their output is not escaped
+		"raw":      func(s string) safeText { return safeText(s) },
+		"body":     func(s string) safeText { return safeText(m.body(s)) },
+		"markdown": func(s string) safeText { return safeText(renderMarkdown(s, m)) },
+		"bold":     func(v interface{}) safeText { return safeText(m.bold(m.escape(fmt.Sprint(v)))) },
+		"code":     func(v interface{}) safeText { return safeText(m.code(fmt.Sprint(v))) },
+		"pre":      func(v interface{}) safeText { return safeText(m.pre(fmt.Sprint(v))) },
+		"spoiler":  func(v interface{}) safeText { return safeText(m.spoiler(m.escape(fmt.Sprint(v)))) },
+		// string helpers
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"truncate":   truncate,
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"regexFind":  regexFind,
+		"json":       toJSON,
+		"jsonIndent": toJSONIndent,
+		// number helpers
+		"humanizeBytes": humanizeBytes,
+		"duration":      formatDuration,
+		"priorityEmoji": func(v interface{}) (string, error) { return priorityEmoji(v, levels) },
+	}
+}
+
+// truncate shortens s to at most n characters, ending with an ellipsis if it was shortened.
+// Characters made of several code points like flags or emojis with skin tones are never split
+func truncate(n int, s string) string {
+	if n <= 0 {
+		return ""
+	}
+	if graphemeCount(s) <= n {
+		return s
+	}
+
+	return strings.Join(graphemes(s)[:n-1], "") + "…"
+}
+
+// regexFind returns the first match of the pattern in s
+func regexFind(pattern, s string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	return re.FindString(s), nil
+}
+
+// errNotANumber is returned by the number helpers for values that aren't numbers
+var errNotANumber = errors.New("is not a number")
+
+// toJSON encodes v as JSON
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// toJSONIndent encodes v as JSON indented with two spaces
+func toJSONIndent(v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// toFloat converts numbers and numeric strings of extras to float64
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case string:
+		var f float64
+		if _, err := fmt.Sscan(n, &f); err != nil {
+			return 0, fmt.Errorf("%q %w", n, errNotANumber)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("%v %w", v, errNotANumber)
+	}
+}
+
+// humanizeBytes formats a number of bytes such as 1.5 GiB
+func humanizeBytes(v interface{}) (string, error) {
+	bytes, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit]), nil
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit]), nil
+}
+
+// priorityEmoji returns the emoji of the priority indicator
+func priorityEmoji(v interface{}, levels []config.PriorityLevel) (string, error) {
+	priority, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+
+	emoji, _ := priorityLevel(int(priority), labels{}, levels)
+	return emoji, nil
+}
+
+// formatDuration formats a number of seconds such as 1h2m3s
+func formatDuration(v interface{}) (string, error) {
+	seconds, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String(), nil
+}
+
+// parseTemplate parses a message template for the markup
+func parseTemplate(text string, m markup, levels []config.PriorityLevel) (*template.Template, error) {
+	tmpl, err := template.New("message").Funcs(templateFuncs(m, levels)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			escapeActions(t.Tree, t.Tree.Root)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// escapeActions appends the escape function to the pipeline of every action that prints a value
+func escapeActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeActions(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	}
+}
+
+// templateData returns the template data of the message
+func templateData(msg api.Message) TemplateData {
+	return TemplateData{
+		Title:      msg.Title,
+		Message:    msg.Message,
+		AppName:    msg.AppName,
+		AppID:      msg.AppID,
+		Priority:   msg.Priority,
+		Extras:     msg.Extras,
+		ExtrasFlat: flattenExtras(msg.Extras),
+		Date:       msg.Date,
+	}
+}
+
+// renderPlainTemplate renders a template without markup or escaping, e.g. for URLs. Missing extras are errors
+func renderPlainTemplate(name, text string, msg api.Message) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs(plainMarkup{}, nil)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, templateData(msg)); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return strings.TrimSpace(builder.String()), nil
+}
+
+// CorrelationKey renders the correlation key template of the message. It is empty without a template
+// and for messages missing the extras the template uses
+func CorrelationKey(text string, msg api.Message) string {
+	if text == "" {
+		return ""
+	}
+
+	key, err := renderPlainTemplate("correlation_key", text, msg)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// renderTemplate renders the message with the template
+func renderTemplate(text string, msg api.Message, m markup, levels []config.PriorityLevel) (string, error) {
+	tmpl, err := parseTemplate(text, m, levels)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, templateData(msg)); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return builder.String(), nil
+}
+
+// sampleMessage is the message templates are validated with
+var sampleMessage = api.Message{
+	AppID:    1,
+	AppName:  "Sample app",
+	Title:    "Sample title",
+	Message:  "Sample message with a [link](https://example.com)",
+	Priority: 5,
+	Extras:   map[string]interface{}{"client::display": map[string]interface{}{"contentType": "text/markdown"}},
+	Date:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+// ValidateTemplate parses the template and renders it with a sample message. Errors of the number
+// helpers are ignored as the extras of the sample message don't match the extras of real messages
+func ValidateTemplate(text, parseMode string) error {
+	m, err := markupFor(parseMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := renderTemplate(text, sampleMessage, m, nil); err != nil && !errors.Is(err, errNotANumber) {
+		return err
+	}
+
+	return nil
+}
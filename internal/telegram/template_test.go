@@ -0,0 +1,308 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	msg := api.Message{
+		AppID:    3,
+		AppName:  "backup.sh",
+		Title:    "Backup finished",
+		Message:  "Saved to [storage](https://example.com/backups) in 5.2s",
+		Priority: 5,
+		Extras: map[string]interface{}{
+			"size":     float64(1610612736),
+			"duration": float64(3723),
+		},
+		Date: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name      string
+		template  string
+		parseMode string
+		expected  string
+		wantErr   string
+	}{
+		{
+			name:      "values are escaped for MarkdownV2",
+			template:  "{{ .AppName }}: {{ .Title }} \\({{ .Priority }}\\)",
+			parseMode: ParseModeMarkdownV2,
+			expected:  "backup\\.sh: Backup finished \\(5\\)",
+		},
+		{
+			name:      "values are escaped for HTML",
+			template:  "<b>{{ .Title }}</b> {{ .Message }}",
+			parseMode: ParseModeHTML,
+			expected:  "<b>Backup finished</b> Saved to [storage](https://example.com/backups) in 5.2s",
+		},
+		{
+			name:      "markup helpers are not escaped",
+			template:  "{{ bold .Title }}\n{{ .Message | body }}\n{{ code .AppName }}{{ raw \"*\" }}",
+			parseMode: ParseModeMarkdownV2,
+			expected:  "*Backup finished*\nSaved to [storage](https://example.com/backups) in 5\\.2s\n`backup.sh`*",
+		},
+		{
+			name:      "markdown helper",
+			template:  "{{ markdown \"**Done** in _5s_\" }}",
+			parseMode: ParseModeHTML,
+			expected:  "<b>Done</b> in <i>5s</i>",
+		},
+		{
+			name:      "string helpers",
+			template:  "{{ upper .Title }} {{ lower .AppName }} {{ truncate 8 .Title }} {{ replace \"finished\" \"done\" .Title }}",
+			parseMode: ParseModeHTML,
+			expected:  "BACKUP FINISHED backup.sh Backup … Backup done",
+		},
+		{
+			name:      "regexFind",
+			template:  `{{ regexFind "[0-9.]+s" .Message }}`,
+			parseMode: ParseModeHTML,
+			expected:  "5.2s",
+		},
+		{
+			name:      "number helpers",
+			template:  "{{ humanizeBytes .Extras.size }} in {{ duration .Extras.duration }}",
+			parseMode: ParseModeHTML,
+			expected:  "1.5 GiB in 1h2m3s",
+		},
+		{
+			name:      "json helpers",
+			template:  "{{ json .Extras }}\n{{ jsonIndent .Extras | pre }}",
+			parseMode: ParseModeHTML,
+			expected:  "{\"duration\":3723,\"size\":1610612736}\n<pre>{\n  \"duration\": 3723,\n  \"size\": 1610612736\n}</pre>",
+		},
+		{
+			name:      "priorityEmoji",
+			template:  "{{ priorityEmoji .Priority }} {{ priorityEmoji 9 }} {{ .Title }}",
+			parseMode: ParseModeMarkdownV2,
+			expected:  "🟡 🔴 Backup finished",
+		},
+		{
+			name:      "date",
+			template:  `{{ .Date.Format "2006-01-02" }}`,
+			parseMode: ParseModeMarkdownV2,
+			expected:  "2024\\-01\\-02",
+		},
+		{
+			name:      "variables are escaped when printed",
+			template:  "{{ $name := .AppName }}{{ $name }}",
+			parseMode: ParseModeMarkdownV2,
+			expected:  "backup\\.sh",
+		},
+		{
+			name:      "invalid template",
+			template:  "{{ .Title",
+			parseMode: ParseModeHTML,
+			wantErr:   "failed to parse template",
+		},
+		{
+			name:      "invalid argument",
+			template:  "{{ humanizeBytes .Title }}",
+			parseMode: ParseModeHTML,
+			wantErr:   "failed to execute template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := markupFor(tt.parseMode)
+			require.NoError(t, err)
+
+			result, err := renderTemplate(tt.template, msg, m, nil)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "", truncate(0, "hello"))
+	assert.Equal(t, "hello", truncate(5, "hello"))
+	assert.Equal(t, "hel…", truncate(4, "hello"))
+	assert.Equal(t, "🔥🔥…", truncate(3, "🔥🔥🔥🔥"))
+	assert.Equal(t, "👍🏽🇩🇪…", truncate(3, "👍🏽🇩🇪👨‍👩‍👧🇫🇷"))
+	assert.Equal(t, "👍🏽🇩🇪👨‍👩‍👧", truncate(3, "👍🏽🇩🇪👨‍👩‍👧"))
+}
+
+func TestHumanizeBytes(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{float64(512), "512 B"},
+		{1536, "1.5 KiB"},
+		{"1048576", "1.0 MiB"},
+		{uint64(5497558138880), "5.0 TiB"},
+	}
+
+	for _, tt := range tests {
+		result, err := humanizeBytes(tt.input)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, result)
+	}
+
+	_, err := humanizeBytes("lots")
+	assert.Error(t, err)
+}
+
+func TestFormatMessage_Template(t *testing.T) {
+	msg := api.Message{Title: "Disk full", Message: "/dev/sda1 at 99%", Priority: 8}
+
+	result, err := FormatMessage(msg, config.MessageFormatOptions{
+		ParseMode:     ParseModeMarkdownV2,
+		IncludeExtras: true,
+		Template:      "🚨 {{ bold .Title }}\n{{ .Message }}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "🚨 *Disk full*\n/dev/sda1 at 99%", result)
+}
+
+func TestRenderTemplate_Conditionals(t *testing.T) {
+	tmpl := `{{ if ge .Priority 8 }}🚨 {{ else if ge .Priority 5 }}⚠️ {{ end }}{{ bold .Title }}
+{{- if gt (len .Message) 20 }}
+{{ truncate 20 .Message }}
+{{- else }}
+{{ .Message }}
+{{- end }}
+{{- range .ExtrasFlat }}
+{{ .Key }}: {{ .Value }}
+{{- else }}
+no extras
+{{- end }}`
+
+	tests := []struct {
+		name     string
+		msg      api.Message
+		expected string
+	}{
+		{
+			name: "critical message with nested extras",
+			msg: api.Message{
+				Title:    "Disk full",
+				Message:  "/dev/sda1 is at 99% capacity",
+				Priority: 9,
+				Extras: map[string]interface{}{
+					"host": "nas-01",
+					"disk": map[string]interface{}{"used_pct": float64(99)},
+				},
+			},
+			expected: "🚨 *Disk full*\n/dev/sda1 is at 99%…\ndisk\\.used\\_pct: 99\nhost: nas\\-01",
+		},
+		{
+			name:     "medium priority message without extras",
+			msg:      api.Message{Title: "Backup", Message: "done", Priority: 5},
+			expected: "⚠️ *Backup*\ndone\nno extras",
+		},
+		{
+			name:     "low priority message",
+			msg:      api.Message{Title: "Ping", Message: "pong", Priority: 1},
+			expected: "*Ping*\npong\nno extras",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderTemplate(tmpl, tt.msg, markdownV2Markup{}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestFlattenExtras(t *testing.T) {
+	extras := map[string]interface{}{
+		"b": "2",
+		"a": map[string]interface{}{
+			"y": float64(1),
+			"x": map[string]interface{}{"z": true},
+		},
+	}
+
+	assert.Equal(t, []ExtraField{
+		{Key: "a.x.z", Value: true},
+		{Key: "a.y", Value: float64(1)},
+		{Key: "b", Value: "2"},
+	}, flattenExtras(extras))
+	assert.Empty(t, flattenExtras(nil))
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		parseMode string
+		wantError string
+	}{
+		{
+			name:      "valid template",
+			template:  "{{ bold .Title }} {{ .AppName | upper }} {{ range .ExtrasFlat }}{{ .Key }}{{ end }}",
+			parseMode: ParseModeHTML,
+		},
+		{
+			name:      "number helpers with extras missing from the sample message",
+			template:  "{{ humanizeBytes .Extras.size }}",
+			parseMode: ParseModeMarkdownV2,
+		},
+		{
+			name:      "syntax error",
+			template:  "{{ .Title ",
+			parseMode: ParseModeMarkdownV2,
+			wantError: "failed to parse template",
+		},
+		{
+			name:      "unknown function",
+			template:  "{{ shout .Title }}",
+			parseMode: ParseModeMarkdownV2,
+			wantError: `function "shout" not defined`,
+		},
+		{
+			name:      "unknown field",
+			template:  "{{ .Subject }}",
+			parseMode: ParseModeMarkdownV2,
+			wantError: "can't evaluate field Subject",
+		},
+		{
+			name:      "invalid regular expression",
+			template:  `{{ regexFind "(" .Message }}`,
+			parseMode: ParseModeMarkdownV2,
+			wantError: "failed to execute template",
+		},
+		{
+			name:      "unsupported parse mode",
+			template:  "{{ .Title }}",
+			parseMode: "BBCode",
+			wantError: "parse mode BBCode is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate(tt.template, tt.parseMode)
+			if tt.wantError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate_PriorityLevels(t *testing.T) {
+	levels := []config.PriorityLevel{{MinPriority: 0, Emoji: "ℹ️"}, {MinPriority: 7, Emoji: "🚨"}}
+
+	result, err := renderTemplate("{{ priorityEmoji .Priority }} {{ priorityEmoji 3 }}", api.Message{Priority: 9}, htmlMarkup{}, levels)
+	require.NoError(t, err)
+	assert.Equal(t, "🚨 ℹ️", result)
+}
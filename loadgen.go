@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+)
+
+// loadgenOptions configure the synthetic load of the loadgen subcommand
+type loadgenOptions struct {
+	// Messages generated per second
+	Rate float64
+	// How long messages are generated
+	Duration time.Duration
+	// Number of gotify apps the messages are spread over
+	Apps int
+	// Size of the message bodies in bytes
+	BodySize int
+	// Interval between two progress reports
+	ReportInterval time.Duration
+}
+
+// parseLoadgenFlags parses the command line flags of the loadgen subcommand
+func parseLoadgenFlags(args []string, output io.Writer) (loadgenOptions, error) {
+	opts := loadgenOptions{}
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Float64Var(&opts.Rate, "rate", 10, "messages generated per second")
+	flags.DurationVar(&opts.Duration, "duration", time.Minute, "how long messages are generated")
+	flags.IntVar(&opts.Apps, "apps", 3, "number of gotify apps the messages are spread over")
+	flags.IntVar(&opts.BodySize, "body-size", 256, "size of the message bodies in bytes")
+	flags.DurationVar(&opts.ReportInterval, "report-interval", 10*time.Second, "interval between two progress reports")
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	switch {
+	case opts.Rate <= 0:
+		return opts, errors.New("rate must be greater than 0")
+	case opts.Duration <= 0:
+		return opts, errors.New("duration must be greater than 0")
+	case opts.Apps <= 0:
+		return opts, errors.New("apps must be greater than 0")
+	case opts.BodySize < 0:
+		return opts, errors.New("body-size must not be negative")
+	case opts.ReportInterval <= 0:
+		return opts, errors.New("report-interval must be greater than 0")
+	}
+
+	return opts, nil
+}
+
+// syntheticMessage returns the n-th message of the synthetic load. Messages cycle through the apps and priorities
+func syntheticMessage(n uint32, opts loadgenOptions, now time.Time) api.Message {
+	appID := 1 + n%uint32(opts.Apps)
+	return api.Message{
+		Id:         n,
+		AppID:      appID,
+		AppName:    fmt.Sprintf("loadgen-%d", appID),
+		Title:      fmt.Sprintf("Synthetic message #%d", n),
+		Message:    strings.Repeat("x", opts.BodySize),
+		Priority:   n % 11,
+		Extras:     map[string]interface{}{"loadgen::sequence": n},
+		Date:       now,
+		ReceivedAt: now,
+	}
+}
+
+// loadgenReport is a snapshot of the pipeline under synthetic load
+type loadgenReport struct {
+	Elapsed    time.Duration
+	Generated  uint64
+	Received   uint64
+	Forwarded  uint64
+	Shed       uint64
+	Backlog    int
+	HeapAlloc  uint64
+	Goroutines int
+	LatencyP50 time.Duration
+	LatencyP95 time.Duration
+}
+
+func (r loadgenReport) String() string {
+	rate := 0.0
+	if r.Elapsed > 0 {
+		rate = float64(r.Forwarded) / r.Elapsed.Seconds()
+	}
+
+	return fmt.Sprintf("elapsed=%s generated=%d received=%d forwarded=%d (%.1f/s) shed=%d backlog=%d "+
+		"heap=%.1fMiB goroutines=%d latency_p50=%s latency_p95=%s",
+		r.Elapsed.Round(time.Second), r.Generated, r.Received, r.Forwarded, rate, r.Shed, r.Backlog,
+		float64(r.HeapAlloc)/(1<<20), r.Goroutines, r.LatencyP50, r.LatencyP95)
+}
+
+// loadgenReport returns a snapshot of the pipeline
+func (p *Plugin) loadgenReport(started time.Time, generated uint64) loadgenReport {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var received uint64
+	for _, app := range p.stats.Apps() {
+		received += app.Received
+	}
+
+	latency := p.stats.Latency()
+	return loadgenReport{
+		Elapsed:    time.Since(started),
+		Generated:  generated,
+		Received:   received,
+		Forwarded:  p.stats.Forwarded(),
+		Shed:       p.stats.Shed(),
+		Backlog:    p.backlog(),
+		HeapAlloc:  mem.HeapAlloc,
+		Goroutines: runtime.NumGoroutine(),
+		LatencyP50: latency.Quantile(0.5),
+		LatencyP95: latency.Quantile(0.95),
+	}
+}
+
+// runLoadgen feeds synthetic messages into the message pipeline at the configured rate, bypassing the
+// websocket, and writes a progress report every report interval. Generating blocks while the message
+// queue is full like the websocket reader does. Returns the final report
+func (p *Plugin) runLoadgen(ctx context.Context, opts loadgenOptions, out io.Writer) loadgenReport {
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	started := time.Now()
+	var generated uint64
+
+	// the reporter is stopped before the final report is written, so that writes to out don't overlap
+	reporterDone := make(chan struct{})
+	go func() {
+		defer close(reporterDone)
+		ticker := time.NewTicker(opts.ReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fmt.Fprintln(out, p.loadgenReport(started, atomic.LoadUint64(&generated)))
+			}
+		}
+	}()
+
+	interval := time.Duration(float64(time.Second) / opts.Rate)
+	next := started
+	for n := uint32(1); ctx.Err() == nil; n++ {
+		select {
+		case <-ctx.Done():
+			continue
+		case p.messages <- syntheticMessage(n, opts, time.Now()):
+			atomic.AddUint64(&generated, 1)
+		}
+
+		// messages are scheduled from the start time so that slow sends don't lower the rate
+		next = next.Add(interval)
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+	}
+
+	<-reporterDone
+	report := p.loadgenReport(started, atomic.LoadUint64(&generated))
+	fmt.Fprintln(out, report)
+	return report
+}
+
+// loadgen is the loadgen subcommand. It runs the plugin with the configuration of the env vars and
+// generates synthetic load until the duration elapsed
+func loadgen(args []string, out io.Writer) error {
+	opts, err := parseLoadgenFlags(args, out)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p := NewGotifyPluginInstance(standaloneUserContext).(*Plugin)
+	defer p.cancel()
+
+	fmt.Fprintf(out, "generating %.1f messages per second for %s\n", opts.Rate, opts.Duration)
+	go p.processMessages()
+	p.runLoadgen(p.ctx, opts, out)
+
+	return nil
+}
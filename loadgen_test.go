@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLoadgenFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected loadgenOptions
+		err      string
+	}{
+		{
+			name:     "defaults",
+			expected: loadgenOptions{Rate: 10, Duration: time.Minute, Apps: 3, BodySize: 256, ReportInterval: 10 * time.Second},
+		},
+		{
+			name:     "flags",
+			args:     []string{"-rate", "50", "-duration", "10m", "-apps", "5", "-body-size", "1024", "-report-interval", "1s"},
+			expected: loadgenOptions{Rate: 50, Duration: 10 * time.Minute, Apps: 5, BodySize: 1024, ReportInterval: time.Second},
+		},
+		{name: "zero rate", args: []string{"-rate", "0"}, err: "rate must be greater than 0"},
+		{name: "no apps", args: []string{"-apps", "0"}, err: "apps must be greater than 0"},
+		{name: "negative body size", args: []string{"-body-size", "-1"}, err: "body-size must not be negative"},
+		{name: "unknown flag", args: []string{"-burst", "5"}, err: "flag provided but not defined: -burst"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseLoadgenFlags(tt.args, io.Discard)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+func TestSyntheticMessage(t *testing.T) {
+	now := time.Now()
+	opts := loadgenOptions{Apps: 3, BodySize: 8}
+
+	msg := syntheticMessage(13, opts, now)
+	assert.Equal(t, uint32(13), msg.Id)
+	assert.Equal(t, uint32(2), msg.AppID)
+	assert.Equal(t, "loadgen-2", msg.AppName)
+	assert.Equal(t, "Synthetic message #13", msg.Title)
+	assert.Equal(t, "xxxxxxxx", msg.Message)
+	assert.Equal(t, uint32(2), msg.Priority)
+	assert.Equal(t, now, msg.ReceivedAt)
+}
+
+func TestPlugin_runLoadgen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the pipeline keeps running until the context is cancelled after the test
+	logger := zerolog.Nop()
+	errChan := make(chan error, 100)
+	p := &Plugin{
+		ctx:      ctx,
+		logger:   &logger,
+		config:   config.DefaultConfig(),
+		messages: make(chan api.Message, 10),
+		errChan:  errChan,
+		stats:    stats.NewTracker(),
+		// sends fail right away without a bot token
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: errChan, Logger: &logger}),
+	}
+	p.setStore(storage.NewMemory())
+	go p.processMessages()
+
+	var out bytes.Buffer
+	report := p.runLoadgen(ctx, loadgenOptions{
+		Rate:           200,
+		Duration:       500 * time.Millisecond,
+		Apps:           2,
+		ReportInterval: 200 * time.Millisecond,
+	}, &out)
+
+	assert.InDelta(t, 100, report.Generated, 30)
+	assert.LessOrEqual(t, report.Received, report.Generated)
+	assert.Greater(t, report.Received, uint64(0))
+	assert.Contains(t, out.String(), "generated=")
+	assert.GreaterOrEqual(t, bytes.Count(out.Bytes(), []byte("\n")), 2)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// shedDigestInterval is how often the digest of shed messages is sent to the admin chats
+const shedDigestInterval = time.Minute
+
+// loadShedder tracks the send backlog and the messages that were shed
+type loadShedder struct {
+	// inFlight is the number of queued sends and sends that have not completed yet
+	inFlight int64
+
+	mu sync.Mutex
+	// pending counts the shed messages per app name that are not part of a digest yet
+	pending map[string]int
+}
+
+// add records a shed message for the next digest
+func (l *loadShedder) add(msg api.Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending == nil {
+		l.pending = make(map[string]int)
+	}
+
+	name := msg.AppName
+	if name == "" {
+		name = fmt.Sprintf("app %d", msg.AppID)
+	}
+	l.pending[name]++
+}
+
+// digest returns a summary of the shed messages since the last digest and resets them. The reason describes
+// which messages were shed. Returns an empty string if no messages were shed
+func (l *loadShedder) digest(reason string) string {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	if len(pending) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(pending))
+	total := 0
+	for name, count := range pending {
+		names = append(names, name)
+		total += count
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if pending[names[i]] != pending[names[j]] {
+			return pending[names[i]] > pending[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	apps := make([]string, len(names))
+	for i, name := range names {
+		apps[i] = fmt.Sprintf("%s (%d)", name, pending[name])
+	}
+
+	return fmt.Sprintf("⚠️ gotify-to-telegram shed %d messages %s during a backlog: %s",
+		total, reason, strings.Join(apps, ", "))
+}
+
+// backlog returns the number of messages waiting to be handled and queued or in flight sends
+func (p *Plugin) backlog() int {
+	return len(p.messages) + int(atomic.LoadInt64(&p.shedder.inFlight))
+}
+
+// sheddingReason describes the messages shed by the load shedding policy
+func sheddingReason(shedding config.LoadShedding) string {
+	switch shedding.Policy {
+	case config.LoadSheddingLowestPriority:
+		return "with the lowest priority"
+	case config.LoadSheddingOldest:
+		return "that were queued the longest"
+	default:
+		return fmt.Sprintf("with a priority below %d", shedding.PriorityFloor)
+	}
+}
+
+// shouldShed returns true if the message must not be forwarded because the backlog is too deep and the
+// priority floor policy sheds it
+func (p *Plugin) shouldShed(msg api.Message) bool {
+	if p.config == nil {
+		return false
+	}
+
+	shedding := p.config.Settings.Telegram.LoadShedding
+	if shedding.MaxBacklog <= 0 || int(msg.Priority) >= shedding.PriorityFloor ||
+		(shedding.Policy != "" && shedding.Policy != config.LoadSheddingPriorityFloor) {
+		return false
+	}
+
+	return p.backlog() > shedding.MaxBacklog
+}
+
+// shed records a message that is not forwarded because of load shedding
+func (p *Plugin) shed(msg api.Message) {
+	p.logger.Warn().
+		Uint32("app_id", msg.AppID).
+		Uint32("priority", msg.Priority).
+		Int("backlog", p.backlog()).
+		Msg("backlog too deep. Shedding message")
+
+	if p.stats != nil {
+		p.stats.RecordShed(msg)
+	}
+
+	if p.config.Settings.Telegram.LoadShedding.Mode == config.LoadSheddingDigest {
+		p.shedder.add(msg)
+	}
+}
+
+// runShedDigest periodically sends the digest of shed messages to the admin chats until the context is done
+func (p *Plugin) runShedDigest(ctx context.Context) {
+	ticker := time.NewTicker(shedDigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if digest := p.shedder.digest(sheddingReason(p.config.Settings.Telegram.LoadShedding)); digest != "" {
+				p.notifyAdmin(digest)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedder_digest(t *testing.T) {
+	var shedder loadShedder
+	assert.Equal(t, "", shedder.digest("with a priority below 5"))
+
+	shedder.add(api.Message{AppID: 1, AppName: "sonarr"})
+	shedder.add(api.Message{AppID: 2, AppName: "backups"})
+	shedder.add(api.Message{AppID: 2, AppName: "backups"})
+	shedder.add(api.Message{AppID: 3})
+
+	assert.Equal(t,
+		"⚠️ gotify-to-telegram shed 4 messages with a priority below 5 during a backlog: backups (2), app 3 (1), sonarr (1)",
+		shedder.digest("with a priority below 5"))
+	assert.Equal(t, "", shedder.digest("with a priority below 5"))
+}
+
+func TestPlugin_shouldShed(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger:   &logger,
+		stats:    stats.NewTracker(),
+		messages: make(chan api.Message, 10),
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{
+					LoadShedding: config.LoadShedding{MaxBacklog: 2, PriorityFloor: 5, Mode: config.LoadSheddingDigest},
+				},
+			},
+		},
+	}
+
+	low := api.Message{AppID: 1, AppName: "sonarr", Priority: 2}
+	critical := api.Message{AppID: 2, AppName: "alerts", Priority: 8}
+
+	assert.False(t, p.shouldShed(low))
+
+	// two queued messages and one send in flight exceed the max backlog of 2
+	p.messages <- low
+	p.messages <- low
+	p.shedder.inFlight = 1
+	assert.True(t, p.shouldShed(low))
+	assert.False(t, p.shouldShed(critical))
+
+	p.shed(low)
+	assert.Equal(t, uint64(1), p.stats.Shed())
+	assert.Contains(t, p.shedder.digest("with a priority below 5"), "sonarr (1)")
+
+	// shed messages are only counted in drop mode
+	p.config.Settings.Telegram.LoadShedding.Mode = config.LoadSheddingDrop
+	p.shed(low)
+	assert.Equal(t, uint64(2), p.stats.Shed())
+	assert.Equal(t, "", p.shedder.digest("with a priority below 5"))
+
+	p.config.Settings.Telegram.LoadShedding.MaxBacklog = 0
+	assert.False(t, p.shouldShed(low))
+}
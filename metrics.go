@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/gin-gonic/gin"
+)
+
+// metricsNamespace prefixes the names of all metrics
+const metricsNamespace = "gotify_to_telegram"
+
+// handleMetrics serves the plugin metrics in the Prometheus text exposition format
+func (p *Plugin) handleMetrics(c *gin.Context) {
+	if p.stats == nil {
+		c.String(http.StatusServiceUnavailable, "statistics are not initialized\n")
+		return
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(renderMetrics(p.stats)))
+}
+
+// renderMetrics renders the statistics in the Prometheus text exposition format
+func renderMetrics(tracker *stats.Tracker) string {
+	var builder strings.Builder
+
+	writeMetricHeader(&builder, "messages_forwarded_total", "counter", "Messages sent to Telegram chats.")
+	fmt.Fprintf(&builder, "%s_messages_forwarded_total %d\n", metricsNamespace, tracker.Forwarded())
+
+	writeMetricHeader(&builder, "messages_shed_total", "counter", "Messages dropped or digested because of load shedding.")
+	fmt.Fprintf(&builder, "%s_messages_shed_total %d\n", metricsNamespace, tracker.Shed())
+
+	writeHistogram(&builder, "delivery_latency_seconds",
+		"Time from receiving a message from the Gotify websocket to sending it to a Telegram chat.", tracker.Latency())
+	writeHistogram(&builder, "delivery_age_seconds",
+		"Time from creating a message in Gotify to sending it to a Telegram chat.", tracker.Age())
+
+	return builder.String()
+}
+
+// writeMetricHeader writes the HELP and TYPE lines of a metric
+func writeMetricHeader(builder *strings.Builder, name, metricType, help string) {
+	fmt.Fprintf(builder, "# HELP %s_%s %s\n", metricsNamespace, name, help)
+	fmt.Fprintf(builder, "# TYPE %s_%s %s\n", metricsNamespace, name, metricType)
+}
+
+// writeHistogram writes the buckets, sum and count of a histogram
+func writeHistogram(builder *strings.Builder, name, help string, histogram stats.HistogramSnapshot) {
+	writeMetricHeader(builder, name, "histogram", help)
+	for i, upper := range histogram.Buckets {
+		fmt.Fprintf(builder, "%s_%s_bucket{le=%q} %d\n",
+			metricsNamespace, name, strconv.FormatFloat(upper, 'g', -1, 64), histogram.Cumulative[i])
+	}
+	fmt.Fprintf(builder, "%s_%s_bucket{le=\"+Inf\"} %d\n", metricsNamespace, name, histogram.Count)
+	fmt.Fprintf(builder, "%s_%s_sum %s\n", metricsNamespace, name, strconv.FormatFloat(histogram.Sum, 'g', -1, 64))
+	fmt.Fprintf(builder, "%s_%s_count %d\n", metricsNamespace, name, histogram.Count)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlugin_handleMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger, stats: stats.NewTracker()}
+	p.RegisterWebhook("/plugin/1/custom/token/", router.Group("/plugin/1/custom/token/"))
+
+	p.stats.RecordForwarded(api.Message{AppID: 1, ReceivedAt: time.Now()}, "123")
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/plugin/1/custom/token/metrics", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Header().Get("Content-Type"), "text/plain")
+
+	body := res.Body.String()
+	assert.Contains(t, body, "# TYPE gotify_to_telegram_messages_forwarded_total counter\n")
+	assert.Contains(t, body, "gotify_to_telegram_messages_forwarded_total 1\n")
+	assert.Contains(t, body, "# TYPE gotify_to_telegram_delivery_latency_seconds histogram\n")
+	assert.Contains(t, body, "gotify_to_telegram_delivery_latency_seconds_bucket{le=\"0.05\"} 1\n")
+	assert.Contains(t, body, "gotify_to_telegram_delivery_latency_seconds_bucket{le=\"+Inf\"} 1\n")
+	assert.Contains(t, body, "gotify_to_telegram_delivery_latency_seconds_count 1\n")
+	assert.Contains(t, body, "gotify_to_telegram_delivery_age_seconds_count 0\n")
+}
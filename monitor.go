@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventReconnectStorm is emitted when the gotify websocket reconnects too often
+const eventReconnectStorm metaEvent = "reconnect_storm"
+
+// reconnectMonitor detects reconnect storms of the gotify websocket connection
+type reconnectMonitor struct {
+	mu         sync.Mutex
+	reconnects []time.Time
+	lastAlert  time.Time
+}
+
+// record records a reconnect and returns the number of reconnects within the window and
+// whether an alert should be sent. At most one alert is sent per window.
+func (m *reconnectMonitor) record(now time.Time, threshold int, window time.Duration) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reconnects = append(m.reconnects, now)
+
+	// Drop reconnects that fell out of the window
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(m.reconnects) && m.reconnects[i].Before(cutoff) {
+		i++
+	}
+	m.reconnects = m.reconnects[i:]
+
+	count := len(m.reconnects)
+	if threshold <= 0 || count <= threshold || now.Sub(m.lastAlert) < window {
+		return count, false
+	}
+
+	m.lastAlert = now
+	return count, true
+}
+
+// handleReconnect is called by the api client every time the websocket connection is re-established
+func (p *Plugin) handleReconnect() {
+	if p.config == nil {
+		return
+	}
+
+	wsOpts := p.config.Settings.GotifyServer.Websocket
+	window := time.Duration(wsOpts.ReconnectAlertWindowMinutes) * time.Minute
+
+	count, storm := p.reconnects.record(time.Now(), wsOpts.ReconnectAlertThreshold, window)
+	p.logger.Warn().
+		Int("reconnects", count).
+		Dur("window", window).
+		Msg("reconnected to gotify server")
+
+	if !storm {
+		return
+	}
+
+	text := fmt.Sprintf(
+		"⚠️ gotify-to-telegram reconnected to the gotify server %d times in the last %d minutes. "+
+			"This usually means a reverse proxy is timing out the websocket connection.",
+		count, wsOpts.ReconnectAlertWindowMinutes,
+	)
+	p.notifyAdmin(text)
+	p.notifyGotify(eventReconnectStorm, "Gotify websocket reconnect storm", text)
+}
+
+// heartbeatText returns the text of a heartbeat message
+func heartbeatText(forwarded uint64, interval time.Duration) string {
+	return fmt.Sprintf("💓 gotify-to-telegram bridge alive, forwarded %d messages in the last %s", forwarded, humanizeInterval(interval))
+}
+
+// humanizeInterval formats whole hours and minutes such as 24h or 90m
+func humanizeInterval(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
+
+// runHeartbeat periodically sends a heartbeat message with the number of forwarded messages
+// to the heartbeat chats until the context is cancelled
+func (p *Plugin) runHeartbeat(ctx context.Context) {
+	heartbeat := p.config.Settings.Telegram.Heartbeat
+	interval := time.Duration(heartbeat.IntervalMinutes) * time.Minute
+	chatIDs := heartbeat.ChatIDs
+	if len(chatIDs) == 0 {
+		chatIDs = p.config.Settings.Telegram.GetAdminChatIDs()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastForwarded := p.stats.Forwarded()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			forwarded := p.stats.Forwarded()
+			text := heartbeatText(forwarded-lastForwarded, interval)
+			lastForwarded = forwarded
+
+			for _, chatID := range chatIDs {
+				if err := p.tgclient.SendText(p.config.Settings.Telegram.DefaultBotToken, chatID, text); err != nil {
+					p.logger.Error().
+						Err(err).
+						Str("chat_id", chatID).
+						Msg("failed to send heartbeat message")
+				}
+			}
+		}
+	}
+}
+
+// eventNoTraffic is emitted when no gotify messages were received for too long
+const eventNoTraffic metaEvent = "no_traffic"
+
+// trafficWatchdog detects silently broken gotify streams
+type trafficWatchdog struct {
+	mu           sync.Mutex
+	lastReceived time.Time
+	alerted      bool
+}
+
+// received records that a gotify message was received
+func (w *trafficWatchdog) received(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastReceived = now
+	w.alerted = false
+}
+
+// check returns how long no messages were received and whether an alert should be sent.
+// Only one alert is sent per silent period.
+func (w *trafficWatchdog) check(now time.Time, connected bool, timeout time.Duration) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lastReceived.IsZero() {
+		w.lastReceived = now
+	}
+
+	silence := now.Sub(w.lastReceived)
+	if !connected || timeout <= 0 || w.alerted || silence < timeout {
+		return silence, false
+	}
+
+	w.alerted = true
+	return silence, true
+}
+
+// runWatchdog periodically checks that gotify messages are received while the connection is healthy
+func (p *Plugin) runWatchdog(ctx context.Context) {
+	timeout := time.Duration(p.config.Settings.GotifyServer.Websocket.NoTrafficAlertMinutes) * time.Minute
+
+	interval := timeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			silence, alert := p.watchdog.check(time.Now(), p.apiclient.IsConnected(), timeout)
+			if !alert {
+				continue
+			}
+
+			p.logger.Warn().
+				Dur("silence", silence).
+				Msg("no messages received from gotify server while connected")
+
+			text := fmt.Sprintf(
+				"⚠️ gotify-to-telegram has not received any gotify messages for %s although the websocket "+
+					"connection is up. The stream may be silently broken, e.g. by a reverse proxy.",
+				humanizeInterval(silence.Truncate(time.Minute)),
+			)
+			p.notifyAdmin(text)
+			p.notifyGotify(eventNoTraffic, "No gotify traffic", text)
+		}
+	}
+}
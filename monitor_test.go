@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnectMonitor_record(t *testing.T) {
+	var m reconnectMonitor
+	start := time.Now()
+	window := 10 * time.Minute
+
+	for i := 0; i < 3; i++ {
+		count, storm := m.record(start.Add(time.Duration(i)*time.Minute), 3, window)
+		assert.Equal(t, i+1, count)
+		assert.False(t, storm)
+	}
+
+	// Fourth reconnect within the window exceeds the threshold
+	count, storm := m.record(start.Add(3*time.Minute), 3, window)
+	assert.Equal(t, 4, count)
+	assert.True(t, storm)
+
+	// Only one alert per window
+	_, storm = m.record(start.Add(4*time.Minute), 3, window)
+	assert.False(t, storm)
+
+	// Old reconnects fall out of the window
+	count, storm = m.record(start.Add(30*time.Minute), 3, window)
+	assert.Equal(t, 1, count)
+	assert.False(t, storm)
+
+	// A threshold of 0 disables the alert
+	var disabled reconnectMonitor
+	for i := 0; i < 10; i++ {
+		_, storm := disabled.record(start, 0, window)
+		assert.False(t, storm)
+	}
+}
+
+func TestHeartbeatText(t *testing.T) {
+	assert.Equal(t,
+		"💓 gotify-to-telegram bridge alive, forwarded 42 messages in the last 24h",
+		heartbeatText(42, 24*time.Hour))
+	assert.Equal(t,
+		"💓 gotify-to-telegram bridge alive, forwarded 0 messages in the last 90m",
+		heartbeatText(0, 90*time.Minute))
+}
+
+func TestTrafficWatchdog_check(t *testing.T) {
+	var w trafficWatchdog
+	start := time.Now()
+	timeout := 30 * time.Minute
+
+	w.received(start)
+
+	_, alert := w.check(start.Add(10*time.Minute), true, timeout)
+	assert.False(t, alert)
+
+	// No alert while disconnected
+	_, alert = w.check(start.Add(40*time.Minute), false, timeout)
+	assert.False(t, alert)
+
+	silence, alert := w.check(start.Add(40*time.Minute), true, timeout)
+	assert.True(t, alert)
+	assert.Equal(t, 40*time.Minute, silence)
+
+	// Only one alert per silent period
+	_, alert = w.check(start.Add(50*time.Minute), true, timeout)
+	assert.False(t, alert)
+
+	// A new message resets the watchdog
+	w.received(start.Add(60 * time.Minute))
+	_, alert = w.check(start.Add(95*time.Minute), true, timeout)
+	assert.True(t, alert)
+}
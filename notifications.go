@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/gotify/plugin-api"
+)
+
+// metaEvent identifies an operational event of the plugin
+type metaEvent string
+
+const (
+	// eventTelegramAuthFailure is emitted when the Telegram API rejects a bot token
+	eventTelegramAuthFailure metaEvent = "telegram_auth_failure"
+)
+
+// metaExtrasKey marks messages posted by the plugin itself
+const metaExtrasKey = "gotify-to-telegram::meta"
+
+// isMetaNotification returns true for notifications posted by the plugin itself. They come back over the
+// websocket and must not be forwarded, e.g. a failing bot token would otherwise notify about itself in a loop
+func isMetaNotification(msg api.Message) bool {
+	_, ok := msg.Extras[metaExtrasKey]
+	return ok
+}
+
+// notifyGotify posts an operational event of the plugin into gotify using the message handler.
+// Notifications for the same event are rate limited by the configured min interval.
+func (p *Plugin) notifyGotify(event metaEvent, title string, message string) {
+	if p.msgHandler == nil || p.config == nil || !p.config.Settings.MetaNotifications.Enabled {
+		return
+	}
+
+	opts := p.config.Settings.MetaNotifications
+	minInterval := time.Duration(opts.MinIntervalSeconds) * time.Second
+
+	p.mu.Lock()
+	if p.lastMetaNotification == nil {
+		p.lastMetaNotification = make(map[metaEvent]time.Time)
+	}
+	if last, ok := p.lastMetaNotification[event]; ok && time.Since(last) < minInterval {
+		p.mu.Unlock()
+		p.logger.Debug().
+			Str("event", string(event)).
+			Msg("skipping gotify notification. Notified recently")
+		return
+	}
+	p.lastMetaNotification[event] = time.Now()
+	p.mu.Unlock()
+
+	err := p.msgHandler.SendMessage(plugin.Message{
+		Title:    title,
+		Message:  message,
+		Priority: opts.Priority,
+		Extras: map[string]interface{}{
+			metaExtrasKey: map[string]interface{}{
+				"event": string(event),
+			},
+		},
+	})
+	if err != nil {
+		p.logger.Error().
+			Err(err).
+			Str("event", string(event)).
+			Msg("failed to post notification to gotify")
+	}
+}
+
+// errorEntry describes an error received from the clients for the recent errors panel
+func errorEntry(err error) stats.ErrorEntry {
+	entry := stats.ErrorEntry{
+		Component: "gotify",
+		Message:   err.Error(),
+	}
+
+	var sendErr *telegram.SendError
+	if errors.As(err, &sendErr) {
+		entry.Component = "telegram"
+		entry.Message = errorText(err, sendErr.Token)
+		entry.ChatID = sendErr.ChatID
+		entry.AppID = sendErr.AppID
+		entry.AppName = sendErr.AppName
+	}
+
+	return entry
+}
+
+// handleError logs errors received from the clients and notifies gotify about operational events
+func (p *Plugin) handleError(err error) {
+	// messages that weren't sent because the circuit breaker is open are still queued. They are neither
+	// errors of the chat nor released for a resend
+	if errors.Is(err, telegram.ErrCircuitOpen) {
+		return
+	}
+
+	p.logger.Error().Err(err).Msg("error received")
+
+	if p.stats != nil {
+		p.stats.RecordError(errorEntry(err))
+	}
+
+	var sendErr *telegram.SendError
+	if errors.As(err, &sendErr) {
+		p.deliveries.release(deliveryKey(sendErr.MessageID, sendErr.ChatID))
+		p.handleTokenAuthError(sendErr)
+		// chats with a backup bot token left fail over to it instead of being marked unhealthy
+		if !p.handleFailover(sendErr) {
+			p.handleSendError(sendErr)
+		}
+	}
+
+	var apiErr *telegram.APIError
+	if errors.As(err, &apiErr) && apiErr.IsAuthError() {
+		p.notifyGotify(
+			eventTelegramAuthFailure,
+			"Telegram bot authentication failed",
+			fmt.Sprintf("The Telegram API rejected a bot token (status %d): %s. Check the bot tokens in the plugin config.",
+				apiErr.StatusCode, apiErr.Description),
+		)
+	}
+}
+
+// notifyAdmin sends a plain text notification to the admin chats using the default bot
+func (p *Plugin) notifyAdmin(text string) {
+	if p.config == nil || p.tgclient == nil {
+		return
+	}
+
+	token := p.config.Settings.Telegram.DefaultBotToken
+	for _, chatID := range p.config.Settings.Telegram.GetAdminChatIDs() {
+		if p.chatHealth.isUnhealthy(chatID) {
+			continue
+		}
+		if err := p.tgclient.SendText(token, chatID, text); err != nil {
+			p.logger.Error().
+				Err(err).
+				Str("chat_id", chatID).
+				Msg("failed to send notification to admin chat")
+		}
+	}
+}
+
+// notifyLifecycle notifies the admin chats that the plugin started or is shutting down
+func (p *Plugin) notifyLifecycle(started bool) {
+	if p.config == nil || !p.config.Settings.Telegram.LifecycleNotifications {
+		return
+	}
+
+	if started {
+		p.notifyAdmin(fmt.Sprintf("gotify-to-telegram started (%s, %d routes)", Version, len(p.config.Settings.Telegram.Bots)))
+		return
+	}
+
+	p.notifyAdmin(fmt.Sprintf("gotify-to-telegram %s shutting down", Version))
+}
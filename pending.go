@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+)
+
+// pendingBucket is the storage bucket of the sends that are queued or being sent if the queue is persisted
+const pendingBucket = "pending_sends"
+
+// pendingSend is a persisted send. The bot token and format options are taken from the route of the app
+// again when it is restored, so they follow configuration changes made in the meantime
+type pendingSend struct {
+	Message api.Message `json:"message"`
+	ChatID  string      `json:"chat_id"`
+}
+
+// savePending persists the job if the queue is persisted and returns its key. It returns an empty key if
+// the job is not persisted
+func (p *Plugin) savePending(job sendJob) string {
+	if p.pending == nil || p.config == nil || !p.config.Settings.Telegram.PersistQueue {
+		return ""
+	}
+
+	data, err := json.Marshal(pendingSend{Message: job.msg, ChatID: job.chatID})
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to encode pending send")
+		return ""
+	}
+
+	key, err := p.pending.Add(data)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to save pending send")
+		return ""
+	}
+
+	return key
+}
+
+// removePending removes the persisted job once it was sent or shed
+func (p *Plugin) removePending(job sendJob) {
+	if p.pending == nil || job.key == "" {
+		return
+	}
+
+	if err := p.pending.Remove(job.key); err != nil {
+		p.logger.Error().Err(err).Msg("failed to remove pending send")
+	}
+}
+
+// restorePendingSends queues the sends persisted before the plugin was restarted. Messages that were being
+// sent when the plugin stopped may be sent again
+func (p *Plugin) restorePendingSends() {
+	if p.pending == nil || p.config == nil {
+		return
+	}
+
+	entries, err := p.pending.Entries()
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to load pending sends")
+		return
+	}
+
+	for _, entry := range entries {
+		var pending pendingSend
+		if err := json.Unmarshal(entry.Value, &pending); err != nil {
+			p.logger.Error().Err(err).Str("key", entry.Key).Msg("failed to decode pending send. Dropping it")
+			p.removePending(sendJob{key: entry.Key})
+			continue
+		}
+
+		job := p.routeJob(pending.Message, pending.ChatID)
+		job.key = entry.Key
+		atomic.AddInt64(&p.shedder.inFlight, 1)
+		p.sendQueue.push(job)
+	}
+
+	if len(entries) > 0 {
+		p.logger.Info().Int("count", len(entries)).Msg("restored pending sends")
+	}
+}
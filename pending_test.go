@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_pendingSends(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	store := storage.NewMemory()
+	cfg := &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+		DefaultBotToken: "new-token",
+		DefaultChatIDs:  []string{"123"},
+		MessageFormatOptions: config.MessageFormatOptions{
+			ParseMode: "HTML",
+		},
+	}}}
+
+	p := &Plugin{logger: &logger, config: cfg}
+	p.setStore(store)
+	p.send(api.Message{Id: 1}, "old-token", "123", config.MessageFormatOptions{})
+	entries, err := p.pending.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the queue is not persisted by default")
+
+	cfg.Settings.Telegram.PersistQueue = true
+	p.send(api.Message{Id: 2, Title: "disk full"}, "old-token", "123", config.MessageFormatOptions{})
+	p.send(api.Message{Id: 3}, "old-token", "456", config.MessageFormatOptions{})
+	entries, err = p.pending.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// a restarted plugin sends the persisted messages with the current route
+	restarted := &Plugin{logger: &logger, config: cfg}
+	restarted.setStore(store)
+	restarted.restorePendingSends()
+	assert.Equal(t, 2, restarted.backlog())
+
+	job, ok := restarted.sendQueue.pop(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, uint32(2), job.msg.Id)
+	assert.Equal(t, "disk full", job.msg.Title)
+	assert.Equal(t, "new-token", job.token)
+	assert.Equal(t, "123", job.chatID)
+	assert.Equal(t, "HTML", job.formatOpts.ParseMode)
+
+	restarted.removePending(job)
+	entries, err = restarted.pending.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	var pending pendingSend
+	require.NoError(t, json.Unmarshal(entries[0].Value, &pending))
+	assert.Equal(t, uint32(3), pending.Message.Id)
+	assert.Equal(t, "456", pending.ChatID)
+}
+
+func TestPlugin_restorePendingSends_Invalid(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger, config: &config.Plugin{}}
+	p.setStore(storage.NewMemory())
+	_, err := p.pending.Add([]byte("invalid"))
+	require.NoError(t, err)
+
+	p.restorePendingSends()
+	assert.Zero(t, p.backlog())
+	entries, err := p.pending.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, entries, "invalid pending sends are dropped")
+}
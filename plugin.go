@@ -2,17 +2,24 @@ package main
 
 import (
 	"context"
-	"embed"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/chaos"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/logger"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/utils"
 	"github.com/gotify/plugin-api"
@@ -20,11 +27,7 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	//go:embed README.md
-	content embed.FS
-	Version string = "dev"
-)
+var Version string = "dev"
 
 // GetGotifyPluginInfo returns gotify plugin info.
 func GetGotifyPluginInfo() plugin.Info {
@@ -52,6 +55,51 @@ type Plugin struct {
 	config     *config.Plugin
 	messages   chan api.Message
 	errChan    chan error
+	// mu guards appIDsByName and lastMetaNotification
+	mu sync.RWMutex
+	// appIDsByName maps lowercased gotify app names to their app ids
+	appIDsByName map[string]uint32
+	// webhookBasePath is the base path of the plugin's webhook routes
+	webhookBasePath string
+	// logCloser closes the log file when logging to a file
+	logCloser io.Closer
+	// lastMetaNotification tracks when gotify was last notified about an event
+	lastMetaNotification map[metaEvent]time.Time
+	// reconnects tracks reconnects of the gotify websocket connection
+	reconnects reconnectMonitor
+	// stats tracks statistics about forwarded messages
+	stats *stats.Tracker
+	// watchdog tracks when the last gotify message was received
+	watchdog trafficWatchdog
+	// store persists data of the plugin. Backed by the gotify storage handler when available
+	store storage.Store
+	// chatHealth tracks chats that messages are no longer sent to
+	chatHealth chatHealth
+	// deliveries remembers delivered messages so that replays are not posted twice
+	deliveries deliveryLog
+	// replies remembers the threads of the apps in the chats that their messages reply to
+	replies replyThreads
+	// tokens rotates between the bot tokens of routes with several tokens
+	tokens tokenPool
+	// failover tracks the chats that failed over to a backup bot token
+	failover tokenFailover
+	// shedder tracks the send backlog and the messages shed because of it
+	shedder loadShedder
+	// sendQueue buffers the messages waiting for a send worker
+	sendQueue sendQueue
+	// history keeps the recently received messages for replays
+	history *storage.Queue
+	// pending keeps the queued sends if the queue is persisted
+	pending *storage.Queue
+	// deadLetters keeps the messages that failed to send
+	deadLetters *storage.Queue
+	// restorePending queues the persisted sends once
+	restorePending sync.Once
+	// configuredBots are the bots of the plugin config. They are restored when the
+	// routes set through the routing webhook are deleted
+	configuredBots map[string]config.TelegramBot
+	// routesOverridden is true while the routes set through the routing webhook are used
+	routesOverridden bool
 }
 
 // Enable enables the plugin.
@@ -59,6 +107,7 @@ func (p *Plugin) Enable() error {
 	p.enabled = true
 	p.logger.Info().Msg("enabling plugin and starting services")
 	go p.Start()
+	go p.notifyLifecycle(true)
 	return nil
 }
 
@@ -66,18 +115,103 @@ func (p *Plugin) Enable() error {
 func (p *Plugin) Disable() error {
 	p.enabled = false
 	p.logger.Debug().Msg("disabling plugin")
+	p.notifyLifecycle(false)
+	if p.config != nil {
+		p.drain(time.Duration(p.config.Settings.Telegram.ShutdownDrainSeconds) * time.Second)
+	}
 	p.cancel()
+	p.saveStats()
+	p.flushStore()
 
 	return nil
 }
 
+// resolveAppNames maps the gotify app names used in the bot configs to app ids.
+// It is invoked every time the api client refreshes the list of applications.
+func (p *Plugin) resolveAppNames(apps []api.Application) {
+	appIDsByName := make(map[string]uint32, len(apps))
+	for _, app := range apps {
+		appIDsByName[strings.ToLower(app.Name)] = app.ID
+	}
+
+	p.mu.Lock()
+	p.appIDsByName = appIDsByName
+	p.mu.Unlock()
+
+	if p.config == nil {
+		return
+	}
+
+	for _, warning := range p.config.Settings.Telegram.LintApps(appIDsByName) {
+		p.logger.Warn().Msg(warning)
+	}
+}
+
+// configWarnings returns the non-fatal warnings about the routes. Warnings that need the
+// applications of the gotify server are only returned after they were fetched
+func (p *Plugin) configWarnings() []string {
+	if p.config == nil {
+		return nil
+	}
+
+	warnings := p.config.Settings.Telegram.Lint()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.appIDsByName != nil {
+		warnings = append(warnings, p.config.Settings.Telegram.LintApps(p.appIDsByName)...)
+	}
+
+	return warnings
+}
+
+// hasAppNameRoutes returns true if any bot routes messages by gotify app name
+func (p *Plugin) hasAppNameRoutes() bool {
+	if p.config == nil {
+		return false
+	}
+
+	for _, bot := range p.config.Settings.Telegram.Bots {
+		if len(bot.AppNames) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refreshApplications fetches the gotify applications so that app names can be resolved to app ids
+func (p *Plugin) refreshApplications() {
+	if _, err := p.apiclient.RefreshApplications(); err != nil {
+		p.errChan <- fmt.Errorf("failed to resolve gotify app names: %w", err)
+	}
+}
+
+// botMatchesAppID returns true if the bot is configured to handle messages for the app id
+func (p *Plugin) botMatchesAppID(bot config.TelegramBot, appID uint32) bool {
+	for _, appid := range bot.AppIDs {
+		if appid == appID {
+			return true
+		}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, appName := range bot.AppNames {
+		if id, ok := p.appIDsByName[strings.ToLower(appName)]; ok && id == appID {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *Plugin) getTelegramBotConfigForAppID(appID uint32) config.TelegramBot {
 	if p.config != nil {
 		for _, bot := range p.config.Settings.Telegram.Bots {
-			for _, appid := range bot.AppIDs {
-				if appid == appID {
-					return bot
-				}
+			if p.botMatchesAppID(bot, appID) {
+				return bot
 			}
 		}
 	}
@@ -87,18 +221,40 @@ func (p *Plugin) getTelegramBotConfigForAppID(appID uint32) config.TelegramBot {
 		Uint32("app_id", appID).
 		Msgf("no rule found for app_id: %d. Using default config", appID)
 	return config.TelegramBot{
-		Token:   p.config.Settings.Telegram.DefaultBotToken,
-		ChatIDs: p.config.Settings.Telegram.DefaultChatIDs,
+		Token:          p.config.Settings.Telegram.DefaultBotToken,
+		BackupTokens:   p.config.Settings.Telegram.DefaultBackupTokens,
+		ChatIDs:        p.config.Settings.Telegram.DefaultChatIDs,
+		FallbackChatID: p.config.Settings.Telegram.DefaultFallbackChatID,
+		ScrubPII:       p.config.Settings.Telegram.DefaultScrubPII,
 	}
 }
 
+// formatOptionsForChat returns the format options with the language of the chat
+func (p *Plugin) formatOptionsForChat(formatOpts config.MessageFormatOptions, chatID string) config.MessageFormatOptions {
+	formatOpts.Language = p.config.Settings.Telegram.LanguageForChat(chatID, formatOpts.Language)
+	return formatOpts
+}
+
 func (p *Plugin) handleMessage(msg api.Message) {
 	p.logger.Debug().
 		Str("app_name", msg.AppName).
 		Uint32("app_id", msg.AppID).
 		Msg("handling message")
 
+	if isMetaNotification(msg) {
+		p.logger.Debug().
+			Uint32("message_id", msg.Id).
+			Msg("skipping notification posted by the plugin")
+		return
+	}
+
+	if p.shouldShed(msg) {
+		p.shed(msg)
+		return
+	}
+
 	config := p.getTelegramBotConfigForAppID(msg.AppID)
+	config.MessageFormatOptions = config.FormatOptionsForApp(msg.AppID, msg.AppName)
 	if config.MessageFormatOptions == nil {
 		config.MessageFormatOptions = &p.config.Settings.Telegram.MessageFormatOptions
 	}
@@ -108,8 +264,24 @@ func (p *Plugin) handleMessage(msg api.Message) {
 		Strs("chat_id", config.ChatIDs).
 		Msg("using telegram config")
 
+	msg = p.redact(msg, config)
+
 	for _, chatID := range config.ChatIDs {
-		go p.tgclient.Send(msg, config.Token, chatID, *config.MessageFormatOptions)
+		if p.chatHealth.isUnhealthy(chatID) {
+			p.logger.Warn().
+				Str("chat_id", chatID).
+				Msg("skipping unhealthy chat")
+			p.sendToFallback(msg, chatID, "chat is unhealthy")
+			continue
+		}
+		if !p.claimDelivery(msg, chatID) {
+			p.logger.Debug().
+				Uint32("message_id", msg.Id).
+				Str("chat_id", chatID).
+				Msg("skipping message already delivered to chat")
+			continue
+		}
+		p.send(msg, p.tokens.pick(config.GetTokens()), chatID, p.formatOptionsForChat(*config.MessageFormatOptions, chatID))
 	}
 }
 
@@ -117,13 +289,52 @@ func (p *Plugin) handleMessage(msg api.Message) {
 func (p *Plugin) Start() error {
 	p.logger.Info().Msg("starting plugin services")
 
+	if p.stats == nil {
+		p.stats = stats.NewTracker()
+	}
+
 	if p.apiclient == nil {
 		p.errChan <- errors.New("api client is not initialized")
 	} else {
 		p.logger.Debug().Msg("starting api client")
 		go p.apiclient.Start()
+
+		if p.hasAppNameRoutes() {
+			go p.refreshApplications()
+		}
 	}
 
+	go p.runStatsSaver(p.ctx)
+	go p.runDeliveryPruner(p.ctx)
+
+	if p.config != nil && p.config.Settings.Telegram.LoadShedding.MaxBacklog > 0 {
+		go p.runShedDigest(p.ctx)
+	}
+
+	if p.config != nil && p.config.Settings.Telegram.Heartbeat.Enabled {
+		go p.runHeartbeat(p.ctx)
+	}
+
+	if p.apiclient != nil && p.config != nil && p.config.Settings.GotifyServer.Websocket.NoTrafficAlertMinutes > 0 {
+		go p.runWatchdog(p.ctx)
+	}
+
+	if p.apiclient != nil && p.config != nil && p.config.Settings.Telegram.DeletionSync.Mode != "" {
+		go p.runDeletionSync(p.ctx)
+	}
+
+	if p.config != nil && p.config.Settings.Telegram.DeadLetter.Enabled {
+		go p.runDeadLetterRetry(p.ctx)
+	}
+
+	return p.processMessages()
+}
+
+// processMessages handles the messages and errors received from the clients until the context is done
+func (p *Plugin) processMessages() error {
+	p.restorePending.Do(p.restorePendingSends)
+	p.startSendWorkers(p.ctx)
+
 	for {
 		select {
 		case <-p.ctx.Done():
@@ -132,10 +343,13 @@ func (p *Plugin) Start() error {
 
 		case err := <-p.errChan:
 			if err != nil {
-				p.logger.Error().Err(err).Msg("error received")
+				p.handleError(err)
 			}
 
 		case msg := <-p.messages:
+			p.watchdog.received(time.Now())
+			p.stats.RecordReceived(msg)
+			p.recordHistory(msg)
 			p.logger.Debug().
 				Interface("message", msg).
 				Msg("message received from gotify server")
@@ -153,13 +367,13 @@ func (p *Plugin) SetMessageHandler(handler plugin.MessageHandler) {
 // GetDisplay implements plugin.Displayer
 // Invoked when the user views the plugin settings. Plugins do not need to be enabled to handle GetDisplay calls.
 func (p *Plugin) GetDisplay(location *url.URL) string {
-	readme, err := content.ReadFile("README.md")
+	status, err := p.renderStatus(location, time.Now())
 	if err != nil {
-		p.logger.Error().Err(err).Msg("failed to read README.md")
+		p.logger.Error().Err(err).Msg("failed to render status page")
 		return "Gotify to Telegram plugin - forwards Gotify messages to Telegram bots based on configurable routing rules."
 	}
 
-	return string(readme)
+	return status
 }
 
 // DefaultConfig implements plugin.Configurer
@@ -169,13 +383,104 @@ func (p *Plugin) DefaultConfig() interface{} {
 	return config.DefaultConfig()
 }
 
+// envScope returns the env vars visible to the plugin instance. Admin users can use the
+// unscoped env vars while all users can use env vars scoped to their user id
+func (p *Plugin) envScope() config.EnvScope {
+	return config.EnvScope{
+		UserID: p.userCtx.ID,
+		Global: p.userCtx.Admin,
+	}
+}
+
 // Configure loads and updates the plugin configuration
 func (p *Plugin) Configure(cfg *config.Plugin) error {
-	newCfg, err := config.Load(cfg)
+	newCfg, err := config.LoadWithScope(cfg, p.envScope())
 	if err != nil {
 		return err
 	}
+	if err := validateTemplates(newCfg.Settings.Telegram); err != nil {
+		return err
+	}
+	if _, err := logger.FilePath(newCfg.Settings.LogOptions.Output, p.envScope().LogDir()); err != nil {
+		return fmt.Errorf("settings.log_options.output is invalid: %w", err)
+	}
+	if path, ok := p.envScope().ConfigFile(); ok {
+		p.logger.Warn().
+			Str("config_file", path).
+			Msg("config file is set. Ignoring config from the gotify UI")
+	}
 	p.config = newCfg
+	p.configuredBots = newCfg.Settings.Telegram.Bots
+	p.routesOverridden = false
+	p.applyStoredRouting()
+	// the new config may fix chats that blocked the bot, e.g. after re-adding the bot
+	p.chatHealth.reset()
+	p.tokens.reset()
+	p.failover.reset()
+
+	for _, warning := range p.config.Settings.Telegram.Lint() {
+		p.logger.Warn().Msg(warning)
+	}
+	return nil
+}
+
+// validateTemplates renders the message templates, headers and footers of all format options with a sample message
+// so that broken templates are rejected when the config is saved rather than when messages are sent
+func validateTemplates(t config.Telegram) error {
+	validate := func(path string, opts *config.MessageFormatOptions) error {
+		if opts == nil {
+			return nil
+		}
+		fields := [][2]string{
+			{"template", opts.Template},
+			{"header", opts.Header},
+			{"footer", opts.Footer},
+			{"correlation_key", opts.CorrelationKey},
+		}
+		for i, button := range opts.Buttons {
+			fields = append(fields, [2]string{fmt.Sprintf("buttons[%d].url", i), button.URL})
+		}
+		for _, field := range fields {
+			if field[1] == "" {
+				continue
+			}
+			if err := telegram.ValidateTemplate(field[1], opts.ParseMode); err != nil {
+				return fmt.Errorf("%s.%s: %w", path, field[0], err)
+			}
+		}
+		return nil
+	}
+
+	if err := validate("settings.telegram.default_message_format_options", &t.MessageFormatOptions); err != nil {
+		return err
+	}
+
+	botNames := make([]string, 0, len(t.Bots))
+	for name := range t.Bots {
+		botNames = append(botNames, name)
+	}
+	sort.Strings(botNames)
+
+	for _, name := range botNames {
+		bot := t.Bots[name]
+		if err := validate("settings.telegram.bots."+name+".message_format_options", bot.MessageFormatOptions); err != nil {
+			return err
+		}
+
+		apps := make([]string, 0, len(bot.AppMessageFormatOptions))
+		for app := range bot.AppMessageFormatOptions {
+			apps = append(apps, app)
+		}
+		sort.Strings(apps)
+
+		for _, app := range apps {
+			path := "settings.telegram.bots." + name + ".app_message_format_options." + app
+			if err := validate(path, bot.AppMessageFormatOptions[app]); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -198,8 +503,9 @@ func (p *Plugin) ValidateAndSetConfig(newConfig interface{}) error {
 		p.cancel()
 	}
 
-	logger.UpdateLogLevel(p.config.Settings.LogOptions.GetZerologLevel())
-	p.logger = logger.Get()
+	if err := p.updateLogger(); err != nil {
+		return err
+	}
 
 	p.logger.Debug().Msg("creating new context")
 	ctx, cancel := context.WithCancel(context.Background())
@@ -224,13 +530,37 @@ func (p *Plugin) ValidateAndSetConfig(newConfig interface{}) error {
 	return nil
 }
 
+// updateLogger recreates the plugin logger with the configured log options
+func (p *Plugin) updateLogger() error {
+	logOpts := p.config.Settings.LogOptions
+	w, closer, err := logger.Writer(logOpts.Format, logOpts.Output, p.envScope().LogDir())
+	if err != nil {
+		return err
+	}
+
+	if p.logCloser != nil {
+		if err := p.logCloser.Close(); err != nil {
+			p.logger.Warn().Err(err).Msg("failed to close log file")
+		}
+	}
+
+	p.logCloser = closer
+	p.logger = logger.New("gotify-to-telegram", Version, p.userCtx, logOpts.GetZerologLevel(), w)
+
+	return nil
+}
+
 func (p *Plugin) updateAPIConfig(ctx context.Context) error {
 	apiConfig := api.Config{
-		Url:              p.config.Settings.GotifyServer.Url,
-		ClientToken:      p.config.Settings.GotifyServer.ClientToken,
-		HandshakeTimeout: p.config.Settings.GotifyServer.Websocket.HandshakeTimeout,
-		Messages:         p.messages,
-		ErrChan:          p.errChan,
+		Url:                   p.config.Settings.GotifyServer.Url,
+		ClientToken:           p.config.Settings.GotifyServer.ClientToken,
+		HandshakeTimeout:      p.config.Settings.GotifyServer.Websocket.HandshakeTimeout,
+		Messages:              p.messages,
+		ErrChan:               p.errChan,
+		Logger:                p.logger,
+		OnApplicationsRefresh: p.resolveAppNames,
+		OnReconnect:           p.handleReconnect,
+		Chaos:                 p.newChaosInjector(),
 	}
 
 	p.logger.Debug().Msg("creating api client with new config")
@@ -241,59 +571,202 @@ func (p *Plugin) updateAPIConfig(ctx context.Context) error {
 
 func (p *Plugin) updateTelegramConfig() error {
 	p.logger.Debug().Msg("updating telegram client")
-	p.tgclient = telegram.NewClient(p.errChan)
+	p.tgclient = p.newTelegramClient()
 	return nil
 }
 
+// privateHosts returns the allowed download hosts that may resolve to private addresses. The gotify
+// server always may, the other allowed hosts only if private networks are allowed
+func privateHosts(gotifyHost string, allowedHosts []string, allowPrivateNetworks bool) []string {
+	if allowPrivateNetworks {
+		return allowedHosts
+	}
+	return []string{gotifyHost}
+}
+
+func (p *Plugin) newTelegramClient() *telegram.Client {
+	var (
+		timeout     time.Duration
+		retry       telegram.RetryPolicy
+		rateLimit   telegram.RateLimitPolicy
+		breaker     telegram.CircuitBreakerPolicy
+		attachments telegram.AttachmentPolicy
+		images      telegram.ImagePolicy
+		footer      = telegram.Footer{Version: Version}
+		gotifyURL   *url.URL
+		proxy       *url.URL
+	)
+	if p.config != nil {
+		settings := p.config.Settings.Telegram
+		timeout = time.Duration(settings.RequestTimeoutSeconds) * time.Second
+		retry = telegram.RetryPolicy{
+			NetworkRetries:     settings.Retry.NetworkRetries,
+			ServerErrorRetries: settings.Retry.ServerErrorRetries,
+			RateLimitRetries:   settings.Retry.RateLimitRetries,
+			InitialBackoff:     time.Duration(settings.Retry.InitialBackoffMs) * time.Millisecond,
+			MaxBackoff:         time.Duration(settings.Retry.MaxBackoffSeconds) * time.Second,
+			Jitter:             float64(settings.Retry.JitterPercent) / 100,
+		}
+		if settings.RateLimit.Enabled {
+			rateLimit = telegram.RateLimitPolicy{
+				ChatPerSecond:  float64(settings.RateLimit.ChatMessagesPerSecond),
+				GroupPerMinute: float64(settings.RateLimit.GroupMessagesPerMinute),
+			}
+		}
+		breaker = telegram.CircuitBreakerPolicy{
+			FailureThreshold: settings.CircuitBreaker.FailureThreshold,
+			OpenDuration:     time.Duration(settings.CircuitBreaker.OpenSeconds) * time.Second,
+		}
+		gotifyHost := p.config.Settings.GotifyServer.URL().Host
+		if settings.Attachments.Enabled {
+			allowedHosts := append([]string{gotifyHost}, settings.Attachments.AllowedHosts...)
+			attachments = telegram.AttachmentPolicy{
+				AllowedHosts: allowedHosts,
+				PrivateHosts: privateHosts(gotifyHost, allowedHosts, settings.Attachments.AllowPrivateNetworks),
+				MaxSize:      int64(settings.Attachments.MaxSizeMB) << 20,
+			}
+		}
+		if settings.Images.Downscale {
+			allowedHosts := append([]string{gotifyHost}, settings.Images.AllowedHosts...)
+			images = telegram.ImagePolicy{
+				MaxDimension: settings.Images.MaxDimension,
+				JPEGQuality:  settings.Images.JPEGQuality,
+				AllowedHosts: allowedHosts,
+				PrivateHosts: privateHosts(gotifyHost, allowedHosts, settings.Images.AllowPrivateNetworks),
+			}
+		}
+		footer.Hostname = settings.Footer.Hostname
+		footer.Environment = settings.Footer.Environment
+		gotifyURL = p.config.Settings.GotifyServer.WebUIURL()
+		var err error
+		if proxy, err = settings.Proxy.ProxyURL(); err != nil {
+			p.logger.Error().Err(err).Msg("ignoring invalid telegram proxy")
+		}
+	}
+	if footer.Hostname == "" {
+		footer.Hostname, _ = os.Hostname()
+	}
+
+	return telegram.NewClient(telegram.Config{
+		ErrChan:        p.errChan,
+		Logger:         p.logger,
+		OnSent:         p.recordForwarded,
+		RequestTimeout: timeout,
+		Retry:          retry,
+		RateLimit:      rateLimit,
+		CircuitBreaker: breaker,
+		Attachments:    attachments,
+		Images:         images,
+		Footer:         footer,
+		GotifyURL:      gotifyURL,
+		FetchAppImage:  p.fetchAppImage,
+		Chaos:          p.newChaosInjector(),
+		Proxy:          proxy,
+	})
+}
+
+// fetchAppImage downloads the image of an app from the gotify server for the telegram client
+func (p *Plugin) fetchAppImage(path string, maxSize int64) ([]byte, error) {
+	if p.apiclient == nil {
+		return nil, errors.New("gotify client is not configured")
+	}
+	return p.apiclient.AppImage(path, maxSize)
+}
+
+// newChaosInjector returns the fault injector of the chaos settings or nil if chaos testing is disabled
+func (p *Plugin) newChaosInjector() *chaos.Injector {
+	if p.config == nil || !p.config.Settings.Chaos.Enabled {
+		return nil
+	}
+
+	p.logger.Warn().Msg("chaos testing is enabled. Telegram errors, websocket drops and slow reads are injected")
+	return chaos.New(p.config.Settings.Chaos)
+}
+
+// recordForwarded is called by the telegram client every time a message was sent to a chat
+func (p *Plugin) recordForwarded(msg api.Message, sent telegram.SentMessage) {
+	p.chatHealth.recordSuccess(sent.ChatID)
+	p.failover.recordSuccess(sent.Token, sent.ChatID)
+	p.completeDelivery(msg, sent.ChatID)
+	p.recordSent(msg, sent)
+	p.recordCorrelation(sent)
+	p.recordReplyRoot(msg, sent)
+	if p.stats != nil {
+		p.stats.RecordForwarded(msg, sent.ChatID)
+	}
+}
+
 // NewGotifyPluginInstance creates a plugin instance for a user context.
+// Every instance has its own logger, channels and clients so that instances
+// of different gotify users never share state.
 func NewGotifyPluginInstance(userCtx plugin.UserContext) plugin.Plugin {
 	ctx, cancel := context.WithCancel(context.Background())
-	log := logger.Init("gotify-to-telegram", Version, userCtx)
+	log := logger.New("gotify-to-telegram", Version, userCtx, zerolog.InfoLevel, zerolog.ConsoleWriter{Out: os.Stdout})
 
 	messages := make(chan api.Message, 100)
 	errChan := make(chan error, 100)
 
+	p := &Plugin{
+		userCtx:  userCtx,
+		ctx:      ctx,
+		cancel:   cancel,
+		messages: messages,
+		errChan:  errChan,
+	}
+
 	cfg := config.DefaultConfig()
-	cfg, err := config.Load(cfg)
+	cfg, err := config.LoadWithScope(cfg, p.envScope())
 	if err != nil {
 		log.Error().Err(err).Msg("failed to parse env vars. Using defaults")
 		cfg = config.DefaultConfig()
 	}
 
-	logLevel := cfg.Settings.LogOptions.GetZerologLevel()
-	logger.UpdateLogLevel(logLevel)
+	p.config = cfg
+	p.configuredBots = cfg.Settings.Telegram.Bots
+	p.logger = log
+	if err := p.updateLogger(); err != nil {
+		log.Error().Err(err).Msg("failed to create logger from log options. Using defaults")
+		p.logger = logger.WithLevel(log, cfg.Settings.LogOptions.GetZerologLevel())
+	}
+
+	p.logger.Info().Msg("creating new plugin instance")
+
+	p.stats = stats.NewTracker()
+	p.setStore(storage.NewMemory())
+	p.tgclient = p.newTelegramClient()
 
 	apiConfig := api.Config{
-		Url:              cfg.Settings.GotifyServer.Url,
-		ClientToken:      cfg.Settings.GotifyServer.ClientToken,
-		HandshakeTimeout: cfg.Settings.GotifyServer.Websocket.HandshakeTimeout,
-		Messages:         messages,
-		ErrChan:          errChan,
+		Url:                   cfg.Settings.GotifyServer.Url,
+		ClientToken:           cfg.Settings.GotifyServer.ClientToken,
+		HandshakeTimeout:      cfg.Settings.GotifyServer.Websocket.HandshakeTimeout,
+		Messages:              messages,
+		ErrChan:               errChan,
+		Logger:                p.logger,
+		OnApplicationsRefresh: p.resolveAppNames,
+		OnReconnect:           p.handleReconnect,
 	}
-	apiclient := api.NewClient(ctx, apiConfig)
-	tgclient := telegram.NewClient(errChan)
+	p.apiclient = api.NewClient(ctx, apiConfig)
 
-	log.Info().Msg("creating new plugin instance")
+	return p
+}
 
-	return &Plugin{
-		userCtx:   userCtx,
-		ctx:       ctx,
-		cancel:    cancel,
-		config:    cfg,
-		logger:    log,
-		apiclient: apiclient,
-		tgclient:  tgclient,
-		messages:  messages,
-		errChan:   errChan,
-	}
+// standaloneUserContext is the user of the plugin when it runs outside of gotify
+var standaloneUserContext = plugin.UserContext{
+	ID:    1,
+	Name:  "0xPeterSatoshi",
+	Admin: true,
 }
 
 func main() {
-	ctx := plugin.UserContext{
-		ID:    1,
-		Name:  "0xPeterSatoshi",
-		Admin: true,
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		if err := loadgen(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
 	}
+
+	ctx := standaloneUserContext
 	p := NewGotifyPluginInstance(ctx)
 	if err := p.Enable(); err != nil {
 		panic(err)
@@ -1,13 +1,27 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
 	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/gin-gonic/gin"
 	"github.com/gotify/plugin-api"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Mock structs
@@ -21,12 +35,16 @@ type MockTelegram struct {
 
 func TestAPICompatibility(t *testing.T) {
 	assert.Implements(t, (*plugin.Plugin)(nil), new(Plugin))
+	assert.Implements(t, (*plugin.Webhooker)(nil), new(Plugin))
+	assert.Implements(t, (*plugin.Messenger)(nil), new(Plugin))
+	assert.Implements(t, (*plugin.Storager)(nil), new(Plugin))
 	// Add other interfaces you intend to implement here
 }
 
 func TestPluginStruct_ValidateAndSetConfig(t *testing.T) {
 	tests := []struct {
 		name       string
+		userCtx    plugin.UserContext
 		userConfig interface{}
 		envVars    map[string]string
 		verify     func(*testing.T, *Plugin, error)
@@ -58,6 +76,31 @@ func TestPluginStruct_ValidateAndSetConfig(t *testing.T) {
 				assert.Equal(t, []string{"111", "222"}, p.config.Settings.Telegram.DefaultChatIDs)
 			},
 		},
+		{
+			name:    "should ignore unscoped env vars for non-admin users",
+			userCtx: plugin.UserContext{ID: 2, Admin: false},
+			userConfig: &config.Plugin{
+				Settings: config.Settings{
+					Telegram: config.Telegram{
+						DefaultBotToken: "user-token",
+						DefaultChatIDs:  []string{"123"},
+					},
+					GotifyServer: config.GotifyServer{
+						RawUrl:      "http://example.com",
+						ClientToken: "client-token",
+					},
+				},
+			},
+			envVars: map[string]string{
+				"TG_PLUGIN__TELEGRAM_DEFAULT_BOT_TOKEN":        "env-token",
+				"TG_PLUGIN__USER_2__TELEGRAM_DEFAULT_CHAT_IDS": "222",
+			},
+			verify: func(t *testing.T, p *Plugin, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "user-token", p.config.Settings.Telegram.DefaultBotToken)
+				assert.Equal(t, []string{"222"}, p.config.Settings.Telegram.DefaultChatIDs)
+			},
+		},
 		{
 			name:       "should fail to validate and set invalid config type",
 			userConfig: &struct{}{},
@@ -95,9 +138,14 @@ func TestPluginStruct_ValidateAndSetConfig(t *testing.T) {
 
 			// Create plugin instance
 			logger := zerolog.New(zerolog.NewTestWriter(t))
+			userCtx := tt.userCtx
+			if userCtx.ID == 0 {
+				userCtx = plugin.UserContext{ID: 1, Admin: true}
+			}
 			p := &Plugin{
 				logger:  &logger,
 				enabled: false,
+				userCtx: userCtx,
 			}
 
 			// Call ValidateAndSetConfig
@@ -172,6 +220,32 @@ func TestPlugin_Configure(t *testing.T) {
 				assert.Equal(t, "http://env.com", p.config.Settings.GotifyServer.RawUrl)
 			},
 		},
+		{
+			name: "should error for invalid message templates",
+			config: &config.Plugin{
+				Settings: config.Settings{
+					IgnoreEnvVars: true,
+					Telegram: config.Telegram{
+						DefaultBotToken: "test-token",
+						DefaultChatIDs:  []string{"123"},
+						Bots: map[string]config.TelegramBot{
+							"backups": {
+								Token:   "bot-token",
+								ChatIDs: []string{"456"},
+								AppMessageFormatOptions: map[string]*config.MessageFormatOptions{
+									"restic": {ParseMode: "HTML", Template: "{{ .Subject }}"},
+								},
+							},
+						},
+					},
+					GotifyServer: config.GotifyServer{
+						RawUrl:      "http://example.com",
+						ClientToken: "client-token",
+					},
+				},
+			},
+			wantError: true,
+		},
 		{
 			name: "should error for invalid config - missing required fields",
 			config: &config.Plugin{
@@ -184,6 +258,93 @@ func TestPlugin_Configure(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "should accept log files in the log directory for admins",
+			config: &config.Plugin{
+				Settings: config.Settings{
+					IgnoreEnvVars: true,
+					LogOptions: config.LogOptions{
+						LogLevel: "info",
+						Output:   "plugin.log",
+					},
+					Telegram: config.Telegram{
+						DefaultBotToken: "test-token",
+						DefaultChatIDs:  []string{"123"},
+					},
+					GotifyServer: config.GotifyServer{
+						RawUrl:      "http://example.com",
+						ClientToken: "client-token",
+					},
+				},
+			},
+			wantError: false,
+		},
+		{
+			name: "should error for log files outside the log directory",
+			config: &config.Plugin{
+				Settings: config.Settings{
+					IgnoreEnvVars: true,
+					LogOptions: config.LogOptions{
+						LogLevel: "info",
+						Output:   "../../etc/cron.d/job",
+					},
+					Telegram: config.Telegram{
+						DefaultBotToken: "test-token",
+						DefaultChatIDs:  []string{"123"},
+					},
+					GotifyServer: config.GotifyServer{
+						RawUrl:      "http://example.com",
+						ClientToken: "client-token",
+					},
+				},
+			},
+			wantError: true,
+		},
+		{
+			name: "should error for absolute log file paths",
+			config: &config.Plugin{
+				Settings: config.Settings{
+					IgnoreEnvVars: true,
+					LogOptions: config.LogOptions{
+						LogLevel: "info",
+						Output:   "/etc/cron.d/job",
+					},
+					Telegram: config.Telegram{
+						DefaultBotToken: "test-token",
+						DefaultChatIDs:  []string{"123"},
+					},
+					GotifyServer: config.GotifyServer{
+						RawUrl:      "http://example.com",
+						ClientToken: "client-token",
+					},
+				},
+			},
+			wantError: true,
+		},
+		{
+			name: "should error for log files of non-admin users",
+			config: &config.Plugin{
+				Settings: config.Settings{
+					IgnoreEnvVars: true,
+					LogOptions: config.LogOptions{
+						LogLevel: "info",
+						Output:   "plugin.log",
+					},
+					Telegram: config.Telegram{
+						DefaultBotToken: "test-token",
+						DefaultChatIDs:  []string{"123"},
+					},
+					GotifyServer: config.GotifyServer{
+						RawUrl:      "http://example.com",
+						ClientToken: "client-token",
+					},
+				},
+			},
+			setup: func(p *Plugin) {
+				p.userCtx = plugin.UserContext{ID: 2}
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -201,7 +362,8 @@ func TestPlugin_Configure(t *testing.T) {
 			// Create plugin instance
 			logger := zerolog.New(zerolog.NewTestWriter(t))
 			p := &Plugin{
-				logger: &logger,
+				logger:  &logger,
+				userCtx: plugin.UserContext{ID: 1, Admin: true},
 			}
 
 			if tt.setup != nil {
@@ -222,3 +384,261 @@ func TestPlugin_Configure(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateTemplates(t *testing.T) {
+	cfg := config.Telegram{
+		MessageFormatOptions: config.MessageFormatOptions{ParseMode: "MarkdownV2", Template: "{{ bold .Title }}"},
+		Bots: map[string]config.TelegramBot{
+			"backups": {
+				MessageFormatOptions: &config.MessageFormatOptions{ParseMode: "HTML"},
+				AppMessageFormatOptions: map[string]*config.MessageFormatOptions{
+					"restic": {ParseMode: "HTML", Template: "{{ .Subject }}"},
+				},
+			},
+		},
+	}
+
+	err := validateTemplates(cfg)
+	assert.ErrorContains(t, err, "settings.telegram.bots.backups.app_message_format_options.restic.template: "+
+		"failed to execute template")
+
+	cfg.Bots["backups"].AppMessageFormatOptions["restic"].Template = "{{ .AppName }}"
+	assert.NoError(t, validateTemplates(cfg))
+
+	cfg.MessageFormatOptions.Footer = "{{ shout .AppName }}"
+	assert.ErrorContains(t, validateTemplates(cfg), "settings.telegram.default_message_format_options.footer: "+
+		"failed to parse template")
+
+	cfg.MessageFormatOptions.Footer = ""
+	cfg.MessageFormatOptions.Buttons = []config.Button{{Text: "Dashboard", URL: "https://example.com/{{ .Dashboard }}"}}
+	assert.ErrorContains(t, validateTemplates(cfg), "settings.telegram.default_message_format_options.buttons[0].url: "+
+		"failed to execute template")
+
+	cfg.MessageFormatOptions.Template = "{{ if .Title }}"
+	assert.ErrorContains(t, validateTemplates(cfg), "settings.telegram.default_message_format_options.template: "+
+		"failed to parse template")
+}
+
+func TestPlugin_getTelegramBotConfigForAppID(t *testing.T) {
+	cfg := &config.Plugin{
+		Settings: config.Settings{
+			Telegram: config.Telegram{
+				DefaultBotToken: "default-token",
+				DefaultChatIDs:  []string{"111"},
+				Bots: map[string]config.TelegramBot{
+					"id_bot": {
+						Token:   "id-token",
+						ChatIDs: []string{"222"},
+						AppIDs:  []uint32{1},
+					},
+					"name_bot": {
+						Token:    "name-token",
+						ChatIDs:  []string{"333"},
+						AppNames: []string{"Backups"},
+					},
+				},
+			},
+		},
+	}
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: cfg,
+	}
+
+	p.resolveAppNames([]api.Application{
+		{ID: 1, Name: "Sonarr"},
+		{ID: 2, Name: "backups"},
+	})
+
+	tests := []struct {
+		name      string
+		appID     uint32
+		wantToken string
+	}{
+		{
+			name:      "should route by app id",
+			appID:     1,
+			wantToken: "id-token",
+		},
+		{
+			name:      "should route by resolved app name",
+			appID:     2,
+			wantToken: "name-token",
+		},
+		{
+			name:      "should fall back to default bot",
+			appID:     3,
+			wantToken: "default-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := p.getTelegramBotConfigForAppID(tt.appID)
+			assert.Equal(t, tt.wantToken, bot.Token)
+		})
+	}
+}
+
+func TestPlugin_handleConfigSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger}
+	p.RegisterWebhook("/plugin/1/custom/token/", router.Group("/plugin/1/custom/token/"))
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/plugin/1/custom/token/config/schema.json", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var schema map[string]interface{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &schema))
+	assert.Equal(t, config.SchemaURI, schema["$schema"])
+
+	location, _ := url.Parse("https://gotify.example.com/plugin/1/display")
+	assert.Equal(t, "https://gotify.example.com/plugin/1/custom/token/config/schema.json", p.webhookURL(location, "config/schema.json"))
+}
+
+type MockMessageHandler struct {
+	mock.Mock
+}
+
+func (m *MockMessageHandler) SendMessage(msg plugin.Message) error {
+	args := m.Called(msg)
+	return args.Error(0)
+}
+
+func TestPlugin_handleError_NotifiesGotify(t *testing.T) {
+	handler := new(MockMessageHandler)
+	handler.On("SendMessage", mock.MatchedBy(func(msg plugin.Message) bool {
+		return msg.Title == "Telegram bot authentication failed" && msg.Priority == 7
+	})).Return(nil).Once()
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger:     &logger,
+		msgHandler: handler,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				MetaNotifications: config.MetaNotifications{
+					Enabled:            true,
+					Priority:           7,
+					MinIntervalSeconds: 60,
+				},
+			},
+		},
+	}
+
+	authErr := fmt.Errorf("failed to make request: %w", &telegram.APIError{StatusCode: http.StatusUnauthorized})
+	p.handleError(authErr)
+	// Rate limited by the min interval
+	p.handleError(authErr)
+	// Not an auth error
+	p.handleError(&telegram.APIError{StatusCode: http.StatusBadRequest})
+
+	handler.AssertExpectations(t)
+	handler.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestPlugin_handleError_CircuitOpen(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{},
+		stats:  stats.NewTracker(),
+	}
+	p.setStore(storage.NewMemory())
+
+	msg := api.Message{Id: 1, AppID: 7}
+	require.True(t, p.claimDelivery(msg, "123"))
+	p.handleError(&telegram.SendError{
+		MessageID: 1,
+		ChatID:    "123",
+		AppID:     7,
+		Err:       fmt.Errorf("failed to make request: %w", telegram.ErrCircuitOpen),
+	})
+
+	assert.Empty(t, p.stats.RecentErrors(), "queued messages aren't recorded as errors")
+	assert.False(t, p.claimDelivery(msg, "123"), "the delivery of the queued message stays claimed")
+	assert.False(t, p.chatHealth.isUnhealthy("123"))
+}
+
+func TestErrorEntry_RedactsBotToken(t *testing.T) {
+	// nothing listens on the proxy, so requests fail with a connection error carrying the request URL
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	proxy, err := url.Parse("http://" + listener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+
+	errChan := make(chan error, 1)
+	client := telegram.NewClient(telegram.Config{ErrChan: errChan, Proxy: proxy, RequestTimeout: time.Second})
+	err = client.SendText("123456:secret-token", "123", "test")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-token")
+	require.Error(t, client.Send(api.Message{Title: "test"}, "123456:secret-token", "123", config.MessageFormatOptions{ParseMode: telegram.ParseModeHTML}))
+
+	entry := errorEntry(<-errChan)
+	assert.Equal(t, "telegram", entry.Component)
+	assert.Contains(t, entry.Message, "bot***")
+	assert.NotContains(t, entry.Message, "secret-token")
+}
+
+func TestErrorEntry_MasksSendErrorToken(t *testing.T) {
+	entry := errorEntry(&telegram.SendError{
+		Token:  "123456:secret-token",
+		ChatID: "123",
+		Err:    errors.New("request with 123456:secret-token failed"),
+	})
+	assert.Equal(t, "request with *** failed", entry.Message)
+}
+
+func TestPlugin_handleMessage_SkipsMetaNotifications(t *testing.T) {
+	var posted plugin.Message
+	handler := new(MockMessageHandler)
+	handler.On("SendMessage", mock.Anything).Run(func(args mock.Arguments) {
+		posted = args.Get(0).(plugin.Message)
+	}).Return(nil)
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger:     &logger,
+		msgHandler: handler,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{
+					DefaultBotToken: "token",
+					DefaultChatIDs:  []string{"123"},
+				},
+				MetaNotifications: config.MetaNotifications{Enabled: true, Priority: 7},
+			},
+		},
+	}
+
+	p.handleError(&telegram.APIError{StatusCode: http.StatusUnauthorized})
+	handler.AssertNumberOfCalls(t, "SendMessage", 1)
+
+	// the notification comes back over the websocket as json
+	data, err := json.Marshal(posted)
+	require.NoError(t, err)
+	var msg api.Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	msg.Id, msg.AppID = 1, 7
+
+	p.handleMessage(msg)
+	assert.Zero(t, p.backlog(), "the plugin's own notifications are not forwarded")
+
+	p.handleMessage(api.Message{Id: 2, AppID: 7, Title: "disk full"})
+	assert.Equal(t, 1, p.backlog())
+}
+
+func TestPrivateHosts(t *testing.T) {
+	allowed := []string{"gotify.local:8080", "camera.local"}
+	assert.Equal(t, []string{"gotify.local:8080"}, privateHosts("gotify.local:8080", allowed, false))
+	assert.Equal(t, allowed, privateHosts("gotify.local:8080", allowed, true))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+)
+
+// redact applies the redaction rules to the message and scrubs personal data if the bot scrubs it
+func (p *Plugin) redact(msg api.Message, bot config.TelegramBot) api.Message {
+	msg = redactMessage(msg, p.config.Settings.Telegram.RedactionRules)
+	if bot.ScrubPII {
+		msg = redactMessage(msg, config.PIIRedactionRules())
+	}
+	return msg
+}
+
+// redactMessage returns a copy of the message with the rules applied to its title, body and the string values of its extras
+func redactMessage(msg api.Message, rules []config.RedactionRule) api.Message {
+	if len(rules) == 0 {
+		return msg
+	}
+
+	msg.Title = redactText(msg.Title, rules)
+	msg.Message = redactText(msg.Message, rules)
+	if msg.Extras != nil {
+		msg.Extras = redactValue(msg.Extras, rules).(map[string]interface{})
+	}
+
+	return msg
+}
+
+func redactText(text string, rules []config.RedactionRule) string {
+	for i := range rules {
+		text = rules[i].Apply(text)
+	}
+	return text
+}
+
+// redactValue redacts the strings of an extras value. Maps and slices are copied so the original message is not modified
+func redactValue(value interface{}, rules []config.RedactionRule) interface{} {
+	switch value := value.(type) {
+	case string:
+		return redactText(value, rules)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			redacted[key] = redactValue(item, rules)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, item := range value {
+			redacted[i] = redactValue(item, rules)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
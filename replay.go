@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// historyBucket is the storage bucket of the recently received messages
+	historyBucket = "history"
+	// maxHistory is the number of recently received messages kept for replays
+	maxHistory = 200
+)
+
+// setStore sets the store of the plugin and the message history, pending sends and dead letters kept in it
+func (p *Plugin) setStore(store storage.Store) {
+	history, err := storage.NewQueue(store, historyBucket)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to load message history")
+	}
+	pending, err := storage.NewQueue(store, pendingBucket)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to load pending sends")
+	}
+	deadLetters, err := storage.NewQueue(store, deadLettersBucket)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to load dead letters")
+	}
+
+	p.store = store
+	p.history = history
+	p.pending = pending
+	p.deadLetters = deadLetters
+}
+
+// recordHistory keeps the message for replays and drops the oldest messages above the limit
+func (p *Plugin) recordHistory(msg api.Message) {
+	if p.history == nil {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err == nil {
+		err = p.history.Push(data)
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to save message to history")
+		return
+	}
+
+	length, err := p.history.Len()
+	for ; err == nil && length > maxHistory; length-- {
+		_, err = p.history.Pop()
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to trim message history")
+	}
+}
+
+// recentMessages returns up to count of the most recent messages of the app from the oldest to the newest.
+// The app is a gotify app id or app name. An empty app matches all apps
+func (p *Plugin) recentMessages(app string, count int) ([]api.Message, error) {
+	if p.history == nil {
+		return nil, nil
+	}
+
+	items, err := p.history.Items()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message history: %w", err)
+	}
+
+	var messages []api.Message
+	for i := len(items) - 1; i >= 0 && len(messages) < count; i-- {
+		var msg api.Message
+		if err := json.Unmarshal(items[i], &msg); err != nil {
+			return nil, fmt.Errorf("failed to decode message history: %w", err)
+		}
+
+		if app == "" || app == strconv.FormatUint(uint64(msg.AppID), 10) || strings.EqualFold(app, msg.AppName) {
+			messages = append(messages, msg)
+		}
+	}
+
+	// restore the order the messages were received in
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+// replayRequest is the body of a replay request
+type replayRequest struct {
+	// Gotify app id or app name. Empty replays messages of all apps
+	App string `json:"app"`
+	// Chat the messages are sent to
+	ChatID string `json:"chat_id" binding:"required"`
+	// Number of messages to replay
+	Count int `json:"count" binding:"required,min=1"`
+}
+
+// handleReplay sends the most recent messages of an app to a chat again. The messages are
+// sent with the bot and format options of the app's route
+func (p *Plugin) handleReplay(c *gin.Context) {
+	var req replayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chatID, err := config.NormalizeChatID(req.ChatID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if p.config == nil || p.tgclient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "plugin is not configured"})
+		return
+	}
+
+	messages, err := p.recentMessages(req.App, req.Count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.logger.Info().
+		Str("app", req.App).
+		Str("chat_id", chatID).
+		Int("count", len(messages)).
+		Msg("replaying messages")
+
+	// messages are sent one after another to keep their order in the chat
+	go func() {
+		for _, msg := range messages {
+			bot := p.getTelegramBotConfigForAppID(msg.AppID)
+			msg = p.redact(msg, bot)
+			formatOpts := bot.FormatOptionsForApp(msg.AppID, msg.AppName)
+			if formatOpts == nil {
+				formatOpts = &p.config.Settings.Telegram.MessageFormatOptions
+			}
+			token := p.failover.resolve(p.tokens.pick(bot.GetTokens()), chatID)
+			p.tgclient.Send(msg, token, chatID, p.formatOptionsForChat(*formatOpts, chatID))
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"replayed": len(messages)})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_recentMessages(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{logger: &logger}
+
+	messages, err := p.recentMessages("", 10)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	p.setStore(storage.NewMemory())
+	for i := 1; i <= maxHistory+2; i++ {
+		msg := api.Message{Id: uint32(i), AppID: 1, AppName: "Backups"}
+		if i%2 == 0 {
+			msg.AppID, msg.AppName = 2, "Sonarr"
+		}
+		p.recordHistory(msg)
+	}
+
+	length, err := p.history.Len()
+	require.NoError(t, err)
+	assert.Equal(t, maxHistory, length)
+
+	messages, err = p.recentMessages("backups", 2)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, uint32(maxHistory-1), messages[0].Id)
+	assert.Equal(t, uint32(maxHistory+1), messages[1].Id)
+
+	messages, err = p.recentMessages("2", 1)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, uint32(maxHistory+2), messages[0].Id)
+
+	messages, err = p.recentMessages("", 3)
+	require.NoError(t, err)
+	assert.Len(t, messages, 3)
+}
+
+func TestPlugin_handleReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	errChan := make(chan error, 10)
+	p := &Plugin{
+		logger:   &logger,
+		errChan:  errChan,
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: errChan, Logger: &logger}),
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{
+					MessageFormatOptions: config.MessageFormatOptions{ParseMode: "MarkdownV2"},
+				},
+			},
+		},
+	}
+	p.setStore(storage.NewMemory())
+	p.recordHistory(api.Message{Id: 1, AppID: 1, AppName: "Backups", Message: "backup failed"})
+	p.RegisterWebhook("/plugin/1/custom/token/", router.Group("/plugin/1/custom/token/"))
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "missing chat ID",
+			body:         `{"app":"backups","count":5}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid chat ID",
+			body:         `{"app":"backups","chat_id":"backups","count":5}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "replay",
+			body:         `{"app":"backups","chat_id":"-1001234567890","count":5}`,
+			expectedCode: http.StatusAccepted,
+			expectedBody: `{"replayed":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/plugin/1/custom/token/replay", bytes.NewBufferString(tt.body))
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.expectedCode, res.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, res.Body.String())
+			}
+		})
+	}
+
+	// the replayed message is sent with the default bot, which has no token in this test
+	var sendErr *telegram.SendError
+	require.ErrorAs(t, <-errChan, &sendErr)
+	assert.Equal(t, "-1001234567890", sendErr.ChatID)
+	assert.Equal(t, uint32(1), sendErr.MessageID)
+}
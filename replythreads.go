@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+)
+
+// replyRoot is the first Telegram message of a gotify app in a chat that later messages reply to
+type replyRoot struct {
+	messageID int64
+	sentAt    time.Time
+}
+
+// replyThreads remembers the last message of every gotify app in every chat that wasn't a reply,
+// so that the following messages of the app can reply to it
+type replyThreads struct {
+	mu    sync.Mutex
+	roots map[string]replyRoot
+}
+
+// replyThreadKey identifies the messages of a gotify app in a chat
+func replyThreadKey(chatID string, appID uint32) string {
+	return fmt.Sprintf("%s:%d", chatID, appID)
+}
+
+// replyTo returns the id of the message to reply to or 0 if the thread of the app started more than
+// window ago or there is no thread yet
+func (r *replyThreads) replyTo(chatID string, appID uint32, window time.Duration, now time.Time) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	root, ok := r.roots[replyThreadKey(chatID, appID)]
+	if !ok || now.Sub(root.sentAt) >= window {
+		return 0
+	}
+	return root.messageID
+}
+
+// record starts a new thread with the sent message unless it replies to a thread
+func (r *replyThreads) record(appID uint32, sent telegram.SentMessage, now time.Time) {
+	if sent.ReplyTo != 0 || len(sent.Parts) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.roots == nil {
+		r.roots = make(map[string]replyRoot)
+	}
+	r.roots[replyThreadKey(sent.ChatID, appID)] = replyRoot{messageID: sent.Parts[0].MessageID, sentAt: now}
+}
+
+// replyTarget returns the id of the message the message replies to in the chat. It is 0 if replies are
+// disabled or the message doesn't belong to an app
+func (p *Plugin) replyTarget(msg api.Message, chatID string, formatOpts config.MessageFormatOptions) int64 {
+	if formatOpts.ReplyWindowMinutes <= 0 || msg.AppID == 0 {
+		return 0
+	}
+
+	window := time.Duration(formatOpts.ReplyWindowMinutes) * time.Minute
+	return p.replies.replyTo(chatID, msg.AppID, window, time.Now())
+}
+
+// recordReplyRoot remembers the sent message as the thread of its app unless it is a reply
+func (p *Plugin) recordReplyRoot(msg api.Message, sent telegram.SentMessage) {
+	if msg.AppID == 0 {
+		return
+	}
+	p.replies.record(msg.AppID, sent, time.Now())
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyThreads(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	var r replyThreads
+
+	assert.Zero(t, r.replyTo("123", 3, time.Hour, now), "no thread")
+
+	r.record(3, telegram.SentMessage{ChatID: "123", Parts: []telegram.SentPart{{MessageID: 42}}}, now)
+	assert.Equal(t, int64(42), r.replyTo("123", 3, time.Hour, now.Add(30*time.Minute)))
+	assert.Zero(t, r.replyTo("123", 3, time.Hour, now.Add(time.Hour)), "thread expired")
+	assert.Zero(t, r.replyTo("456", 3, time.Hour, now), "other chat")
+	assert.Zero(t, r.replyTo("123", 4, time.Hour, now), "other app")
+
+	r.record(3, telegram.SentMessage{ChatID: "123", ReplyTo: 42, Parts: []telegram.SentPart{{MessageID: 43}}}, now.Add(time.Minute))
+	assert.Equal(t, int64(42), r.replyTo("123", 3, time.Hour, now.Add(time.Minute)), "replies keep the thread")
+
+	r.record(3, telegram.SentMessage{ChatID: "123", Parts: []telegram.SentPart{{MessageID: 50}}}, now.Add(2*time.Hour))
+	assert.Equal(t, int64(50), r.replyTo("123", 3, time.Hour, now.Add(2*time.Hour)), "new thread")
+}
+
+func TestPlugin_replyTarget(t *testing.T) {
+	p := &Plugin{}
+	formatOpts := config.MessageFormatOptions{ReplyWindowMinutes: 10}
+	msg := api.Message{AppID: 3}
+
+	p.recordReplyRoot(msg, telegram.SentMessage{ChatID: "123", Parts: []telegram.SentPart{{MessageID: 42}}})
+
+	assert.Equal(t, int64(42), p.replyTarget(msg, "123", formatOpts))
+	assert.Zero(t, p.replyTarget(msg, "123", config.MessageFormatOptions{}), "replies disabled")
+	assert.Zero(t, p.replyTarget(api.Message{}, "123", formatOpts), "no app")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// routingBucket is the storage bucket of the routes set through the routing webhook
+	routingBucket = "routing"
+	// routingKey is the key of the stored routes
+	routingKey = "bots"
+)
+
+// maskRouting returns a copy of the bots with masked tokens
+func maskRouting(bots map[string]config.TelegramBot) *config.Routing {
+	routing := &config.Routing{Bots: make(map[string]config.TelegramBot, len(bots))}
+	for name, bot := range bots {
+		bot.Token = utils.MaskToken(bot.Token)
+		tokens := make([]string, len(bot.Tokens))
+		for i, token := range bot.Tokens {
+			tokens[i] = utils.MaskToken(token)
+		}
+		bot.Tokens = tokens
+		routing.Bots[name] = bot
+	}
+
+	return routing
+}
+
+// unmaskRouting replaces masked tokens with the known tokens they were masked from so
+// that exported routes can be imported again. Masks shared by several tokens are kept
+func unmaskRouting(routing *config.Routing, known ...map[string]config.TelegramBot) {
+	tokens := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for _, bots := range known {
+		for _, bot := range bots {
+			for _, token := range bot.GetTokens() {
+				masked := utils.MaskToken(token)
+				if existing, ok := tokens[masked]; ok && existing != token {
+					ambiguous[masked] = true
+				}
+				tokens[masked] = token
+			}
+		}
+	}
+
+	unmask := func(token string) string {
+		if original, ok := tokens[token]; ok && !ambiguous[token] {
+			return original
+		}
+		return token
+	}
+
+	for name, bot := range routing.Bots {
+		bot.Token = unmask(bot.Token)
+		for i, token := range bot.Tokens {
+			bot.Tokens[i] = unmask(token)
+		}
+		routing.Bots[name] = bot
+	}
+}
+
+// setBots validates the bots and switches the plugin to them
+func (p *Plugin) setBots(bots map[string]config.TelegramBot) error {
+	routing := config.Routing{Bots: bots}
+	if err := routing.Validate(); err != nil {
+		return err
+	}
+
+	cfg := *p.config
+	cfg.Settings.Telegram.Bots = bots
+	p.config = &cfg
+	p.tokens.reset()
+	p.failover.reset()
+	return nil
+}
+
+// applyStoredRouting replaces the configured bots with the bots set through the routing webhook, if any
+func (p *Plugin) applyStoredRouting() {
+	if p.store == nil || p.config == nil {
+		return
+	}
+
+	data, err := p.store.Get(routingBucket, routingKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return
+	}
+	if err == nil {
+		var routing *config.Routing
+		if routing, err = config.ParseRouting(data); err == nil {
+			err = p.setBots(routing.Bots)
+		}
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to apply the stored routes. Using the configured routes")
+		return
+	}
+
+	p.routesOverridden = true
+	p.logger.Info().Msg("using the routes set through the routing webhook")
+}
+
+// handleGetRouting exports the routes with masked bot tokens
+func (p *Plugin) handleGetRouting(c *gin.Context) {
+	if p.config == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "plugin is not configured"})
+		return
+	}
+
+	data, err := maskRouting(p.config.Settings.Telegram.Bots).JSON()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// handlePutRouting replaces the routes. The routes are persisted and override the
+// configured routes until they are deleted through the routing webhook
+func (p *Plugin) handlePutRouting(c *gin.Context) {
+	if p.config == nil || p.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "plugin is not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	routing, err := config.ParseRouting(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	unmaskRouting(routing, p.config.Settings.Telegram.Bots, p.configuredBots)
+
+	if err := p.setBots(routing.Bots); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := routing.JSON()
+	if err == nil {
+		err = p.store.Put(routingBucket, routingKey, data)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "routes applied but not persisted: " + err.Error()})
+		return
+	}
+
+	p.routesOverridden = true
+	p.logger.Info().
+		Int("bots", len(routing.Bots)).
+		Msg("routes replaced through the routing webhook")
+
+	c.Status(http.StatusNoContent)
+}
+
+// handleDeleteRouting deletes the routes set through the routing webhook and restores the configured routes
+func (p *Plugin) handleDeleteRouting(c *gin.Context) {
+	if p.config == nil || p.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "plugin is not configured"})
+		return
+	}
+
+	if err := p.store.Delete(routingBucket, routingKey); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := p.setBots(p.configuredBots); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.routesOverridden = false
+	p.logger.Info().Msg("restored the configured routes")
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmaskRouting(t *testing.T) {
+	known := map[string]config.TelegramBot{
+		"alerts":  {Token: "123456:alerts-token", Tokens: []string{"234567:pooled-token"}},
+		"backups": {Token: "short"},
+		"other":   {Token: "tiny"},
+	}
+
+	routing := &config.Routing{Bots: map[string]config.TelegramBot{
+		"alerts":  {Token: "1234...oken", Tokens: []string{"2345...oken"}},
+		"backups": {Token: "***"},
+		"new":     {Token: "345678:new-token"},
+	}}
+	unmaskRouting(routing, known)
+
+	assert.Equal(t, "123456:alerts-token", routing.Bots["alerts"].Token)
+	assert.Equal(t, []string{"234567:pooled-token"}, routing.Bots["alerts"].Tokens)
+	// both short tokens are masked as *** so the mask is kept
+	assert.Equal(t, "***", routing.Bots["backups"].Token)
+	assert.Equal(t, "345678:new-token", routing.Bots["new"].Token)
+}
+
+func TestPlugin_routingWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	configured := map[string]config.TelegramBot{
+		"backups": {Token: "123456:backups-token", ChatIDs: []string{"333"}, AppIDs: []uint32{5}},
+	}
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{DefaultBotToken: "default-token", Bots: configured},
+			},
+		},
+		configuredBots: configured,
+	}
+	store := storage.NewMemory()
+	p.setStore(store)
+	p.RegisterWebhook("/plugin/1/custom/token/", router.Group("/plugin/1/custom/token/"))
+
+	request := func(method, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/plugin/1/custom/token/routing", bytes.NewBufferString(body))
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := request(http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"bots":{"backups":{"token":"1234...oken","chat_ids":["333"],"gotify_app_ids":[5]}}}`, res.Body.String())
+
+	res = request(http.MethodPut, `{"bots":{"backups":{"token":"1234...oken","chat_ids":["backups"]}}}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "invalid chat ID")
+
+	res = request(http.MethodPut, `{"bots":{"backups":{"token":"1234...oken","chat_ids":["444"],"gotify_app_ids":[5,6]}}}`)
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.True(t, p.routesOverridden)
+	assert.Equal(t, "123456:backups-token", p.config.Settings.Telegram.Bots["backups"].Token)
+	assert.Equal(t, []string{"444"}, p.config.Settings.Telegram.Bots["backups"].ChatIDs)
+	assert.Equal(t, "444", p.getTelegramBotConfigForAppID(6).ChatIDs[0])
+
+	// the routes survive a restart
+	restarted := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{Bots: configured}}},
+	}
+	restarted.setStore(store)
+	restarted.applyStoredRouting()
+	assert.True(t, restarted.routesOverridden)
+	assert.Equal(t, []uint32{5, 6}, restarted.config.Settings.Telegram.Bots["backups"].AppIDs)
+
+	res = request(http.MethodDelete, "")
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.False(t, p.routesOverridden)
+	assert.Equal(t, configured, p.config.Settings.Telegram.Bots)
+
+	_, err := store.Get(routingBucket, routingKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+)
+
+const (
+	// defaultSendWorkers is the number of send workers if none are configured
+	defaultSendWorkers = 4
+	// drainPollInterval is how often the backlog is checked while it is drained on shutdown
+	drainPollInterval = 100 * time.Millisecond
+)
+
+// sendJob is a message waiting to be sent to a chat
+type sendJob struct {
+	msg        api.Message
+	token      string
+	chatID     string
+	formatOpts config.MessageFormatOptions
+	// key of the job in the pending sends if the queue is persisted
+	key string
+	// attempts is the number of failed sends of a retried dead letter
+	attempts int
+}
+
+// sendQueue buffers the messages waiting for a send worker in the order they were received. Only one message
+// is sent to a chat at a time, so the messages of a chat are sent in order unless they are sent by priority
+type sendQueue struct {
+	mu   sync.Mutex
+	jobs []sendJob
+	// byPriority sends the queued messages with the highest priority first
+	byPriority bool
+	// busy are the chats a worker is sending a message to
+	busy map[string]bool
+	// wake wakes up a waiting worker after jobs were added or a chat is no longer busy
+	wake chan struct{}
+}
+
+// signal wakes up a waiting worker without blocking
+func (q *sendQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// push adds a job to the end of the queue
+func (q *sendQueue) push(job sendJob) {
+	q.mu.Lock()
+	if q.wake == nil {
+		q.wake = make(chan struct{}, 1)
+	}
+	q.jobs = append(q.jobs, job)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// setOrder sets whether the queued messages with the highest priority are sent first
+func (q *sendQueue) setOrder(byPriority bool) {
+	q.mu.Lock()
+	q.byPriority = byPriority
+	q.mu.Unlock()
+}
+
+// next returns the index of the next job to send or -1 if the chats of all jobs are busy. That is the oldest
+// job or, if sent by priority, the oldest job with the highest priority
+func (q *sendQueue) next() int {
+	next := -1
+	for i, job := range q.jobs {
+		if q.busy[job.chatID] {
+			continue
+		}
+		if !q.byPriority {
+			return i
+		}
+		if next < 0 || job.msg.Priority > q.jobs[next].msg.Priority {
+			next = i
+		}
+	}
+	return next
+}
+
+// pop takes the next job of a chat that is not busy from the queue and marks the chat busy until done is
+// called. It waits for a job until the context is done
+func (q *sendQueue) pop(ctx context.Context) (sendJob, bool) {
+	for {
+		q.mu.Lock()
+		if q.wake == nil {
+			q.wake = make(chan struct{}, 1)
+		}
+		if i := q.next(); i >= 0 {
+			job := q.jobs[i]
+			if q.busy == nil {
+				q.busy = make(map[string]bool)
+			}
+			q.busy[job.chatID] = true
+			q.jobs = slices.Delete(q.jobs, i, i+1)
+			if len(q.jobs) > 0 {
+				// let the next worker take the remaining jobs
+				q.signal()
+			}
+			q.mu.Unlock()
+			return job, true
+		}
+		wake := q.wake
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return sendJob{}, false
+		case <-wake:
+		}
+	}
+}
+
+// done marks the chat of a popped job as no longer busy so that its next job can be sent
+func (q *sendQueue) done(job sendJob) {
+	q.mu.Lock()
+	delete(q.busy, job.chatID)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// requeue puts a popped job back at the front of the queue and marks its chat as no longer busy
+func (q *sendQueue) requeue(job sendJob) {
+	q.mu.Lock()
+	q.jobs = slices.Insert(q.jobs, 0, job)
+	delete(q.busy, job.chatID)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// shedOver removes jobs while more than limit jobs are queued and returns them. It removes the oldest job or,
+// if lowestPriority is set, the oldest job with the lowest priority
+func (q *sendQueue) shedOver(limit int, lowestPriority bool) []sendJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var shed []sendJob
+	for len(q.jobs) > limit {
+		i := 0
+		if lowestPriority {
+			for j, job := range q.jobs {
+				if job.msg.Priority < q.jobs[i].msg.Priority {
+					i = j
+				}
+			}
+		}
+		shed = append(shed, q.jobs[i])
+		q.jobs = slices.Delete(q.jobs, i, i+1)
+	}
+	return shed
+}
+
+// len returns the number of queued jobs
+func (q *sendQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+// send queues the message for the send workers and tracks it in the backlog
+func (p *Plugin) send(msg api.Message, token, chatID string, formatOpts config.MessageFormatOptions) {
+	p.enqueue(sendJob{msg: msg, token: token, chatID: chatID, formatOpts: formatOpts})
+}
+
+// enqueue queues the job for the send workers and tracks it in the backlog. Queued sends are shed if the
+// load shedding policy sheds queued sends and too many are queued
+func (p *Plugin) enqueue(job sendJob) {
+	job.key = p.savePending(job)
+	atomic.AddInt64(&p.shedder.inFlight, 1)
+	p.sendQueue.push(job)
+
+	if p.config == nil {
+		return
+	}
+	shedding := p.config.Settings.Telegram.LoadShedding
+	if shedding.MaxBacklog <= 0 || (shedding.Policy != config.LoadSheddingLowestPriority && shedding.Policy != config.LoadSheddingOldest) {
+		return
+	}
+
+	for _, shed := range p.sendQueue.shedOver(shedding.MaxBacklog, shedding.Policy == config.LoadSheddingLowestPriority) {
+		atomic.AddInt64(&p.shedder.inFlight, -1)
+		p.removePending(shed)
+		if shed.msg.Id != 0 {
+			// a replay may send the message again
+			p.deliveries.release(deliveryKey(shed.msg.Id, shed.chatID))
+		}
+		p.shed(shed.msg)
+	}
+}
+
+// sendWorkers returns the configured number of send workers
+func (p *Plugin) sendWorkers() int {
+	if p.config == nil || p.config.Settings.Telegram.SendWorkers <= 0 {
+		return defaultSendWorkers
+	}
+	return p.config.Settings.Telegram.SendWorkers
+}
+
+// startSendWorkers starts the send workers. They stop when the context is done, leaving the queued messages
+// to the workers started next
+func (p *Plugin) startSendWorkers(ctx context.Context) {
+	p.sendQueue.setOrder(p.config != nil && p.config.Settings.Telegram.SendOrder == config.SendOrderPriority)
+	for i := 0; i < p.sendWorkers(); i++ {
+		go p.runSendWorker(ctx)
+	}
+}
+
+// drain waits until the received and queued messages were sent or the timeout elapsed while the send workers
+// keep running. Returns false if messages are left
+func (p *Plugin) drain(timeout time.Duration) bool {
+	if p.backlog() == 0 {
+		return true
+	}
+	if timeout <= 0 || p.ctx == nil || p.ctx.Err() != nil {
+		return false
+	}
+
+	p.logger.Info().Int("backlog", p.backlog()).Dur("timeout", timeout).Msg("draining queued messages")
+	deadline := time.Now().Add(timeout)
+	for p.backlog() > 0 {
+		if time.Now().After(deadline) {
+			p.logger.Warn().
+				Int("backlog", p.backlog()).
+				Msg("shutdown drain deadline reached. Stopping with queued messages left")
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+
+	p.logger.Info().Msg("queued messages drained")
+	return true
+}
+
+// runSendWorker sends the queued messages until the context is done
+func (p *Plugin) runSendWorker(ctx context.Context) {
+	for {
+		job, ok := p.sendQueue.pop(ctx)
+		if !ok {
+			return
+		}
+		if !p.deliverWhenAvailable(ctx, job) {
+			// the workers started next send the job
+			p.sendQueue.requeue(job)
+			return
+		}
+		atomic.AddInt64(&p.shedder.inFlight, -1)
+		p.removePending(job)
+		p.sendQueue.done(job)
+	}
+}
+
+// deliverWhenAvailable delivers the job once the circuit breaker around the Telegram API is closed, so that
+// messages stay queued during a Telegram outage. It returns false if the context is done first
+func (p *Plugin) deliverWhenAvailable(ctx context.Context, job sendJob) bool {
+	for p.tgclient.WaitForAPI(ctx) {
+		if err := p.deliver(job); !errors.Is(err, telegram.ErrCircuitOpen) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver sends the message of the job to its chat. It edits the message last sent to the chat with the same
+// correlation key instead if possible or replies to the thread of its app. Messages that fail to send are kept
+// as dead letters and forwarded to the fallback chat of their route unless they weren't sent because the circuit
+// breaker is open. A message whose error fails the chat over to a backup bot token is sent again with it first
+func (p *Plugin) deliver(job sendJob) error {
+	send := func() error {
+		return p.tgclient.SendOrEdit(job.msg, job.token, job.chatID, job.formatOpts, telegram.Related{
+			Previous: p.correlatedMessage(job.msg, job.chatID, job.formatOpts),
+			ReplyTo:  p.replyTarget(job.msg, job.chatID, job.formatOpts),
+		})
+	}
+
+	job.token = p.failover.resolve(job.token, job.chatID)
+	err := send()
+	// the message is sent once more with the backup token if the error failed the chat over to it
+	var sendErr *telegram.SendError
+	if errors.As(err, &sendErr) && p.handleFailover(sendErr) {
+		if backup := p.failover.resolve(job.token, job.chatID); backup != job.token {
+			job.token = backup
+			err = send()
+		}
+	}
+	if err != nil && !errors.Is(err, telegram.ErrCircuitOpen) {
+		p.addDeadLetter(job, err)
+		p.sendToFallback(job.msg, job.chatID, errorText(err, job.token))
+	}
+	return err
+}
+
+// routeJob returns the job sending the message to the chat with the bot token and format options of the
+// current route of its app
+func (p *Plugin) routeJob(msg api.Message, chatID string) sendJob {
+	bot := p.getTelegramBotConfigForAppID(msg.AppID)
+	formatOpts := bot.FormatOptionsForApp(msg.AppID, msg.AppName)
+	if formatOpts == nil {
+		formatOpts = &p.config.Settings.Telegram.MessageFormatOptions
+	}
+
+	return sendJob{
+		msg:        msg,
+		token:      p.tokens.pick(bot.GetTokens()),
+		chatID:     chatID,
+		formatOpts: p.formatOptionsForChat(*formatOpts, chatID),
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendQueue(t *testing.T) {
+	var q sendQueue
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q.push(sendJob{chatID: "1"})
+	q.push(sendJob{chatID: "2"})
+	assert.Equal(t, 2, q.len())
+
+	job, ok := q.pop(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "1", job.chatID)
+	job, ok = q.pop(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "2", job.chatID)
+
+	// pop waits for the next job
+	popped := make(chan sendJob)
+	go func() {
+		job, _ := q.pop(ctx)
+		popped <- job
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.push(sendJob{chatID: "3"})
+	select {
+	case job := <-popped:
+		assert.Equal(t, "3", job.chatID)
+	case <-time.After(time.Second):
+		t.Fatal("pop did not return the pushed job")
+	}
+
+	// pop returns when the context is done
+	cancel()
+	_, ok = q.pop(ctx)
+	assert.False(t, ok)
+}
+
+func TestSendQueue_ChatOrder(t *testing.T) {
+	var q sendQueue
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q.push(sendJob{msg: api.Message{Id: 1}, chatID: "a"})
+	q.push(sendJob{msg: api.Message{Id: 2}, chatID: "a"})
+	q.push(sendJob{msg: api.Message{Id: 3}, chatID: "b"})
+
+	first, ok := q.pop(ctx)
+	require.True(t, ok)
+	assert.Equal(t, uint32(1), first.msg.Id)
+
+	// the next message of chat a waits until the first one was sent
+	job, ok := q.pop(ctx)
+	require.True(t, ok)
+	assert.Equal(t, uint32(3), job.msg.Id)
+
+	timeout, cancelTimeout := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelTimeout()
+	_, ok = q.pop(timeout)
+	assert.False(t, ok)
+
+	q.done(first)
+	job, ok = q.pop(ctx)
+	require.True(t, ok)
+	assert.Equal(t, uint32(2), job.msg.Id)
+}
+
+func TestSendQueue_Priority(t *testing.T) {
+	var q sendQueue
+	q.setOrder(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q.push(sendJob{msg: api.Message{Id: 1, Priority: 2}, chatID: "a"})
+	q.push(sendJob{msg: api.Message{Id: 2, Priority: 8}, chatID: "a"})
+	q.push(sendJob{msg: api.Message{Id: 3, Priority: 8}, chatID: "b"})
+	q.push(sendJob{msg: api.Message{Id: 4, Priority: 5}, chatID: "c"})
+
+	var ids []uint32
+	for i := 0; i < 4; i++ {
+		job, ok := q.pop(ctx)
+		require.True(t, ok)
+		ids = append(ids, job.msg.Id)
+		q.done(job)
+	}
+	assert.Equal(t, []uint32{2, 3, 4, 1}, ids, "highest priority first, oldest first within a priority")
+}
+
+func TestSendQueue_shedOver(t *testing.T) {
+	newQueue := func() *sendQueue {
+		q := &sendQueue{}
+		for i, priority := range []uint32{5, 1, 8, 1, 3} {
+			q.push(sendJob{msg: api.Message{Id: uint32(i + 1), Priority: priority}, chatID: "123"})
+		}
+		return q
+	}
+	ids := func(jobs []sendJob) []uint32 {
+		var ids []uint32
+		for _, job := range jobs {
+			ids = append(ids, job.msg.Id)
+		}
+		return ids
+	}
+
+	q := newQueue()
+	assert.Empty(t, q.shedOver(5, false))
+	assert.Equal(t, []uint32{1, 2}, ids(q.shedOver(3, false)), "oldest")
+	assert.Equal(t, []uint32{3, 4, 5}, ids(q.jobs))
+
+	q = newQueue()
+	assert.Equal(t, []uint32{2, 4, 5}, ids(q.shedOver(2, true)), "lowest priority, oldest first")
+	assert.Equal(t, []uint32{1, 3}, ids(q.jobs))
+}
+
+func TestPlugin_send_LoadShedding(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		stats:  stats.NewTracker(),
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			LoadShedding: config.LoadShedding{MaxBacklog: 2, Policy: config.LoadSheddingLowestPriority, Mode: config.LoadSheddingDigest},
+		}}},
+	}
+
+	p.send(api.Message{Id: 1, AppName: "sonarr", Priority: 2}, "token", "123", config.MessageFormatOptions{})
+	p.send(api.Message{Id: 2, AppName: "alerts", Priority: 8}, "token", "123", config.MessageFormatOptions{})
+	assert.False(t, p.shouldShed(api.Message{Priority: 0}), "the priority floor doesn't apply")
+	p.send(api.Message{Id: 3, AppName: "radarr", Priority: 5}, "token", "123", config.MessageFormatOptions{})
+
+	assert.Equal(t, 2, p.sendQueue.len())
+	assert.Equal(t, 2, p.backlog())
+	assert.Equal(t, uint64(1), p.stats.Shed())
+	assert.Equal(t, "⚠️ gotify-to-telegram shed 1 messages with the lowest priority during a backlog: sonarr (1)",
+		p.shedder.digest(sheddingReason(p.config.Settings.Telegram.LoadShedding)))
+}
+
+func TestPlugin_sendWorkers(t *testing.T) {
+	p := &Plugin{}
+	assert.Equal(t, defaultSendWorkers, p.sendWorkers())
+
+	p.config = &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{SendWorkers: 2}}}
+	assert.Equal(t, 2, p.sendWorkers())
+}
+
+func TestPlugin_routeJob_BotTemplates(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{
+			DefaultBotToken: "default-token",
+			DefaultChatIDs:  []string{"123"},
+			MessageFormatOptions: config.MessageFormatOptions{
+				ParseMode: telegram.ParseModeMarkdownV2,
+				Template:  "{{ .AppName }}: {{ .Title }}",
+			},
+			Bots: map[string]config.TelegramBot{
+				"backups": {
+					Token:   "backup-token",
+					ChatIDs: []string{"456"},
+					AppIDs:  []uint32{1},
+					MessageFormatOptions: &config.MessageFormatOptions{
+						ParseMode: telegram.ParseModeMarkdownV2,
+						Template:  "💾 {{ bold .Title }} (priority {{ .Priority }})",
+					},
+				},
+			},
+		}}},
+	}
+
+	render := func(msg api.Message, chatID string) string {
+		job := p.routeJob(msg, chatID)
+		text, err := telegram.FormatMessage(job.msg, job.formatOpts)
+		require.NoError(t, err)
+		return text
+	}
+
+	assert.Equal(t, "💾 *Nightly backup* (priority 5)", render(api.Message{AppID: 1, AppName: "restic", Title: "Nightly backup", Priority: 5}, "456"))
+	assert.Equal(t, "grafana: Disk full", render(api.Message{AppID: 2, AppName: "grafana", Title: "Disk full"}, "123"))
+}
+
+func TestPlugin_send(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	errChan := make(chan error, 100)
+	p := &Plugin{
+		logger:  &logger,
+		config:  &config.Plugin{Settings: config.Settings{Telegram: config.Telegram{SendWorkers: 2}}},
+		errChan: errChan,
+		// sends fail right away without a bot token
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: errChan, Logger: &logger}),
+	}
+
+	for i := 0; i < 20; i++ {
+		p.send(api.Message{Id: uint32(i)}, "", []string{"123", "456"}[i%2], config.MessageFormatOptions{})
+	}
+	assert.Equal(t, 20, p.backlog(), "nothing is sent without workers")
+
+	p.startSendWorkers(ctx)
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&p.shedder.inFlight) == 0 }, time.Second, time.Millisecond)
+	assert.Zero(t, p.sendQueue.len())
+
+	// the messages of a chat are sent in order
+	require.Len(t, errChan, 20)
+	last := map[string]int{"123": -1, "456": -1}
+	for i := 0; i < 20; i++ {
+		var sendErr *telegram.SendError
+		require.ErrorAs(t, <-errChan, &sendErr)
+		assert.Greater(t, int(sendErr.MessageID), last[sendErr.ChatID])
+		last[sendErr.ChatID] = int(sendErr.MessageID)
+	}
+}
+
+func TestPlugin_send_CircuitOpen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	errChan := make(chan error, 10)
+	p := &Plugin{
+		logger:  &logger,
+		config:  &config.Plugin{},
+		errChan: errChan,
+		// requests fail at the unreachable proxy and open the circuit
+		tgclient: telegram.NewClient(telegram.Config{
+			ErrChan:        errChan,
+			Logger:         &logger,
+			RequestTimeout: time.Second,
+			Proxy:          &url.URL{Scheme: "http", Host: "127.0.0.1:1"},
+			CircuitBreaker: telegram.CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: time.Hour},
+		}),
+	}
+	require.Error(t, p.tgclient.SendText("token", "123", "test"))
+	require.Equal(t, "open", p.tgclient.CircuitState())
+
+	p.send(api.Message{Id: 1}, "token", "123", config.MessageFormatOptions{})
+	p.startSendWorkers(ctx)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, p.backlog(), "messages stay queued while the circuit is open")
+	assert.Empty(t, errChan)
+	status, err := p.renderStatus(nil, time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, status, "| Telegram API | unavailable (circuit breaker open, messages are queued) |")
+
+	// the message is left to the workers started next
+	cancel()
+	assert.Eventually(t, func() bool { return p.sendQueue.len() == 1 }, time.Second, time.Millisecond)
+	job, ok := p.sendQueue.pop(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, uint32(1), job.msg.Id)
+	assert.Equal(t, 1, p.backlog())
+}
+
+func TestPlugin_drain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		ctx:    ctx,
+		logger: &logger,
+		config: &config.Plugin{},
+		// sends fail right away without a bot token
+		tgclient: telegram.NewClient(telegram.Config{ErrChan: make(chan error, 10), Logger: &logger}),
+	}
+	assert.True(t, p.drain(0), "nothing is queued")
+
+	for i := 1; i <= 3; i++ {
+		p.send(api.Message{Id: uint32(i)}, "", "123", config.MessageFormatOptions{})
+	}
+	assert.False(t, p.drain(0))
+	assert.False(t, p.drain(20*time.Millisecond), "nothing is sent without send workers")
+
+	p.startSendWorkers(ctx)
+	assert.True(t, p.drain(time.Second))
+	assert.Zero(t, p.backlog())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/storage"
+	"github.com/gotify/plugin-api"
+)
+
+// statsSaveInterval is how often the statistics are persisted while the plugin is running
+const statsSaveInterval = time.Minute
+
+// SetStorageHandler sets the gotify storage handler. The plugin's persistent
+// data is stored in the gotify database through it.
+func (p *Plugin) SetStorageHandler(handler plugin.StorageHandler) {
+	store, err := storage.NewPersistent(handler)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to load plugin storage. Data will not be persisted")
+		return
+	}
+
+	p.setStore(store)
+	if p.stats != nil {
+		if err := p.stats.Load(store); err != nil {
+			p.logger.Error().Err(err).Msg("failed to load statistics from storage")
+		}
+	}
+	p.applyStoredRouting()
+}
+
+// saveStats persists the statistics in the plugin storage
+func (p *Plugin) saveStats() {
+	if p.store == nil || p.stats == nil {
+		return
+	}
+
+	if err := p.stats.Save(p.store); err != nil {
+		p.logger.Error().Err(err).Msg("failed to save statistics")
+	}
+}
+
+// flushStore persists the changes of the plugin storage that are not written yet
+func (p *Plugin) flushStore() {
+	if p.store == nil {
+		return
+	}
+
+	if err := p.store.Flush(); err != nil {
+		p.logger.Error().Err(err).Msg("failed to persist plugin storage")
+	}
+}
+
+// runStatsSaver periodically persists the statistics until the context is done
+func (p *Plugin) runStatsSaver(ctx context.Context) {
+	ticker := time.NewTicker(statsSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.saveStats()
+		}
+	}
+}
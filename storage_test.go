@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/api"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/stats"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStorageHandler is an in-memory gotify storage handler
+type MockStorageHandler struct {
+	data []byte
+}
+
+func (m *MockStorageHandler) Save(b []byte) error {
+	m.data = b
+	return nil
+}
+
+func (m *MockStorageHandler) Load() ([]byte, error) {
+	return m.data, nil
+}
+
+func TestPlugin_SetStorageHandler(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	handler := &MockStorageHandler{}
+
+	p := &Plugin{logger: &logger, stats: stats.NewTracker()}
+	p.SetStorageHandler(handler)
+	require.NotNil(t, p.store)
+
+	p.stats.RecordForwarded(api.Message{AppID: 1, AppName: "backups"}, "123")
+	p.saveStats()
+	p.flushStore()
+	assert.NotEmpty(t, handler.data)
+
+	restarted := &Plugin{logger: &logger, stats: stats.NewTracker()}
+	restarted.SetStorageHandler(handler)
+	assert.Equal(t, uint64(1), restarted.stats.Forwarded())
+}
+
+func TestPlugin_SetStorageHandler_InvalidData(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+
+	p := &Plugin{logger: &logger, stats: stats.NewTracker()}
+	p.SetStorageHandler(&MockStorageHandler{data: []byte("invalid")})
+	assert.Nil(t, p.store)
+
+	// saving without a store is a no-op
+	p.saveStats()
+}
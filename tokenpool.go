@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/utils"
+)
+
+// tokenPool rotates between the bot tokens of a route to spread the per-bot
+// rate limits of the Telegram API. Tokens rejected by the API are skipped
+type tokenPool struct {
+	mu sync.Mutex
+	// next is the index of the next token keyed by the first token of the route
+	next map[string]int
+	// removed holds the tokens that returned auth errors
+	removed map[string]bool
+}
+
+// pick returns the next token of the route. If all tokens were removed the first
+// token is returned so that the auth errors stay visible
+func (t *tokenPool) pick(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	if len(tokens) == 1 {
+		return tokens[0]
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	active := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if !t.removed[token] {
+			active = append(active, token)
+		}
+	}
+	if len(active) == 0 {
+		return tokens[0]
+	}
+
+	if t.next == nil {
+		t.next = make(map[string]int)
+	}
+	i := t.next[tokens[0]] % len(active)
+	t.next[tokens[0]] = i + 1
+
+	return active[i]
+}
+
+// remove stops using the token. Returns false if the token was already removed
+func (t *tokenPool) remove(token string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.removed[token] {
+		return false
+	}
+	if t.removed == nil {
+		t.removed = make(map[string]bool)
+	}
+	t.removed[token] = true
+	return true
+}
+
+// reset uses all tokens again
+func (t *tokenPool) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.next = nil
+	t.removed = nil
+}
+
+// isPooled returns true if the token is one of several tokens of a route
+func (p *Plugin) isPooled(token string) bool {
+	if p.config == nil {
+		return false
+	}
+
+	for _, bot := range p.config.Settings.Telegram.Bots {
+		tokens := bot.GetTokens()
+		if len(tokens) < 2 {
+			continue
+		}
+		for _, t := range tokens {
+			if t == token {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// handleTokenAuthError removes a pooled bot token from rotation after the Telegram API rejected it
+func (p *Plugin) handleTokenAuthError(sendErr *telegram.SendError) {
+	var apiErr *telegram.APIError
+	if !errors.As(sendErr, &apiErr) || !apiErr.IsAuthError() || !p.isPooled(sendErr.Token) {
+		return
+	}
+
+	if p.tokens.remove(sendErr.Token) {
+		p.logger.Warn().
+			Str("bot_token", utils.MaskToken(sendErr.Token)).
+			Msg("bot token rejected by the Telegram API. Removed it from the token pool")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/telegram"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenPool_pick(t *testing.T) {
+	var pool tokenPool
+	tokens := []string{"token-a", "token-b", "token-c"}
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		picked = append(picked, pool.pick(tokens))
+	}
+	assert.Equal(t, []string{"token-a", "token-b", "token-c", "token-a"}, picked)
+
+	assert.True(t, pool.remove("token-b"))
+	assert.False(t, pool.remove("token-b"))
+	assert.Equal(t, "token-c", pool.pick(tokens))
+	assert.Equal(t, "token-a", pool.pick(tokens))
+	assert.Equal(t, "token-c", pool.pick(tokens))
+
+	// the first token is used when all tokens were removed
+	pool.remove("token-a")
+	pool.remove("token-c")
+	assert.Equal(t, "token-a", pool.pick(tokens))
+
+	pool.reset()
+	assert.Equal(t, "token-a", pool.pick(tokens))
+	assert.Equal(t, "token-b", pool.pick(tokens))
+
+	assert.Equal(t, "", pool.pick(nil))
+}
+
+func TestPlugin_handleError_RemovesPooledToken(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+	p := &Plugin{
+		logger: &logger,
+		config: &config.Plugin{
+			Settings: config.Settings{
+				Telegram: config.Telegram{
+					DefaultBotToken: "default-token",
+					Bots: map[string]config.TelegramBot{
+						"alerts": {Token: "token-a", Tokens: []string{"token-b"}, ChatIDs: []string{"123"}},
+					},
+				},
+			},
+		},
+	}
+
+	unauthorized := &telegram.APIError{StatusCode: http.StatusUnauthorized, Description: "Unauthorized"}
+
+	// tokens that are not pooled stay in use
+	p.handleError(&telegram.SendError{Token: "default-token", ChatID: "123", Err: unauthorized})
+	assert.False(t, p.tokens.removed["default-token"])
+
+	p.handleError(&telegram.SendError{Token: "token-a", ChatID: "123", Err: unauthorized})
+	bot := p.config.Settings.Telegram.Bots["alerts"]
+	tokens := bot.GetTokens()
+	assert.Equal(t, "token-b", p.tokens.pick(tokens))
+	assert.Equal(t, "token-b", p.tokens.pick(tokens))
+}
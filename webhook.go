@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/0xPeterSatoshi/gotify-to-telegram/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebhook implements plugin.Webhooker
+// Invoked during initialization to register the plugin's custom http handlers.
+func (p *Plugin) RegisterWebhook(basePath string, mux *gin.RouterGroup) {
+	p.webhookBasePath = basePath
+
+	mux.GET("/config/schema.json", p.handleConfigSchema)
+	mux.GET("/chats", p.handleDiscoverChats)
+	mux.POST("/chats/:chat_id/release", p.handleReleaseChat)
+	mux.GET("/dead-letters", p.handleGetDeadLetters)
+	mux.POST("/dead-letters/retry", p.handleRetryDeadLetters)
+	mux.DELETE("/dead-letters", p.handleFlushDeadLetters)
+	mux.GET("/metrics", p.handleMetrics)
+	mux.POST("/replay", p.handleReplay)
+	mux.GET("/routing", p.handleGetRouting)
+	mux.PUT("/routing", p.handlePutRouting)
+	mux.DELETE("/routing", p.handleDeleteRouting)
+}
+
+// handleConfigSchema serves the JSON schema of the yaml plugin config
+func (p *Plugin) handleConfigSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, config.Schema())
+}
+
+// handleReleaseChat releases a quarantined or blocked chat so that messages are sent to it again
+func (p *Plugin) handleReleaseChat(c *gin.Context) {
+	chatID := c.Param("chat_id")
+	if !p.chatHealth.release(chatID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "chat " + chatID + " is not quarantined"})
+		return
+	}
+
+	p.logger.Info().
+		Str("chat_id", chatID).
+		Msg("chat released from quarantine")
+
+	c.Status(http.StatusNoContent)
+}
+
+// webhookURL returns the absolute url of a webhook route relative to the location
+// the user is accessing the gotify API from
+func (p *Plugin) webhookURL(location *url.URL, route string) string {
+	path := strings.TrimSuffix(p.webhookBasePath, "/") + "/" + strings.TrimPrefix(route, "/")
+	if location == nil {
+		return path
+	}
+
+	return (&url.URL{Scheme: location.Scheme, Host: location.Host, Path: path}).String()
+}